@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+
+	"fdi-analyzer/fdi"
+)
+
+// Annotations of the file being analyzed, shown in dumps and diffs; loaded
+// from the file's sidecar in main
+var annotations fdi.Annotations
+
+// The sidecar file holding a file's annotations
+func notesPath(file string) string {
+	return file + ".notes.json"
+}
+
+// Read the annotations saved for file; none when it has no sidecar yet
+func loadAnnotations(file string) (fdi.Annotations, error) {
+	src, err := os.ReadFile(notesPath(file))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var notes fdi.Annotations
+	if err := json.Unmarshal(src, &notes); err != nil {
+		return nil, fmt.Errorf("%s: %v", notesPath(file), err)
+	}
+	return notes, nil
+}
+
+// Add, replace or remove the annotations of the -annotate specs, save them
+// to the file's sidecar and list what it now holds
+func annotateFile(w io.Writer, file string, dataLen int, specs []string) int {
+	if file == "-" {
+		logError("Cannot annotate standard input; pass the file with -file")
+		return exitUsage
+	}
+	if isArchived(file) {
+		logError("Cannot annotate a file inside an archive; extract it first")
+		return exitUsage
+	}
+	notes := annotations
+	for _, spec := range specs {
+		a, err := fdi.ParseAnnotation(spec)
+		if err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+		if a.End >= dataLen {
+			logError("Error: annotation %q extends past the end of the file (%d bytes)", spec, dataLen)
+			return exitUsage
+		}
+		notes = notes.Set(a)
+	}
+
+	out, err := json.MarshalIndent(nonNil(notes), "", "  ")
+	if err == nil {
+		err = os.WriteFile(notesPath(file), append(out, '\n'), 0o644)
+	}
+	if err != nil {
+		logError("Error writing annotations: %v", err)
+		return exitIOError
+	}
+	annotations = notes
+	return printAnnotations(w, file)
+}
+
+// List the annotations of file
+func printAnnotations(w io.Writer, file string) int {
+	if outputJSON {
+		return writeJSON(w, struct {
+			Path        string           `json:"path"`
+			Annotations []fdi.Annotation `json:"annotations"`
+		}{notesPath(file), nonNil(annotations)})
+	}
+
+	fmt.Fprintf(w, "\n=== Annotations (%s) ===\n", notesPath(file))
+	if len(annotations) == 0 {
+		fmt.Fprintln(w, "No annotations")
+	}
+	for _, a := range annotations {
+		fmt.Fprintln(w, a.Label())
+	}
+	return exitOK
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// How many of an archive's files an error lists when the one asked for is
+// not there
+const maxListedMembers = 20
+
+// The archive and member of an archive:member path, such as
+// game.zip:DATA/LIGA.FDI; not when a file of that name exists
+func archivedPath(p string) (string, string, bool) {
+	if p == "-" {
+		return "", "", false
+	}
+	if _, err := os.Stat(p); err == nil {
+		return "", "", false
+	}
+	return fdi.SplitArchivePath(p)
+}
+
+// Whether the path names a file inside an archive, which is read but never
+// written
+func isArchived(p string) bool {
+	_, _, ok := archivedPath(p)
+	return ok
+}
+
+// Read the directory of the archive at archivePath; the returned function
+// closes it once its files are read
+func openArchive(archivePath string) (*fdi.Archive, func(), error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	a, err := fdi.OpenArchive(f, info.Size(), fdi.ArchiveFormat(archivePath))
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("%s: %v", archivePath, err)
+	}
+	return a, func() { f.Close() }, nil
+}
+
+// Read one file out of an archive, listing what it holds when that file is
+// not there
+func readArchived(archivePath, member string) ([]byte, func(), error) {
+	a, done, err := openArchive(archivePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer done()
+	e, ok := a.Find(member)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no %s; it holds %s", archivePath, member, memberList(a))
+	}
+	data, err := e.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", archivePath, err)
+	}
+	logVerbose("Read %s (%d bytes) from the %s archive %s", e.Name, len(data), a.Format, archivePath)
+	return data, func() {}, nil
+}
+
+func memberList(a *fdi.Archive) string {
+	if len(a.Entries) == 0 {
+		return "no files"
+	}
+	var names []string
+	for i, e := range a.Entries {
+		if i == maxListedMembers {
+			names = append(names, fmt.Sprintf("and %d more", len(a.Entries)-i))
+			break
+		}
+		names = append(names, e.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// The archive:member paths of the files in an archive matching the pattern
+// after its colon
+func expandArchived(archivePath, pattern string) ([]string, error) {
+	a, done, err := openArchive(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	done()
+	names, err := a.Match(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("pattern %q: %v", pattern, err)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no files in %s match %q; it holds %s", archivePath, pattern, memberList(a))
+	}
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = archivePath + ":" + name
+	}
+	return paths, nil
+}
+
+// The directory and base name files derived from the input are written
+// with: those of the archive and of its member for a file inside one
+func outputBase(p string) (string, string) {
+	if p == "-" {
+		return ".", "stdin"
+	}
+	if archive, member, ok := archivedPath(p); ok {
+		return filepath.Dir(archive), path.Base(strings.ReplaceAll(member, `\`, "/"))
+	}
+	return filepath.Dir(p), filepath.Base(p)
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Bytes of each file kept to compare headers across files
+const batchHeaderBytes = 4096
+
+// Expand -file arguments: glob patterns to the files they match, those after
+// the colon of archive:member to the archive's files they match, and
+// directories to the .fdi files in them
+func expandFileArgs(args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		if arg == "-" {
+			files = append(files, arg)
+			continue
+		}
+		if archive, member, ok := archivedPath(arg); ok && strings.ContainsAny(member, "*?[") {
+			members, err := expandArchived(archive, member)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, members...)
+			continue
+		}
+		if strings.ContainsAny(arg, "*?[") {
+			matches, err := filepath.Glob(arg)
+			if err != nil {
+				return nil, fmt.Errorf("pattern %q: %v", arg, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", arg)
+			}
+			files = append(files, matches...)
+			continue
+		}
+		if info, err := os.Stat(arg); err == nil && info.IsDir() {
+			dirFiles, err := listFDIFiles(arg)
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, dirFiles...)
+			continue
+		}
+		files = append(files, arg)
+	}
+	return files, nil
+}
+
+// One file of a batch summary
+type fileSummary struct {
+	Path        string       `json:"path"`
+	Size        int          `json:"size"`
+	Magic       fdi.HexBytes `json:"magic"` // first four bytes
+	RecordSize  int          `json:"record_size,omitempty"`
+	RecordStart int          `json:"record_start,omitempty"`
+	Records     int          `json:"records,omitempty"`
+	Strings     int          `json:"strings"`
+}
+
+// Files that share a magic and record size, and how much of their header
+// is identical
+type layoutGroup struct {
+	Magic        fdi.HexBytes `json:"magic"`
+	RecordSize   int          `json:"record_size,omitempty"`
+	Files        []string     `json:"files"`
+	SharedHeader int          `json:"shared_header"` // identical leading bytes
+	header       []byte
+}
+
+// Summarize each file and group those that look alike
+func summarizeFiles(w io.Writer, files []string, opts fdi.AnalysisOptions) int {
+	summaries := make([]fileSummary, 0, len(files))
+	var groups []*layoutGroup
+	for i, path := range files {
+		if progress.Stopped() {
+			break
+		}
+		progress.Advance("files", i, len(files))
+		data, release, err := readInput(path)
+		if err != nil {
+			logError("Error reading file: %v", err)
+			return exitIOError
+		}
+
+		s := fileSummary{Path: path, Size: len(data), Magic: bytes.Clone(data[:min(4, len(data))])}
+		if res, ok := cachedStride(data, 0, len(data)); ok {
+			s.RecordSize, s.RecordStart, s.Records = res.Size, res.Start, res.Records
+		}
+		s.Strings = len(fdi.NewAnalyzer(data, opts).Strings())
+		summaries = append(summaries, s)
+
+		header := data[:min(batchHeaderBytes, len(data))]
+		var group *layoutGroup
+		for _, g := range groups {
+			if bytes.Equal(g.Magic, s.Magic) && g.RecordSize == s.RecordSize {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &layoutGroup{Magic: s.Magic, RecordSize: s.RecordSize, SharedHeader: len(header), header: bytes.Clone(header)}
+			groups = append(groups, group)
+		}
+		group.Files = append(group.Files, path)
+		group.SharedHeader = commonPrefix(group.header[:group.SharedHeader], header)
+		release()
+	}
+	progress.Advance("files", len(summaries), len(files))
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Files       []fileSummary  `json:"files"`
+			Layouts     []*layoutGroup `json:"layouts"`
+			Interrupted bool           `json:"interrupted,omitempty"` // by Ctrl-C, before the last files
+		}{summaries, groups, len(summaries) < len(files)})
+	}
+
+	width := len("File")
+	for _, s := range summaries {
+		width = max(width, len(s.Path))
+	}
+	fmt.Fprintf(w, "\n=== Batch Summary (%d files) ===\n", len(files))
+	if len(summaries) < len(files) {
+		fmt.Fprintf(w, "Interrupted: only the first %d are summarized\n", len(summaries))
+	}
+	fmt.Fprintf(w, "%-*s %10s  %-10s %-22s %s\n", width, "File", "Size", "Magic", "Records", "Strings")
+	for _, s := range summaries {
+		records := "-"
+		if s.RecordSize > 0 {
+			records = fmt.Sprintf("%d x %d @0x%X", s.Records, s.RecordSize, s.RecordStart)
+		}
+		fmt.Fprintf(w, "%-*s %10d  %-10s %-22s %d\n", width, s.Path, s.Size, tagName(s.Magic), records, s.Strings)
+	}
+
+	fmt.Fprintln(w, "\n=== Layouts ===")
+	for _, g := range groups {
+		layout := "no fixed-size records"
+		if g.RecordSize > 0 {
+			layout = fmt.Sprintf("%d-byte records", g.RecordSize)
+		}
+		if len(g.Files) == 1 {
+			fmt.Fprintf(w, "Magic %s, %s: 1 file\n", tagName(g.Magic), layout)
+		} else {
+			fmt.Fprintf(w, "Magic %s, %s: %d files, first %d bytes identical\n", tagName(g.Magic), layout, len(g.Files), g.SharedHeader)
+		}
+		for _, path := range g.Files {
+			fmt.Fprintf(w, "  %s\n", path)
+		}
+	}
+	return exitOK
+}
+
+// Length of the common prefix of a and b
+func commonPrefix(a []byte, b []byte) int {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return i
+		}
+	}
+	return n
+}
+
+// A string common to several files of a merged result, with its first
+// offset in each file that holds it
+type mergedString struct {
+	Text    string         `json:"text"`
+	Files   int            `json:"files"`
+	Offsets map[string]int `json:"offsets"`
+}
+
+// Analyze each file and write the results as one JSON object keyed by path,
+// with the strings found in at least minFiles of them (0 means all)
+func mergeAnalyses(w io.Writer, files []string, opts fdi.AnalysisOptions, minFiles int) int {
+	results := make(map[string]fdi.AnalysisResult, len(files))
+	var analyzed []string
+	var perFile [][]fdi.FoundString
+	for i, path := range files {
+		if progress.Stopped() {
+			break
+		}
+		progress.Advance("files", i, len(files))
+		data, release, err := readInput(path)
+		if err != nil {
+			logError("Error reading file: %v", err)
+			return exitIOError
+		}
+		res := fdi.NewAnalyzer(data, opts).UseCache(analysisCache).Records(0, len(data)).AnalysisResult
+		release()
+		results[path] = res
+		analyzed = append(analyzed, path)
+		perFile = append(perFile, res.Strings)
+	}
+	progress.Advance("files", len(analyzed), len(files))
+
+	if minFiles <= 0 || minFiles > len(analyzed) {
+		minFiles = len(analyzed)
+	}
+	common := []mergedString{}
+	for _, cs := range fdi.FindCommonStrings(perFile, minFiles) {
+		m := mergedString{Text: cs.Text, Files: cs.Files, Offsets: map[string]int{}}
+		for i, off := range cs.Offsets {
+			if off >= 0 {
+				m.Offsets[analyzed[i]] = off
+			}
+		}
+		common = append(common, m)
+	}
+	return writeJSON(w, struct {
+		Files         map[string]fdi.AnalysisResult `json:"files"`
+		MinFiles      int                           `json:"min_files"`
+		CommonStrings []mergedString                `json:"common_strings"`
+		Interrupted   bool                          `json:"interrupted,omitempty"` // by Ctrl-C, before the last files
+	}{results, minFiles, common, len(analyzed) < len(files)})
+}
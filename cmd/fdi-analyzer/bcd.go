@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Print the runs of bytes that decode as packed BCD
+func scanBCD(w io.Writer, data []byte) {
+	fmt.Fprintln(w, "\n=== BCD Number Scan ===")
+
+	numbers := fdi.ScanBCD(data)
+	for i, n := range numbers {
+		if i >= 20 {
+			fmt.Fprintln(w, "... and more BCD candidates")
+			break
+		}
+		fmt.Fprintf(w, "Offset 0x%X (%d bytes): %s -> %d\n", n.Offset, n.Length, n.Digits, n.Value)
+	}
+
+	if len(numbers) == 0 {
+		fmt.Fprintln(w, "No BCD-encoded numbers found")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Show how each bit of an intra-record byte is set across all records and
+// the runs of varying bits that may be packed fields
+func analyzeBits(w io.Writer, data []byte, start int, recordSize int, fieldOff int) int {
+	report, err := fdi.AnalyzeBits(data, start, recordSize, fieldOff)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			RecordSize  int `json:"record_size"`
+			FieldOffset int `json:"field_offset"`
+			fdi.BitReport
+		}{recordSize, fieldOff, report})
+	}
+
+	fmt.Fprintf(w, "\n=== Bit Fields (Record size: %d, Field offset: %d, %d records) ===\n", recordSize, fieldOff, report.Records)
+	for i := len(report.Bits) - 1; i >= 0; i-- {
+		b := report.Bits[i]
+		note := ""
+		switch b.Set {
+		case 0:
+			note = "  always clear"
+		case report.Records:
+			note = "  always set"
+		}
+		fmt.Fprintf(w, "Bit %d: %6d set (%5.1f%%) %s%s\n", b.Bit, b.Set, 100*b.Ratio, strings.Repeat("#", int(20*b.Ratio+0.5)), note)
+	}
+
+	if len(report.Groups) == 0 {
+		fmt.Fprintln(w, "No bits vary across the records")
+		return exitOK
+	}
+	fmt.Fprintln(w, "\nCandidate fields (bit_offset and bits for a -schema field):")
+	for _, g := range report.Groups {
+		values := make([]string, len(g.Values))
+		for i, v := range g.Values {
+			values[i] = fmt.Sprintf("%d x%d", v.Value, v.Records)
+		}
+		more := ""
+		if g.Distinct > len(g.Values) {
+			more = ", ..."
+		}
+		bits := fmt.Sprintf("Bits %d-%d", g.BitOffset, g.BitOffset+g.Bits-1)
+		if g.Bits == 1 {
+			bits = fmt.Sprintf("Bit %d", g.BitOffset)
+		}
+		fmt.Fprintf(w, "%s (bit_offset %d, bits %d): %d distinct, %d-%d: %s%s\n",
+			bits, g.BitOffset, g.Bits, g.Distinct, g.Min, g.Max, strings.Join(values, ", "), more)
+	}
+	return exitOK
+}
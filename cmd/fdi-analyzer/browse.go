@@ -0,0 +1,363 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Screen lines used by everything except the hex rows: the title, a blank
+// line, six inspector lines and the prompt line
+const browseChromeLines = 9
+
+// Prompt modes of the browser
+const (
+	promptNone = iota
+	promptGoto
+	promptSearch
+	promptHexSearch
+)
+
+// Interactive hex browser state
+type browser struct {
+	data   []byte
+	cursor int // offset of the selected byte
+	top    int // offset of the first visible row
+	rows   int // visible hex rows
+
+	prompt int    // promptNone or the active prompt
+	input  string // text typed at the prompt
+	origin int    // cursor when the search prompt opened
+	query  []byte // last search pattern, for n and N
+	status string // message shown on the prompt line
+}
+
+// Browse data interactively on the terminal, starting at offset
+func browseFile(w io.Writer, data []byte, offset int) int {
+	if len(data) == 0 {
+		logError("Nothing to browse: the file is empty")
+		return exitUsage
+	}
+	if offset >= len(data) {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+
+	restore, err := rawTerminal()
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	defer restore()
+
+	runBrowser(w, os.Stdin, data, offset, terminalHeight())
+	fmt.Fprint(w, "\x1b[H\x1b[2J")
+	return exitOK
+}
+
+// Run the browser until q is pressed or input ends. height is the screen
+// height in lines.
+func runBrowser(w io.Writer, r io.Reader, data []byte, offset int, height int) {
+	b := &browser{data: data, rows: max(height-browseChromeLines, 1)}
+	b.moveTo(offset)
+	b.render(w)
+
+	buf := make([]byte, 64)
+	for {
+		n, err := r.Read(buf)
+		for _, key := range decodeKeys(buf[:n]) {
+			if b.handle(key) {
+				return
+			}
+		}
+		b.render(w)
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Split terminal input into key names: printable characters as themselves,
+// escape sequences as "up", "down", "left", "right", "pgup", "pgdn", "home"
+// and "end", and "enter", "backspace" and "esc".
+func decodeKeys(in []byte) []string {
+	sequences := []struct{ seq, key string }{
+		{"\x1b[A", "up"}, {"\x1b[B", "down"}, {"\x1b[C", "right"}, {"\x1b[D", "left"},
+		{"\x1b[5~", "pgup"}, {"\x1b[6~", "pgdn"},
+		{"\x1b[H", "home"}, {"\x1b[F", "end"}, {"\x1b[1~", "home"}, {"\x1b[4~", "end"},
+	}
+
+	var keys []string
+	for len(in) > 0 {
+		matched := false
+		for _, s := range sequences {
+			if bytes.HasPrefix(in, []byte(s.seq)) {
+				keys = append(keys, s.key)
+				in = in[len(s.seq):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		switch c := in[0]; {
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == 0x7F || c == 0x08:
+			keys = append(keys, "backspace")
+		case c == 0x1b:
+			keys = append(keys, "esc")
+		case c == 0x03: // Ctrl-C
+			keys = append(keys, "q")
+		case c >= 0x20 && c < 0x7F:
+			keys = append(keys, string(c))
+		}
+		in = in[1:]
+	}
+	return keys
+}
+
+// Apply one key. Returns true when the browser should exit.
+func (b *browser) handle(key string) bool {
+	if b.prompt != promptNone {
+		b.handlePrompt(key)
+		return false
+	}
+
+	b.status = ""
+	page := b.rows * 16
+	switch key {
+	case "q":
+		return true
+	case "left", "h":
+		b.moveTo(b.cursor - 1)
+	case "right", "l":
+		b.moveTo(b.cursor + 1)
+	case "up", "k":
+		b.moveTo(b.cursor - 16)
+	case "down", "j":
+		b.moveTo(b.cursor + 16)
+	case "pgup", "b":
+		b.top = max(b.top-page, 0)
+		b.moveTo(b.cursor - page)
+	case "pgdn", " ":
+		b.top = min(b.top+page, (len(b.data)-1)&^15)
+		b.moveTo(b.cursor + page)
+	case "home":
+		b.moveTo(0)
+	case "end":
+		b.moveTo(len(b.data) - 1)
+	case "g":
+		b.prompt, b.input = promptGoto, ""
+	case "/":
+		b.prompt, b.input, b.origin = promptSearch, "", b.cursor
+	case "\\":
+		b.prompt, b.input, b.origin = promptHexSearch, "", b.cursor
+	case "n":
+		b.findNext(b.cursor+1, true)
+	case "N":
+		b.findNext(b.cursor-1, false)
+	}
+	return false
+}
+
+// Input at a prompt. Text searches are incremental: the cursor follows the
+// first match after where the search started as the query is typed.
+func (b *browser) handlePrompt(key string) {
+	switch key {
+	case "esc":
+		if b.prompt == promptSearch || b.prompt == promptHexSearch {
+			b.moveTo(b.origin)
+		}
+		b.prompt, b.status = promptNone, ""
+		return
+	case "enter":
+		b.finishPrompt()
+		return
+	case "backspace":
+		if b.input != "" {
+			b.input = b.input[:len(b.input)-1]
+		}
+	default:
+		if len(key) != 1 {
+			return
+		}
+		b.input += key
+	}
+
+	if b.prompt == promptSearch {
+		b.query = []byte(b.input)
+		if len(b.query) == 0 {
+			b.moveTo(b.origin)
+			return
+		}
+		b.findNext(b.origin+1, true)
+	}
+}
+
+func (b *browser) finishPrompt() {
+	prompt := b.prompt
+	b.prompt = promptNone
+
+	switch prompt {
+	case promptGoto:
+		off, err := strconv.ParseInt(strings.TrimSpace(b.input), 0, 64)
+		if err != nil || off < 0 || int(off) >= len(b.data) {
+			b.status = fmt.Sprintf("Invalid offset %q", b.input)
+			return
+		}
+		b.moveTo(int(off))
+	case promptSearch:
+		if len(b.query) > 0 && b.status == "" {
+			b.status = fmt.Sprintf("Found %q at 0x%X (n: next, N: previous)", b.query, b.cursor)
+		}
+	case promptHexSearch:
+		pattern, err := fdi.ParseHexPattern(b.input)
+		if err != nil {
+			b.status = fmt.Sprintf("Invalid hex pattern: %v", err)
+			return
+		}
+		b.query = pattern
+		b.findNext(b.origin+1, true)
+		if b.status == "" {
+			b.status = fmt.Sprintf("Found %X at 0x%X (n: next, N: previous)", pattern, b.cursor)
+		}
+	}
+}
+
+// Move the cursor to the next match of the query at or after from, or at or
+// before it when searching backwards. Wraps around the end of the data.
+func (b *browser) findNext(from int, forward bool) {
+	if len(b.query) == 0 {
+		b.status = "No search yet: press / or \\"
+		return
+	}
+
+	var at int
+	if forward {
+		from = min(max(from, 0), len(b.data))
+		at = bytes.Index(b.data[from:], b.query)
+		if at >= 0 {
+			at += from
+		} else {
+			at = bytes.Index(b.data, b.query) // wrap to the start
+		}
+	} else {
+		end := min(max(from, 0)+len(b.query), len(b.data))
+		at = bytes.LastIndex(b.data[:end], b.query)
+		if at < 0 {
+			at = bytes.LastIndex(b.data, b.query) // wrap to the end
+		}
+	}
+
+	if at < 0 {
+		b.status = fmt.Sprintf("Not found: %q", b.query)
+		return
+	}
+	b.status = ""
+	b.moveTo(at)
+}
+
+// Select offset, clamped to the data, and scroll it into view
+func (b *browser) moveTo(offset int) {
+	b.cursor = min(max(offset, 0), len(b.data)-1)
+	row := b.cursor &^ 15
+	if row < b.top {
+		b.top = row
+	}
+	if row >= b.top+b.rows*16 {
+		b.top = row - (b.rows-1)*16
+	}
+}
+
+// Draw the whole screen. Lines end in \r\n since the terminal is in raw mode.
+func (b *browser) render(w io.Writer) {
+	var sb strings.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	fmt.Fprintf(&sb, "Offset 0x%X of 0x%X   arrows/hjkl move, PgUp/PgDn page, g goto, / text, \\ hex, n/N next/prev, q quit\r\n",
+		b.cursor, len(b.data))
+
+	for row := 0; row < b.rows; row++ {
+		start := b.top + row*16
+		if start >= len(b.data) {
+			sb.WriteString("\r\n")
+			continue
+		}
+		end := min(start+16, len(b.data))
+
+		fmt.Fprintf(&sb, "0x%08X | ", start)
+		for i := start; i < end; i++ {
+			b.cell(&sb, i, fmt.Sprintf("%02X", b.data[i]))
+			sb.WriteByte(' ')
+		}
+		sb.WriteString(strings.Repeat("   ", 16-(end-start)))
+		sb.WriteString("| ")
+		for i := start; i < end; i++ {
+			b.cell(&sb, i, string(fdi.DumpChar(b.data[i], dumpCodepage)))
+		}
+		sb.WriteString("\r\n")
+	}
+
+	sb.WriteString("\r\n")
+	b.inspector(&sb)
+
+	switch b.prompt {
+	case promptGoto:
+		fmt.Fprintf(&sb, "Go to offset: %s", b.input)
+	case promptSearch:
+		fmt.Fprintf(&sb, "Search: %s", b.input)
+		if b.status != "" {
+			fmt.Fprintf(&sb, "   (%s)", b.status)
+		}
+	case promptHexSearch:
+		fmt.Fprintf(&sb, "Hex search: %s", b.input)
+	default:
+		sb.WriteString(b.status)
+	}
+	io.WriteString(w, sb.String())
+}
+
+// Write text for the byte at i, in reverse video when it is under the cursor
+func (b *browser) cell(sb *strings.Builder, i int, text string) {
+	if i == b.cursor {
+		sb.WriteString("\x1b[7m" + text + "\x1b[0m")
+		return
+	}
+	sb.WriteString(text)
+}
+
+// Six lines interpreting the bytes under the cursor in both byte orders
+func (b *browser) inspector(sb *strings.Builder) {
+	v, err := fdi.Decode(b.data, b.cursor)
+	if err != nil {
+		sb.WriteString(strings.Repeat("\r\n", 6))
+		return
+	}
+
+	fmt.Fprintf(sb, "u8  %-12d i8  %d\r\n", v.U8, v.I8)
+	lines := []struct {
+		width  int
+		format string
+		args   []any
+	}{
+		{2, "u16 %-12d %-12d i16 %-12d %d", []any{v.U16LE, v.U16BE, v.I16LE, v.I16BE}},
+		{4, "u32 %-12d %-12d i32 %-12d %d", []any{v.U32LE, v.U32BE, v.I32LE, v.I32BE}},
+		{4, "f32 %-12g %g", []any{v.F32LE, v.F32BE}},
+		{8, "f64 %-25g %g", []any{v.F64LE, v.F64BE}},
+	}
+	for _, l := range lines {
+		if v.Available < l.width {
+			sb.WriteString("\r\n")
+			continue
+		}
+		fmt.Fprintf(sb, l.format+"\r\n", l.args...)
+	}
+	sb.WriteString("    (little endian, then big endian)\r\n")
+}
@@ -0,0 +1,42 @@
+package main
+
+import "fdi-analyzer/fdi"
+
+// Where the deep passes keep their results between runs; nil with -no-cache
+// or when the directory cannot be created. Set in main
+var analysisCache *fdi.Cache
+
+// Open the cache in dir, or the default directory for ""; without one every
+// pass is run as before
+func openCache(disabled bool, dir string) *fdi.Cache {
+	if disabled {
+		return nil
+	}
+	c, err := fdi.OpenCache(dir)
+	if err != nil {
+		logVerbose("Not caching the analysis: %v", err)
+		return nil
+	}
+	logVerbose("Caching the analysis in %s", c.Dir)
+	return c
+}
+
+// fdi.InferStride over data[start:end], from the cache when it was run before
+func cachedStride(data []byte, start int, end int) (fdi.StrideResult, bool) {
+	type inferred struct {
+		Result fdi.StrideResult
+		Found  bool
+	}
+	res := fdi.Cached(analysisCache, data, "stride", [2]int{start, end}, func() inferred {
+		res, ok := fdi.InferStride(data, start, end)
+		return inferred{res, ok}
+	})
+	return res.Result, res.Found
+}
+
+// fdi.EntropyMap of data, from the cache when it was run before
+func cachedEntropyMap(data []byte, window int) []fdi.WindowStats {
+	return fdi.Cached(analysisCache, data, "entropy", window, func() []fdi.WindowStats {
+		return fdi.EntropyMap(data, window)
+	})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"fdi-analyzer/fdi"
+)
+
+// The index -carve writes next to the sections
+type carveManifest struct {
+	File     string         `json:"file"`
+	Size     int            `json:"size"`
+	Sections []carvedOutput `json:"sections"`
+}
+
+type carvedOutput struct {
+	fdi.CarvedSection
+	Size int    `json:"size"`
+	Out  string `json:"out"` // relative to the manifest
+}
+
+// Split the file at the sections fdi.Carve finds, writing each to its own
+// file in dir (<file>.sections by default) with a manifest.json index
+func carveFile(w io.Writer, data []byte, path string, dir string) int {
+	parent, base := outputBase(path)
+	if dir == "" {
+		dir = filepath.Join(parent, base+".sections")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		logError("Error creating directory: %v", err)
+		return exitIOError
+	}
+
+	manifest := carveManifest{File: path, Size: len(data)}
+	for i, s := range fdi.Carve(data) {
+		name := fmt.Sprintf("%03d_0x%X_%s.bin", i, s.Start, s.Source)
+		if err := os.WriteFile(filepath.Join(dir, name), data[s.Start:s.End], 0o644); err != nil {
+			logError("Error writing file: %v", err)
+			return exitIOError
+		}
+		manifest.Sections = append(manifest.Sections, carvedOutput{s, s.End - s.Start, name})
+	}
+
+	out, err := json.MarshalIndent(manifest, "", "  ")
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "manifest.json"), append(out, '\n'), 0o644)
+	}
+	if err != nil {
+		logError("Error writing manifest: %v", err)
+		return exitIOError
+	}
+
+	if outputJSON {
+		return writeJSON(w, manifest)
+	}
+	fmt.Fprintf(w, "\n=== Carved %d sections into %s ===\n", len(manifest.Sections), dir)
+	fmt.Fprintf(w, "%-21s %8s  %-18s %-28s %s\n", "Range", "Size", "Class", "File", "Cut at")
+	for _, s := range manifest.Sections {
+		cut := s.Source
+		if s.Detail != "" {
+			cut += ": " + s.Detail
+		}
+		fmt.Fprintf(w, "0x%08X-0x%08X %8d  %-18s %-28s %s\n", s.Start, s.End-1, s.Size, s.Class, s.Out, cut)
+	}
+	fmt.Fprintf(w, "Index written to %s\n", filepath.Join(dir, "manifest.json"))
+	return exitOK
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Look for an intra-record field that holds a checksum of the rest of the record
+func scanRecordChecksums(w io.Writer, data []byte, start int, recordSize int) int {
+	fields, err := fdi.FindRecordChecksums(data, start, recordSize)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			RecordSize int                 `json:"record_size"`
+			Records    int                 `json:"records"`
+			Fields     []fdi.ChecksumField `json:"fields"`
+		}{recordSize, fdi.RecordCount(data, start, recordSize), nonNil(fields)})
+	}
+
+	fmt.Fprintf(w, "\n=== Record Checksum Scan (Record size: %d, Records: %d) ===\n",
+		recordSize, fdi.RecordCount(data, start, recordSize))
+	for _, f := range fields {
+		fmt.Fprintf(w, "Checksum field at record offset %d (%d bytes): %s of the remaining bytes\n",
+			f.Offset, f.Width, f.Algorithm)
+	}
+
+	if len(fields) == 0 {
+		fmt.Fprintln(w, "No per-record checksum field found")
+	}
+	return exitOK
+}
+
+// List the checksums stored at either end of the file
+func scanFileChecksums(w io.Writer, data []byte) int {
+	sums := fdi.FindChecksums(data)
+	if outputJSON {
+		return writeJSON(w, struct {
+			Checksums []fdi.Checksum `json:"checksums"`
+		}{nonNil(sums)})
+	}
+
+	fmt.Fprintln(w, "\n=== Checksum Scan ===")
+	for _, c := range sums {
+		fmt.Fprintf(w, "%s at 0x%X (%d bytes) covers 0x%X-0x%X", c.Algorithm, c.Offset, c.Width, c.Start, c.End-1)
+		if c.Offset >= c.Start && c.Offset < c.End {
+			fmt.Fprint(w, " except itself")
+		}
+		fmt.Fprintf(w, "  (-checksum %s)\n", c)
+	}
+	if len(sums) == 0 {
+		fmt.Fprintln(w, "No stored checksum found in the first or last 16 bytes")
+	}
+	return exitOK
+}
+
+// Checksums to keep valid across an edit, from -fixchecksum
+type checksumFix struct {
+	enabled    bool
+	specs      []string // -checksum; detected in the original when empty
+	start      int      // record layout for per-record checksums
+	recordSize int
+}
+
+// The checksums to recompute: the given specs, or those that match in the
+// original data, stored at the ends of the file or, with a record size, in
+// every record
+func (fix checksumFix) checksums(w io.Writer, data []byte) ([]fdi.Checksum, int) {
+	var sums []fdi.Checksum
+	for _, spec := range fix.specs {
+		c, err := fdi.ParseChecksum(spec, len(data))
+		if err != nil {
+			logError("Error: %v", err)
+			return nil, exitUsage
+		}
+		sums = append(sums, c)
+	}
+	if len(sums) > 0 {
+		return sums, exitOK
+	}
+
+	// Per-record checksums first, since a file checksum may cover them
+	if fix.recordSize > 0 {
+		fields, _ := fdi.FindRecordChecksums(data, fix.start, fix.recordSize)
+		for _, f := range fields {
+			sums = append(sums, f.RecordChecksums(fix.start, fix.recordSize, fdi.RecordCount(data, fix.start, fix.recordSize))...)
+		}
+	}
+	return append(sums, fdi.FindChecksums(data)...), exitOK
+}
+
+// A checksum -fixchecksum changed
+type fixedChecksum struct {
+	fdi.Checksum
+	Before fdi.HexBytes `json:"before"`
+	After  fdi.HexBytes `json:"after"`
+}
+
+// Recompute the checksums of the original data within patched. Returns the
+// fixed data, the number of checksums checked and those that changed.
+func applyChecksumFix(w io.Writer, data []byte, patched []byte, fix checksumFix) ([]byte, int, []fixedChecksum, int) {
+	if !fix.enabled {
+		return patched, 0, nil, exitOK
+	}
+	sums, code := fix.checksums(w, data)
+	if code != exitOK {
+		return nil, 0, nil, code
+	}
+
+	fixed, patches := fdi.FixChecksums(patched, sums)
+	var changed []fixedChecksum
+	for _, p := range patches {
+		for _, c := range sums {
+			if c.Offset == p.Offset {
+				changed = append(changed, fixedChecksum{c, patched[c.Offset : c.Offset+c.Width], p.Bytes})
+				break
+			}
+		}
+	}
+	return fixed, len(sums), changed, exitOK
+}
+
+func printChecksumFixes(w io.Writer, fix checksumFix, checked int, changed []fixedChecksum) {
+	if !fix.enabled {
+		return
+	}
+	fmt.Fprintf(w, "\n=== Checksums (%d checked) ===\n", checked)
+	for _, c := range changed {
+		fmt.Fprintf(w, "Fixed %s: %X -> %X\n", c.Checksum, []byte(c.Before), []byte(c.After))
+	}
+	switch {
+	case checked == 0:
+		fmt.Fprintln(w, "No checksum found in the original; pass its location with -checksum")
+	case len(changed) == 0:
+		fmt.Fprintln(w, "All checksums already match")
+	}
+}
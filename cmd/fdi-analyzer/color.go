@@ -0,0 +1,18 @@
+package main
+
+import "os"
+
+// Whether hex dumps are colorized; set from -color in main
+var colorOutput bool
+
+// Color is only used on a terminal and never when NO_COLOR is set
+func colorSupported() bool {
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
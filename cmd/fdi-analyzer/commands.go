@@ -0,0 +1,224 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// A subcommand: a mode of the analyzer with the flags that apply to it
+type subcommand struct {
+	name   string
+	args   string // what follows the flags
+	help   string
+	report string // the part of the default report it prints, if any
+	flags  []string
+	needs  []string // one of these must be given
+}
+
+// Flags every subcommand takes
+var commonFlags = []string{"file", "format", "json", "color", "maxmem", "progress", "no-cache", "cache-dir", "codepage", "decompress-at", "signatures", "q", "v"}
+
+var subcommands = []subcommand{
+	{
+		name:   "dump",
+		args:   "<file>",
+		help:   "Print a hex dump of -bytes bytes from -offset, or look at one offset more closely with -inspect, -decode or -where-is-offset, the file's byte order first unless -endian sets it. -stats and -entropy summarize the dumped range, -browse pages through the file interactively and -annotate notes what a range holds. -highlight, or -highlight-from the JSON results of another command, marks offsets in every dump, and -around dumps only the rows near them.",
+		report: "dump",
+		flags: []string{"offset", "bytes", "end", "inspect", "decode", "where-is-offset", "record-size", "recsize", "endian",
+			"browse", "annotate", "notes", "highlight", "highlight-from", "around", "stats", "entropy", "window", "fingerprint"},
+	},
+	{
+		name:   "search",
+		args:   "<file>",
+		help:   "Search for text with -search or -isearch, for bytes with -hexsearch or in the strings with -regex. -findvalue finds where a number is stored, in the file's byte order unless -type or -endian says otherwise, with -session to narrow it down over several saves, and -xref what refers to an offset. -pointers, -bcd-scan, -date-scan, -checksum-scan and -compression-scan look for structures of their kind.",
+		report: "search",
+		needs: []string{"search", "isearch", "hexsearch", "regex", "findvalue", "session", "xref", "bcd-scan", "pointers",
+			"date-scan", "checksum-scan", "compression-scan"},
+		flags: []string{"search", "isearch", "hexsearch", "regex", "utf16", "ignorecase", "nooverlap", "fuzzy", "minstr",
+			"min-string-len", "encoding", "findvalue", "type", "session", "changed", "unchanged", "increased", "decreased",
+			"offset", "end", "limit", "verbose", "xref", "base", "record-size", "recsize", "bcd-scan", "pointers", "follow",
+			"min-entries", "bytes", "date-scan", "years", "checksum-scan", "compression-scan", "endian"},
+	},
+	{
+		name:   "strings",
+		args:   "<file>...",
+		help:   "List the text strings of at least -minstr characters between -offset and -end. -rename-strings-table dumps a table of fixed-width names, -strings-table-infer finds one, -name-tables finds them all and the record fields that index them, and -find-common-strings lists the strings several files share.",
+		report: "strings",
+		flags: []string{"minstr", "min-string-len", "maxstr", "max-strings", "encoding", "stringsout", "offset", "end",
+			"rename-strings-table", "width", "count", "strings-table-infer", "name-tables", "find-common-strings", "min-files",
+			"dir", "min-padding", "keep-padding"},
+	},
+	{
+		name:   "records",
+		args:   "<file>...",
+		help:   "Look for repeating delimiters and the record length they suggest, between -offset and -end. -record dumps one record, -infer-stride, -field-type-guess and -bitfield work out the layout without delimiters, -schema decodes records with a layout file and -annotate-template breaks one of them down field by field, showing the bytes no field maps as unknown, -sections lists tagged sections and -padding the padding between blocks with their alignment. -duplicates finds the regions that occur more than once and the runs of alike blocks, such as one per team. -stats with -schema or -record-size summarizes each field across the records and flags those that are constant, increasing or look like enums, and -endianness tells whether their numbers are little- or big-endian. Several files are summarized and grouped by layout, or with -merge-json their analyses written as one JSON object keyed by path, with the strings they have in common.",
+		report: "records",
+		flags: []string{"offset", "end", "records", "verbose", "limit", "maxstr", "max-strings", "minstr", "min-string-len",
+			"encoding", "fast", "deep", "record", "record-size", "recsize", "infer-stride", "field-type-guess",
+			"bitfield", "record-checksum-scan", "schema", "count", "stats", "sections", "magic", "dir", "padding", "min-padding",
+			"keep-padding", "duplicates", "window", "similarity", "endianness", "merge-json", "min-files",
+			"annotate-template"},
+	},
+	{
+		name:  "decode",
+		args:  "-schema <table> <file>",
+		help:  "Decode the table a -schema describes, whose fields may give the range of values a real record holds. The table starts at -offset or the schema's start, or is found as the longest run of records whose strings are printable and whose values are within their ranges; -count limits the records. The named fields are printed as a table, as JSON or, with -export, as CSV or SQLite. -identify tests each version of the format in a -registry against the file. -foreign-keys decodes the tables of a -registry found in the file, and one a -schema describes, and reports the columns that refer to another table's records.",
+		flags: []string{"offset", "count", "export", "out", "ksy", "identify", "registry", "foreign-keys", "schema"},
+	},
+	{
+		name:  "plugin",
+		args:  "<program> <file>",
+		help:  "Run a custom analysis pass: the program, quoted with its arguments such as \"python3 transfers.py\", reads the file's path, size, -offset, -end, record layout, format and annotations as JSON on stdin and writes its findings as JSON on stdout.",
+		flags: []string{"plugin", "offset", "end", "record-size", "recsize"},
+	},
+	{
+		name:  "diff",
+		args:  "<file> <other>",
+		help:  "Compare two files byte by byte, grouping the changes by record with -schema, -record-size or -records. With -export ips, bps or json, write the changes that turn <file> into <other> as a delta patch to -out or stdout. With -watch, give one file: each time it is saved it is compared against the version before, checking every -interval, and -plugin is run on the new version.",
+		flags: []string{"diff", "schema", "record-size", "recsize", "records", "offset", "export", "out", "watch", "interval", "plugin"},
+	},
+	{
+		name:  "edit",
+		args:  "<file>",
+		help:  "Change the file: -write-offset with -write-hex or -write-string, or -set for a -schema field of -record, edit it in place and keep a .bak copy; -patch writes a patched copy to -out. -replace old=new rewrites every occurrence of a text, or hex: bytes, after showing them and asking unless -yes is given. -apply applies an IPS, BPS or JSON delta patch, in place or to -out. -fixchecksum updates the checksums over the changed bytes.",
+		needs: []string{"patch", "write-offset", "set", "replace", "apply", "fixchecksum"},
+		flags: []string{"patch", "out", "write-offset", "write-hex", "write-string", "set", "schema", "record", "offset",
+			"replace", "yes", "ignorecase", "apply", "fixchecksum", "checksum", "record-size", "recsize"},
+	},
+	{
+		name: "undo",
+		args: "<file>",
+		help: "Undo the last edit made in place by edit, restoring the bytes it changed from the file's journal, <file>.journal, which records every edit with the bytes before and after. Run it again to undo the edit before.",
+	},
+	{
+		name: "redo",
+		args: "<file>",
+		help: "Make the last edit undone by undo again. A new edit after an undo drops the edits that could be redone.",
+	},
+	{
+		name:  "serve",
+		args:  "<file>...",
+		help:  "Serve the analysis of the files as JSON over HTTP on -listen, for a front end to drive: /files lists them, /dump?offset=&size= returns a page of the hex dump with the offset of the next, /search?q= or ?hex= and /strings pages of matches (from=, limit=), /records?offset=&end= the record analysis and /decode?offset=&count= the records of the table a -schema describes. file= picks a file by its index or name.",
+		flags: []string{"listen", "allow-origin", "minstr", "min-string-len", "encoding", "schema"},
+	},
+	{
+		name:  "build",
+		args:  "-in <document>",
+		help:  "Build a file from a JSON document written by export -export json: the records' values are encoded with the -schema's fields and the bytes kept as hex are written as they were, so an unedited document gives back the same file. The file is written to -out.",
+		flags: []string{"schema", "in", "out"},
+	},
+	{
+		name: "export",
+		args: "<file>",
+		help: "Export the strings, and the records of -schema, as -export csv (the default) or sqlite. -export json, the default for an -out ending in .json, writes the whole file as a document of the records' values, by field name, and the other bytes as hex, to edit and rebuild with build. -carve splits the file into its sections and -decompress extracts its compressed blocks instead, and -report writes an HTML report with a hex view highlighting the annotations and the hits of -search, -isearch and -hexsearch.",
+		flags: []string{"export", "out", "schema", "offset", "count", "minstr", "min-string-len", "encoding", "carve", "outdir", "decompress",
+			"report", "search", "isearch", "hexsearch", "ignorecase", "utf16", "nooverlap", "fuzzy"},
+	},
+}
+
+func lookupSubcommand(name string) (*subcommand, bool) {
+	for i := range subcommands {
+		if subcommands[i].name == name {
+			return &subcommands[i], true
+		}
+	}
+	return nil, false
+}
+
+func (c *subcommand) allows(name string) bool {
+	for _, f := range c.flags {
+		if f == name {
+			return true
+		}
+	}
+	for _, f := range commonFlags {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Print the subcommand's help and the defaults of its flags
+func (c *subcommand) printUsage(out io.Writer) {
+	fmt.Fprintf(out, "Usage: %s %s [flags] %s\n\n%s\n\nFlags:\n", progName(), c.name, c.args, c.help)
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	flag.VisitAll(func(f *flag.Flag) {
+		if c.allows(f.Name) {
+			fs.Var(f.Value, f.Name, f.Usage)
+			fs.Lookup(f.Name).DefValue = f.DefValue
+		}
+	})
+	fs.PrintDefaults()
+}
+
+// Whether a flag was given on the command line
+func isSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) { set = set || f.Name == name })
+	return set
+}
+
+// Whether none of the flags the subcommand needs one of was given
+func (c *subcommand) missing() bool {
+	for _, name := range c.needs {
+		if isSet(name) {
+			return false
+		}
+	}
+	return len(c.needs) > 0
+}
+
+// The flags given that the subcommand does not take
+func (c *subcommand) foreignFlags() []string {
+	var names []string
+	flag.Visit(func(f *flag.Flag) {
+		if !c.allows(f.Name) {
+			names = append(names, "-"+f.Name)
+		}
+	})
+	sort.Strings(names)
+	return names
+}
+
+// Parse the command line after the subcommand name, allowing flags after
+// the file arguments as well as before. Returns the positional arguments.
+func parseInterspersed(args []string) []string {
+	var positional []string
+	for {
+		flag.CommandLine.Parse(args)
+		rest := flag.Args()
+		if n := len(args) - len(rest); len(rest) == 0 || n > 0 && args[n-1] == "--" {
+			return append(positional, rest...)
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// The name the analyzer was run as
+func progName() string {
+	return strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+}
+
+// Print the commands with what each is for
+func printCommands(out io.Writer) {
+	fmt.Fprintf(out, "Usage: %s <command> [flags] <file>\n\nCommands:\n", progName())
+	for _, c := range subcommands {
+		fmt.Fprintf(out, "  %-8s %s\n", c.name, firstSentence(c.help))
+	}
+	fmt.Fprintf(out, "\nRun %s help <command> for the flags of each.\n", progName())
+}
+
+func firstSentence(s string) string {
+	if i := strings.Index(s, ". "); i >= 0 {
+		return s[:i+1]
+	}
+	return s
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Collect the .fdi files in a directory (not recursive)
+func listFDIFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.EqualFold(filepath.Ext(entry.Name()), ".fdi") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files, nil
+}
+
+// Report the strings shared by at least minFiles of the given files (0 means all of them)
+func findCommonStrings(w io.Writer, files []string, minFiles int) int {
+	perFile := make([][]fdi.FoundString, 0, len(files))
+	for _, path := range files {
+		data, release, err := readInput(path)
+		if err != nil {
+			logError("Error reading file: %v", err)
+			return exitIOError
+		}
+		perFile = append(perFile, fdi.ExtractStrings(data, 4))
+		release()
+	}
+
+	if minFiles <= 0 || minFiles > len(files) {
+		minFiles = len(files)
+	}
+	common := fdi.FindCommonStrings(perFile, minFiles)
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Files    []string           `json:"files"`
+			MinFiles int                `json:"min_files"`
+			Strings  []fdi.CommonString `json:"strings"`
+		}{files, minFiles, nonNil(common)})
+	}
+
+	fmt.Fprintf(w, "\n=== Common Strings (%d files, in at least %d) ===\n", len(files), minFiles)
+	if len(common) == 0 {
+		fmt.Fprintln(w, "No common strings found")
+		return exitOK
+	}
+
+	for _, cs := range common {
+		fmt.Fprintf(w, "%q in %d/%d files\n", cs.Text, cs.Files, len(files))
+		for i, path := range files {
+			if cs.Offsets[i] >= 0 {
+				fmt.Fprintf(w, "  %s: 0x%X\n", path, cs.Offsets[i])
+			}
+		}
+	}
+	return exitOK
+}
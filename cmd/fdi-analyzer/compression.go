@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"fdi-analyzer/fdi"
+)
+
+// List the compressed and high-entropy blocks in data
+func scanCompression(w io.Writer, data []byte) int {
+	blocks := fdi.FindCompressed(data)
+	if outputJSON {
+		return writeJSON(w, struct {
+			Blocks []fdi.CompressedBlock `json:"blocks"`
+		}{nonNil(blocks)})
+	}
+
+	fmt.Fprintln(w, "\n=== Compressed Blocks ===")
+	if len(blocks) == 0 {
+		fmt.Fprintln(w, "No compressed or high-entropy blocks found")
+		return exitOK
+	}
+	fmt.Fprintf(w, "%-10s %-8s %10s %12s %s\n", "Offset", "Format", "Length", "Unpacked", "Entropy")
+	for _, b := range blocks {
+		unpacked := "?"
+		if b.Size > 0 {
+			unpacked = fmt.Sprint(b.Size)
+		}
+		fmt.Fprintf(w, "0x%-8X %-8s %10d %12s %.2f\n", b.Offset, b.Format, b.Length, unpacked, b.Entropy)
+	}
+	fmt.Fprintf(w, "\n%d blocks; analyze one with -decompress-at <format>@<offset>\n", len(blocks))
+	return exitOK
+}
+
+// Write every block FindCompressed could decode to <dir>/<file>.0x<offset>.bin,
+// next to the input file when dir is empty
+func extractCompressed(w io.Writer, data []byte, path string, dir string) int {
+	parent, base := outputBase(path)
+	if dir == "" {
+		dir = parent
+	}
+
+	type jsonExtract struct {
+		fdi.CompressedBlock
+		Out string `json:"out"`
+	}
+	var written []jsonExtract
+	for _, b := range fdi.FindCompressed(data) {
+		if b.Format == "unknown" {
+			continue
+		}
+		out, _, err := fdi.Decompress(data[b.Offset:b.Offset+b.Length], b.Format)
+		if err != nil {
+			logError("Error decompressing the %s block at 0x%X: %v", b.Format, b.Offset, err)
+			return exitIOError
+		}
+		name := filepath.Join(dir, fmt.Sprintf("%s.0x%X.bin", base, b.Offset))
+		if err := os.WriteFile(name, out, 0o644); err != nil {
+			logError("Error writing file: %v", err)
+			return exitIOError
+		}
+		written = append(written, jsonExtract{b, name})
+		if !outputJSON {
+			fmt.Fprintf(w, "Extracted %s block at 0x%X (%d -> %d bytes) to %s\n", b.Format, b.Offset, b.Length, len(out), name)
+		}
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Extracted []jsonExtract `json:"extracted"`
+		}{nonNil(written)})
+	}
+	if len(written) == 0 {
+		fmt.Fprintln(w, "No zlib, gzip or lzss block found to extract")
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// Decompress the block named by a -decompress-at spec so the rest of the
+// analysis runs on its contents
+func openCompressed(w io.Writer, data []byte, spec string) ([]byte, int) {
+	format, start, end, err := fdi.ParseCompressedBlock(spec, len(data))
+	if err != nil {
+		logError("Error: %v", err)
+		return nil, exitUsage
+	}
+	if format == "" {
+		if format = fdi.DetectCompression(data[start:end]); format == "" {
+			logError("No zlib or gzip header at 0x%X; name the format, as in lzss@0x%X", start, start)
+			return nil, exitUsage
+		}
+	}
+
+	out, n, err := fdi.Decompress(data[start:end], format)
+	if err != nil {
+		logError("Error: %v", err)
+		return nil, exitUsage
+	}
+	if !outputJSON {
+		fmt.Fprintf(w, "Decompressed %s block at 0x%X (%d -> %d bytes)\n", format, start, n, len(out))
+	}
+	return out, exitOK
+}
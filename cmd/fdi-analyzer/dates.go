@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Date fields shown in the text report, unless -verbose
+const dateFieldLimit = 20
+
+// Print the fields that hold a plausible date in every record of a table
+func scanDates(w io.Writer, data []byte, years string, verbose bool) int {
+	var opts fdi.DateOptions
+	if years != "" {
+		var err error
+		if opts, err = fdi.ParseYears(years); err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+	}
+	fields, err := fdi.ScanDates(data, opts)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	if outputJSON {
+		writeJSON(w, struct {
+			Fields []fdi.DateField `json:"fields"`
+		}{nonNil(fields)})
+	} else {
+		fmt.Fprintln(w, "\n=== Date Fields ===")
+		for i, f := range fields {
+			if i == dateFieldLimit && !verbose {
+				fmt.Fprintf(w, "... and %d more (-verbose lists them all)\n", len(fields)-i)
+				break
+			}
+			order := ""
+			if f.Ascending {
+				order = ", ascending"
+			}
+			fmt.Fprintf(w, "0x%X every %d bytes, %d records (%s): %s to %s, %d distinct%s\n",
+				f.Offset, f.Stride, f.Count, f.Encoding, f.Earliest, f.Latest, f.Distinct, order)
+			fmt.Fprintf(w, "  %s\n", strings.Join(f.Sample, ", "))
+		}
+	}
+	if len(fields) == 0 {
+		if !outputJSON {
+			fmt.Fprintf(w, "No field holds a date in %d or more records in a row\n", fdi.MinDateRecords)
+		}
+		return exitNoMatch
+	}
+	return exitOK
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Print the bytes at an offset interpreted as the common numeric types, those
+// in the file's byte order first when endian is known
+func decodeAt(w io.Writer, data []byte, offset int, endian string) int {
+	v, err := fdi.Decode(data, offset)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	v.Endian = endian
+
+	if outputJSON {
+		return writeJSON(w, v)
+	}
+
+	fmt.Fprintf(w, "\n=== Decode (Offset: 0x%X) ===\n", offset)
+	printNumbers(w, v)
+	return exitOK
+}
+
+// Print the numbers of every width the bytes available hold, big-endian
+// first when that is the file's byte order
+func printNumbers(w io.Writer, v fdi.NumericValues) {
+	pair := func(name, verb string, le, be any) {
+		first, second := "LE", "BE"
+		if v.Endian == fdi.BigEndian {
+			le, be, first, second = be, le, second, first
+		}
+		fmt.Fprintf(w, "%-9s"+verb+" (%s)  "+verb+" (%s)\n", name+":", le, first, be, second)
+	}
+
+	fmt.Fprintf(w, "uint8:   %d\n", v.U8)
+	fmt.Fprintf(w, "int8:    %d\n", v.I8)
+
+	if v.Available < 2 {
+		fmt.Fprintln(w, "Not enough bytes remaining for 16-bit values")
+		return
+	}
+	pair("uint16", "%d", v.U16LE, v.U16BE)
+	pair("int16", "%d", v.I16LE, v.I16BE)
+
+	if v.Available < 4 {
+		fmt.Fprintln(w, "Not enough bytes remaining for 32-bit values")
+		return
+	}
+	pair("uint32", "%d", v.U32LE, v.U32BE)
+	pair("int32", "%d", v.I32LE, v.I32BE)
+	pair("float32", "%g", v.F32LE, v.F32BE)
+
+	if v.Available < 8 {
+		fmt.Fprintln(w, "Not enough bytes remaining for 64-bit values")
+		return
+	}
+	pair("uint64", "%d", v.U64LE, v.U64BE)
+	pair("int64", "%d", v.I64LE, v.I64BE)
+	pair("float64", "%g", v.F64LE, v.F64BE)
+}
+
+// Print everything the bytes at an offset might be: the numbers, in the
+// file's byte order first, DOS timestamps and text in each encoding
+func inspectAt(w io.Writer, data []byte, offset int, endian string) int {
+	in, err := fdi.Inspect(data, offset)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	in.Numbers.Endian = endian
+
+	if outputJSON {
+		return writeJSON(w, in)
+	}
+
+	fmt.Fprintf(w, "\n=== Inspect (Offset: 0x%X) ===\n", offset)
+	fmt.Fprintf(w, "bytes:   % X\n", data[offset:min(offset+16, len(data))])
+	printNumbers(w, in.Numbers)
+
+	for _, ts := range []struct{ label, value string }{
+		{"DOS date (u16le):", in.DOSDate},
+		{"DOS time (u16le):", in.DOSTime},
+		{"DOS date+time (u32le, time first):", in.DOSDateTime},
+	} {
+		if ts.value == "" {
+			ts.value = "-"
+		}
+		fmt.Fprintf(w, "%-35s %s\n", ts.label, ts.value)
+	}
+
+	fmt.Fprintln(w, "Text up to the first NUL:")
+	for _, s := range in.Strings {
+		fmt.Fprintf(w, "  %-8s %q (%d bytes)\n", s.Encoding+":", s.Text, s.Bytes)
+	}
+	return exitOK
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Write the changes that turn data into the modified file as a delta patch
+// in format, to outPath or w
+func writeDeltaPatch(w io.Writer, data []byte, modifiedPath, format, outPath string) int {
+	if !slices.Contains(fdi.PatchFormats, format) {
+		logError("Unknown patch format %q (supported: %s)", format, strings.Join(fdi.PatchFormats, ", "))
+		return exitUsage
+	}
+	modified, release, err := readInput(modifiedPath)
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	defer release()
+
+	patch, err := fdi.CreateDeltaPatch(format, data, modified)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	if outPath == "" {
+		w.Write(patch)
+		return exitOK
+	}
+	if err := os.WriteFile(outPath, patch, 0o644); err != nil {
+		logError("Error writing file: %v", err)
+		return exitIOError
+	}
+
+	runs := fdi.Diff(data, modified)
+	if outputJSON {
+		return writeJSON(w, struct {
+			Out    string `json:"out"`
+			Format string `json:"format"`
+			Size   int    `json:"size"`
+			Runs   int    `json:"runs"`
+		}{outPath, format, len(patch), len(runs)})
+	}
+	fmt.Fprintf(w, "Exported %d changed runs as a %d-byte %s patch to %s\n", len(runs), len(patch), format, outPath)
+	return exitOK
+}
+
+// Apply a delta patch file to data, in place keeping a .bak copy, or to a
+// copy at outPath
+func applyDeltaPatch(w io.Writer, data []byte, path, patchPath, outPath string) int {
+	if (path == "-" || isArchived(path)) && outPath == "" {
+		logError("Cannot patch stdin or a file inside an archive in place; give -out for the result")
+		return exitUsage
+	}
+	patch, err := os.ReadFile(patchPath)
+	if err != nil {
+		logError("Error reading patch: %v", err)
+		return exitIOError
+	}
+	patched, format, err := fdi.ApplyDeltaPatch(data, patch)
+	if err != nil {
+		logError("Cannot apply %s: %v", patchPath, err)
+		return exitUsage
+	}
+	logVerbose("Applying %s as a %s patch", patchPath, format)
+
+	backup := ""
+	if outPath != "" {
+		if err := os.WriteFile(outPath, patched, 0o644); err != nil {
+			logError("Error writing file: %v", err)
+			return exitIOError
+		}
+	} else {
+		backup = path + ".bak"
+		if err := os.WriteFile(backup, data, 0o644); err != nil {
+			logError("Error writing backup: %v", err)
+			return exitIOError
+		}
+		if err := replaceFile(path, patched); err != nil {
+			logError("Error writing file: %v", err)
+			return exitIOError
+		}
+		// The journal keeps changed bytes, not a new size
+		if len(patched) != len(data) {
+			logInfo("The patch changes the size of %s; restore %s to undo it", path, backup)
+		} else if code := journalEdit(path, data, patched); code != exitOK {
+			return code
+		}
+	}
+
+	runs := fdi.Diff(data, patched)
+	if outputJSON {
+		return writeJSON(w, struct {
+			File    string        `json:"file"`
+			Patch   string        `json:"patch"`
+			Format  string        `json:"format"`
+			Out     string        `json:"out,omitempty"`
+			Backup  string        `json:"backup,omitempty"`
+			Size    int           `json:"size"`
+			NewSize int           `json:"new_size"`
+			Runs    []fdi.DiffRun `json:"runs"`
+		}{path, patchPath, format, outPath, backup, len(data), len(patched), nonNil(runs)})
+	}
+	for _, r := range runs {
+		fmt.Fprintf(w, "\n=== Patched 0x%X (%d bytes) ===\n", r.Offset, len(r.Old))
+		fmt.Fprintln(w, "Before:")
+		printFileHeader(w, data, len(r.Old), r.Offset)
+		fmt.Fprintln(w, "After:")
+		printFileHeader(w, patched, len(r.Old), r.Offset)
+	}
+	if len(patched) != len(data) {
+		fmt.Fprintf(w, "\nSize: %d -> %d bytes\n", len(data), len(patched))
+	}
+	if backup != "" {
+		fmt.Fprintf(w, "\nApplied the %s patch %s to %s (original saved as %s)\n", format, patchPath, path, backup)
+	} else {
+		fmt.Fprintf(w, "\nApplied the %s patch %s, wrote %s\n", format, patchPath, outPath)
+	}
+	return exitOK
+}
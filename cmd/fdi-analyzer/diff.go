@@ -0,0 +1,177 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Maximum number of bytes shown per changed run
+const diffPreview = 16
+
+// Rows of hex/ASCII context shown for each changed run
+const diffContextRows = 2
+
+// Report the byte runs that differ between data and another file. With a
+// layout, the changes are also attributed to records and fields.
+func diffFiles(w io.Writer, data []byte, otherPath string, layout *fdi.Schema) int {
+	other, release, err := readInput(otherPath)
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	defer release()
+	return diffData(w, data, other, otherPath, layout)
+}
+
+// Report the byte runs that differ between data and other, named by label
+func diffData(w io.Writer, data []byte, other []byte, label string, layout *fdi.Schema) int {
+	runs := fdi.Diff(data, other)
+	changed := 0
+	for _, run := range runs {
+		changed += len(run.Old)
+	}
+
+	var changes []fdi.RecordChange
+	if layout != nil {
+		changes = fdi.DiffRecords(data, other, *layout)
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Other     string             `json:"other"`
+			Size      int                `json:"size"`
+			OtherSize int                `json:"other_size"`
+			Changed   int                `json:"changed"` // bytes
+			Runs      []fdi.DiffRun      `json:"runs"`
+			Records   []fdi.RecordChange `json:"records,omitempty"` // with a record layout
+			Notes     []fdi.Annotation   `json:"notes,omitempty"`   // annotations on changed bytes
+		}{label, len(data), len(other), changed, nonNil(runs), changes, changedNotes(runs)})
+	}
+
+	fmt.Fprintf(w, "\n=== Diff against %s ===\n", label)
+
+	if len(other) != len(data) {
+		fmt.Fprintf(w, "Files differ in length: %d vs %d bytes (comparing the first %d)\n",
+			len(data), len(other), min(len(data), len(other)))
+	}
+
+	for _, run := range runs {
+		printDiffRun(w, run)
+		printDiffContext(w, data, other, run)
+	}
+
+	if changed == 0 {
+		fmt.Fprintln(w, "No differing bytes")
+	}
+	fmt.Fprintf(w, "%d bytes changed in %d runs\n", changed, len(runs))
+
+	if layout != nil && len(changes) > 0 {
+		printRecordChanges(w, *layout, changes)
+	}
+	return exitOK
+}
+
+// Print the 16-byte rows around the start of a run in both files
+func printDiffContext(w io.Writer, data, other []byte, run fdi.DiffRun) {
+	start := run.Offset &^ 15
+	end := (run.Offset + len(run.Old) + 15) &^ 15
+	size := min(end-start, diffContextRows*16)
+	for _, side := range []struct {
+		label string
+		data  []byte
+	}{{"old", data}, {"new", other}} {
+		rows, err := fdi.Dump(side.data, fdi.DumpOptions{Offset: start, Size: size, Codepage: dumpCodepage})
+		if err != nil {
+			continue
+		}
+		for _, row := range rows {
+			fmt.Fprintf(w, "  %s 0x%08X | %-48s| %s\n", side.label, row.Offset, hexPreview(row.Bytes)+" ", row.Text)
+		}
+	}
+}
+
+// Print the changes grouped by record, with decoded old and new field values
+func printRecordChanges(w io.Writer, layout fdi.Schema, changes []fdi.RecordChange) {
+	fmt.Fprintf(w, "\n=== Changes by Record (Offset: 0x%X, Record size: %d) ===\n", layout.Start, layout.RecordSize)
+	for i, c := range changes {
+		if c.Record < 0 {
+			fmt.Fprintf(w, "Before the records, 0x%X: %s -> %s\n", c.Offset, formatFieldValue(c.Old), formatFieldValue(c.New))
+			continue
+		}
+		if i == 0 || changes[i-1].Record != c.Record {
+			fmt.Fprintf(w, "Record %d (0x%X):\n", c.Record, c.Offset-c.RecordByte)
+		}
+		name := c.Field
+		if name == "" {
+			name = fmt.Sprintf("byte %d", c.RecordByte)
+		}
+		fmt.Fprintf(w, "  %s: %s -> %s\n", name, formatFieldValue(c.Old), formatFieldValue(c.New))
+	}
+}
+
+// The record layout to attribute diff changes to: the schema if given,
+// otherwise -record-size from -offset, otherwise the detected record length
+// when detect is set. Returns nil without a layout.
+func diffLayout(w io.Writer, data []byte, schemaPath string, start int, recordSize int, detect bool) (*fdi.Schema, int) {
+	switch {
+	case schemaPath != "":
+		schema, code := loadSchema(w, schemaPath, start, 0)
+		if code != exitOK {
+			return nil, code
+		}
+		return &schema, exitOK
+	case recordSize > 0:
+		return &fdi.Schema{Start: start, RecordSize: recordSize}, exitOK
+	case detect:
+		best, ok := fdi.LikelyRecordSize(fdi.TallyStrides(fdi.FindRepeatPatterns(data)))
+		if !ok {
+			if !outputJSON {
+				fmt.Fprintln(w, "No record length detected; changes are not grouped by record")
+			}
+			return nil, exitOK
+		}
+		return &fdi.Schema{Start: best.Offset, RecordSize: best.Stride}, exitOK
+	}
+	return nil, exitOK
+}
+
+// Print one contiguous run of changed bytes, old values first, and the
+// annotations it touches
+func printDiffRun(w io.Writer, run fdi.DiffRun) {
+	fmt.Fprintf(w, "0x%08X-0x%08X (%d bytes): %s -> %s\n",
+		run.Offset, run.Offset+len(run.Old)-1, len(run.Old), hexPreview(run.Old), hexPreview(run.New))
+	for _, a := range annotations.Overlapping(run.Offset, run.Offset+len(run.Old)) {
+		fmt.Fprintf(w, "  in %s\n", a.Label())
+	}
+}
+
+// The annotations that cover changed bytes, each once
+func changedNotes(runs []fdi.DiffRun) []fdi.Annotation {
+	var notes []fdi.Annotation
+	for _, a := range annotations {
+		for _, run := range runs {
+			if a.Start < run.Offset+len(run.Old) && a.End >= run.Offset {
+				notes = append(notes, a)
+				break
+			}
+		}
+	}
+	return notes
+}
+
+// Space-separated hex of up to diffPreview bytes
+func hexPreview(b []byte) string {
+	s := ""
+	for i, v := range b {
+		if i == diffPreview {
+			return s + " ..."
+		}
+		if i > 0 {
+			s += " "
+		}
+		s += fmt.Sprintf("%02X", v)
+	}
+	return s
+}
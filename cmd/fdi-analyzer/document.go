@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"fdi-analyzer/fdi"
+)
+
+// Write the whole file as a JSON document of the schema's records and the
+// bytes around them, to -out or w
+func exportDocument(w io.Writer, data []byte, req exportRequest) int {
+	if req.schemaPath == "" {
+		logError("Please give the -schema of the records to export as JSON")
+		return exitUsage
+	}
+	schema, code := loadSchema(w, req.schemaPath, req.start, req.count)
+	if code != exitOK {
+		return code
+	}
+	doc, err := fdi.ExportDocument(data, schema)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	if req.outPath == "" {
+		return writeJSON(w, doc)
+	}
+	f, err := os.Create(req.outPath)
+	if err != nil {
+		logError("Error writing file: %v", err)
+		return exitIOError
+	}
+	defer f.Close()
+	if code := writeJSON(f, doc); code != exitOK {
+		return code
+	}
+	kept := 0
+	for _, b := range doc.Blobs {
+		kept += len(b.Bytes)
+	}
+	fmt.Fprintf(w, "Exported %d records, and %d bytes around them as they are, to %s\n", len(doc.Records), kept, req.outPath)
+	return exitOK
+}
+
+// Build a file from a JSON document written by exportDocument, encoding its
+// records with the schema's fields
+func buildDocument(w io.Writer, schemaPath, inPath, outPath string) int {
+	if schemaPath == "" || inPath == "" || outPath == "" {
+		logError("%s build needs the -schema, the document to read with -in and the file to write with -out", progName())
+		return exitUsage
+	}
+	schema, code := loadSchema(w, schemaPath, 0, 0)
+	if code != exitOK {
+		return code
+	}
+	var src []byte
+	var err error
+	if inPath == "-" {
+		src, err = io.ReadAll(os.Stdin)
+	} else {
+		src, err = os.ReadFile(inPath)
+	}
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	var doc fdi.Document
+	if err := json.Unmarshal(src, &doc); err != nil {
+		logError("Error in %s: %v", inPath, err)
+		return exitUsage
+	}
+	if doc.Schema != "" && schema.Name != "" && doc.Schema != schema.Name {
+		logInfo("Note: %s was exported with the schema %s, not %s", inPath, doc.Schema, schema.Name)
+	}
+	data, err := fdi.BuildDocument(doc, schema)
+	if err != nil {
+		logError("Error in %s: %v", inPath, err)
+		return exitUsage
+	}
+	if err := os.WriteFile(outPath, data, 0o644); err != nil {
+		logError("Error writing file: %v", err)
+		return exitIOError
+	}
+
+	sum := sha256.Sum256(data)
+	same := hex.EncodeToString(sum[:]) == doc.SHA256
+	if outputJSON {
+		return writeJSON(w, struct {
+			Out       string `json:"out"`
+			Size      int    `json:"size"`
+			Records   int    `json:"records"`
+			Unchanged bool   `json:"unchanged"` // the same bytes as the file exported
+		}{outPath, len(data), len(doc.Records), same})
+	}
+	fmt.Fprintf(w, "Built %d records into %s (%d bytes)", len(doc.Records), outPath, len(data))
+	if same {
+		fmt.Fprint(w, ", identical to the file exported")
+	}
+	fmt.Fprintln(w)
+	return exitOK
+}
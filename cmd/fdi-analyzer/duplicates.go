@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Identical regions, and the offsets of each, listed in the text output
+const (
+	identicalRegionLimit = 20
+	identicalOffsetLimit = 8
+)
+
+// Print the runs of alike blocks and the regions found more than once
+func printDuplicates(w io.Writer, data []byte, opts fdi.DuplicateOptions) int {
+	report, err := fdi.FindDuplicates(data, opts)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	found := len(report.Blocks) > 0 || len(report.Identical) > 0
+	report.Blocks, report.Identical = nonNil(report.Blocks), nonNil(report.Identical)
+	if outputJSON {
+		if code := writeJSON(w, report); code != exitOK || found {
+			return code
+		}
+		return exitNoMatch
+	}
+
+	fmt.Fprintf(w, "\n=== Repeated Blocks (windows of %d bytes) ===\n", report.Window)
+	if report.Interrupted {
+		fmt.Fprintln(w, "Interrupted: only the copies found before then are listed")
+	}
+	if !found {
+		fmt.Fprintln(w, "No region of the file occurs twice")
+		return exitNoMatch
+	}
+	for _, b := range report.Blocks {
+		fmt.Fprintf(w, "%d blocks of %d bytes at 0x%X-0x%X, %.0f%% alike\n",
+			b.Count, b.BlockSize, b.Offset, b.Offset+b.Count*b.BlockSize-1, 100*b.Similarity)
+	}
+	if len(report.Blocks) == 0 {
+		fmt.Fprintln(w, "No run of alike blocks")
+	}
+
+	fmt.Fprintln(w, "\nIdentical regions:")
+	for i, r := range report.Identical {
+		if i >= identicalRegionLimit {
+			fmt.Fprintf(w, "... and %d more regions\n", len(report.Identical)-i)
+			break
+		}
+		offs := make([]string, 0, identicalOffsetLimit)
+		for _, off := range r.Offsets[:min(len(r.Offsets), identicalOffsetLimit)] {
+			offs = append(offs, fmt.Sprintf("0x%X", off))
+		}
+		more := ""
+		if n := len(r.Offsets) - identicalOffsetLimit; n > 0 {
+			more = fmt.Sprintf(" and %d more", n)
+		}
+		fmt.Fprintf(w, "  %d bytes, %d copies: %s%s\n", r.Length, len(r.Offsets), strings.Join(offs, ", "), more)
+	}
+	return exitOK
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// In-place edits requested with -write-offset and -set
+type editRequest struct {
+	offset     int    // -write-offset, or -1
+	hex        string // -write-hex
+	text       string // -write-string, encoded with -codepage if given
+	sets       []string
+	schemaPath string
+	record     int // record index for -set
+	start      int // -offset, overrides the schema's start
+	fix        checksumFix
+}
+
+// Turn the request into patches against data
+func (req editRequest) patches(w io.Writer, data []byte) ([]fdi.Patch, int) {
+	var patches []fdi.Patch
+
+	if req.offset >= 0 {
+		var raw []byte
+		var err error
+		switch {
+		case (req.hex == "") == (req.text == ""):
+			logError("-write-offset needs one of -write-hex or -write-string")
+			return nil, exitUsage
+		case req.hex != "":
+			raw, err = fdi.ParseHexPattern(req.hex)
+		case dumpCodepage != nil:
+			raw, err = dumpCodepage.EncodeString(req.text)
+		default:
+			raw = []byte(req.text)
+		}
+		if err != nil {
+			logError("Error: %v", err)
+			return nil, exitUsage
+		}
+		patches = append(patches, fdi.Patch{Offset: req.offset, Bytes: raw})
+	} else if req.hex != "" || req.text != "" {
+		logError("-write-hex and -write-string need -write-offset")
+		return nil, exitUsage
+	}
+
+	if len(req.sets) == 0 {
+		return patches, exitOK
+	}
+	if req.schemaPath == "" || req.record < 0 {
+		logError("-set needs -schema and the -record to change")
+		return nil, exitUsage
+	}
+	schema, code := loadSchema(w, req.schemaPath, req.start, 0)
+	if code != exitOK {
+		return nil, code
+	}
+	// Each -set sees the ones before it, so bit fields sharing a byte all stick
+	current := data
+	for _, spec := range req.sets {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok {
+			logError("-set %q: expected field=value", spec)
+			return nil, exitUsage
+		}
+		p, err := schema.SetField(current, req.record, strings.TrimSpace(name), value)
+		if err == nil {
+			current, err = fdi.ApplyPatches(current, []fdi.Patch{p})
+		}
+		if err != nil {
+			logError("Error: %v", err)
+			return nil, exitUsage
+		}
+		patches = append(patches, p)
+	}
+	return patches, exitOK
+}
+
+// Apply the edits to the file at path, keeping the original as path.bak
+func editFile(w io.Writer, data []byte, path string, req editRequest) int {
+	if path == "-" {
+		logError("Cannot edit stdin in place; use -patch with -out instead")
+		return exitUsage
+	}
+	if isArchived(path) {
+		logError("Cannot edit a file inside an archive in place; use -patch with -out instead")
+		return exitUsage
+	}
+
+	patches, code := req.patches(w, data)
+	if code != exitOK {
+		return code
+	}
+	if len(patches) == 0 && len(req.fix.specs) == 0 {
+		logError("-fixchecksum without edits needs the checksum locations with -checksum")
+		return exitUsage
+	}
+	patched, err := fdi.ApplyPatches(data, patches)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	patched, checked, fixed, code := applyChecksumFix(w, data, patched, req.fix)
+	if code != exitOK {
+		return code
+	}
+
+	if !outputJSON {
+		for _, p := range patches {
+			fmt.Fprintf(w, "\n=== Edit at 0x%X (%d bytes) ===\n", p.Offset, len(p.Bytes))
+			fmt.Fprintln(w, "Before:")
+			printFileHeader(w, data, len(p.Bytes), p.Offset)
+			fmt.Fprintln(w, "After:")
+			printFileHeader(w, patched, len(p.Bytes), p.Offset)
+		}
+		printChecksumFixes(w, req.fix, checked, fixed)
+	}
+	type jsonEdit struct {
+		Offset int          `json:"offset"`
+		Before fdi.HexBytes `json:"before"`
+		After  fdi.HexBytes `json:"after"`
+	}
+	edits := make([]jsonEdit, 0, len(patches))
+	for _, p := range patches {
+		end := p.Offset + len(p.Bytes)
+		edits = append(edits, jsonEdit{p.Offset, data[p.Offset:end], patched[p.Offset:end]})
+	}
+
+	backup := path + ".bak"
+	if err := os.WriteFile(backup, data, 0o644); err != nil {
+		logError("Error writing backup: %v", err)
+		return exitIOError
+	}
+	if err := replaceFile(path, patched); err != nil {
+		logError("Error writing file: %v", err)
+		return exitIOError
+	}
+	if code := journalEdit(path, data, patched); code != exitOK {
+		return code
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			File      string          `json:"file"`
+			Backup    string          `json:"backup"`
+			Edits     []jsonEdit      `json:"edits"`
+			Checksums []fixedChecksum `json:"fixed_checksums,omitempty"`
+		}{path, backup, edits, fixed})
+	}
+	if len(patches) == 0 {
+		fmt.Fprintf(w, "\nFixed %d checksums in %s (original saved as %s)\n", len(fixed), path, backup)
+		return exitOK
+	}
+	fmt.Fprintf(w, "\nApplied %d edits to %s (original saved as %s)\n", len(patches), path, backup)
+	return exitOK
+}
+
+// Replace the file at path with data by writing a temporary file next to it
+// and renaming it over the original, so a mapping of the old file stays valid
+func replaceFile(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
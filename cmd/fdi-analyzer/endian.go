@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// The records the byte order is told from: those of -record-size from start,
+// or else those stride inference finds
+func endianRecords(data []byte, start int, size int) (int, int, int, bool) {
+	if size > 0 {
+		return start, size, (len(data) - start) / size, start < len(data)
+	}
+	res, ok := cachedStride(data, 0, len(data))
+	return res.Start, res.Size, res.Records, ok
+}
+
+// The byte order -endian asks for: le or be as given, or for auto the one the
+// numbers of the records suggest, "" when they do not
+func fileEndian(data []byte, mode string, start int, size int) string {
+	if mode != "auto" {
+		return mode
+	}
+	recStart, recSize, count, ok := endianRecords(data, start, size)
+	if !ok {
+		logVerbose("No records to tell the byte order from")
+		return ""
+	}
+	r := fdi.DetectEndianness(data, recStart, recSize, count)
+	if r.Endian == "" {
+		logVerbose("The byte order is unclear: %d fields read as little-endian, %d as big-endian", r.Little, r.Big)
+		return ""
+	}
+	logVerbose("The numbers look %s (%d fields against %d); -endian sets the byte order", endianName(r.Endian), max(r.Little, r.Big), min(r.Little, r.Big))
+	return r.Endian
+}
+
+// The byte order of the types -findvalue tries, only needed without -type
+func valueEndian(data []byte, types string, mode string, start int, size int) string {
+	if types != "" {
+		return ""
+	}
+	return fileEndian(data, mode, start, size)
+}
+
+func endianName(endian string) string {
+	if endian == fdi.BigEndian {
+		return "big-endian"
+	}
+	return "little-endian"
+}
+
+// Report whether the numbers of the records read as little- or big-endian,
+// and the fields that tell
+func printEndianness(w io.Writer, data []byte, start int, size int) int {
+	if start >= len(data) {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+	recStart, recSize, count, ok := endianRecords(data, start, size)
+	var r fdi.EndianReport
+	if ok {
+		r = fdi.DetectEndianness(data, recStart, recSize, count)
+	}
+	if outputJSON {
+		writeJSON(w, struct {
+			RecordStart int `json:"record_start"`
+			RecordSize  int `json:"record_size"`
+			Records     int `json:"records"`
+			fdi.EndianReport
+		}{recStart, recSize, count, r})
+	} else if !ok {
+		fmt.Fprintln(w, "\nNo fixed-size records to tell the byte order from; give -record-size")
+	} else {
+		fmt.Fprintln(w, "\n=== Byte Order ===")
+		fmt.Fprintf(w, "%d records of %d bytes from 0x%X, %d fields holding numbers\n", count, recSize, recStart, r.Sampled)
+		fmt.Fprintf(w, "Fields that read as small numbers only little-endian: %d, only big-endian: %d\n", r.Little, r.Big)
+		if r.Endian == "" {
+			fmt.Fprintln(w, "Neither byte order wins")
+		} else {
+			fmt.Fprintf(w, "The numbers are %s\n", endianName(r.Endian))
+		}
+		for _, f := range r.Fields {
+			rising := ""
+			if f.Rising {
+				rising = ", rising"
+			}
+			fmt.Fprintf(w, "  +%-4d %d bytes  %s  up to %d, %d the other way%s\n", f.Offset, f.Width, f.Endian, f.Max, f.Other, rising)
+		}
+	}
+	if r.Endian == "" {
+		return exitNoMatch
+	}
+	return exitOK
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"fdi-analyzer/fdi"
+)
+
+// Heatmap shades from no entropy to the most a window can have
+const entropyShades = " .:-=+*#%@"
+
+// Windows per heatmap row
+const heatmapWidth = 64
+
+// Print an entropy heatmap of data[start:end] split into windows, and the
+// regions of neighbouring windows that look alike
+func printEntropy(w io.Writer, data []byte, start int, end int, window int) int {
+	if start >= len(data) {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+	if end <= 0 || end > len(data) {
+		end = len(data)
+	}
+	if end <= start {
+		logError("End offset must be greater than the start offset")
+		return exitUsage
+	}
+	if window <= 0 {
+		logError("-window must be positive")
+		return exitUsage
+	}
+
+	windows := cachedEntropyMap(data[start:end], window)
+	for i := range windows {
+		windows[i].Offset += start
+	}
+	regions := fdi.EntropyRegions(windows)
+	if outputJSON {
+		return writeJSON(w, struct {
+			Window  int                 `json:"window"`
+			Windows []fdi.WindowStats   `json:"windows"`
+			Regions []fdi.EntropyRegion `json:"regions"`
+		}{window, windows, regions})
+	}
+
+	fmt.Fprintf(w, "\n=== Entropy (0x%X-0x%X, %d-byte windows) ===\n", start, end-1, window)
+	fmt.Fprintf(w, "Scale: '%s' = 0 to %.0f bits/byte\n\n", entropyShades, maxEntropy(window))
+	for i := 0; i < len(windows); i += heatmapWidth {
+		fmt.Fprintf(w, "0x%08X |", windows[i].Offset)
+		for _, ws := range windows[i:min(i+heatmapWidth, len(windows))] {
+			fmt.Fprint(w, shade(ws.Entropy, maxEntropy(ws.Length)))
+		}
+		fmt.Fprintln(w, "|")
+	}
+
+	fmt.Fprintln(w, "\nRegions:")
+	for _, r := range regions {
+		fmt.Fprintf(w, "0x%08X-0x%08X %8d bytes  %.2f bits/byte  %s\n", r.Start, r.End-1, r.End-r.Start, r.Entropy, r.Class)
+	}
+	return exitOK
+}
+
+// The most entropy n bytes can have: 8 bits per byte, less when there are
+// fewer than 256 of them to hold every byte value
+func maxEntropy(n int) float64 {
+	return math.Min(8, math.Log2(float64(max(n, 2))))
+}
+
+// The heatmap character for an entropy out of the given maximum
+func shade(entropy float64, most float64) string {
+	i := shadeIndex(entropy, most)
+	return entropyShades[i : i+1]
+}
+
+// Which of the heatmap shades an entropy out of the given maximum takes
+func shadeIndex(entropy float64, most float64) int {
+	i := int(math.Round(entropy / most * float64(len(entropyShades)-1)))
+	return max(0, min(i, len(entropyShades)-1))
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+
+	"fdi-analyzer/fdi"
+)
+
+// What -export writes
+type exportRequest struct {
+	format     string // csv, sqlite, ksy or json
+	outPath    string // required for sqlite; csv goes to w without it
+	schemaPath string
+	schema     fdi.Schema // with records, a table decoded already instead of -schema
+	records    []fdi.Record
+	start      int // -offset and -count override the schema's
+	count      int
+	analysis   fdi.AnalysisOptions
+}
+
+// Export the strings, and the records a schema decodes, as CSV or SQLite.
+// CSV holds one table: the records when there is a schema, else the strings.
+func exportData(w io.Writer, data []byte, req exportRequest) int {
+	switch req.format {
+	case "ksy":
+		return exportKaitai(w, req)
+	case "json":
+		return exportDocument(w, data, req)
+	}
+	tables := []sqlTable{stringsTable(data, req.analysis)}
+	if req.records != nil {
+		tables = append(tables, recordsTable(req.schema, req.records))
+	} else if req.schemaPath != "" {
+		schema, code := loadSchema(w, req.schemaPath, req.start, req.count)
+		if code != exitOK {
+			return code
+		}
+		records, err := fdi.DecodeRecords(data, schema)
+		if err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+		tables = append(tables, recordsTable(schema, records))
+	}
+
+	switch req.format {
+	case "csv":
+		t := tables[len(tables)-1]
+		out := w
+		if req.outPath != "" {
+			f, err := os.Create(req.outPath)
+			if err != nil {
+				logError("Error writing file: %v", err)
+				return exitIOError
+			}
+			defer f.Close()
+			out = f
+		}
+		if err := writeCSV(out, t); err != nil {
+			logError("Error writing file: %v", err)
+			return exitIOError
+		}
+		if req.outPath != "" {
+			fmt.Fprintf(w, "Exported %d %s to %s\n", len(t.rows), t.name, req.outPath)
+		}
+	case "sqlite":
+		if req.outPath == "" {
+			logError("Please specify the database to write with -out")
+			return exitUsage
+		}
+		if err := writeSQLite(req.outPath, tables); err != nil {
+			logError("Error writing database: %v", err)
+			return exitIOError
+		}
+		for _, t := range tables {
+			fmt.Fprintf(w, "Exported %d rows to table %s\n", len(t.rows), t.name)
+		}
+		logInfo("Wrote %s", req.outPath)
+	default:
+		logError("Unknown export format %q (supported: csv, sqlite, ksy, json)", req.format)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// Write the schema, or the table decoded already, as a Kaitai Struct
+// definition to -out or w
+func exportKaitai(w io.Writer, req exportRequest) int {
+	schema := req.schema
+	if req.records == nil {
+		if req.schemaPath == "" {
+			logError("Please give the -schema to write as a Kaitai Struct definition")
+			return exitUsage
+		}
+		var code int
+		if schema, code = loadSchema(w, req.schemaPath, req.start, req.count); code != exitOK {
+			return code
+		}
+	}
+	ksy := fdi.KaitaiFromSchema(schema)
+	if req.outPath == "" {
+		w.Write(ksy)
+		return exitOK
+	}
+	if err := os.WriteFile(req.outPath, ksy, 0o644); err != nil {
+		logError("Error writing file: %v", err)
+		return exitIOError
+	}
+	logInfo("Wrote %s", req.outPath)
+	return exitOK
+}
+
+// The strings table: offset, encoding and text of every string
+func stringsTable(data []byte, opts fdi.AnalysisOptions) sqlTable {
+	defaultEnc := "ascii"
+	if opts.Codepage != nil {
+		defaultEnc = opts.Codepage.Name
+	}
+	t := sqlTable{name: "strings", columns: []sqlColumn{{"offset", "INTEGER"}, {"encoding", "TEXT"}, {"value", "TEXT"}}}
+	for _, s := range fdi.NewAnalyzer(data, opts).Strings() {
+		enc := s.Encoding
+		if enc == "" {
+			enc = defaultEnc
+		}
+		t.rows = append(t.rows, []any{int64(s.Offset), enc, s.Text})
+	}
+	return t
+}
+
+// A table of decoded records named after the schema, with a column per field
+func recordsTable(schema fdi.Schema, records []fdi.Record) sqlTable {
+	name := schema.Name
+	if name == "" || name == "strings" {
+		name = "records"
+	}
+	t := sqlTable{name: name, columns: []sqlColumn{{"record", "INTEGER"}, {"offset", "INTEGER"}}}
+	for _, f := range schema.Fields {
+		typ := "INTEGER"
+		switch f.Type {
+		case "float32", "float64":
+			typ = "REAL"
+		case "string", "bcd":
+			typ = "TEXT"
+		case "bytes":
+			typ = "BLOB"
+		}
+		t.columns = append(t.columns, sqlColumn{f.Name, typ})
+	}
+
+	for _, rec := range records {
+		row := []any{int64(rec.Index), int64(rec.Offset)}
+		for _, f := range rec.Fields {
+			row = append(row, sqlValue(f.Value))
+		}
+		t.rows = append(t.rows, row)
+	}
+	return t
+}
+
+// A decoded field value as one sqliteRecord stores
+func sqlValue(v any) any {
+	switch v := v.(type) {
+	case uint64:
+		if v > math.MaxInt64 {
+			return strconv.FormatUint(v, 10)
+		}
+		return int64(v)
+	case fdi.HexBytes:
+		return []byte(v)
+	}
+	return v
+}
+
+// Write a table as CSV with a header row of column names; blobs as hex
+func writeCSV(w io.Writer, t sqlTable) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		header[i] = c.name
+	}
+	cw.Write(header)
+	for _, row := range t.rows {
+		rec := make([]string, len(row))
+		for i, v := range row {
+			switch v := v.(type) {
+			case []byte:
+				rec[i] = fmt.Sprintf("%X", v)
+			case float64:
+				rec[i] = strconv.FormatFloat(v, 'g', -1, 64)
+			case nil:
+			default:
+				rec[i] = fmt.Sprint(v)
+			}
+		}
+		cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,1520 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"fdi-analyzer/fdi"
+)
+
+// Repeatable string flag
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// Integer flag that accepts decimal or 0x-prefixed hex values; offsets,
+// sizes and counts are never negative, so negative values are refused
+type numberValue int
+
+func (n *numberValue) String() string {
+	return strconv.Itoa(int(*n))
+}
+
+func (n *numberValue) Set(value string) error {
+	v, err := strconv.ParseInt(value, 0, 64)
+	if err != nil || v < 0 {
+		return errors.New("expected a non-negative decimal or 0x-prefixed hex number")
+	}
+	*n = numberValue(v)
+	return nil
+}
+
+// Repeatable number flag, each value decimal or 0x-prefixed hex
+type numberList []int
+
+func (l *numberList) String() string {
+	return fmt.Sprint(*l)
+}
+
+func (l *numberList) Set(value string) error {
+	var n numberValue
+	if err := n.Set(value); err != nil {
+		return err
+	}
+	*l = append(*l, int(n))
+	return nil
+}
+
+// Define a number flag, like flag.Int but also accepting hex
+func numberFlag(name string, value int, usage string) *int {
+	p := new(int)
+	*p = value
+	flag.Var((*numberValue)(p), name, usage)
+	return p
+}
+
+// Byte count flag that also accepts a K, M or G suffix
+type sizeValue int64
+
+func (n *sizeValue) String() string {
+	return strconv.FormatInt(int64(*n), 10)
+}
+
+func (n *sizeValue) Set(value string) error {
+	shift := 0
+	switch strings.ToUpper(value[len(value)-min(len(value), 1):]) {
+	case "K":
+		shift = 10
+	case "M":
+		shift = 20
+	case "G":
+		shift = 30
+	}
+	if shift > 0 {
+		value = value[:len(value)-1]
+	}
+	v, err := strconv.ParseInt(value, 0, 64)
+	if err != nil || v < 0 {
+		return errors.New("expected a byte count such as 65536 or 512M")
+	}
+	*n = sizeValue(v << shift)
+	return nil
+}
+
+// Files larger than this are memory-mapped, or streamed, instead of read
+// into RAM; set from -maxmem in main
+var maxMem int64 = 64 << 20
+
+// Read a file, stdin when the path is "-", or a file inside a zip, ISO 9660 or
+// ARJ archive (stored members only) for archive:member. Files larger than -maxmem are
+// memory-mapped so only the pages an analysis touches are loaded, and stdin
+// that large is spooled to a temporary file to map. Where mapping fails the
+// error is a *tooLargeError naming the file to stream instead. The returned
+// function releases the data and must be called once it is no longer used.
+func readInput(path string) ([]byte, func(), error) {
+	if path == "-" {
+		return readStdin()
+	}
+	if archive, member, ok := archivedPath(path); ok {
+		return readArchived(archive, member)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if info.Size() > maxMem {
+		data, release, err := mapFile(f, info.Size())
+		if err != nil {
+			return nil, nil, &tooLargeError{path: path, size: info.Size(), err: err, release: func() {}}
+		}
+		logVerbose("Mapped %s (%d bytes, over -maxmem) into memory", path, info.Size())
+		return data, release, nil
+	}
+	data, err := io.ReadAll(f)
+	return data, func() {}, err
+}
+
+// Whether stdin is a pipe or file rather than a terminal
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
+
+// Exit codes
+const (
+	exitOK      = 0 // success, or at least one search match
+	exitNoMatch = 1 // a search or scan was requested but found nothing
+	exitUsage   = 2 // invalid flags or flag values
+	exitIOError = 3 // a file could not be read or written
+
+	exitInterrupted = 130 // Ctrl-C stopped an analysis, whose results are partial
+)
+
+// Print the commands, the flags of earlier releases and the exit codes
+func usage() {
+	out := flag.CommandLine.Output()
+	printCommands(out)
+	fmt.Fprintln(out, "\nWithout a command, the flags of earlier releases still work until the next release:")
+	flag.PrintDefaults()
+	printExitCodes(out)
+}
+
+// The exit codes, for every usage message
+func printExitCodes(out io.Writer) {
+	fmt.Fprintln(out, "\nExit codes:")
+	fmt.Fprintln(out, "  0  success, or at least one -search/-isearch/-hexsearch/-regex match")
+	fmt.Fprintln(out, "  1  a search or scan was requested but found nothing")
+	fmt.Fprintln(out, "  2  invalid flags or flag values")
+	fmt.Fprintln(out, "  3  a file could not be read or written")
+	fmt.Fprintln(out, "  130  Ctrl-C stopped an analysis; the results printed are partial")
+}
+
+func main() {
+	os.Exit(run(os.Stdout))
+}
+
+// Parse the command line, run the requested analyses with the report written
+// to w, and return the exit code
+func run(w io.Writer) int {
+	flag.Usage = usage
+
+	// Command line flags
+	var filePaths stringList
+	flag.Var(&filePaths, "file", "Path to the .fdi file, - for stdin, archive:member for a file in a .zip, .iso or .arj (stored members only), or a directory or glob pattern; several files give a batch summary (repeatable)")
+	dirPath := flag.String("dir", "", "Directory of .fdi files to summarize or compare with -find-common-strings")
+	dumpSize := numberFlag("bytes", 256, "Number of bytes to dump")
+	var searchTerms stringList
+	flag.Var(&searchTerms, "search", "Search for text (case sensitive; repeatable or comma-separated)")
+	ignoreCase := flag.Bool("ignorecase", false, "Ignore ASCII case in -search")
+	var isearchTerms stringList
+	flag.Var(&isearchTerms, "isearch", "Search for text ignoring ASCII case (repeatable or comma-separated)")
+	regexSearch := flag.String("regex", "", "Search the printable strings (at least -minstr long) with a regular expression, e.g. '[A-Z]{3,} [A-Z][a-z]+'")
+	utf16Search := flag.Bool("utf16", false, "Search for the -search text encoded as UTF-16LE")
+	noOverlap := flag.Bool("nooverlap", false, "Count only non-overlapping search matches")
+	hexSearch := flag.String("hexsearch", "", "Search for a hex byte sequence (e.g. 00ff00ff, with ?? for any byte)")
+	fuzzy := numberFlag("fuzzy", 0, "Allow up to this many inserted, deleted or changed bytes in -search, -isearch and -hexsearch matches, showing the edits of each")
+	offset := numberFlag("offset", 0, "Starting offset for reading and record analysis")
+	endOffset := numberFlag("end", 0, "End offset (exclusive) for the dump and record analysis; overrides -bytes")
+	bcdScan := flag.Bool("bcd-scan", false, "Scan for packed BCD-encoded numbers")
+	stringTable := flag.Bool("rename-strings-table", false, "Dump fixed-width string fields starting at -offset as an indexed list")
+	fieldWidth := numberFlag("width", 0, "Field width in bytes for -rename-strings-table")
+	tableCount := numberFlag("count", 0, "Maximum number of table entries to dump (0 means until the first empty entry)")
+	whereIs := numberFlag("where-is-offset", -1, "Report everything known about the given offset")
+	recordSize := numberFlag("record-size", 0, "Record size in bytes, if known")
+	flag.Var((*numberValue)(recordSize), "recsize", "Alias for -record-size")
+	recordIndex := numberFlag("record", -1, "Dump record n (records start at -offset, size from -record-size or detected)")
+	templateIndex := numberFlag("annotate-template", -1, "Break record n of the -schema down field by field, with the bytes no field maps shown as unknown")
+	commonStrings := flag.Bool("find-common-strings", false, "Report strings shared across all given files")
+	minFiles := numberFlag("min-files", 0, "Minimum number of files a common string must appear in (0 means all)")
+	mergeJSON := flag.Bool("merge-json", false, "Write the analysis of every given file as one JSON object keyed by path, with the strings common to them (-min-files)")
+	checksumScan := flag.Bool("record-checksum-scan", false, "Look for a per-record checksum field (records start at -offset, size from -record-size)")
+	tableInfer := flag.Bool("strings-table-infer", false, "Locate the most likely fixed-width string table")
+	nameTables := flag.Bool("name-tables", false, "Find every table of names and the record fields that index them")
+	listenAddr := flag.String("listen", "localhost:8080", "Address the serve command listens on (:8080 for every interface)")
+	allowOrigin := flag.String("allow-origin", "", "Origin allowed to call the serve command's endpoints from a browser (Access-Control-Allow-Origin)")
+	pointerMode := flag.Bool("pointers", false, "Look for tables of increasing 16/32-bit offsets into the file")
+	follow := flag.Bool("follow", false, "With -pointers, dump up to -bytes bytes of the region each entry points to")
+	minEntries := numberFlag("min-entries", fdi.MinPointerEntries, "Fewest entries a -pointers table may have")
+	endianMode := flag.String("endian", "auto", "Byte order of the numbers for -decode, -inspect and -findvalue: le, be, or auto to tell it from the records")
+	endianReport := flag.Bool("endianness", false, "Report whether the numbers of the records (of -record-size, or as inferred) are little- or big-endian")
+	strideMode := flag.Bool("infer-stride", false, "Infer the size, start and column types of fixed-size records (over -offset/-end if given)")
+	fieldGuess := numberFlag("field-type-guess", -1, "Guess the type of the field at this intra-record offset (records start at -offset, size from -record-size)")
+	bitField := numberFlag("bitfield", -1, "Show how each bit of the byte at this intra-record offset is set across the records, to find packed fields (records start at -offset, size from -record-size)")
+	minStr := numberFlag("minstr", 4, "Minimum length of detected text strings")
+	flag.Var((*numberValue)(minStr), "min-string-len", "Alias for -minstr")
+	encoding := flag.String("encoding", "", "Encoding of detected strings: a -codepage name, utf16le, or auto to try latin1, cp1252, cp437, cp850 and utf16le and report the cleanest for each string")
+	maxStr := numberFlag("maxstr", 10, "Maximum number of detected text strings to print (0 means unlimited)")
+	flag.Var((*numberValue)(maxStr), "max-strings", "Alias for -maxstr")
+	stringsOut := flag.String("stringsout", "", "Write every detected string to this file as offset<TAB>string lines")
+	stringsOnly := flag.Bool("strings-only", false, "Without a command, print only the text strings, all of them unless -maxstr is given, as the strings command does")
+	xrefTarget := numberFlag("xref", -1, "List the 16/32-bit values anywhere in the file that refer to this offset, absolutely, from a -base or relative to themselves")
+	var xrefBases numberList
+	flag.Var(&xrefBases, "base", "Base offset that -xref values may be relative to, such as a header size (repeatable; a record -offset is always tried)")
+	inspectOffset := numberFlag("inspect", -1, "Show the bytes at this offset as every integer and float type, DOS dates and times, and text in each encoding")
+	ksyPath := flag.String("ksy", "", "Parse the file from -offset with this Kaitai Struct (.ksy) definition and print the tree of values it reads")
+	identify := flag.Bool("identify", false, "Test each version of the format in a -registry against the file and report how well each fits, with the best match when there are several")
+	foreignKeys := flag.Bool("foreign-keys", false, "Decode the tables of a -registry found in the file, and one a -schema describes, and report the integer columns that look like references to another table's records")
+	registryPath := flag.String("registry", "", "YAML registry of the format versions for -identify to tell apart, each a list of its tables, which -foreign-keys decodes")
+	decodeOffset := numberFlag("decode", -1, "Interpret the bytes at this offset as integers and floats")
+	watchMode := flag.Bool("watch", false, "Keep watching the file and, each time it is saved, print a diff against the version before (grouped like -diff, and running -plugin on each version)")
+	watchInterval := flag.Duration("interval", time.Second, "How often -watch looks at the file for changes")
+	diffPath := flag.String("diff", "", "Compare the file byte by byte against another file (grouped by record with -schema, -record-size or -records), or with -export ips, bps or json write the changes as a delta patch")
+	format := flag.String("format", "text", "Output format: text, or json for machine-readable results")
+	jsonOut := flag.Bool("json", false, "Shorthand for -format json")
+	progressMode := flag.String("progress", "auto", "Show how far long analyses have got: auto (a bar on a terminal once a pass takes a second), bar, percent (a line every tenth) or off")
+	quiet := flag.Bool("q", false, "Print only errors on stderr, no progress or notes")
+	var verbosity countFlag
+	flag.Var(&verbosity, "v", "Also print on stderr what each step found or chose; twice (-v -v) for debug output with the time each analysis pass took")
+	entropyMode := flag.Bool("entropy", false, "Print an entropy heatmap and the fill, text, binary and high-entropy regions (over -offset/-end if given)")
+	window := numberFlag("window", fdi.RegionWindow, "Window size in bytes for -entropy, or the shortest identical region for -duplicates (default 32 there)")
+	paddingMode := flag.Bool("padding", false, "List the runs of 0x00, 0xFF and space padding and the alignment of the blocks after them (over -offset/-end if given)")
+	duplicatesMode := flag.Bool("duplicates", false, "Find the regions of the file that occur more than once and the runs of alike blocks they suggest, such as one block per team")
+	similarity := flag.Float64("similarity", 0.6, "Share of equal bytes for -duplicates to take consecutive blocks as alike")
+	minPadding := numberFlag("min-padding", fdi.MinPaddingRun, "Bytes of one padding byte in a row that count as padding, for -padding and to leave out of the delimiters and strings")
+	keepPadding := flag.Bool("keep-padding", false, "Keep padding in the potential record delimiters and the strings")
+	statsMode := flag.Bool("stats", false, "Print checksums, entropy and a byte histogram (over -offset/-end if given), or with -schema or -record-size the range, distinct values and histogram of each field of the records")
+	codepageName := flag.String("codepage", "", "Decode high bytes in the dump and detected strings with this codepage (latin1, cp1252, cp437, cp850)")
+	colorFlag := flag.Bool("color", false, "Colorize the hex dump (ignored when stdout is not a terminal or NO_COLOR is set)")
+	var patchSpecs stringList
+	flag.Var(&patchSpecs, "patch", "Patch bytes as <offset>=<hexbytes> (repeatable, requires -out)")
+	outPath := flag.String("out", "", "Output path for -patch (pass the input path to modify it in place) or -export, or directory for -decompress")
+	writeOffset := numberFlag("write-offset", -1, "Overwrite the bytes at this offset in place with -write-hex or -write-string (the original is kept as <file>.bak)")
+	writeHex := flag.String("write-hex", "", "Hex bytes to write at -write-offset")
+	writeString := flag.String("write-string", "", "Text to write at -write-offset (encoded with -codepage if given, no terminator)")
+	var replaceSpecs stringList
+	flag.Var(&replaceSpecs, "replace", "Replace every occurrence of text, or with a hex: prefix bytes, as old=new after showing them and asking (repeatable; the original is kept as <file>.bak unless -out is given)")
+	confirmYes := flag.Bool("yes", false, "Replace without asking for confirmation")
+	applyPath := flag.String("apply", "", "Apply an IPS, BPS or JSON delta patch file (the original is kept as <file>.bak unless -out is given)")
+	var setSpecs stringList
+	flag.Var(&setSpecs, "set", "Set a -schema field of -record in place as field=value (repeatable; the original is kept as <file>.bak)")
+	fixChecksum := flag.Bool("fixchecksum", false, "Recompute checksums after -patch, -write-offset or -set: those given with -checksum, or those that match in the original file")
+	var checksumSpecs stringList
+	flag.Var(&checksumSpecs, "checksum", "Checksum location for -fixchecksum as <algorithm>@<offset>:<start>-<end>, as printed by -checksum-scan (repeatable)")
+	fileChecksumScan := flag.Bool("checksum-scan", false, "Look for CRC16/CRC32/Adler-32/sum/xor checksums stored at either end of the file")
+	showRecords := flag.Bool("records", false, "Dump the first records when a likely record length is detected")
+	verbose := flag.Bool("verbose", false, "Print every delimiter pattern with all of its offsets and distances")
+	schemaPath := flag.String("schema", "", "Decode records with the field definitions in this YAML file (-offset and -count override its start and count)")
+	sectionsMode := flag.Bool("sections", false, "List the section tags found in the file with their offsets and the gap to the next tag")
+	var magicTags stringList
+	flag.Var(&magicTags, "magic", "Additional 4-byte section tag for -sections, as text or 0x-prefixed hex (repeatable)")
+	findValueFlag := flag.String("findvalue", "", "Find every offset where this number is stored (within -offset/-end), e.g. -findvalue 87")
+	valueTypes := flag.String("type", "", "Comma-separated encodings for -findvalue, e.g. u8,u16le,u32le (default all that can hold the value: "+strings.Join(fdi.ValueTypes, ", ")+")")
+	sessionName := flag.String("session", "", "Track -findvalue candidates across snapshots in this session file (.json is added); narrow them with -changed, -unchanged, -increased, -decreased or another -findvalue")
+	changed := flag.Bool("changed", false, "With -session, keep candidates whose value differs in this file")
+	unchanged := flag.Bool("unchanged", false, "With -session, keep candidates whose value is the same in this file")
+	increased := flag.Bool("increased", false, "With -session, keep candidates whose value grew in this file")
+	decreased := flag.Bool("decreased", false, "With -session, keep candidates whose value shrank in this file")
+	compressionScan := flag.Bool("compression-scan", false, "List zlib and gzip streams, LZSS-compressed text and other high-entropy blocks")
+	dateScan := flag.Bool("date-scan", false, "Look for fields that hold a date in every record of a table: MS-DOS dates, u16 days since 1970, and year, month and day bytes")
+	dateYears := flag.String("years", "", "Years a date found by -date-scan may fall in, as <from>-<to> (default 1980-2030)")
+	decompress := flag.Bool("decompress", false, "Write each zlib, gzip or lzss block found to <file>.0x<offset>.bin, in the -out directory if given")
+	carveMode := flag.Bool("carve", false, "Split the file at pointer tables, record runs, section tags and entropy changes, writing each section and a manifest.json to -outdir")
+	outDir := flag.String("outdir", "", "Directory for -carve (default <file>.sections) and -decompress (default -out, or next to the file)")
+	decompressAt := flag.String("decompress-at", "", "Analyze the decompressed contents of the block at [<format>@]<offset>[:<end>] instead of the file; format is one of zlib, gzip, lzss, rle")
+	var annotateSpecs stringList
+	flag.Var(&annotateSpecs, "annotate", "Annotate a byte or range as <offset>[:<last>]=<note>, saved in <file>.notes.json and shown in dumps and diffs; an empty note removes it (repeatable)")
+	fingerprintMode := flag.Bool("fingerprint", false, "Identify the file format and version from its signature and show the defaults it selects")
+	signaturesPath := flag.String("signatures", "", "YAML file of extra signatures, such as the .fdi layouts of different game versions, checked before the built-in ones")
+	listNotes := flag.Bool("notes", false, "List the annotations saved for the file")
+	var highlightSpecs stringList
+	flag.Var(&highlightSpecs, "highlight", "Mark offsets and ranges in the hex dumps, e.g. 0x12,0x400-0x40F (repeatable)")
+	highlightFrom := flag.String("highlight-from", "", "Mark the offsets of the results in a JSON file written with -json, or - for stdin, in the hex dumps")
+	aroundBytes := numberFlag("around", 0, "With -highlight or -highlight-from, dump only the rows within this many bytes of each highlighted range")
+	exportFormat := flag.String("export", "", "Export the strings, and the records of -schema, as csv (to -out or stdout) or sqlite (to -out), the whole file with the records of -schema as an editable json document for build, or the -schema as a Kaitai Struct definition with ksy")
+	inPath := flag.String("in", "", "The JSON document to build the file from, as written by export -export json")
+	reportPath := flag.String("report", "", "Write a standalone HTML report to this file: summary, entropy heatmap, sections, strings and a hex view highlighting the annotations and -search/-isearch/-hexsearch hits")
+	pluginCommand := flag.String("plugin", "", "Run this program (with any arguments) as a custom analysis pass: it reads the context as JSON on stdin and writes its findings as JSON")
+	browseMode := flag.Bool("browse", false, "Browse the file interactively from -offset: paging, goto, incremental search and a data inspector")
+	noCache := flag.Bool("no-cache", false, "Run every analysis pass again rather than reusing the results cached for an unchanged file")
+	cacheDir := flag.String("cache-dir", "", "Keep the cached analysis results in this directory rather than fdi-analyzer in the user's cache directory")
+	flag.Var((*sizeValue)(&maxMem), "maxmem", "Largest file to read into memory, e.g. 512M; larger files are memory-mapped, or where that fails streamed in chunks of this size for the dump, searches and strings")
+	fastMode := flag.Bool("fast", false, "Look only for 2- and 4-byte record delimiters, in the first MiB")
+	deepMode := flag.Bool("deep", false, "Also look for 3- and 6-byte record delimiters, repeating up to 4096 bytes apart")
+	limit := numberFlag("limit", 0, "Maximum number of delimiter patterns, and of offsets and distances per pattern, to print (default 5 patterns, 3 offsets)")
+
+	// A subcommand takes only its own flags; without one, every flag works
+	// as it did before subcommands, with a note that this is going away
+	args := os.Args[1:]
+	var cmd *subcommand
+	if len(args) > 0 && args[0] == "help" {
+		if len(args) > 1 {
+			if c, ok := lookupSubcommand(args[1]); ok {
+				c.printUsage(w)
+				return exitOK
+			}
+		}
+		flag.CommandLine.SetOutput(w)
+		usage()
+		return exitOK
+	}
+	if len(args) > 0 {
+		if c, ok := lookupSubcommand(args[0]); ok {
+			cmd = c
+			flag.Usage = func() {
+				c.printUsage(flag.CommandLine.Output())
+				printExitCodes(flag.CommandLine.Output())
+			}
+		}
+	}
+	var positional []string
+	legacy := false // flags without a command
+	if cmd != nil {
+		positional = parseInterspersed(args[1:])
+		if names := cmd.foreignFlags(); len(names) > 0 {
+			logError("%s %s does not take %s; see %s help %s", progName(), cmd.name, strings.Join(names, ", "), progName(), cmd.name)
+			return exitUsage
+		}
+	} else {
+		flag.Parse()
+		positional = flag.Args()
+		if *stringsOnly {
+			cmd, _ = lookupSubcommand("strings")
+		}
+		legacy = len(args) > 0
+	}
+
+	colorOutput = *colorFlag && colorSupported()
+
+	switch *format {
+	case "text":
+	case "json":
+		outputJSON = true
+	default:
+		logError("Unknown output format %q (supported: text, json)", *format)
+		return exitUsage
+	}
+	if *jsonOut || *mergeJSON {
+		outputJSON = true
+	}
+
+	if *quiet && verbosity > 0 {
+		logError("Use only one of -q and -v")
+		return exitUsage
+	}
+	setupLogging(os.Stderr, *quiet, int(verbosity), outputJSON)
+	if !setupProgress(*progressMode, *quiet) {
+		logError("Unknown -progress %q (supported: auto, bar, percent, off)", *progressMode)
+		return exitUsage
+	}
+	if legacy {
+		logInfo("Note: flags without a command are deprecated and stop working in the next release; run %s help for the commands", progName())
+	}
+	analysisCache = openCache(*noCache, *cacheDir)
+
+	if *codepageName != "" {
+		cp, ok := fdi.LookupCodepage(*codepageName)
+		if !ok {
+			logError("Unknown codepage %q (supported: latin1, cp1252, cp437, cp850)", *codepageName)
+			return exitUsage
+		}
+		dumpCodepage = cp
+	}
+	analysisOpts := fdi.AnalysisOptions{MinString: *minStr, Codepage: dumpCodepage, MinPadding: *minPadding, KeepPadding: *keepPadding}
+	switch {
+	case *fastMode && *deepMode:
+		logError("Use only one of -fast and -deep")
+		return exitUsage
+	case *fastMode:
+		analysisOpts.Patterns = fdi.FastPatternSearch
+	case *deepMode:
+		analysisOpts.Patterns = fdi.DeepPatternSearch
+	}
+	if !setEncoding(&analysisOpts, *encoding) {
+		logError("Unknown encoding %q (supported: ascii, latin1, cp1252, cp437, cp850, utf16le, auto)", *encoding)
+		return exitUsage
+	}
+
+	if *minStr < 1 {
+		logError("Invalid -minstr %d: strings are at least 1 character long", *minStr)
+		return exitUsage
+	}
+
+	switch *endianMode {
+	case "auto", fdi.LittleEndian, fdi.BigEndian:
+	default:
+		logError("Unknown -endian %q (supported: le, be, auto)", *endianMode)
+		return exitUsage
+	}
+
+	var regex *regexp.Regexp
+	if *regexSearch != "" {
+		var err error
+		if regex, err = regexp.Compile(*regexSearch); err != nil {
+			logError("Invalid -regex: %v", err)
+			return exitUsage
+		}
+	}
+
+	var err error
+	if highlights, err = loadHighlights(highlightSpecs, *highlightFrom); err != nil {
+		logError("Invalid highlights: %v", err)
+		return exitUsage
+	}
+
+	files, err := expandFileArgs(filePaths)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitIOError
+	}
+	if *dirPath != "" {
+		dirFiles, err := listFDIFiles(*dirPath)
+		if err != nil {
+			logError("Error reading directory: %v", err)
+			return exitIOError
+		}
+		files = append(files, dirFiles...)
+	}
+
+	// The decode command decodes the table its -schema describes
+	var layout *fdi.Layout
+	if cmd != nil && cmd.name == "decode" && *ksyPath == "" && !*identify && !*foreignKeys {
+		if *schemaPath == "" {
+			logError("%s decode needs a -schema describing the table's records", progName())
+			return exitUsage
+		}
+		l, code := loadLayout(*schemaPath)
+		if code != exitOK {
+			return code
+		}
+		layout = &l
+	}
+
+	// The plugin command names its program before the file
+	// So does the plugin command its program
+	if cmd != nil && cmd.name == "plugin" && *pluginCommand == "" {
+		if len(positional) == 0 {
+			logError("%s plugin needs the program to run", progName())
+			return exitUsage
+		}
+		*pluginCommand, positional = positional[0], positional[1:]
+	}
+
+	args, err = expandFileArgs(positional)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitIOError
+	}
+	files = append(files, args...)
+
+	// Building writes a new file from a document rather than reading one
+	if cmd != nil && cmd.name == "build" {
+		if *inPath == "" && len(files) == 1 {
+			*inPath, files = files[0], nil
+		}
+		if len(files) > 0 {
+			logError("%s build reads the document given with -in, not %s", progName(), files[0])
+			return exitUsage
+		}
+		return buildDocument(w, *schemaPath, *inPath, *outPath)
+	}
+
+	// With no file given, read piped input from stdin
+	if len(files) == 0 && stdinIsPiped() {
+		files = append(files, "-")
+	}
+
+	if len(files) == 0 {
+		logError("Please specify a file path with -file flag")
+		flag.Usage()
+		return exitUsage
+	}
+
+	// What a subcommand needs beyond its own flags
+	if cmd != nil {
+		if cmd.missing() {
+			logError("%s %s needs one of -%s", progName(), cmd.name, strings.Join(cmd.needs, ", -"))
+			return exitUsage
+		}
+		switch cmd.name {
+		case "diff":
+			if *diffPath == "" && len(files) == 2 {
+				*diffPath, files = files[1], files[:1]
+			}
+			if *diffPath == "" && !*watchMode {
+				logError("%s diff needs a second file to compare against, or -watch", progName())
+				return exitUsage
+			}
+		case "strings":
+			if !isSet("maxstr") && !isSet("max-strings") {
+				*maxStr = 0
+			}
+		case "export":
+			if *exportFormat == "" && !*carveMode && !*decompress && *reportPath == "" {
+				*exportFormat = "csv"
+				if *schemaPath != "" && strings.EqualFold(filepath.Ext(*outPath), ".json") {
+					*exportFormat = "json"
+				}
+			}
+		}
+	}
+
+	// Serving works on the whole file set, reading each file per request
+	if cmd != nil && cmd.name == "serve" {
+		var layout *fdi.Layout
+		if *schemaPath != "" {
+			l, code := loadLayout(*schemaPath)
+			if code != exitOK {
+				return code
+			}
+			layout = &l
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return serveFiles(w, serveRequest{
+			files:       files,
+			listen:      *listenAddr,
+			allowOrigin: *allowOrigin,
+			analysis:    analysisOpts,
+			layout:      layout,
+			stop:        ctx.Done(),
+		})
+	}
+	analysisOpts.Progress = progress
+
+	// Undo and redo work on the file and its journal rather than its contents
+	if cmd != nil && (cmd.name == "undo" || cmd.name == "redo") {
+		if len(files) > 1 {
+			logError("%s %s takes one file", progName(), cmd.name)
+			return exitUsage
+		}
+		return undoEdit(w, files[0], cmd.name == "redo")
+	}
+
+	// Cross-file string comparison works on the whole file set
+	if *commonStrings {
+		return findCommonStrings(w, files, *minFiles)
+	}
+
+	// So does merging their analyses into one JSON object
+	if *mergeJSON {
+		interrupted := interruptible()
+		code := mergeAnalyses(w, files, analysisOpts, *minFiles)
+		if interrupted() {
+			return exitInterrupted
+		}
+		return code
+	}
+
+	// The other modes work on one file; several get a summary of each
+	if len(files) > 1 {
+		interrupted := interruptible()
+		code := summarizeFiles(w, files, analysisOpts)
+		if interrupted() {
+			return exitInterrupted
+		}
+		return code
+	}
+
+	only := ""
+	if cmd != nil {
+		only = cmd.report
+	}
+
+	// Read the file, or stream the report through it when it is too large
+	done := logPass("read")
+	data, release, err := readInput(files[0])
+	done()
+	var tooLarge *tooLargeError
+	if errors.As(err, &tooLarge) {
+		defer tooLarge.release()
+		logVerbose("Streaming %s: %v", files[0], tooLarge)
+		if !checkStreamable(w, tooLarge) {
+			return exitUsage
+		}
+		size := *dumpSize
+		if *endOffset > *offset {
+			size = *endOffset - *offset
+		}
+		return streamReport(w, tooLarge, reportRequest{
+			dump:       fdi.DumpOptions{Offset: *offset, Size: size, Codepage: dumpCodepage, Color: colorOutput},
+			terms:      splitTerms(searchTerms),
+			iterms:     splitTerms(isearchTerms),
+			regex:      regex,
+			searchOpts: fdi.SearchOptions{IgnoreCase: *ignoreCase, UTF16: *utf16Search, NoOverlap: *noOverlap, MaxEdits: *fuzzy},
+			hexSearch:  *hexSearch,
+			analysis:   analysisOpts,
+			only:       only,
+		}, *maxStr, *stringsOut)
+	}
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	defer release()
+
+	// Work on the contents of a compressed block from here on
+	if *decompressAt != "" {
+		if *writeOffset >= 0 || len(setSpecs) > 0 || len(replaceSpecs) > 0 && *outPath == "" || (*fixChecksum && len(patchSpecs) == 0) {
+			logError("Cannot edit a decompressed block in place; use -patch with -out instead")
+			return exitUsage
+		}
+		var code int
+		if data, code = openCompressed(w, data, *decompressAt); code != exitOK {
+			return code
+		}
+	}
+
+	// Identify the format, and take the parameters it suggests for the
+	// record layout and strings where no flag chose them
+	sigs, code := loadSignatures(w, *signaturesPath)
+	if code != exitOK {
+		return code
+	}
+	done = logPass("signatures")
+	formats := fdi.Identify(data, sigs)
+	done()
+	for _, f := range formats {
+		logVerbose("Format: %s", formatName(f))
+	}
+	if *fingerprintMode {
+		return printFingerprints(w, formats)
+	}
+	recordStart := *offset
+	if len(formats) > 0 {
+		explicit := make(map[string]bool)
+		flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+		p := formats[0].Profile
+		if p.RecordSize > 0 && !explicit["record-size"] && !explicit["recsize"] {
+			*recordSize = p.RecordSize
+			if !explicit["offset"] {
+				recordStart = p.RecordStart
+			}
+			logVerbose("Taking %d-byte records from 0x%X from the format", *recordSize, recordStart)
+		}
+		if p.MinString > 0 && !explicit["minstr"] && !explicit["min-string-len"] {
+			analysisOpts.MinString = p.MinString
+			logVerbose("Taking strings of at least %d characters from the format", p.MinString)
+		}
+		if p.Encoding != "" && !explicit["encoding"] {
+			setEncoding(&analysisOpts, p.Encoding)
+			logVerbose("Taking the %s encoding from the format", p.Encoding)
+		}
+	}
+
+	// Annotations refer to the file's own offsets, not a decompressed block's
+	if files[0] != "-" && !isArchived(files[0]) && *decompressAt == "" {
+		if annotations, err = loadAnnotations(files[0]); err != nil {
+			logError("Error reading annotations: %v", err)
+			return exitIOError
+		}
+	}
+	if len(annotateSpecs) > 0 {
+		return annotateFile(w, files[0], len(data), annotateSpecs)
+	}
+	if *listNotes {
+		return printAnnotations(w, files[0])
+	}
+
+	// Parse with a Kaitai Struct definition instead of printing an analysis
+	if *ksyPath != "" {
+		return decodeKaitai(w, data, *ksyPath, *offset)
+	}
+
+	// Identify the version of the format instead of printing an analysis
+	if *identify {
+		return identifyVersion(w, data, *registryPath)
+	}
+
+	// Find the foreign keys between the decoded tables instead of printing an analysis
+	if *foreignKeys {
+		return printForeignKeys(w, data, *registryPath, *schemaPath, *offset, *tableCount)
+	}
+
+	// Decode the table of the schema instead of printing an analysis
+	if layout != nil {
+		start := layout.Schema.Start
+		if isSet("offset") {
+			start = *offset
+		}
+		count := layout.Schema.Count
+		if *tableCount > 0 {
+			count = *tableCount
+		}
+		return decodeLayout(w, data, layoutRequest{
+			layout:   *layout,
+			start:    start,
+			given:    isSet("offset") || layout.Schema.Start > 0,
+			count:    count,
+			exported: *exportFormat != "",
+			export: exportRequest{
+				format:   *exportFormat,
+				outPath:  *outPath,
+				analysis: analysisOpts,
+			},
+		})
+	}
+
+	// Write the changes against another file as a delta patch instead of exporting tables
+	if *diffPath != "" && *exportFormat != "" {
+		return writeDeltaPatch(w, data, *diffPath, *exportFormat, *outPath)
+	}
+
+	// Export tables instead of printing an analysis
+	if *exportFormat != "" {
+		return exportData(w, data, exportRequest{
+			format:     *exportFormat,
+			outPath:    *outPath,
+			schemaPath: *schemaPath,
+			start:      *offset,
+			count:      *tableCount,
+			analysis:   analysisOpts,
+		})
+	}
+
+	pluginFile := files[0]
+	if *decompressAt != "" {
+		pluginFile = "" // the plugin gets the block's contents
+	}
+	pluginCtx := pluginContext{
+		File:        pluginFile,
+		Source:      files[0],
+		Offset:      *offset,
+		End:         analysisEnd(data, *endOffset),
+		RecordSize:  *recordSize,
+		RecordStart: recordStart,
+		Formats:     formats,
+		Annotations: annotations,
+	}
+
+	// Diff each saved version against the one before instead of printing an analysis
+	if *watchMode {
+		if *decompressAt != "" {
+			logError("-watch compares the whole file; it cannot be combined with -decompress-at")
+			return exitUsage
+		}
+		layout, code := diffLayout(w, data, *schemaPath, recordStart, *recordSize, *showRecords)
+		if code != exitOK {
+			return code
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return watchFile(w, data, watchRequest{
+			path:     files[0],
+			interval: *watchInterval,
+			layout:   layout,
+			plugin:   *pluginCommand,
+			context:  pluginCtx,
+			stop:     ctx.Done(),
+		})
+	}
+
+	// Run a custom pass instead of printing an analysis
+	if *pluginCommand != "" {
+		return runPlugin(w, data, *pluginCommand, pluginCtx)
+	}
+
+	// Write an HTML report instead of printing an analysis
+	if *reportPath != "" {
+		return writeHTMLReport(w, data, htmlRequest{
+			path:       *reportPath,
+			file:       files[0],
+			terms:      splitTerms(searchTerms),
+			iterms:     splitTerms(isearchTerms),
+			hexSearch:  *hexSearch,
+			searchOpts: fdi.SearchOptions{IgnoreCase: *ignoreCase, UTF16: *utf16Search, NoOverlap: *noOverlap, MaxEdits: *fuzzy},
+			analysis:   analysisOpts,
+			formats:    formats,
+		})
+	}
+
+	// Browse interactively instead of printing an analysis
+	if *browseMode {
+		return browseFile(w, data, *offset)
+	}
+
+	if !outputJSON {
+		fmt.Fprintf(w, "File size: %d bytes\n", len(data))
+		if len(formats) > 0 {
+			fmt.Fprintf(w, "Format: %s\n", formatName(formats[0]))
+		}
+	}
+
+	// Dump around the highlighted offsets instead of the general analysis
+	if *aroundBytes > 0 {
+		return dumpNeighborhoods(w, data, *aroundBytes)
+	}
+
+	// Dump a fixed-width string table instead of the general analysis
+	if *stringTable {
+		return dumpStringTable(w, data, *offset, *fieldWidth, *tableCount)
+	}
+
+	// Locate a string table instead of the general analysis
+	if *tableInfer {
+		return inferStringTable(w, data)
+	}
+
+	// Find name tables and what refers to them instead of the general analysis
+	if *nameTables {
+		return findNameTables(w, data)
+	}
+
+	fix := checksumFix{enabled: *fixChecksum, specs: checksumSpecs, start: recordStart, recordSize: *recordSize}
+
+	// Write patched bytes instead of the general analysis
+	if len(patchSpecs) > 0 {
+		return patchFile(w, data, files[0], patchSpecs, *outPath, fix)
+	}
+
+	// Replace text or bytes throughout the file instead of the general analysis
+	if len(replaceSpecs) > 0 {
+		req := replaceRequest{specs: replaceSpecs, ignoreCase: *ignoreCase, yes: *confirmYes, outPath: *outPath}
+		if files[0] != "-" {
+			req.answer = os.Stdin
+		}
+		return replaceAll(w, data, files[0], req)
+	}
+
+	// Apply a delta patch file instead of the general analysis
+	if *applyPath != "" {
+		return applyDeltaPatch(w, data, files[0], *applyPath, *outPath)
+	}
+
+	// Edit the file in place instead of the general analysis
+	if *writeOffset >= 0 || *writeHex != "" || *writeString != "" || len(setSpecs) > 0 || (*fixChecksum && len(patchSpecs) == 0) {
+		return editFile(w, data, files[0], editRequest{
+			offset:     *writeOffset,
+			hex:        *writeHex,
+			text:       *writeString,
+			sets:       setSpecs,
+			schemaPath: *schemaPath,
+			record:     *recordIndex,
+			start:      *offset,
+			fix:        fix,
+		})
+	}
+
+	// Summarize each field of the records, or fingerprint the file or a
+	// range, instead of the general analysis
+	if *statsMode {
+		if *schemaPath != "" || isSet("record-size") || isSet("recsize") {
+			return printColumnStats(w, data, *schemaPath, recordStart, *recordSize, *tableCount)
+		}
+		return printStats(w, data, *offset, *endOffset)
+	}
+
+	// Find repeated blocks instead of the general analysis
+	if *duplicatesMode {
+		opts := fdi.DuplicateOptions{Similarity: *similarity, Progress: progress}
+		if isSet("window") {
+			opts.Window = *window
+		}
+		interrupted := interruptible()
+		code := printDuplicates(w, data, opts)
+		if interrupted() {
+			return exitInterrupted
+		}
+		return code
+	}
+
+	// List the padding instead of the general analysis
+	if *paddingMode {
+		return printPadding(w, data, *offset, *endOffset, *minPadding)
+	}
+
+	// Map entropy by window instead of the general analysis
+	if *entropyMode {
+		return printEntropy(w, data, *offset, *endOffset, *window)
+	}
+
+	// Compare against another file instead of the general analysis
+	if *diffPath != "" {
+		layout, code := diffLayout(w, data, *schemaPath, recordStart, *recordSize, *showRecords)
+		if code != exitOK {
+			return code
+		}
+		return diffFiles(w, data, *diffPath, layout)
+	}
+
+	// Decode the numeric values at an offset instead of the general analysis
+	if *decodeOffset >= 0 {
+		return decodeAt(w, data, *decodeOffset, fileEndian(data, *endianMode, recordStart, *recordSize))
+	}
+	if *inspectOffset >= 0 {
+		return inspectAt(w, data, *inspectOffset, fileEndian(data, *endianMode, recordStart, *recordSize))
+	}
+
+	valueLimit := findValueLimit
+	if *limit > 0 {
+		valueLimit = *limit
+	}
+	if *verbose {
+		valueLimit = 0
+	}
+
+	// Narrow down value candidates across snapshots instead of the general analysis
+	if *sessionName != "" {
+		req := sessionRequest{
+			name:      *sessionName,
+			file:      files[0],
+			value:     *findValueFlag,
+			types:     *valueTypes,
+			endian:    valueEndian(data, *valueTypes, *endianMode, recordStart, *recordSize),
+			start:     *offset,
+			end:       analysisEnd(data, *endOffset),
+			showLimit: valueLimit,
+		}
+		for _, f := range []struct {
+			set  bool
+			name string
+		}{{*changed, fdi.FilterChanged}, {*unchanged, fdi.FilterUnchanged}, {*increased, fdi.FilterIncreased}, {*decreased, fdi.FilterDecreased}} {
+			if !f.set {
+				continue
+			}
+			if req.filter != "" {
+				logError("Use only one of -changed, -unchanged, -increased and -decreased")
+				return exitUsage
+			}
+			req.filter = f.name
+		}
+		return runSession(w, data, req)
+	}
+
+	// Find where a number is stored instead of the general analysis
+	if *findValueFlag != "" {
+		endian := valueEndian(data, *valueTypes, *endianMode, recordStart, *recordSize)
+		return findValue(w, data, *findValueFlag, *valueTypes, endian, *offset, analysisEnd(data, *endOffset), valueLimit)
+	}
+
+	// Find what refers to an offset instead of the general analysis
+	if *xrefTarget >= 0 {
+		bases := xrefBases
+		if recordStart > 0 {
+			bases = append(bases, recordStart)
+		}
+		return printXRefs(w, data, *xrefTarget, bases, valueLimit)
+	}
+
+	// Break a record of the schema down instead of the general analysis
+	if *templateIndex >= 0 {
+		if *schemaPath == "" {
+			logError("-annotate-template needs a -schema of the records")
+			return exitUsage
+		}
+		return printBreakdown(w, data, *schemaPath, *offset, *templateIndex)
+	}
+
+	// Dump a single record instead of the general analysis
+	if *recordIndex >= 0 {
+		return dumpRecord(w, data, *recordIndex, *recordSize, recordStart)
+	}
+
+	// Classify a single offset instead of the general analysis
+	if *whereIs >= 0 {
+		return whereIsOffset(w, data, *whereIs, *recordSize)
+	}
+
+	// Locate compressed blocks instead of the general analysis
+	if *compressionScan {
+		return scanCompression(w, data)
+	}
+	if *decompress {
+		dir := *outDir
+		if dir == "" {
+			dir = *outPath
+		}
+		return extractCompressed(w, data, files[0], dir)
+	}
+	if *carveMode {
+		return carveFile(w, data, files[0], *outDir)
+	}
+
+	// Look for date fields instead of the general analysis
+	if *dateScan {
+		return scanDates(w, data, *dateYears, *verbose)
+	}
+
+	// Look for stored checksums instead of the general analysis
+	if *fileChecksumScan {
+		return scanFileChecksums(w, data)
+	}
+
+	// Search for a per-record checksum field instead of the general analysis
+	if *checksumScan {
+		return scanRecordChecksums(w, data, recordStart, *recordSize)
+	}
+
+	// Look for offset tables instead of the general analysis
+	if *pointerMode {
+		return printPointerTables(w, data, *minEntries, *follow, *dumpSize, valueLimit)
+	}
+
+	// Tell the byte order instead of the general analysis
+	if *endianReport {
+		return printEndianness(w, data, recordStart, *recordSize)
+	}
+
+	// Infer the record layout instead of the general analysis
+	if *strideMode {
+		return inferStride(w, data, *offset, *endOffset)
+	}
+
+	// Guess a single field's type instead of the general analysis
+	if *fieldGuess >= 0 {
+		return guessFieldType(w, data, recordStart, *recordSize, *fieldGuess)
+	}
+
+	// Break a byte down into bits instead of the general analysis
+	if *bitField >= 0 {
+		return analyzeBits(w, data, recordStart, *recordSize, *bitField)
+	}
+
+	// Decode records with a schema instead of the general analysis
+	if *schemaPath != "" {
+		return decodeSchema(w, data, *schemaPath, *offset, *tableCount)
+	}
+
+	// Reconstruct the section layout instead of the general analysis
+	if *sectionsMode {
+		return printSections(w, data, magicTags)
+	}
+
+	// Basic file analysis
+	if *offset >= len(data) {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+	size := *dumpSize
+	if *endOffset > 0 {
+		if *endOffset <= *offset {
+			logError("End offset must be greater than the start offset")
+			return exitUsage
+		}
+		size = *endOffset - *offset
+	}
+	req := reportRequest{
+		dump:       fdi.DumpOptions{Offset: *offset, Size: size, Codepage: dumpCodepage, Annotations: annotations},
+		terms:      splitTerms(searchTerms),
+		iterms:     splitTerms(isearchTerms),
+		regex:      regex,
+		searchOpts: fdi.SearchOptions{IgnoreCase: *ignoreCase, UTF16: *utf16Search, NoOverlap: *noOverlap, MaxEdits: *fuzzy},
+		hexSearch:  *hexSearch,
+		bcd:        *bcdScan,
+		start:      *offset,
+		end:        analysisEnd(data, *endOffset),
+		analysis:   analysisOpts,
+		formats:    formats,
+	}
+	req.only = only
+	searched := len(req.terms) > 0 || len(req.iterms) > 0 || req.hexSearch != "" || req.regex != nil
+
+	interrupted := interruptible()
+	defer interrupted()
+	var matches int
+	if outputJSON {
+		report, err := buildReport(data, req)
+		if err != nil {
+			logError("Invalid hex pattern: %v", err)
+			return exitUsage
+		}
+		if code := writeJSON(w, report); code != exitOK {
+			return code
+		}
+		for _, s := range report.Searches {
+			matches += len(s.Matches)
+		}
+		if report.Regex != nil {
+			matches += len(report.Regex.Matches)
+		}
+	} else {
+		patternLimit, offsetLimit := 5, 3
+		if *limit > 0 {
+			patternLimit, offsetLimit = *limit, *limit
+		}
+		if *verbose {
+			patternLimit, offsetLimit = 0, 0
+		}
+		limits := recordLimits{patterns: patternLimit, offsets: offsetLimit, strings: *maxStr}
+
+		var err error
+		if matches, err = printReport(w, data, req, limits, *showRecords); err != nil {
+			return exitUsage
+		}
+	}
+
+	// Write the full string list to a file if requested
+	if *stringsOut != "" {
+		strs := fdi.NewAnalyzer(data, analysisOpts).Strings()
+		if err := writeStrings(*stringsOut, strs); err != nil {
+			logError("Error writing strings: %v", err)
+			return exitIOError
+		}
+		logInfo("Wrote %d strings to %s", len(strs), *stringsOut)
+	}
+
+	if interrupted() {
+		return exitInterrupted
+	}
+	if searched && matches == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// Print the default text report: the dump, any searches, the BCD scan and the
+// record analysis. Returns the number of search matches, or an error if the
+// hex search pattern is invalid.
+func printReport(w io.Writer, data []byte, req reportRequest, limits recordLimits, showRecords bool) (int, error) {
+	if req.has("dump") {
+		done := logPass("dump")
+		printFileHeader(w, data, req.dump.Size, req.dump.Offset)
+		done()
+	}
+
+	// Search for text if requested
+	matches := 0
+	if len(req.terms) > 0 || len(req.iterms) > 0 {
+		done := logPass("search")
+		matches += searchForTerms(w, inMemory(data), req.terms, req.iterms, req.searchOpts)
+		done()
+	}
+
+	// Search for a byte sequence if requested
+	if req.hexSearch != "" {
+		done := logPass("hexsearch")
+		found, err := searchForHex(w, inMemory(data), req.hexSearch, req.hexOpts())
+		done()
+		if err != nil {
+			return 0, err
+		}
+		matches += found
+	}
+
+	// Search the printable strings with a regular expression if requested
+	if req.regex != nil {
+		done := logPass("regex")
+		matches += searchForRegexp(w, req.regex, fdi.NewAnalyzer(data, req.analysis).SearchRegexp(req.regex))
+		done()
+	}
+
+	// Look for BCD-encoded numbers if requested
+	if req.bcd {
+		done := logPass("bcd")
+		scanBCD(w, data)
+		done()
+	}
+
+	// Try to detect record structure
+	if req.has("records") {
+		done := logPass("records")
+		detectRecords(w, data, req.start, req.end, req.analysis, limits, showRecords)
+		done()
+	}
+
+	// List the strings alone for the strings subcommand
+	if req.only == "strings" {
+		fmt.Fprintln(w, "\n=== Text Strings ===")
+		done := logPass("strings")
+		strs := rangeStrings(data, req.start, req.end, req.analysis)
+		done()
+		printStrings(w, strs, limits.strings)
+		fmt.Fprintf(w, "\n%d strings\n", len(strs))
+	}
+	return matches, nil
+}
+
+// The strings of data[start:end], with offsets into data
+func rangeStrings(data []byte, start int, end int, opts fdi.AnalysisOptions) []fdi.FoundString {
+	strs := fdi.NewAnalyzer(data[start:end], opts).Strings()
+	for i := range strs {
+		strs[i].Offset += start
+	}
+	return strs
+}
+
+// Set the string encoding of opts from an -encoding name; false if unknown
+func setEncoding(opts *fdi.AnalysisOptions, name string) bool {
+	switch name = strings.ToLower(name); name {
+	case "", "ascii":
+	case fdi.EncodingUTF16LE, "utf-16le", fdi.EncodingAuto:
+		opts.Encoding = strings.ReplaceAll(name, "-", "")
+	default:
+		cp, ok := fdi.LookupCodepage(name)
+		if !ok {
+			return false
+		}
+		opts.Codepage = cp
+	}
+	return true
+}
+
+// Codepage for the dump's ASCII column and detected strings; set from -codepage in main
+var dumpCodepage *fdi.Codepage
+
+// Print the file header in hex and ASCII
+func printFileHeader(w io.Writer, data []byte, size int, offset int) {
+	printDump(w, data, size, offset, false)
+}
+
+// Print a hex and ASCII dump. With skipZeros the ASCII column leaves out
+// 0x00 bytes so UTF-16LE text reads naturally.
+func printDump(w io.Writer, data []byte, size int, offset int, skipZeros bool) {
+	printWindow(w, data, 0, size, offset, skipZeros)
+}
+
+// Print a dump of a window of the file whose first byte is at base
+func printWindow(w io.Writer, window []byte, base int, size int, offset int, skipZeros bool) {
+	if offset-base >= len(window) {
+		fmt.Fprintln(w, "Offset is beyond file size")
+		return
+	}
+
+	fmt.Fprintf(w, "\n=== File Dump (Offset: %d) ===\n", offset)
+	fdi.WriteDump(w, window, fdi.DumpOptions{
+		Base:        base,
+		Offset:      offset,
+		Size:        size,
+		SkipZeros:   skipZeros,
+		Codepage:    dumpCodepage,
+		Color:       colorOutput,
+		Annotations: annotations,
+		Highlights:  highlights,
+	})
+}
+
+// Split comma-separated search terms, dropping empty ones
+func splitTerms(values []string) []string {
+	var terms []string
+	for _, v := range values {
+		for _, term := range strings.Split(v, ",") {
+			if term != "" {
+				terms = append(terms, term)
+			}
+		}
+	}
+	return terms
+}
+
+// Search runs a search over the file, whether it is in memory or streamed
+type searchFunc func(pattern []byte, opts fdi.SearchOptions) ([]fdi.SearchResult, error)
+
+// Search data in memory
+func inMemory(data []byte) searchFunc {
+	return func(pattern []byte, opts fdi.SearchOptions) ([]fdi.SearchResult, error) {
+		return fdi.Search(data, pattern, opts)
+	}
+}
+
+// Search for each term in turn, then each case-insensitive iterm, and
+// summarize the ones that were not found. Returns the total number of matches.
+func searchForTerms(w io.Writer, search searchFunc, terms []string, iterms []string, opts fdi.SearchOptions) int {
+	var missing []string
+	matches := 0
+	iopts := opts
+	iopts.IgnoreCase = true
+	for i, term := range append(terms[:len(terms):len(terms)], iterms...) {
+		termOpts := opts
+		if i >= len(terms) {
+			termOpts = iopts
+		}
+		n := searchForText(w, search, term, termOpts)
+		if n == 0 {
+			missing = append(missing, term)
+		}
+		matches += n
+	}
+
+	total := len(terms) + len(iterms)
+	if total < 2 {
+		return matches
+	}
+	fmt.Fprintf(w, "\n=== Search Summary: %d of %d terms found ===\n", total-len(missing), total)
+	if len(missing) > 0 {
+		fmt.Fprintf(w, "Not found: %s\n", strings.Join(missing, ", "))
+	}
+	return matches
+}
+
+// Search for a string in the file. Returns the number of matches.
+func searchForText(w io.Writer, search searchFunc, searchStr string, opts fdi.SearchOptions) int {
+	if opts.IgnoreCase {
+		fmt.Fprintf(w, "\n=== Searching for: %s (ignoring case) ===\n", searchStr)
+	} else {
+		fmt.Fprintf(w, "\n=== Searching for: %s ===\n", searchStr)
+	}
+
+	pattern := []byte(searchStr)
+	if opts.UTF16 {
+		pattern = fdi.EncodeUTF16LE(searchStr)
+	}
+	results, err := search(pattern, opts)
+	if err != nil {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return 0
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(w, "String not found in file")
+	}
+	printSearchResults(w, results, opts.UTF16)
+	return len(results)
+}
+
+// Search for a hex-encoded byte sequence in the file. Returns the number of
+// matches, or an error if the pattern is not valid hex.
+func searchForHex(w io.Writer, search searchFunc, hexStr string, opts fdi.SearchOptions) (int, error) {
+	fmt.Fprintf(w, "\n=== Searching for hex: %s ===\n", hexStr)
+
+	pattern, wild, err := fdi.ParseHexWildcards(hexStr)
+	if err != nil {
+		logError("Invalid hex pattern: %v", err)
+		return 0, err
+	}
+	opts.Wild = wild
+
+	results, err := search(pattern, opts)
+	if err != nil {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return 0, nil
+	}
+	if len(results) == 0 {
+		fmt.Fprintln(w, "Pattern not found in file")
+	}
+	printSearchResults(w, results, false)
+	return len(results), nil
+}
+
+// Print the matches of a regular expression in the printable strings.
+// Returns the number of matches.
+func searchForRegexp(w io.Writer, re *regexp.Regexp, matches []fdi.RegexpMatch) int {
+	fmt.Fprintf(w, "\n=== Searching for regex: %s ===\n", re)
+
+	if len(matches) == 0 {
+		fmt.Fprintln(w, "No string matches the expression")
+	}
+	for _, m := range matches {
+		fmt.Fprintf(w, "Found at offset: 0x%X (%d): %q\n", m.Offset, m.Offset, m.Text)
+	}
+	fmt.Fprintf(w, "\n%d matches\n", len(matches))
+	return len(matches)
+}
+
+// Print each search hit with a context dump, then the match count
+func printSearchResults(w io.Writer, results []fdi.SearchResult, skipZeros bool) {
+	for _, r := range results {
+		if r.Length > 0 {
+			fmt.Fprintf(w, "Found at offset: 0x%X (%d), %d bytes at edit distance %d\n", r.Offset, r.Offset, r.Length, r.Edits)
+		} else {
+			fmt.Fprintf(w, "Found at offset: 0x%X (%d)\n", r.Offset, r.Offset)
+		}
+		fmt.Fprintln(w, "\nContext:")
+		printWindow(w, r.Context, r.ContextOffset, len(r.Context), r.ContextOffset, skipZeros)
+	}
+	fmt.Fprintf(w, "\n%d matches\n", len(results))
+}
+
+// End of the analysis window: -end if given, otherwise the end of the file
+func analysisEnd(data []byte, end int) int {
+	if end <= 0 || end > len(data) {
+		return len(data)
+	}
+	return end
+}
+
+// How much of the record analysis to print; 0 means no limit
+type recordLimits struct {
+	patterns int // delimiter patterns
+	offsets  int // offsets and distances per pattern
+	strings  int // text strings
+}
+
+// Try to detect record structures in data[start:end], printing as much as
+// the limits allow. With showRecords the first records of a detected record
+// length are dumped.
+func detectRecords(w io.Writer, data []byte, start int, end int, opts fdi.AnalysisOptions, limits recordLimits, showRecords bool) {
+	if start >= end {
+		fmt.Fprintln(w, "\nRecord analysis skipped: offset is beyond file size")
+		return
+	}
+	if start > 0 || end < len(data) {
+		fmt.Fprintf(w, "\n=== Record Structure Analysis (0x%X-0x%X) ===\n", start, end-1)
+	} else {
+		fmt.Fprintln(w, "\n=== Record Structure Analysis ===")
+	}
+
+	report := fdi.NewAnalyzer(data, opts).UseCache(analysisCache).Records(start, end)
+	if report.Interrupted {
+		fmt.Fprintln(w, "Interrupted: only the patterns found before then are listed")
+	}
+
+	// Report on potential record delimiters
+	patterns := report.Patterns
+	if len(patterns) > 0 {
+		fmt.Fprintln(w, "Potential record delimiters found:")
+		for count, p := range patterns {
+			if limits.patterns > 0 && count >= limits.patterns {
+				fmt.Fprintln(w, "... and more patterns")
+				break
+			}
+
+			fmt.Fprintf(w, "Pattern: 0x%X appears at offsets: ", p.Pattern)
+			for i, pos := range capped(p.Offsets, limits.offsets) {
+				if i > 0 {
+					fmt.Fprint(w, ", ")
+				}
+				fmt.Fprintf(w, "0x%X", pos)
+			}
+
+			fmt.Fprint(w, " (Distances: ")
+			for i, dist := range capped(p.Distances, limits.offsets) {
+				if i > 0 {
+					fmt.Fprint(w, ", ")
+				}
+				fmt.Fprintf(w, "%d", dist)
+			}
+			fmt.Fprintln(w, ")")
+		}
+
+		printStrides(w, data, report, showRecords)
+	} else {
+		fmt.Fprintln(w, "No obvious repeating patterns found")
+	}
+
+	// Try to detect strings that might indicate player or team names
+	fmt.Fprintln(w, "\nPotential text strings found:")
+	printStrings(w, report.Strings, limits.strings)
+}
+
+// Print up to limit detected strings, or all of them when limit is 0
+func printStrings(w io.Writer, strs []fdi.FoundString, limit int) {
+	for i, str := range strs {
+		if limit > 0 && i >= limit {
+			fmt.Fprintln(w, "... and more text strings")
+			break
+		}
+		if str.Encoding != "" {
+			fmt.Fprintf(w, "Offset 0x%X: %s (%s)\n", str.Offset, str.Text, str.Encoding)
+		} else {
+			fmt.Fprintf(w, "Offset 0x%X: %s\n", str.Offset, str.Text)
+		}
+	}
+}
+
+// The first n values, or all of them when n is 0
+func capped(values []int, n int) []int {
+	if n > 0 && n < len(values) {
+		return values[:n]
+	}
+	return values
+}
+
+// Report the most common delimiter spacings and, if one dominates, the likely record length
+func printStrides(w io.Writer, data []byte, report fdi.RecordReport, showRecords bool) {
+	strides := report.Strides
+	if len(strides) == 0 {
+		return
+	}
+
+	fmt.Fprint(w, "\nMost common strides: ")
+	for i, c := range strides[:min(3, len(strides))] {
+		if i > 0 {
+			fmt.Fprint(w, ", ")
+		}
+		fmt.Fprintf(w, "%d bytes (%d gaps)", c.Stride, c.Gaps)
+	}
+	fmt.Fprintln(w)
+
+	best := report.RecordLength
+	if best == nil {
+		fmt.Fprintln(w, "No dominant record length")
+		return
+	}
+	fmt.Fprintf(w, "Likely record length: %d bytes, starting at 0x%X\n", best.Stride, best.Offset)
+
+	if showRecords {
+		for i := 0; i < 3; i++ {
+			recStart := best.Offset + i*best.Stride
+			if recStart >= len(data) {
+				break
+			}
+			fmt.Fprintf(w, "\nRecord %d:", i)
+			printFileHeader(w, data, best.Stride, recStart)
+		}
+	}
+}
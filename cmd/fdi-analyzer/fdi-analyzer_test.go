@@ -0,0 +1,917 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fdi-analyzer/fdi"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// Compare got against testdata/<name>.golden, rewriting it with -update
+func golden(t *testing.T, name string, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s\n--- got ---\n%s\n--- want ---\n%s", path, got, want)
+	}
+}
+
+// Eight 16-byte records, each starting with a 01 02 marker and a name
+func recordData() []byte {
+	var data []byte
+	for i := 0; i < 8; i++ {
+		rec := make([]byte, 16)
+		copy(rec, "\x01\x02PLAYER0")
+		rec[8] = byte('0' + i)
+		rec[12] = byte(i)
+		data = append(data, rec...)
+	}
+	return data
+}
+
+func TestPrintFileHeader(t *testing.T) {
+	data := []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ\x00\x01\x02\x7F\xFF!")
+
+	tests := []struct {
+		name   string
+		size   int
+		offset int
+	}{
+		{"dump_full_rows", 16, 0},
+		{"dump_partial_row", 256, 0},
+		{"dump_offset", 8, 4},
+		{"dump_beyond_eof", 16, len(data)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			printFileHeader(&buf, data, tt.size, tt.offset)
+			golden(t, tt.name, buf.String())
+		})
+	}
+}
+
+func TestSearchForText(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		term  string
+		opts  fdi.SearchOptions
+		count int
+	}{
+		{"found", "xxJUVENTUSxx", "JUVENTUS", fdi.SearchOptions{}, 1},
+		{"not found", "xxJUVENTUSxx", "MILAN", fdi.SearchOptions{}, 0},
+		{"at offset 0", "JUVENTUSxx", "JUVENTUS", fdi.SearchOptions{}, 1},
+		{"overlapping", "aaaa", "aa", fdi.SearchOptions{}, 3},
+		{"no overlap", "aaaa", "aa", fdi.SearchOptions{NoOverlap: true}, 2},
+		{"ignore case", "Juventus", "JUVENTUS", fdi.SearchOptions{IgnoreCase: true}, 1},
+		{"longer than data", "ab", "abc", fdi.SearchOptions{}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if got := searchForText(&buf, inMemory([]byte(tt.data)), tt.term, tt.opts); got != tt.count {
+				t.Errorf("searchForText(%q, %q) = %d matches, want %d", tt.data, tt.term, got, tt.count)
+			}
+			if tt.count == 0 && !strings.Contains(buf.String(), "String not found") && !strings.Contains(buf.String(), "Error") {
+				t.Errorf("no not-found message in output:\n%s", buf.String())
+			}
+		})
+	}
+}
+
+func TestSearchForTextOutput(t *testing.T) {
+	var buf bytes.Buffer
+	searchForText(&buf, inMemory([]byte("JUVENTUS and more JUVENTUS")), "JUVENTUS", fdi.SearchOptions{})
+	golden(t, "search", buf.String())
+}
+
+func TestSearchForTermsIgnoreCase(t *testing.T) {
+	var buf bytes.Buffer
+	data := []byte("xxJUVENTUSxxjuventus")
+	if got := searchForTerms(&buf, inMemory(data), []string{"juventus"}, []string{"Juventus"}, fdi.SearchOptions{}); got != 3 {
+		t.Errorf("searchForTerms = %d matches, want 1 exact and 2 ignoring case", got)
+	}
+	if !strings.Contains(buf.String(), "=== Searching for: Juventus (ignoring case) ===") {
+		t.Errorf("output does not mark the case-insensitive search:\n%s", buf.String())
+	}
+}
+
+func TestDetectRecords(t *testing.T) {
+	data := recordData()
+	limits := recordLimits{patterns: 5, offsets: 3, strings: 10}
+
+	var buf bytes.Buffer
+	detectRecords(&buf, data, 0, len(data), fdi.AnalysisOptions{MinString: 4}, limits, false)
+	out := buf.String()
+	if !strings.Contains(out, "Likely record length: 16 bytes, starting at 0x0") {
+		t.Errorf("record length not detected:\n%s", out)
+	}
+	golden(t, "records", out)
+}
+
+func TestDetectRecordsRange(t *testing.T) {
+	data := recordData()
+	limits := recordLimits{patterns: 5, offsets: 3, strings: 10}
+
+	var buf bytes.Buffer
+	detectRecords(&buf, data, 32, 96, fdi.AnalysisOptions{MinString: 4}, limits, true)
+	golden(t, "records_range", buf.String())
+}
+
+func TestDetectRecordsBeyondEOF(t *testing.T) {
+	var buf bytes.Buffer
+	detectRecords(&buf, recordData(), 200, 128, fdi.AnalysisOptions{MinString: 4}, recordLimits{}, false)
+	if got := buf.String(); !strings.Contains(got, "Record analysis skipped") {
+		t.Errorf("got %q, want a skipped message", got)
+	}
+}
+
+func TestBuildReportJSON(t *testing.T) {
+	data := recordData()
+	report, err := buildReport(data, reportRequest{
+		dump:       fdi.DumpOptions{Size: 20},
+		terms:      []string{"PLAYER3", "MILAN"},
+		searchOpts: fdi.SearchOptions{},
+		hexSearch:  "0102",
+		end:        48,
+		analysis:   fdi.AnalysisOptions{MinString: 4},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if code := writeJSON(&buf, report); code != exitOK {
+		t.Fatalf("writeJSON = %d", code)
+	}
+	golden(t, "report_json", buf.String())
+}
+
+func TestBuildReportInvalidHex(t *testing.T) {
+	if _, err := buildReport(recordData(), reportRequest{hexSearch: "zz"}); err == nil {
+		t.Error("buildReport with hex zz succeeded, want an error")
+	}
+}
+
+func TestBuildReportOnlyStrings(t *testing.T) {
+	data := recordData()
+	report, err := buildReport(data, reportRequest{
+		dump:     fdi.DumpOptions{Size: 20},
+		end:      len(data),
+		analysis: fdi.AnalysisOptions{MinString: 4},
+		only:     "strings",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Dump != nil || report.Records != nil {
+		t.Errorf("strings report has dump %v and records %v, want neither", report.Dump, report.Records)
+	}
+	if len(report.Strings) != 8 {
+		t.Errorf("strings report lists %d strings, want 8", len(report.Strings))
+	}
+}
+
+func TestSubcommandFlags(t *testing.T) {
+	for _, c := range subcommands {
+		for _, name := range c.needs {
+			if !c.allows(name) {
+				t.Errorf("%s needs -%s but does not take it", c.name, name)
+			}
+		}
+		if c.report != "" && c.report != c.name {
+			t.Errorf("%s prints the %s report", c.name, c.report)
+		}
+	}
+	if c, _ := lookupSubcommand("dump"); !c.allows("file") || c.allows("search") {
+		t.Error("dump should take -file and not -search")
+	}
+}
+
+func TestBrowserKeys(t *testing.T) {
+	tests := []struct {
+		keys string
+		want int // cursor offset afterwards
+	}{
+		{"\x1b[C\x1b[Cj", 18},
+		{"g0x40\r", 0x40},
+		{"g0x40\rk\x1b[D", 0x2F},
+		{"/PLAY", 2},                // incremental: the first match as typed
+		{"/PLAYER3", 0x32},          // narrows to record 3
+		{"/PLAYER3\x1b", 0},         // escape returns to the start
+		{"/PLAYER\rnn", 0x22},       // n moves to later matches
+		{"/PLAYER\rN", 0x72},        // N wraps backwards to the last match
+		{"\\0102\r", 0x10},          // hex search starts after the cursor
+		{"gzz\r", 0},                // invalid offsets leave the cursor alone
+		{"\x1b[F\x1b[B\x1b[C", 127}, // movement stops at the last byte
+	}
+
+	for _, tc := range tests {
+		b := &browser{data: recordData(), rows: 4}
+		for _, key := range decodeKeys([]byte(tc.keys)) {
+			b.handle(key)
+		}
+		if b.cursor != tc.want {
+			t.Errorf("keys %q: cursor = 0x%X, want 0x%X", tc.keys, b.cursor, tc.want)
+		}
+		if b.cursor < b.top || b.cursor >= b.top+b.rows*16 {
+			t.Errorf("keys %q: cursor 0x%X is outside the visible rows from 0x%X", tc.keys, b.cursor, b.top)
+		}
+	}
+}
+
+func TestRunBrowserScreen(t *testing.T) {
+	var buf bytes.Buffer
+	runBrowser(&buf, strings.NewReader("j"), recordData(), 0, 24)
+	got := buf.String()
+	if !strings.Contains(got, "Offset 0x10 of 0x80") {
+		t.Errorf("screen does not show the moved cursor:\n%s", got)
+	}
+	if !strings.Contains(got, "u16 513") {
+		t.Errorf("inspector does not show u16 values for 01 02:\n%s", got)
+	}
+}
+
+func TestSessionNarrowing(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "rating")
+	req := sessionRequest{name: name, file: "a.fdi", value: "87", types: "u8", end: 4}
+
+	var buf bytes.Buffer
+	if code := runSession(&buf, []byte{87, 87, 1, 87}, req); code != exitOK {
+		t.Fatalf("starting the session = %d:\n%s", code, buf.String())
+	}
+
+	req.value, req.filter = "", fdi.FilterIncreased
+	buf.Reset()
+	if code := runSession(&buf, []byte{88, 87, 1, 80}, req); code != exitOK {
+		t.Fatalf("narrowing the session = %d:\n%s", code, buf.String())
+	}
+	if got := buf.String(); !strings.Contains(got, "3 -> 1 candidates") || !strings.Contains(got, "0x0        u8     88") {
+		t.Errorf("narrowing output:\n%s", got)
+	}
+
+	if _, err := os.Stat(name + ".json"); err != nil {
+		t.Errorf("session file not written: %v", err)
+	}
+	req.filter = fdi.FilterChanged
+	if code := runSession(&buf, []byte{88, 87, 1, 80}, req); code != exitNoMatch {
+		t.Errorf("narrowing to nothing = %d, want %d", code, exitNoMatch)
+	}
+}
+
+func TestEditFileKeepsBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.fdi")
+	orig := []byte("HDR\x00PLAYER1\x00")
+	if err := os.WriteFile(path, orig, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	code := editFile(&buf, orig, path, editRequest{offset: 4, text: "KEEPER", record: -1})
+	if code != exitOK {
+		t.Fatalf("editFile = %d:\n%s", code, buf.String())
+	}
+
+	if got, _ := os.ReadFile(path); string(got) != "HDR\x00KEEPER1\x00" {
+		t.Errorf("edited file = %q", got)
+	}
+	if got, _ := os.ReadFile(path + ".bak"); !bytes.Equal(got, orig) {
+		t.Errorf("backup = %q, want the original", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("edited file mode = %v, %v; want 0600", info.Mode(), err)
+	}
+}
+
+func TestPatchFileInPlace(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.fdi")
+	orig := []byte("HDR\x00PLAYER1\x00")
+	if err := os.WriteFile(path, orig, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if code := patchFile(&buf, orig, path, []string{"0x4=4b45"}, path, checksumFix{}); code != exitOK {
+		t.Fatalf("patchFile = %d:\n%s", code, buf.String())
+	}
+	if got, _ := os.ReadFile(path); string(got) != "HDR\x00KEAYER1\x00" {
+		t.Errorf("patched file = %q", got)
+	}
+	if got, _ := os.ReadFile(path + ".bak"); !bytes.Equal(got, orig) {
+		t.Errorf("backup = %q, want the original", got)
+	}
+	if code := undoEdit(&buf, path, false); code != exitOK {
+		t.Fatalf("undo = %d:\n%s", code, buf.String())
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, orig) {
+		t.Errorf("undone file = %q, want the original", got)
+	}
+}
+
+func TestEditFileFixesChecksum(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.fdi")
+	body := []byte("HDR\x00PLAYER1\x00PLAYER2\x00")
+	orig := binary.LittleEndian.AppendUint32(nil, crc32.ChecksumIEEE(body))
+	orig = append(orig, body...)
+	if err := os.WriteFile(path, orig, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	code := editFile(&buf, orig, path, editRequest{offset: 8, text: "KEEPER", record: -1, fix: checksumFix{enabled: true}})
+	if code != exitOK {
+		t.Fatalf("editFile = %d:\n%s", code, buf.String())
+	}
+	if !strings.Contains(buf.String(), "Fixed crc32-le@0x0:0x4-0x") {
+		t.Errorf("missing checksum fix in:\n%s", buf.String())
+	}
+
+	got, _ := os.ReadFile(path)
+	if want := crc32.ChecksumIEEE(got[4:]); binary.LittleEndian.Uint32(got) != want {
+		t.Errorf("stored checksum = %08X, want %08X", binary.LittleEndian.Uint32(got), want)
+	}
+}
+
+func TestPrintEntropy(t *testing.T) {
+	data := make([]byte, 0, 1024)
+	data = append(data, make([]byte, 256)...)
+	data = append(data, strings.Repeat("PLAYER ROSSI MILAN ", 14)[:256]...)
+	for i := 0; i < 512; i++ {
+		data = append(data, byte(i*167+i/256))
+	}
+
+	var buf bytes.Buffer
+	if code := printEntropy(&buf, data, 0, 0, 128); code != exitOK {
+		t.Fatalf("printEntropy = %d", code)
+	}
+	golden(t, "entropy", buf.String())
+}
+
+func TestSummarizeFilesGroupsLayouts(t *testing.T) {
+	dir := t.TempDir()
+	season1 := recordData()
+	season2 := recordData()
+	season2[40] = 'X'
+	for name, data := range map[string][]byte{"s1.fdi": season1, "s2.fdi": season2, "other.fdi": []byte("TEXT file, not like the others")} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := expandFileArgs([]string{filepath.Join(dir, "s*.fdi"), filepath.Join(dir, "other.fdi")})
+	if err != nil || len(files) != 3 {
+		t.Fatalf("expandFileArgs = %v, %v", files, err)
+	}
+
+	var buf bytes.Buffer
+	if code := summarizeFiles(&buf, files, fdi.AnalysisOptions{MinString: 4}); code != exitOK {
+		t.Fatalf("summarizeFiles = %d:\n%s", code, buf.String())
+	}
+	out := buf.String()
+	if !strings.Contains(out, "16-byte records: 2 files, first 40 bytes identical") {
+		t.Errorf("seasons not grouped:\n%s", out)
+	}
+	if !strings.Contains(out, "Magic TEXT, no fixed-size records: 1 file") {
+		t.Errorf("other file not in its own group:\n%s", out)
+	}
+}
+
+func TestPrintBreakdown(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "players.yaml")
+	src := "name: players\nrecord_size: 16\nfields:\n  - name: name\n    offset: 2\n    type: string\n    length: 8\n  - name: number\n    offset: 12\n    type: uint8\n"
+	if err := os.WriteFile(schema, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if code := printBreakdown(&buf, recordData(), schema, 0, 3); code != exitOK {
+		t.Fatalf("printBreakdown = %d:\n%s", code, buf.String())
+	}
+	out := buf.String()
+	for _, want := range []string{"Record 3 of players (Offset: 0x30, Size: 16)", "+0x00   unknown  01 02", "\"PLAYER3\"",
+		"+0x0C   number   03", "+0x0D   unknown  00 00 00", "7 of 16 bytes are not mapped"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("breakdown lacks %q:\n%s", want, out)
+		}
+	}
+	if code := printBreakdown(io.Discard, recordData(), schema, 0, 8); code != exitUsage {
+		t.Errorf("a record past the end gave %d, want %d", code, exitUsage)
+	}
+}
+
+func TestMergeAnalyses(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.fdi"), filepath.Join(dir, "b.fdi")
+	if err := os.WriteFile(a, []byte("HELLO WORLD\x00\x01SHARED TEXT\x00"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(b, []byte("SHARED TEXT\x00\x02OTHER STRING"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if code := mergeAnalyses(&buf, []string{a, b}, fdi.AnalysisOptions{MinString: 4}, 0); code != exitOK {
+		t.Fatalf("mergeAnalyses = %d:\n%s", code, buf.String())
+	}
+	var merged struct {
+		Files         map[string]fdi.AnalysisResult
+		MinFiles      int            `json:"min_files"`
+		CommonStrings []mergedString `json:"common_strings"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &merged); err != nil {
+		t.Fatal(err)
+	}
+	if len(merged.Files) != 2 || len(merged.Files[a].Strings) != 2 || merged.Files[b].FileSize != 25 || merged.MinFiles != 2 {
+		t.Errorf("merged files = %+v", merged)
+	}
+	if len(merged.CommonStrings) != 1 || merged.CommonStrings[0].Text != "SHARED TEXT" ||
+		merged.CommonStrings[0].Offsets[a] != 13 || merged.CommonStrings[0].Offsets[b] != 0 {
+		t.Errorf("common strings = %+v", merged.CommonStrings)
+	}
+}
+
+func TestExportRecordsCSV(t *testing.T) {
+	schema := filepath.Join(t.TempDir(), "players.yaml")
+	src := "name: players\nrecord_size: 16\nfields:\n  - name: name\n    offset: 2\n    type: string\n    length: 8\n  - name: number\n    offset: 12\n    type: uint8\n"
+	if err := os.WriteFile(schema, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	req := exportRequest{format: "csv", schemaPath: schema, count: 3}
+	if code := exportData(&buf, recordData(), req); code != exitOK {
+		t.Fatalf("exportData = %d:\n%s", code, buf.String())
+	}
+	want := "record,offset,name,number\n0,0,PLAYER0,0\n1,16,PLAYER1,1\n2,32,PLAYER2,2\n"
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	annotations = fdi.Annotations{{Start: 16, End: 23, Note: "second <player>"}}
+	defer func() { annotations = nil }()
+
+	path := filepath.Join(t.TempDir(), "report.html")
+	var buf bytes.Buffer
+	req := htmlRequest{path: path, file: "players.fdi", terms: []string{"PLAYER3"}, analysis: fdi.AnalysisOptions{MinString: 4}}
+	if code := writeHTMLReport(&buf, recordData(), req); code != exitOK {
+		t.Fatalf("writeHTMLReport = %d:\n%s", code, buf.String())
+	}
+	page, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"<h1>players.fdi</h1>",
+		`<a href="#0x32">0x32</a>`, // the PLAYER3 hit and string
+		`"kind":"hit","label":"search: PLAYER3"`,
+		"second &lt;player&gt;",
+	} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("report lacks %s", want)
+		}
+	}
+}
+
+// Run as a plugin by TestRunPlugin: report where the context said records start
+func TestPluginHelper(t *testing.T) {
+	if os.Getenv("FDI_PLUGIN_HELPER") == "" {
+		t.Skip("run as a plugin")
+	}
+	var ctx pluginContext
+	if err := json.NewDecoder(os.Stdin).Decode(&ctx); err != nil {
+		os.Exit(2)
+	}
+	data, _ := os.ReadFile(ctx.File)
+	json.NewEncoder(os.Stdout).Encode(pluginReport{Title: "helper", Findings: []pluginFinding{
+		{Offset: ctx.RecordStart, Length: ctx.RecordSize, Note: "first record", Value: len(data)},
+	}})
+	os.Exit(0)
+}
+
+func TestRunPlugin(t *testing.T) {
+	t.Setenv("FDI_PLUGIN_HELPER", "1")
+	var buf bytes.Buffer
+	command := os.Args[0] + " -test.run=^TestPluginHelper$"
+	code := runPlugin(&buf, recordData(), command, pluginContext{File: "-", RecordStart: 16, RecordSize: 16})
+	if code != exitOK {
+		t.Fatalf("runPlugin = %d:\n%s", code, buf.String())
+	}
+	want := "0x00000010     16  01 02 50 4C 41 59 45 52  first record = 128"
+	if !strings.Contains(buf.String(), "=== helper: 1 findings ===") || !strings.Contains(buf.String(), want) {
+		t.Errorf("got\n%s\nwant a line %q", buf.String(), want)
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.fdi")
+	data := recordData()
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	done := make(chan int)
+	var buf bytes.Buffer
+	go func() {
+		done <- watchFile(&buf, data, watchRequest{path: path, interval: 10 * time.Millisecond, stop: stop})
+	}()
+
+	time.Sleep(30 * time.Millisecond)
+	saved := bytes.Clone(data)
+	saved[20] = 0x99
+	if err := os.WriteFile(path, saved, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute) // a new mtime even on coarse filesystems
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	close(stop)
+	if code := <-done; code != exitOK {
+		t.Fatalf("watchFile = %d:\n%s", code, buf.String())
+	}
+
+	want := fmt.Sprintf("0x00000014-0x00000014 (1 bytes): %02X -> 99", data[20])
+	if !strings.Contains(buf.String(), want) || strings.Count(buf.String(), "=== Diff against") != 1 {
+		t.Errorf("got\n%s\nwant one diff with %q", buf.String(), want)
+	}
+}
+
+func TestDecodeKaitai(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "table.ksy")
+	ksy := "meta:\n  id: table\nseq:\n  - id: rows\n    type: row\n    repeat: eos\ntypes:\n  row:\n    seq:\n" +
+		"      - id: marker\n        contents: [1, 2]\n      - id: name\n        type: strz\n        encoding: ASCII\n" +
+		"      - id: rest\n        size: 16 - 3 - name.length\n"
+	if err := os.WriteFile(path, []byte(ksy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if code := decodeKaitai(&buf, recordData(), path, 0); code != exitOK {
+		t.Fatalf("decodeKaitai = %d:\n%s", code, buf.String())
+	}
+	for _, want := range []string{
+		"=== table (table.ksy, Offset: 0x0, 128 bytes) ===",
+		"0x00000000    128  rows: row[8]",
+		"0x00000070     16    [7]: row",
+		"0x00000072      8      name = \"PLAYER7\"",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("got\n%s\nwant a line %q", buf.String(), want)
+		}
+	}
+}
+
+func TestWriteSQLiteLayout(t *testing.T) {
+	var rows [][]any
+	for i := 0; i < 2000; i++ {
+		rows = append(rows, []any{int64(i), strings.Repeat("x", i%50), nil})
+	}
+	rows[7][1] = strings.Repeat("long ", 2000) // spills to overflow pages
+	path := filepath.Join(t.TempDir(), "out.db")
+	table := sqlTable{name: "t", columns: []sqlColumn{{"n", "INTEGER"}, {"s", "TEXT"}, {"z", "BLOB"}}, rows: rows}
+	if err := writeSQLite(path, []sqlTable{table}); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(db, []byte("SQLite format 3\x00")) {
+		t.Fatalf("bad header % X", db[:16])
+	}
+	pages := int(binary.BigEndian.Uint32(db[28:]))
+	if len(db) != pages*sqlitePageSize || pages < 10 {
+		t.Errorf("%d bytes for %d pages", len(db), pages)
+	}
+	if !bytes.Contains(db[:sqlitePageSize], []byte(`CREATE TABLE "t" ("n" INTEGER, "s" TEXT, "z" BLOB)`)) {
+		t.Error("schema table missing the CREATE TABLE statement")
+	}
+
+	for v, want := range map[uint64]string{0x7F: "7F", 0x80: "8100", 0x3FFF: "FF7F", 1 << 56: "80C080808080808000"} {
+		if got := fmt.Sprintf("%X", sqliteVarint(v)); got != want {
+			t.Errorf("sqliteVarint(%#x) = %s, want %s", v, got, want)
+		}
+	}
+}
+
+func TestStreamReport(t *testing.T) {
+	defer func(old int64) { maxMem = old }(maxMem)
+	maxMem = fdi.MinChunkSize
+
+	data := bytes.Repeat([]byte{0x01}, 3*fdi.MinChunkSize)
+	copy(data[fdi.MinChunkSize-4:], "JUVENTUS")
+	copy(data[2*fdi.MinChunkSize:], "JUVENTUS")
+	path := filepath.Join(t.TempDir(), "big.fdi")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	in := &tooLargeError{path: path, size: int64(len(data)), err: errors.ErrUnsupported, release: func() {}}
+	req := reportRequest{dump: fdi.DumpOptions{Offset: fdi.MinChunkSize - 8, Size: 16}, terms: []string{"JUVENTUS"}, analysis: fdi.AnalysisOptions{MinString: 4}}
+	if code := streamReport(&buf, in, req, 10, ""); code != exitOK {
+		t.Fatalf("streamReport = %d, want %d\n%s", code, exitOK, buf.String())
+	}
+	for _, want := range []string{"0x00000FF8 | 01 01 01 01 4A 55", "2 matches", "Offset 0xFFC: JUVENTUS", "Offset 0x2000: JUVENTUS"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("streamed report lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestServeEndpoints(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.fdi")
+	if err := os.WriteFile(path, recordData(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	h := (&server{files: []string{path}, analysis: fdi.AnalysisOptions{MinString: 4}}).handler()
+
+	get := func(url string, wantStatus int, v any) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+		if rec.Code != wantStatus {
+			t.Fatalf("GET %s = %d, want %d:\n%s", url, rec.Code, wantStatus, rec.Body)
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), v); err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+	}
+
+	var dump struct {
+		Offset, Next int
+		Rows         []fdi.DumpRow
+	}
+	get("/dump?offset=0x10&size=32", http.StatusOK, &dump)
+	if dump.Next != 0x30 || len(dump.Rows) != 2 || dump.Rows[0].Offset != 0x10 {
+		t.Errorf("dump = %+v", dump)
+	}
+
+	var search struct {
+		Total, From, Next int
+		Items             []fdi.SearchResult
+	}
+	get("/search?q=PLAYER&from=2&limit=3&file=records.fdi", http.StatusOK, &search)
+	if search.Total != 8 || search.Next != 5 || len(search.Items) != 3 || search.Items[0].Offset != 0x22 {
+		t.Errorf("search = %+v", search)
+	}
+
+	var bad struct{ Error string }
+	get("/search?q=PLAYER&file=other.fdi", http.StatusBadRequest, &bad)
+	if !strings.Contains(bad.Error, "other.fdi") {
+		t.Errorf("unknown file error = %q", bad.Error)
+	}
+	get("/dump?size=-1", http.StatusBadRequest, &bad)
+	for _, url := range []string{"/strings?offset=20&end=5", "/records?offset=0x100000", "/strings?offset=0x100000&end=0x100010"} {
+		bad.Error = ""
+		get(url, http.StatusBadRequest, &bad)
+		if !strings.Contains(bad.Error, "past") {
+			t.Errorf("GET %s error = %q", url, bad.Error)
+		}
+	}
+
+	// /decode takes its table from serve's -schema
+	get("/decode", http.StatusBadRequest, &bad)
+	if !strings.Contains(bad.Error, "-schema") {
+		t.Errorf("decode without a schema error = %q", bad.Error)
+	}
+	l, err := fdi.ParseLayout([]byte("name: players\nrecord_size: 16\nfields:\n  - name: name\n    offset: 2\n    type: string\n    length: 8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h = (&server{files: []string{path}, layout: &l}).handler()
+	var decoded struct {
+		Layout string
+		Schema fdi.Schema
+		Total  int
+		Items  []fdi.Record
+	}
+	get("/decode?limit=2", http.StatusOK, &decoded)
+	if decoded.Layout != "players" || decoded.Total != 8 || len(decoded.Items) != 2 || decoded.Items[1].Fields[0].Value != "PLAYER1" {
+		t.Errorf("decode = %+v", decoded)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	defer setupLogging(os.Stderr, false, 0, false)
+
+	var buf bytes.Buffer
+	setupLogging(&buf, false, 2, false)
+	logError("Error reading file: %v", errors.New("gone"))
+	logVerbose("Format: %s", "liga")
+	logPass("records")()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 3 || lines[0] != "Error reading file: gone" || lines[1] != "Format: liga" ||
+		!strings.HasPrefix(lines[2], "debug: pass name=records took=") {
+		t.Errorf("debug log:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	setupLogging(&buf, true, 0, false)
+	logInfo("Wrote %s", "out.csv")
+	logError("Invalid -fuzzy %d", -1)
+	if got := buf.String(); got != "Invalid -fuzzy -1\n" {
+		t.Errorf("quiet log = %q, want the error alone", got)
+	}
+
+	buf.Reset()
+	setupLogging(&buf, false, 1, true)
+	logVerbose("Taking %d-byte records", 64)
+	var rec struct{ Level, Msg string }
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil || rec.Level != "VERBOSE" || rec.Msg != "Taking 64-byte records" {
+		t.Errorf("JSON log = %s (%v)", buf.String(), err)
+	}
+}
+
+func TestReplaceAll(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "save.fdi")
+	data := []byte("..JUVENTUS....JUVENTUS FC..")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	req := replaceRequest{specs: []string{"JUVENTUS=TORINO"}, answer: strings.NewReader("n\n")}
+	if code := replaceAll(&buf, data, path, req); code != exitOK {
+		t.Fatalf("replaceAll = %d:\n%s", code, buf.String())
+	}
+	if !strings.Contains(buf.String(), "2 occurrences") || !strings.Contains(buf.String(), "-> ..TORINO......TORINO   FC..") {
+		t.Errorf("preview:\n%s", buf.String())
+	}
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, data) {
+		t.Errorf("declined replace changed the file to %q", got)
+	}
+
+	req.answer = strings.NewReader("y\n")
+	if code := replaceAll(&buf, data, path, req); code != exitOK {
+		t.Fatalf("replaceAll = %d:\n%s", code, buf.String())
+	}
+	if got, _ := os.ReadFile(path); string(got) != "..TORINO\x00\x00....TORINO   FC.." {
+		t.Errorf("replaced file = %q", got)
+	}
+	if bak, _ := os.ReadFile(path + ".bak"); !bytes.Equal(bak, data) {
+		t.Errorf("backup = %q, want the original", bak)
+	}
+}
+
+func TestDeltaPatchRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	orig := []byte("ROSSI...BAGGIO..")
+	fixed := []byte("TOTTI...BAGGIO..")
+	fixedPath, patchPath, copyPath := filepath.Join(dir, "fixed.fdi"), filepath.Join(dir, "fix.ips"), filepath.Join(dir, "copy.fdi")
+	for path, data := range map[string][]byte{fixedPath: fixed, copyPath: orig} {
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if code := writeDeltaPatch(&buf, orig, fixedPath, "ips", patchPath); code != exitOK {
+		t.Fatalf("writeDeltaPatch = %d:\n%s", code, buf.String())
+	}
+	if code := applyDeltaPatch(&buf, orig, copyPath, patchPath, ""); code != exitOK {
+		t.Fatalf("applyDeltaPatch = %d:\n%s", code, buf.String())
+	}
+	if got, _ := os.ReadFile(copyPath); !bytes.Equal(got, fixed) {
+		t.Errorf("patched copy = %q, want %q", got, fixed)
+	}
+	if bak, _ := os.ReadFile(copyPath + ".bak"); !bytes.Equal(bak, orig) {
+		t.Errorf("backup = %q, want the original", bak)
+	}
+	if j, _ := loadJournal(copyPath); j.CanUndo() != 1 {
+		t.Errorf("journal has %d edits, want 1", j.CanUndo())
+	}
+}
+
+func TestReadArchived(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := filepath.Join(dir, "game.zip")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string][]byte{"DATA/LIGA.FDI": recordData(), "DATA/CUP.FDI": []byte("CUP")}
+	for _, name := range []string{"DATA/LIGA.FDI", "DATA/CUP.FDI"} {
+		f, _ := zw.Create(name)
+		f.Write(files[name])
+	}
+	zw.Close()
+	if err := os.WriteFile(zipPath, buf.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	data, release, err := readInput(zipPath + `:data\liga.fdi`)
+	if err != nil || !bytes.Equal(data, files["DATA/LIGA.FDI"]) {
+		t.Fatalf("readInput = %q, %v", data, err)
+	}
+	release()
+	if _, _, err := readInput(zipPath + ":DATA/NONE.FDI"); err == nil || !strings.Contains(err.Error(), "DATA/CUP.FDI") {
+		t.Errorf("reading a missing member: %v, want the members listed", err)
+	}
+	paths, err := expandFileArgs([]string{zipPath + ":DATA/*.FDI"})
+	if err != nil || len(paths) != 2 || paths[1] != zipPath+":DATA/CUP.FDI" {
+		t.Errorf("expandFileArgs = %v, %v", paths, err)
+	}
+	if code := editFile(&buf, data, zipPath+":DATA/LIGA.FDI", editRequest{}); code != exitUsage {
+		t.Errorf("editing a member in place = %d, want %d", code, exitUsage)
+	}
+}
+
+func TestIdentifySingleVersion(t *testing.T) {
+	var buf bytes.Buffer
+	if code := identifyVersion(&buf, recordData(), ""); code != exitUsage {
+		t.Errorf("identifyVersion without a registry = %d, want %d", code, exitUsage)
+	}
+
+	path := filepath.Join(t.TempDir(), "versions.yaml")
+	registry := "versions:\n  - name: demo\n    tables:\n      - name: records\n        record_size: 16\n" +
+		"        fields:\n          - name: tag\n            offset: 2\n            type: string\n            length: 6\n"
+	if err := os.WriteFile(path, []byte(registry), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if code := identifyVersion(&buf, recordData(), path); code != exitOK {
+		t.Errorf("identifyVersion = %d, want %d:\n%s", code, exitOK, buf.String())
+	}
+	if out := buf.String(); !strings.Contains(out, "one version (demo)") || strings.Contains(out, "Best match") {
+		t.Errorf("single version output:\n%s", out)
+	}
+}
+
+func TestNumberValue(t *testing.T) {
+	var n numberValue
+	for value, want := range map[string]int{"0": 0, "42": 42, "0x1F": 0x1F} {
+		if err := n.Set(value); err != nil || int(n) != want {
+			t.Errorf("Set(%q) = %d, %v; want %d", value, n, err, want)
+		}
+	}
+	for _, value := range []string{"-5", "-0x10", "ten", ""} {
+		if err := n.Set(value); err == nil {
+			t.Errorf("Set(%q) took %d", value, n)
+		}
+	}
+}
+
+// Run the tool with the arguments in FDI_RUN_ARGS, one per line
+func TestRunHelper(t *testing.T) {
+	args := os.Getenv("FDI_RUN_ARGS")
+	if args == "" {
+		t.Skip("run as the tool")
+	}
+	os.Args = append([]string{"fdi-analyzer"}, strings.Split(args, "\n")...)
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	os.Exit(run(io.Discard))
+}
+
+func TestNegativeCountFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.fdi")
+	if err := os.WriteFile(path, recordData(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	status := func(args ...string) int {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestRunHelper$")
+		cmd.Env = append(os.Environ(), "FDI_RUN_ARGS="+strings.Join(append(args, path), "\n"))
+		var exit *exec.ExitError
+		if err := cmd.Run(); errors.As(err, &exit) {
+			return exit.ExitCode()
+		}
+		return exitOK
+	}
+	for _, args := range [][]string{{"records", "-count"}, {"records", "-limit"}, {"search", "-search", "PLAYER", "-fuzzy"}} {
+		if code := status(append(args, "0x2")...); code == exitUsage {
+			t.Errorf("%v 0x2: exit status %d", args, code)
+		}
+		if code := status(append(args, "-1")...); code != exitUsage {
+			t.Errorf("%v -1: exit status %d, want %d", args, code, exitUsage)
+		}
+	}
+}
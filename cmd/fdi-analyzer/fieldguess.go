@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Guess the type of an intra-record field from its values across all records
+func guessFieldType(w io.Writer, data []byte, start int, recordSize int, fieldOff int) int {
+	stats, err := fdi.GuessFieldType(data, start, recordSize, fieldOff)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			RecordSize  int `json:"record_size"`
+			FieldOffset int `json:"field_offset"`
+			fdi.FieldStats
+		}{recordSize, fieldOff, stats})
+	}
+
+	fmt.Fprintf(w, "\n=== Field Type Guess (Record size: %d, Field offset: %d) ===\n", recordSize, fieldOff)
+	fmt.Fprintf(w, "Records sampled: %d\n", stats.Records)
+	fmt.Fprintf(w, "Distinct values: %d\n", stats.Distinct)
+	fmt.Fprintf(w, "Range (uint8): %d-%d\n", stats.Min, stats.Max)
+	if stats.HasUint16 {
+		fmt.Fprintf(w, "Range (uint16 LE): %d-%d\n", stats.MinU16, stats.MaxU16)
+	}
+	fmt.Fprintf(w, "Printable ratio: %.2f\n", stats.PrintableRatio)
+	fmt.Fprintf(w, "Likely type: %s\n", stats.Type)
+	return exitOK
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Offsets printed per type unless -limit or -verbose says otherwise
+const findValueLimit = 32
+
+// Print every offset in data[start:end] where value is stored as one of the
+// comma-separated types (all types when empty, or those of endian when it is
+// known). limit caps the offsets
+// printed per type; 0 prints them all.
+func findValue(w io.Writer, data []byte, value string, typeList string, endian string, start int, end int, limit int) int {
+	if start >= end {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+
+	types, err := valueTypeList(typeList)
+	var results []fdi.ValueMatches
+	if err == nil {
+		results, err = findValueTypes(data[start:end], value, types, endian)
+	}
+	if err != nil {
+		logError("Error: %v (types: %s)", err, strings.Join(fdi.ValueTypes, ", "))
+		return exitUsage
+	}
+	total := 0
+	for i := range results {
+		for j := range results[i].Offsets {
+			results[i].Offsets[j] += start
+		}
+		total += len(results[i].Offsets)
+	}
+
+	if outputJSON {
+		if code := writeJSON(w, struct {
+			Value   string             `json:"value"`
+			Start   int                `json:"start"`
+			End     int                `json:"end"`
+			Matches []fdi.ValueMatches `json:"matches"`
+		}{value, start, end, nonNil(results)}); code != exitOK {
+			return code
+		}
+	} else {
+		fmt.Fprintf(w, "\n=== Value %s (0x%X-0x%X) ===\n", value, start, end-1)
+		for _, m := range results {
+			fmt.Fprintf(w, "%-6s %-18X %d matches\n", m.Type, []byte(m.Pattern), len(m.Offsets))
+			shown := capped(m.Offsets, limit)
+			for i := 0; i < len(shown); i += 8 {
+				line := make([]string, 0, 8)
+				for _, off := range shown[i:min(i+8, len(shown))] {
+					line = append(line, fmt.Sprintf("0x%X", off))
+				}
+				fmt.Fprintf(w, "  %s\n", strings.Join(line, " "))
+			}
+			if len(shown) < len(m.Offsets) {
+				fmt.Fprintf(w, "  ... and %d more\n", len(m.Offsets)-len(shown))
+			}
+		}
+		fmt.Fprintf(w, "\n%d matches\n", total)
+	}
+
+	if total == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// Find value as the -type list asks, or when none is given in every type of
+// the file's byte order if it is known
+func findValueTypes(data []byte, value string, types []string, endian string) ([]fdi.ValueMatches, error) {
+	if len(types) == 0 && endian != "" {
+		return fdi.FindValueIn(data, value, endian)
+	}
+	return fdi.FindValue(data, value, types)
+}
+
+// Split a -type list, checking each type
+func valueTypeList(list string) ([]string, error) {
+	var types []string
+	for _, t := range strings.Split(list, ",") {
+		if t = strings.ToLower(strings.TrimSpace(t)); t == "" {
+			continue
+		}
+		if fdi.ValueSize(t) == 0 {
+			return nil, fmt.Errorf("%w %q", fdi.ErrUnknownValueType, t)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
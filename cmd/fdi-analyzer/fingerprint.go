@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// The built-in signatures, after those of a -signatures file if given so
+// that a game's own variants win over the generic formats
+func loadSignatures(w io.Writer, path string) ([]fdi.Signature, int) {
+	if path == "" {
+		return fdi.KnownSignatures, exitOK
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		logError("Error reading signatures: %v", err)
+		return nil, exitIOError
+	}
+	sigs, err := fdi.ParseSignatures(src)
+	if err != nil {
+		logError("Error in signatures %s: %v", path, err)
+		return nil, exitUsage
+	}
+	return append(sigs, fdi.KnownSignatures...), exitOK
+}
+
+// A format name with its version, if known
+func formatName(fp fdi.Fingerprint) string {
+	if fp.Version == "" {
+		return fp.Name
+	}
+	return fp.Name + " version " + fp.Version
+}
+
+// The parameters of a profile, as a list for the report
+func profileSummary(p fdi.Profile) string {
+	var parts []string
+	if p.RecordSize > 0 {
+		parts = append(parts, fmt.Sprintf("record size %d from 0x%X", p.RecordSize, p.RecordStart))
+	}
+	if p.Encoding != "" {
+		parts = append(parts, "encoding "+p.Encoding)
+	}
+	if p.MinString > 0 {
+		parts = append(parts, fmt.Sprintf("strings of %d+ characters", p.MinString))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// List every signature that matches the file, most specific first
+func printFingerprints(w io.Writer, prints []fdi.Fingerprint) int {
+	if outputJSON {
+		return writeJSON(w, struct {
+			Formats []fdi.Fingerprint `json:"formats"`
+		}{nonNil(prints)})
+	}
+
+	fmt.Fprintln(w, "\n=== Format ===")
+	if len(prints) == 0 {
+		fmt.Fprintln(w, "No known signature matches")
+		return exitNoMatch
+	}
+	for i, fp := range prints {
+		label := "Probably"
+		if i > 0 {
+			label = "Also matches"
+		}
+		fmt.Fprintf(w, "%s: %s (magic %X at 0x%X)\n", label, formatName(fp), []byte(fp.Magic), fp.Offset)
+		if s := profileSummary(fp.Profile); s != "" {
+			fmt.Fprintf(w, "  defaults: %s\n", s)
+		}
+	}
+	return exitOK
+}
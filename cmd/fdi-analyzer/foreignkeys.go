@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// A table decode -foreign-keys compares, with where it was found
+type foundTable struct {
+	Name    string `json:"name"`
+	Start   int    `json:"start"`
+	Records int    `json:"records"`
+}
+
+// Decode the tables of a -registry found in the file, those of the version
+// that fits it best, and the one a -schema describes, and report the integer
+// columns of each that look like references to the records of another
+func printForeignKeys(w io.Writer, data []byte, registryPath, schemaPath string, start, count int) int {
+	var layouts []fdi.Layout
+	if registryPath != "" {
+		versions, code := loadRegistry(registryPath)
+		if code != exitOK {
+			return code
+		}
+		best := fdi.IdentifyVersion(data, versions)[0].Version
+		for _, v := range versions {
+			if v.Name == best {
+				layouts = v.Tables
+				break
+			}
+		}
+	}
+
+	var tables []fdi.Table
+	var found []foundTable
+	for _, l := range layouts {
+		at, n, ok := fdi.FindLayout(data, l)
+		if !ok {
+			continue
+		}
+		records, err := fdi.DecodeLayout(data, l, at, n)
+		if err != nil || len(records) == 0 {
+			continue
+		}
+		tables = append(tables, fdi.Table{Name: l.Name, Schema: l.Schema, Records: records, Ranges: l.Ranges})
+		found = append(found, foundTable{l.Name, at, len(records)})
+	}
+	if schemaPath != "" {
+		schema, code := loadSchema(w, schemaPath, start, count)
+		if code != exitOK {
+			return code
+		}
+		records, err := fdi.DecodeRecords(data, schema)
+		if err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+		name := schema.Name
+		if name == "" {
+			name = strings.TrimSuffix(filepath.Base(schemaPath), filepath.Ext(schemaPath))
+		}
+		tables = append(tables, fdi.Table{Name: name, Schema: schema, Records: records})
+		found = append(found, foundTable{name, schema.Start, len(records)})
+	}
+
+	var keys []fdi.ForeignKey
+	if len(tables) >= 2 {
+		keys = fdi.FindForeignKeys(tables)
+	}
+	if outputJSON {
+		writeJSON(w, struct {
+			Tables      []foundTable     `json:"tables"`
+			ForeignKeys []fdi.ForeignKey `json:"foreign_keys"`
+		}{nonNil(found), nonNil(keys)})
+	} else {
+		fmt.Fprintln(w, "\n=== Tables ===")
+		if len(found) == 0 {
+			fmt.Fprintln(w, "  none found")
+		}
+		for _, t := range found {
+			fmt.Fprintf(w, "  %-10s 0x%X, %d records\n", t.Name, t.Start, t.Records)
+		}
+		if len(tables) < 2 {
+			fmt.Fprintln(w, "\nForeign keys need two tables; give a -registry of them or describe another with -schema")
+		} else {
+			fmt.Fprintln(w, "\n=== Foreign keys ===")
+			if len(keys) == 0 {
+				fmt.Fprintln(w, "  none found")
+			}
+			for _, fk := range keys {
+				fmt.Fprintf(w, "  %-24s -> %-24s %5.1f%% of %d values match, %d of %d records referenced",
+					fk.From+"."+fk.Column, keyName(fk), 100*fk.Matched, fk.Values, fk.Referenced, recordsOf(found, fk.To))
+				if fk.Nulls > 0 {
+					fmt.Fprintf(w, ", %d null", fk.Nulls)
+				}
+				if len(fk.Unmatched) > 0 {
+					fmt.Fprintf(w, " (unmatched: %s)", joinInts(fk.Unmatched))
+				}
+				fmt.Fprintln(w)
+			}
+		}
+	}
+	if len(keys) == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// The key a foreign key refers to, as table.column or by record position
+func keyName(fk fdi.ForeignKey) string {
+	switch fk.Key {
+	case fdi.KeyIndex:
+		return fk.To + " record index"
+	case fdi.KeyNumber:
+		return fk.To + " record number"
+	}
+	return fk.To + "." + fk.Key
+}
+
+func recordsOf(found []foundTable, name string) int {
+	for _, t := range found {
+		if t.Name == name {
+			return t.Records
+		}
+	}
+	return 0
+}
+
+func joinInts(values []int64) string {
+	s := make([]string, len(values))
+	for i, v := range values {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"fdi-analyzer/fdi"
+)
+
+// Ranges from -highlight and -highlight-from, marked in every hex dump; set
+// in main
+var highlights fdi.Highlights
+
+// Read the -highlight specs and the offsets of a JSON results file, or of
+// stdin for "-"
+func loadHighlights(specs []string, fromPath string) (fdi.Highlights, error) {
+	var hs fdi.Highlights
+	for _, spec := range specs {
+		h, err := fdi.ParseHighlights(spec)
+		if err != nil {
+			return nil, err
+		}
+		hs = hs.Add(h)
+	}
+	if fromPath != "" {
+		var src []byte
+		var err error
+		if fromPath == "-" {
+			src, err = io.ReadAll(os.Stdin)
+		} else {
+			src, err = os.ReadFile(fromPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		h, err := fdi.HighlightsFromJSON(src)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", fromPath, err)
+		}
+		logVerbose("Highlighting %d ranges from %s", len(h), fromPath)
+		hs = hs.Add(h)
+	}
+	return hs, nil
+}
+
+// Dump only the rows within around bytes of the highlighted ranges
+func dumpNeighborhoods(w io.Writer, data []byte, around int) int {
+	if len(highlights) == 0 {
+		logError("Please give the offsets to dump around with -highlight or -highlight-from")
+		return exitUsage
+	}
+	windows := highlights.Neighborhoods(around, len(data))
+	if outputJSON {
+		type jsonWindow struct {
+			Offset int           `json:"offset"`
+			End    int           `json:"end"` // exclusive
+			Rows   []fdi.DumpRow `json:"rows"`
+		}
+		list := make([]jsonWindow, 0, len(windows))
+		for _, win := range windows {
+			rows, _ := fdi.Dump(data, fdi.DumpOptions{Offset: win[0], Size: win[1] - win[0], Codepage: dumpCodepage, Annotations: annotations})
+			list = append(list, jsonWindow{win[0], win[1], rows})
+		}
+		return writeJSON(w, struct {
+			Highlights fdi.Highlights `json:"highlights"`
+			Windows    []jsonWindow   `json:"windows"`
+		}{highlights, list})
+	}
+
+	fmt.Fprintf(w, "\n=== Highlighted Ranges (%d, %d bytes around each) ===\n", len(highlights), around)
+	if len(windows) == 0 {
+		fmt.Fprintln(w, "Every highlighted offset is beyond the end of the file")
+		return exitNoMatch
+	}
+	for _, win := range windows {
+		fmt.Fprintf(w, "\n--- 0x%X-0x%X ---\n", win[0], win[1]-1)
+		fdi.WriteDump(w, data, fdi.DumpOptions{
+			Offset:      win[0],
+			Size:        win[1] - win[0],
+			Codepage:    dumpCodepage,
+			Color:       colorOutput,
+			Annotations: annotations,
+			Highlights:  highlights,
+		})
+	}
+	return exitOK
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+
+	"fdi-analyzer/fdi"
+)
+
+// Bytes of the file the report's hex view holds; larger files are shown in
+// the tables but only this far in the hex view
+const htmlHexLimit = 4 << 20
+
+// Cells in the report's entropy heatmap; the window grows to keep to it
+const htmlHeatmapCells = 4096
+
+// Strings listed in the report
+const htmlMaxStrings = 10000
+
+// What -report puts in the HTML report
+type htmlRequest struct {
+	path       string // where to write it
+	file       string // the file analyzed
+	terms      []string
+	iterms     []string // searched ignoring case
+	hexSearch  string
+	searchOpts fdi.SearchOptions
+	analysis   fdi.AnalysisOptions
+	formats    []fdi.Fingerprint
+}
+
+// A highlighted range of the hex view
+type htmlMark struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"` // exclusive
+	Kind  string `json:"kind"`
+	Label string `json:"label"`
+}
+
+type htmlCell struct {
+	Offset int
+	Shade  int // 0 to len(entropyShades)-1
+	Title  string
+}
+
+type htmlHit struct {
+	Term   string
+	Offset int
+	Length int
+	Edits  int
+}
+
+type htmlPage struct {
+	File     string
+	Size     int
+	Formats  []fdi.Fingerprint
+	Stats    fdi.Stats
+	Record   *fdi.StrideCandidate
+	Window   int
+	Cells    []htmlCell
+	Regions  []fdi.EntropyRegion
+	Sections []fdi.CarvedSection
+	Hits     []htmlHit
+	Notes    []fdi.Annotation
+	Strings  []fdi.FoundString
+	More     int // strings beyond htmlMaxStrings
+	Shown    int // bytes in the hex view
+	Script   htmlScript
+}
+
+// The data the hex view's script works from
+type htmlScript struct {
+	Data  string     `json:"data"` // base64
+	Marks []htmlMark `json:"marks"`
+}
+
+// Write a standalone HTML report of the file: a summary, the entropy
+// heatmap, the carved sections, search hits, annotations and strings, and a
+// hex view that every offset in them links to
+func writeHTMLReport(w io.Writer, data []byte, req htmlRequest) int {
+	an := fdi.NewAnalyzer(data, req.analysis).UseCache(analysisCache)
+	page := htmlPage{
+		File:     filepath.Base(req.file),
+		Size:     len(data),
+		Formats:  req.formats,
+		Stats:    fdi.ComputeStats(data),
+		Record:   an.Records(0, len(data)).RecordLength,
+		Sections: fdi.Carve(data),
+		Notes:    annotations,
+		Shown:    min(len(data), htmlHexLimit),
+	}
+	if req.file == "-" {
+		page.File = "stdin"
+	}
+
+	page.Window = fdi.RegionWindow
+	for len(data) > page.Window*htmlHeatmapCells {
+		page.Window *= 2
+	}
+	windows := cachedEntropyMap(data, page.Window)
+	for _, ws := range windows {
+		page.Cells = append(page.Cells, htmlCell{
+			Offset: ws.Offset,
+			Shade:  shadeIndex(ws.Entropy, maxEntropy(ws.Length)),
+			Title:  fmt.Sprintf("0x%X: %.2f bits/byte, %s", ws.Offset, ws.Entropy, ws.Class),
+		})
+	}
+	page.Regions = fdi.EntropyRegions(windows)
+
+	strs := an.Strings()
+	page.Strings = strs[:min(len(strs), htmlMaxStrings)]
+	page.More = len(strs) - len(page.Strings)
+
+	var marks []htmlMark
+	for _, a := range annotations {
+		marks = append(marks, htmlMark{a.Start, a.End + 1, "note", a.Label()})
+	}
+	addHits := func(term string, results []fdi.SearchResult, length int) {
+		for _, r := range results {
+			n := length
+			if r.Length > 0 {
+				n = r.Length
+			}
+			page.Hits = append(page.Hits, htmlHit{term, r.Offset, n, r.Edits})
+			marks = append(marks, htmlMark{r.Offset, r.Offset + n, "hit", "search: " + term})
+		}
+	}
+	iopts := req.searchOpts
+	iopts.IgnoreCase = true
+	for i, term := range append(req.terms[:len(req.terms):len(req.terms)], req.iterms...) {
+		opts := req.searchOpts
+		if i >= len(req.terms) {
+			opts = iopts
+		}
+		pattern := []byte(term)
+		if opts.UTF16 {
+			pattern = fdi.EncodeUTF16LE(term)
+		}
+		results, _ := fdi.Search(data, pattern, opts)
+		addHits(term, results, len(pattern))
+	}
+	if req.hexSearch != "" {
+		pattern, wild, err := fdi.ParseHexWildcards(req.hexSearch)
+		if err != nil {
+			logError("Invalid hex pattern: %v", err)
+			return exitUsage
+		}
+		results, _ := fdi.Search(data, pattern, fdi.SearchOptions{NoOverlap: req.searchOpts.NoOverlap, MaxEdits: req.searchOpts.MaxEdits, Wild: wild})
+		addHits(req.hexSearch, results, len(pattern))
+	}
+
+	page.Script = htmlScript{Data: base64.StdEncoding.EncodeToString(data[:page.Shown]), Marks: nonNil(marks)}
+
+	f, err := os.Create(req.path)
+	if err != nil {
+		logError("Error writing report: %v", err)
+		return exitIOError
+	}
+	err = htmlTemplate.Execute(f, page)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		logError("Error writing report: %v", err)
+		return exitIOError
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Report   string `json:"report"`
+			Sections int    `json:"sections"`
+			Strings  int    `json:"strings"`
+			Hits     int    `json:"hits"`
+		}{req.path, len(page.Sections), len(strs), len(page.Hits)})
+	}
+	fmt.Fprintf(w, "Wrote HTML report to %s: %d sections, %d strings, %d search hits\n", req.path, len(page.Sections), len(strs), len(page.Hits))
+	return exitOK
+}
+
+var htmlTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"hex":    func(n int) string { return fmt.Sprintf("0x%X", n) },
+	"format": formatName,
+	"sub":    func(a, b int) int { return a - b },
+}).Parse(htmlSource))
+
+const htmlSource = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.File}} - fdi-analyzer report</title>
+<style>
+body { font: 14px sans-serif; margin: 0 1.5em 2em; color: #222; }
+h1 { font-size: 1.4em; } h2 { font-size: 1.15em; margin-top: 1.6em; }
+table { border-collapse: collapse; } td, th { padding: 2px 10px 2px 0; text-align: left; vertical-align: top; }
+th { border-bottom: 1px solid #ccc; } .num { text-align: right; }
+a { color: #0645ad; text-decoration: none; } a:hover { text-decoration: underline; }
+code, .mono, #hex { font-family: ui-monospace, Menlo, Consolas, monospace; }
+.scroll { max-height: 24em; overflow-y: auto; border: 1px solid #ddd; padding: 4px; display: inline-block; }
+#heatmap { display: grid; grid-template-columns: repeat(64, 10px); gap: 1px; }
+#heatmap a { display: block; width: 10px; height: 10px; }
+.e0 { background: #f4f4f4; } .e1 { background: #dbe9f6; } .e2 { background: #bad6eb; } .e3 { background: #89bedc; }
+.e4 { background: #539ecd; } .e5 { background: #2b7bba; } .e6 { background: #fdae61; } .e7 { background: #f46d43; }
+.e8 { background: #d73027; } .e9 { background: #a50026; }
+#viewer { position: sticky; top: 0; background: #fff; padding: 6px 0; border-bottom: 1px solid #ccc; }
+#hex { white-space: pre; line-height: 1.4; }
+#hex span[data-o] { cursor: pointer; }
+.note { background: #ffe58f; } .hit { background: #b7eb8f; } .note.hit { background: #d3f261; }
+.sel { outline: 2px solid #d4380d; }
+#status { margin-left: 1em; color: #555; }
+</style>
+</head>
+<body>
+<h1>{{.File}}</h1>
+<table>
+<tr><th>Size</th><td>{{.Size}} bytes ({{hex .Size}})</td></tr>
+{{range .Formats}}<tr><th>Format</th><td>{{format .}}</td></tr>
+{{end}}<tr><th>MD5</th><td class="mono">{{.Stats.MD5}}</td></tr>
+<tr><th>SHA-256</th><td class="mono">{{.Stats.SHA256}}</td></tr>
+<tr><th>CRC32</th><td class="mono">{{printf "%08X" .Stats.CRC32}}</td></tr>
+<tr><th>Entropy</th><td>{{printf "%.2f" .Stats.Entropy}} bits/byte</td></tr>
+{{with .Record}}<tr><th>Record length</th><td>{{.Stride}} bytes, from <a href="#{{hex .Offset}}">{{hex .Offset}}</a> ({{.Gaps}} gaps)</td></tr>
+{{end}}</table>
+
+<h2>Entropy</h2>
+<p>One cell per {{.Window}} bytes, from no entropy (light) to random-looking (dark red); click a cell to view it.</p>
+<div id="heatmap">{{range .Cells}}<a class="e{{.Shade}}" href="#{{hex .Offset}}" title="{{.Title}}"></a>{{end}}</div>
+<p></p>
+<div class="scroll"><table>
+<tr><th>Start</th><th>End</th><th class="num">Bytes</th><th>Bits/byte</th><th>Class</th></tr>
+{{range .Regions}}<tr><td><a href="#{{hex .Start}}">{{hex .Start}}</a></td><td>{{hex .End}}</td><td class="num">{{sub .End .Start}}</td><td>{{printf "%.2f" .Entropy}}</td><td>{{.Class}}</td></tr>
+{{end}}</table></div>
+
+<h2>Sections</h2>
+<div class="scroll"><table>
+<tr><th>Start</th><th>End</th><th>Class</th><th>Cut at</th></tr>
+{{range .Sections}}<tr><td><a href="#{{hex .Start}}">{{hex .Start}}</a></td><td>{{hex .End}}</td><td>{{.Class}}</td><td>{{.Source}}{{with .Detail}}: {{.}}{{end}}</td></tr>
+{{end}}</table></div>
+{{if .Hits}}
+<h2>Search hits</h2>
+<div class="scroll"><table>
+<tr><th>Term</th><th>Offset</th><th class="num">Bytes</th><th class="num">Edits</th></tr>
+{{range .Hits}}<tr><td>{{.Term}}</td><td><a href="#{{hex .Offset}}">{{hex .Offset}}</a></td><td class="num">{{.Length}}</td><td class="num">{{.Edits}}</td></tr>
+{{end}}</table></div>
+{{end}}{{if .Notes}}
+<h2>Annotations</h2>
+<div class="scroll"><table>
+<tr><th>Range</th><th>Note</th></tr>
+{{range .Notes}}<tr><td><a href="#{{hex .Start}}">{{hex .Start}}</a>{{if ne .Start .End}}-{{hex .End}}{{end}}</td><td>{{.Note}}</td></tr>
+{{end}}</table></div>
+{{end}}
+<h2>Strings</h2>
+<div class="scroll"><table>
+<tr><th>Offset</th><th>Encoding</th><th>Text</th></tr>
+{{range .Strings}}<tr><td><a href="#{{hex .Offset}}">{{hex .Offset}}</a></td><td>{{.Encoding}}</td><td class="mono">{{.Text}}</td></tr>
+{{end}}</table></div>
+{{if .More}}<p>and {{.More}} more strings.</p>{{end}}
+
+<h2>Hex view</h2>
+<div id="viewer">
+<button id="prev">&larr; Page</button> <button id="next">Page &rarr;</button>
+<input id="goto" size="12" placeholder="offset, e.g. 0x400"> <button id="go">Go</button>
+<span id="status"></span>
+</div>
+<div id="hex"></div>
+{{if lt .Shown .Size}}<p>The hex view holds the first {{.Shown}} bytes of the file.</p>{{end}}
+
+<script>
+const report = {{.Script}};
+const bytes = Uint8Array.from(atob(report.data), c => c.charCodeAt(0));
+const rows = 32, view = document.getElementById("hex"), statusBar = document.getElementById("status");
+let first = 0, selected = -1;
+
+const hex = (n, width) => n.toString(16).toUpperCase().padStart(width, "0");
+const esc = s => s.replace(/[&<>"]/g, c => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;"})[c]);
+const marksAt = (marks, o) => marks.filter(m => o >= m.start && o < m.end);
+
+function render() {
+	const end = first + rows * 16;
+	const marks = report.marks.filter(m => m.start < end && m.end > first);
+	let out = "";
+	for (let row = first; row < end && row < bytes.length; row += 16) {
+		let hexPart = "", text = "";
+		for (let o = row; o < row + 16; o++) {
+			if (o >= bytes.length) { hexPart += "   "; continue; }
+			const here = marksAt(marks, o);
+			const cls = here.map(m => m.kind).concat(o === selected ? ["sel"] : []).join(" ");
+			const attrs = ' data-o="' + o + '" class="' + cls + '" title="' + esc("0x" + hex(o, 1) + here.map(m => "\n" + m.label).join("")) + '"';
+			const b = bytes[o];
+			hexPart += "<span" + attrs + ">" + hex(b, 2) + "</span> ";
+			text += "<span" + attrs + ">" + (b >= 0x20 && b < 0x7F ? esc(String.fromCharCode(b)) : ".") + "</span>";
+		}
+		out += hex(row, 8) + " | " + hexPart + "| " + text + "\n";
+	}
+	view.innerHTML = out;
+}
+
+function show(offset) {
+	if (isNaN(offset) || offset < 0) return;
+	if (offset >= bytes.length) {
+		statusBar.textContent = "0x" + hex(offset, 1) + " is past the " + bytes.length + " bytes in this report";
+		return;
+	}
+	selected = offset;
+	first = Math.max(0, Math.floor(offset / 16) * 16 - 4 * 16);
+	render();
+	const notes = marksAt(report.marks, offset).map(m => m.label).join("; ");
+	statusBar.textContent = "0x" + hex(offset, 1) + " (" + offset + "): 0x" + hex(bytes[offset], 2) + (notes ? " - " + notes : "");
+	document.getElementById("viewer").scrollIntoView();
+}
+
+const fromHash = () => show(Number(decodeURIComponent(location.hash.slice(1))));
+window.addEventListener("hashchange", fromHash);
+view.addEventListener("click", e => { const o = e.target.dataset.o; if (o !== undefined) location.hash = "0x" + hex(Number(o), 1); });
+document.getElementById("prev").onclick = () => { first = Math.max(0, first - rows * 16); render(); };
+document.getElementById("next").onclick = () => { if (first + rows * 16 < bytes.length) first += rows * 16; render(); };
+document.getElementById("go").onclick = () => { location.hash = document.getElementById("goto").value.trim(); };
+document.getElementById("goto").addEventListener("keydown", e => { if (e.key === "Enter") document.getElementById("go").click(); });
+if (location.hash) fromHash(); else render();
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"fdi-analyzer/fdi"
+)
+
+// The sidecar file holding the history of a file's edits
+func journalPath(file string) string {
+	return file + ".journal"
+}
+
+// Read the journal of file; an empty one when it has none yet
+func loadJournal(file string) (fdi.Journal, error) {
+	var j fdi.Journal
+	src, err := os.ReadFile(journalPath(file))
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return j, err
+	}
+	if err := json.Unmarshal(src, &j); err != nil {
+		return j, fmt.Errorf("%s: %v", journalPath(file), err)
+	}
+	return j, nil
+}
+
+func saveJournal(file string, j fdi.Journal) error {
+	out, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(journalPath(file), append(out, '\n'), 0o644)
+}
+
+// Add an edit made in place to the file's journal, so it can be undone
+func journalEdit(file string, before, after []byte) int {
+	j, err := loadJournal(file)
+	if err == nil {
+		edit := progName() + " " + strings.Join(os.Args[1:], " ")
+		if j.Record(edit, before, after, time.Now()) {
+			err = saveJournal(file, j)
+		}
+	}
+	if err != nil {
+		logError("Error writing journal: %v", err)
+		return exitIOError
+	}
+	return exitOK
+}
+
+// Undo the last edit of the file recorded in its journal, or with redo make
+// the last one undone again
+func undoEdit(w io.Writer, path string, redo bool) int {
+	if path == "-" || isArchived(path) {
+		logError("Cannot undo edits of stdin or a file inside an archive; give the file that was edited")
+		return exitUsage
+	}
+	data, release, err := readInput(path)
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	defer release()
+	j, err := loadJournal(path)
+	if err != nil {
+		logError("Error reading journal: %v", err)
+		return exitIOError
+	}
+
+	name, step := "Undo", j.Undo
+	if redo {
+		name, step = "Redo", j.Redo
+	}
+	changed, e, err := step(data)
+	if errors.Is(err, fdi.ErrNothingToUndo) || errors.Is(err, fdi.ErrNothingToRedo) {
+		fmt.Fprintf(w, "Nothing to %s in %s\n", strings.ToLower(name), journalPath(path))
+		return exitNoMatch
+	}
+	if err != nil {
+		logError("Cannot %s: %v", strings.ToLower(name), err)
+		return exitUsage
+	}
+
+	if err := replaceFile(path, changed); err != nil {
+		logError("Error writing file: %v", err)
+		return exitIOError
+	}
+	if err := saveJournal(path, j); err != nil {
+		logError("Error writing journal: %v", err)
+		return exitIOError
+	}
+
+	if outputJSON {
+		res := struct {
+			File    string            `json:"file"`
+			Undone  *fdi.JournalEntry `json:"undone,omitempty"`
+			Redone  *fdi.JournalEntry `json:"redone,omitempty"`
+			CanUndo int               `json:"can_undo"`
+			CanRedo int               `json:"can_redo"`
+		}{File: path, CanUndo: j.CanUndo(), CanRedo: j.CanRedo()}
+		if redo {
+			res.Redone = &e
+		} else {
+			res.Undone = &e
+		}
+		return writeJSON(w, res)
+	}
+	fmt.Fprintf(w, "\n=== %s: %s (%s) ===\n", name, e.Edit, e.Time.Format(time.DateTime))
+	for _, c := range e.Changes {
+		fmt.Fprintf(w, "\n--- 0x%X (%d bytes) ---\n", c.Offset, len(c.Old))
+		fmt.Fprintln(w, "Before:")
+		printFileHeader(w, data, len(c.Old), c.Offset)
+		fmt.Fprintln(w, "After:")
+		printFileHeader(w, changed, len(c.Old), c.Offset)
+	}
+	fmt.Fprintf(w, "\n%d edits can be undone and %d redone\n", j.CanUndo(), j.CanRedo())
+	return exitOK
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+
+	"fdi-analyzer/fdi"
+)
+
+// Whether results are written as JSON instead of text; set from -format in main
+var outputJSON bool
+
+// Write v as indented JSON
+func writeJSON(w io.Writer, v any) int {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		logError("Error encoding JSON: %v", err)
+		return exitIOError
+	}
+	return exitOK
+}
+
+// The default report as JSON: the dump, any searches and the record analysis,
+// or the one part a subcommand asks for
+type jsonReport struct {
+	FileSize int               `json:"file_size"`
+	Formats  []fdi.Fingerprint `json:"formats,omitempty"` // most specific first
+	Dump     *jsonDump         `json:"dump,omitempty"`
+	Searches []jsonSearch      `json:"searches,omitempty"`
+	Regex    *jsonRegex        `json:"regex,omitempty"` // only with -regex
+	BCD      []fdi.BCDNumber   `json:"bcd,omitempty"`   // only with -bcd-scan
+	Records  *fdi.RecordReport `json:"records,omitempty"`
+	Strings  []fdi.FoundString `json:"strings,omitempty"` // only from the strings subcommand
+}
+
+type jsonDump struct {
+	Offset int           `json:"offset"`
+	Rows   []fdi.DumpRow `json:"rows"`
+}
+
+type jsonSearch struct {
+	Term       string             `json:"term"`
+	Hex        bool               `json:"hex,omitempty"` // Term is a hex byte pattern
+	IgnoreCase bool               `json:"ignore_case,omitempty"`
+	Matches    []fdi.SearchResult `json:"matches"`
+}
+
+type jsonRegex struct {
+	Pattern string            `json:"pattern"`
+	Matches []fdi.RegexpMatch `json:"matches"`
+}
+
+// What the default report covers
+type reportRequest struct {
+	dump       fdi.DumpOptions
+	terms      []string
+	iterms     []string // searched ignoring case
+	regex      *regexp.Regexp
+	searchOpts fdi.SearchOptions
+	hexSearch  string
+	bcd        bool
+	start, end int // record analysis range
+	analysis   fdi.AnalysisOptions
+	formats    []fdi.Fingerprint
+	only       string // dump, search, strings or records for just that part; "" for the whole report
+}
+
+// Whether the report includes a part
+func (r reportRequest) has(part string) bool {
+	return r.only == "" || r.only == part
+}
+
+// Run the default report's analyses. Only an invalid hex pattern is an error.
+// Unlike the text report, lists are not truncated.
+func buildReport(data []byte, req reportRequest) (jsonReport, error) {
+	an := fdi.NewAnalyzer(data, req.analysis).UseCache(analysisCache)
+	report := jsonReport{FileSize: len(data), Formats: req.formats}
+
+	if req.has("dump") {
+		done := logPass("dump")
+		report.Dump = &jsonDump{Offset: req.dump.Offset}
+		if rows, err := an.Dump(req.dump); err == nil {
+			report.Dump.Rows = rows
+		}
+		done()
+	}
+
+	if len(req.terms) > 0 || len(req.iterms) > 0 {
+		done := logPass("search")
+		for _, term := range req.terms {
+			results, _ := an.SearchText(term, req.searchOpts)
+			report.Searches = append(report.Searches, jsonSearch{Term: term, IgnoreCase: req.searchOpts.IgnoreCase, Matches: nonNil(results)})
+		}
+		iopts := req.searchOpts
+		iopts.IgnoreCase = true
+		for _, term := range req.iterms {
+			results, _ := an.SearchText(term, iopts)
+			report.Searches = append(report.Searches, jsonSearch{Term: term, IgnoreCase: true, Matches: nonNil(results)})
+		}
+		done()
+	}
+	if req.hexSearch != "" {
+		done := logPass("hexsearch")
+		pattern, wild, err := fdi.ParseHexWildcards(req.hexSearch)
+		if err != nil {
+			return jsonReport{}, err
+		}
+		opts := req.hexOpts()
+		opts.Wild = wild
+		results, _ := an.Search(pattern, opts)
+		report.Searches = append(report.Searches, jsonSearch{Term: req.hexSearch, Hex: true, Matches: nonNil(results)})
+		done()
+	}
+
+	if req.regex != nil {
+		done := logPass("regex")
+		report.Regex = &jsonRegex{Pattern: req.regex.String(), Matches: nonNil(an.SearchRegexp(req.regex))}
+		done()
+	}
+
+	if req.bcd {
+		done := logPass("bcd")
+		report.BCD = fdi.ScanBCD(data)
+		done()
+	}
+	if req.has("records") {
+		done := logPass("records")
+		records := an.Records(req.start, req.end)
+		report.Records = &records
+		done()
+	}
+	if req.only == "strings" {
+		done := logPass("strings")
+		report.Strings = nonNil(rangeStrings(data, req.start, req.end, req.analysis))
+		done()
+	}
+	return report, nil
+}
+
+// Hex searches take no case or text encoding
+func (req reportRequest) hexOpts() fdi.SearchOptions {
+	return fdi.SearchOptions{NoOverlap: req.searchOpts.NoOverlap, MaxEdits: req.searchOpts.MaxEdits}
+}
+
+// Empty lists rather than null keep the JSON shape stable
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Bytes of a raw value shown in the tree
+const kaitaiPreview = 16
+
+// Parse data from offset with a Kaitai Struct definition and print the tree
+// of values it reads
+func decodeKaitai(w io.Writer, data []byte, path string, offset int) int {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		logError("Error reading definition: %v", err)
+		return exitIOError
+	}
+	spec, err := fdi.ParseKaitai(src)
+	if err != nil {
+		logError("Error in definition %s: %v", path, err)
+		return exitUsage
+	}
+	root, err := spec.Parse(data, offset)
+	if err != nil {
+		fmt.Fprintf(w, "The file does not parse with %s: %v\n", path, err)
+		return exitNoMatch
+	}
+	if outputJSON {
+		return writeJSON(w, root)
+	}
+
+	fmt.Fprintf(w, "\n=== %s (%s, Offset: 0x%X, %d bytes) ===\n", root.Name, filepath.Base(path), root.Offset, root.Size)
+	for _, f := range root.Fields {
+		printKaitaiNode(w, f, "", 0)
+	}
+	return exitOK
+}
+
+// Print a node with its offset and size, then its fields or items indented
+func printKaitaiNode(w io.Writer, n *fdi.KaitaiNode, name string, depth int) {
+	if name == "" {
+		name = n.Name
+	}
+	line := fmt.Sprintf("0x%08X %6d  %s%s", n.Offset, n.Size, strings.Repeat("  ", depth), name)
+	switch {
+	case n.Value != nil:
+		line += " = " + kaitaiValue(n.Value)
+		if n.Enum != "" {
+			line += " (" + n.Enum + ")"
+		}
+	case strings.HasSuffix(n.Type, "[]"):
+		line += fmt.Sprintf(": %s[%d]", strings.TrimSuffix(n.Type, "[]"), len(n.Items))
+	default:
+		line += ": " + n.Type
+	}
+	fmt.Fprintln(w, line)
+
+	for _, f := range n.Fields {
+		printKaitaiNode(w, f, "", depth+1)
+	}
+	for i, item := range n.Items {
+		printKaitaiNode(w, item, fmt.Sprintf("[%d]", i), depth+1)
+	}
+}
+
+// A value as the tree shows it, with long byte runs cut short
+func kaitaiValue(v any) string {
+	if b, ok := v.(fdi.HexBytes); ok && len(b) > kaitaiPreview {
+		return fmt.Sprintf("% X ... (%d bytes)", []byte(b[:kaitaiPreview]), len(b))
+	}
+	if b, ok := v.(fdi.HexBytes); ok {
+		return fmt.Sprintf("% X", []byte(b))
+	}
+	return formatFieldValue(v)
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// What the decode command decodes
+type layoutRequest struct {
+	layout   fdi.Layout
+	start    int
+	given    bool // start is from -offset or the schema; otherwise the table is searched for
+	count    int  // 0 means the run of plausible records
+	export   exportRequest
+	exported bool // -export was given
+}
+
+// Decode the table a schema describes as a table, JSON or an export
+func decodeLayout(w io.Writer, data []byte, req layoutRequest) int {
+	l := req.layout
+	start, count := req.start, req.count
+	if !req.given {
+		var ok bool
+		if start, count, ok = fdi.FindLayout(data, l); !ok {
+			fmt.Fprintf(w, "No %s table found; give its -offset if you know where it starts, or ranges for its fields to check records by\n", l.Name)
+			return exitNoMatch
+		}
+		if req.count > 0 {
+			count = req.count
+		}
+	}
+
+	records, err := fdi.DecodeLayout(data, l, start, count)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	schema := l.Schema
+	schema.Start, schema.Count = start, len(records)
+
+	if req.exported {
+		req.export.schema, req.export.records = schema, records
+		return exportData(w, data, req.export)
+	}
+	if outputJSON {
+		return writeJSON(w, struct {
+			Layout  string       `json:"layout"`
+			Schema  fdi.Schema   `json:"schema"`
+			Records []fdi.Record `json:"records"`
+		}{l.Name, schema, records})
+	}
+
+	fmt.Fprintf(w, "\n=== %s (Offset: 0x%X, Record size: %d, Records: %d) ===\n",
+		l.Description, start, schema.RecordSize, len(records))
+	printRecordTable(w, schema, records)
+	return exitOK
+}
+
+// Read the table a schema file describes, with the ranges and labels of its
+// fields. A table with no name is named after the file.
+func loadLayout(path string) (fdi.Layout, int) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		logError("Error reading schema: %v", err)
+		return fdi.Layout{}, exitIOError
+	}
+	l, err := fdi.ParseLayout(src)
+	if err != nil {
+		logError("Error in schema %s: %v", path, err)
+		return fdi.Layout{}, exitUsage
+	}
+	if l.Name == "" {
+		l.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if l.Description == "" {
+		l.Description = l.Name
+	}
+	return l, exitOK
+}
+
+// Read the format versions of a registry file
+func loadRegistry(path string) ([]fdi.Version, int) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		logError("Error reading registry: %v", err)
+		return nil, exitIOError
+	}
+	versions, err := fdi.ParseRegistry(src)
+	if err != nil {
+		logError("Error in registry %s: %v", path, err)
+		return nil, exitUsage
+	}
+	return versions, exitOK
+}
+
+// Test the versions of the format in a registry file against the file and
+// report how well each fits. None are built in until the tables of real
+// versions have been mapped, and with a single version there is nothing to
+// choose between, so only its confidence is reported.
+func identifyVersion(w io.Writer, data []byte, registryPath string) int {
+	if registryPath == "" {
+		logError("-identify needs a -registry of the versions to tell apart; none are built in")
+		return exitUsage
+	}
+	versions, code := loadRegistry(registryPath)
+	if code != exitOK {
+		return code
+	}
+
+	matches := fdi.IdentifyVersion(data, versions)
+	fits := matches[0].Confidence > 0
+	best := ""
+	if fits && len(matches) > 1 {
+		best = matches[0].Version
+	}
+	if outputJSON {
+		writeJSON(w, struct {
+			Best     string             `json:"best,omitempty"` // absent with a single version
+			Known    int                `json:"known_versions"`
+			Versions []fdi.VersionMatch `json:"versions"`
+		}{best, len(matches), matches})
+	} else {
+		if len(matches) == 1 {
+			fmt.Fprintf(w, "\nThe registry holds one version (%s); the confidence tells how well the file fits it, not which version it is\n", matches[0].Version)
+		}
+		fmt.Fprintln(w, "\n=== Format versions ===")
+		for _, m := range matches {
+			fmt.Fprintf(w, "  %-12s %5.1f%%  %s\n", m.Version, 100*m.Confidence, m.Description)
+			for _, t := range m.Tables {
+				if t.Found {
+					fmt.Fprintf(w, "      %-10s 0x%X, %d records\n", t.Table, t.Start, t.Records)
+				} else {
+					fmt.Fprintf(w, "      %-10s not found\n", t.Table)
+				}
+			}
+		}
+		switch {
+		case best != "":
+			fmt.Fprintf(w, "\nBest match: %s (confidence %.1f%%)\n", best, 100*matches[0].Confidence)
+		case fits:
+			fmt.Fprintf(w, "\nThe file fits %s with confidence %.1f%%\n", matches[0].Version, 100*matches[0].Confidence)
+		default:
+			fmt.Fprintln(w, "\nNo version in the registry matches")
+		}
+	}
+	if !fits {
+		return exitNoMatch
+	}
+	return exitOK
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Between info and debug: what each step found or chose
+const levelVerbose = slog.Level(-2)
+
+// Diagnostics go to stderr so that the results on stdout can be piped; -q
+// keeps only the errors, -v adds what each step found or chose, and -v -v
+// the time each analysis pass took
+var (
+	logLevel = new(slog.LevelVar)
+	logger   = slog.New(&logHandler{out: os.Stderr, level: logLevel, mu: new(sync.Mutex)})
+)
+
+// The number of times a flag is given, for -v -v
+type countFlag int
+
+func (c *countFlag) String() string   { return fmt.Sprint(int(*c)) }
+func (c *countFlag) IsBoolFlag() bool { return true }
+
+func (c *countFlag) Set(v string) error {
+	switch v {
+	case "true":
+		*c++
+	case "false":
+		*c = 0
+	default:
+		return fmt.Errorf("expected no value, got %q", v)
+	}
+	return nil
+}
+
+// Set the level for -q and -v, and log JSON objects rather than lines when
+// the results are JSON
+func setupLogging(out io.Writer, quiet bool, verbosity int, jsonLines bool) {
+	switch {
+	case quiet:
+		logLevel.Set(slog.LevelError)
+	case verbosity >= 2:
+		logLevel.Set(slog.LevelDebug)
+	case verbosity == 1:
+		logLevel.Set(levelVerbose)
+	default:
+		logLevel.Set(slog.LevelInfo)
+	}
+	if jsonLines {
+		logger = slog.New(slog.NewJSONHandler(out, &slog.HandlerOptions{Level: logLevel, ReplaceAttr: levelName}))
+	} else {
+		logger = slog.New(&logHandler{out: out, level: logLevel, mu: new(sync.Mutex)})
+	}
+}
+
+// Name the verbose level in JSON rather than as DEBUG+2
+func levelName(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 && a.Value.Any() == levelVerbose {
+		a.Value = slog.StringValue("VERBOSE")
+	}
+	return a
+}
+
+// An error that stops the run, or a flag value that cannot be used
+func logError(format string, args ...any) {
+	logger.Error(fmt.Sprintf(format, args...))
+}
+
+// Progress and notes: files written, deprecated usage
+func logInfo(format string, args ...any) {
+	logger.Info(fmt.Sprintf(format, args...))
+}
+
+// What a step found or chose, shown with -v
+func logVerbose(format string, args ...any) {
+	logger.Log(context.Background(), levelVerbose, fmt.Sprintf(format, args...))
+}
+
+// Time an analysis pass, logged at debug level when it ends:
+//
+//	defer logPass("records")()
+func logPass(name string) func() {
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return func() {}
+	}
+	start := time.Now()
+	return func() { logger.Debug("pass", "name", name, "took", time.Since(start)) }
+}
+
+// Plain lines for a terminal: the message, with debug records marked as such
+// and followed by their attributes
+type logHandler struct {
+	out   io.Writer
+	level slog.Leveler
+	attrs []slog.Attr
+	mu    *sync.Mutex // shared by the handlers derived from one
+}
+
+func (h *logHandler) Enabled(_ context.Context, l slog.Level) bool { return l >= h.level.Level() }
+
+func (h *logHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	if r.Level <= slog.LevelDebug {
+		b.WriteString("debug: ")
+	}
+	b.WriteString(r.Message)
+	add := func(a slog.Attr) bool {
+		fmt.Fprintf(&b, " %s=%v", a.Key, a.Value)
+		return true
+	}
+	for _, a := range h.attrs {
+		add(a)
+	}
+	r.Attrs(add)
+	b.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(h.out, b.String())
+	return err
+}
+
+func (h *logHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = append(c.attrs[:len(c.attrs):len(c.attrs)], attrs...)
+	return &c
+}
+
+// Groups are not used; their attributes are kept flat
+func (h *logHandler) WithGroup(string) slog.Handler { return h }
@@ -0,0 +1,14 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+// Memory mapping is not available here, so files larger than -maxmem are
+// streamed instead
+func mapFile(f *os.File, size int64) ([]byte, func(), error) {
+	return nil, nil, errors.ErrUnsupported
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Padding runs listed in the text output
+const paddingRunLimit = 20
+
+// Print the padding runs of data[start:end] and how the blocks after them
+// are aligned
+func printPadding(w io.Writer, data []byte, start int, end int, minRun int) int {
+	if start >= len(data) {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+	if end <= 0 || end > len(data) {
+		end = len(data)
+	}
+	if end <= start {
+		logError("End offset must be greater than the start offset")
+		return exitUsage
+	}
+	if minRun <= 0 {
+		minRun = fdi.MinPaddingRun
+	}
+
+	report := fdi.AnalyzePaddingRange(data, start, end, minRun)
+	if outputJSON {
+		if c := writeJSON(w, report); c != exitOK || len(report.Runs) > 0 {
+			return c
+		}
+		return exitNoMatch
+	}
+
+	fmt.Fprintf(w, "\n=== Padding (0x%X-0x%X, runs of %d or more) ===\n", start, end-1, minRun)
+	if len(report.Runs) == 0 {
+		fmt.Fprintln(w, "No padding found")
+		return exitNoMatch
+	}
+	for i, r := range report.Runs {
+		if i >= paddingRunLimit {
+			fmt.Fprintf(w, "... and %d more runs\n", len(report.Runs)-i)
+			break
+		}
+		fmt.Fprintf(w, "0x%08X-0x%08X %8d bytes of 0x%02X  next block aligned to %d\n", r.Start, r.End-1, r.End-r.Start, r.Byte, r.Alignment)
+	}
+	fmt.Fprintf(w, "%d runs, %d bytes (%.1f%% of the range)\n", len(report.Runs), report.Bytes, 100*float64(report.Bytes)/float64(end-start))
+
+	fmt.Fprintln(w, "\nBlocks after padding:")
+	for _, a := range report.Alignment {
+		fmt.Fprintf(w, "  %3d-byte aligned: %d of %d\n", a.Boundary, a.Aligned, a.Blocks)
+	}
+	if report.BlockSize > 0 {
+		fmt.Fprintf(w, "Structures look aligned to %d bytes\n", report.BlockSize)
+	} else {
+		fmt.Fprintln(w, "No common alignment")
+	}
+	return exitOK
+}
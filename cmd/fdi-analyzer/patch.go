@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"fdi-analyzer/fdi"
+)
+
+// Apply the patch specs, show before/after dumps and write the result to
+// outPath, recomputing checksums if asked. An outPath that is the input file
+// itself edits it in place as -write-offset does, with a .bak copy and a
+// journal entry so the edit can be undone.
+func patchFile(w io.Writer, data []byte, path string, specs []string, outPath string, fix checksumFix) int {
+	if outPath == "" {
+		logError("Please specify where to write the patched file with -out")
+		return exitUsage
+	}
+
+	patches := make([]fdi.Patch, 0, len(specs))
+	for _, spec := range specs {
+		p, err := fdi.ParsePatch(spec)
+		if err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+		patches = append(patches, p)
+	}
+
+	patched, err := fdi.ApplyPatches(data, patches)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	patched, checked, fixed, code := applyChecksumFix(w, data, patched, fix)
+	if code != exitOK {
+		return code
+	}
+
+	if outputJSON {
+		type jsonPatch struct {
+			Offset int          `json:"offset"`
+			Before fdi.HexBytes `json:"before"`
+			After  fdi.HexBytes `json:"after"`
+		}
+		applied := make([]jsonPatch, 0, len(patches))
+		for _, p := range patches {
+			end := p.Offset + len(p.Bytes)
+			applied = append(applied, jsonPatch{p.Offset, data[p.Offset:end], patched[p.Offset:end]})
+		}
+		backup, code := writePatched(data, patched, path, outPath)
+		if code != exitOK {
+			return code
+		}
+		return writeJSON(w, struct {
+			Out       string          `json:"out"`
+			Backup    string          `json:"backup,omitempty"`
+			Patches   []jsonPatch     `json:"patches"`
+			Checksums []fixedChecksum `json:"fixed_checksums,omitempty"`
+		}{outPath, backup, applied, fixed})
+	}
+
+	for _, p := range patches {
+		fmt.Fprintf(w, "\n=== Patch at 0x%X (%d bytes) ===\n", p.Offset, len(p.Bytes))
+		fmt.Fprintln(w, "Before:")
+		printFileHeader(w, data, len(p.Bytes), p.Offset)
+		fmt.Fprintln(w, "After:")
+		printFileHeader(w, patched, len(p.Bytes), p.Offset)
+	}
+	printChecksumFixes(w, fix, checked, fixed)
+
+	backup, code := writePatched(data, patched, path, outPath)
+	if code != exitOK {
+		return code
+	}
+	if backup != "" {
+		fmt.Fprintf(w, "\nApplied %d patches to %s in place (backup: %s)\n", len(patches), path, backup)
+		return exitOK
+	}
+	fmt.Fprintf(w, "\nApplied %d patches, wrote %s\n", len(patches), outPath)
+	return exitOK
+}
+
+// Write the patched bytes to outPath or, when that is the input file, over
+// it with a backup and a journal entry; the backup's path is returned for an
+// edit in place
+func writePatched(data, patched []byte, path, outPath string) (string, int) {
+	if !sameFile(path, outPath) {
+		if err := os.WriteFile(outPath, patched, 0644); err != nil {
+			logError("Error writing file: %v", err)
+			return "", exitIOError
+		}
+		return "", exitOK
+	}
+	if isArchived(path) {
+		logError("Cannot patch a file inside an archive in place; give another -out")
+		return "", exitUsage
+	}
+	backup := path + ".bak"
+	if err := os.WriteFile(backup, data, 0o644); err != nil {
+		logError("Error writing backup: %v", err)
+		return "", exitIOError
+	}
+	if err := replaceFile(path, patched); err != nil {
+		logError("Error writing file: %v", err)
+		return "", exitIOError
+	}
+	return backup, journalEdit(path, data, patched)
+}
+
+// Whether the two paths name the same existing file
+func sameFile(a, b string) bool {
+	if a == "-" || b == "-" {
+		return false
+	}
+	ia, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	ib, err := os.Stat(b)
+	return err == nil && os.SameFile(ia, ib)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// What a plugin is given on stdin: where the bytes are and what the
+// analyzer already knows about them
+type pluginContext struct {
+	File        string            `json:"file"` // the bytes to analyze; a temporary copy for stdin or -decompress-at
+	Source      string            `json:"source"`
+	Size        int               `json:"size"`
+	Offset      int               `json:"offset"`
+	End         int               `json:"end"`
+	RecordSize  int               `json:"record_size,omitempty"`
+	RecordStart int               `json:"record_start"`
+	Formats     []fdi.Fingerprint `json:"formats"`
+	Annotations []fdi.Annotation  `json:"annotations"`
+}
+
+// What a plugin writes to stdout
+type pluginReport struct {
+	Title    string          `json:"title"`
+	Findings []pluginFinding `json:"findings"`
+}
+
+type pluginFinding struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length,omitempty"`
+	Note   string `json:"note"`
+	Value  any    `json:"value,omitempty"`
+}
+
+// Bytes of each finding shown in the table
+const pluginPreview = 8
+
+// Run a plugin: a program, with its arguments, given the context as JSON on
+// stdin that answers with a report of findings as JSON on stdout
+func runPlugin(w io.Writer, data []byte, command string, ctx pluginContext) int {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		logError("Please give the plugin program to run")
+		return exitUsage
+	}
+	if ctx.File == "-" || ctx.File == "" || isArchived(ctx.File) {
+		f, err := os.CreateTemp("", "fdi-analyzer-plugin-*")
+		if err == nil {
+			defer os.Remove(f.Name())
+			_, err = f.Write(data)
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+		}
+		if err != nil {
+			logError("Error writing file for the plugin: %v", err)
+			return exitIOError
+		}
+		ctx.File = f.Name()
+	} else if abs, err := filepath.Abs(ctx.File); err == nil {
+		ctx.File = abs
+	}
+	ctx.Size = len(data)
+	ctx.Formats, ctx.Annotations = nonNil(ctx.Formats), nonNil(ctx.Annotations)
+
+	input, err := json.Marshal(ctx)
+	if err != nil {
+		logError("Error encoding JSON: %v", err)
+		return exitIOError
+	}
+	var out bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = bytes.NewReader(input), &out, os.Stderr
+	if err := cmd.Run(); err != nil {
+		logError("Plugin %s failed: %v", args[0], err)
+		return exitIOError
+	}
+
+	var report pluginReport
+	if err := json.Unmarshal(out.Bytes(), &report); err != nil {
+		logError("Plugin %s wrote no valid report: %v", args[0], err)
+		return exitIOError
+	}
+	if report.Title == "" {
+		report.Title = filepath.Base(args[0])
+	}
+	report.Findings = nonNil(report.Findings)
+
+	code := exitOK
+	if len(report.Findings) == 0 {
+		code = exitNoMatch
+	}
+	if outputJSON {
+		if c := writeJSON(w, report); c != exitOK {
+			return c
+		}
+		return code
+	}
+
+	fmt.Fprintf(w, "\n=== %s: %d findings ===\n", report.Title, len(report.Findings))
+	for _, f := range report.Findings {
+		preview := ""
+		if f.Offset >= 0 && f.Offset < len(data) {
+			n := pluginPreview
+			if f.Length > 0 {
+				n = min(n, f.Length)
+			}
+			preview = fmt.Sprintf("% X", data[f.Offset:min(f.Offset+n, len(data))])
+		}
+		line := fmt.Sprintf("0x%08X %6d  %-23s  %s", f.Offset, f.Length, preview, f.Note)
+		if f.Value != nil {
+			line += fmt.Sprintf(" = %v", f.Value)
+		}
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	return code
+}
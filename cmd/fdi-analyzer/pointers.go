@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// List candidate offset tables; with follow, dump up to dumpSize bytes of
+// each region they point to
+func printPointerTables(w io.Writer, data []byte, minEntries int, follow bool, dumpSize int, limit int) int {
+	tables := fdi.FindPointerTables(data, minEntries)
+
+	if outputJSON {
+		type jsonTarget struct {
+			Offset int          `json:"offset"`
+			Length int          `json:"length"` // up to the next target
+			Bytes  fdi.HexBytes `json:"bytes"`
+		}
+		type jsonTable struct {
+			fdi.PointerTable
+			Regions []jsonTarget `json:"regions,omitempty"`
+		}
+		out := make([]jsonTable, 0, len(tables))
+		for _, t := range tables {
+			jt := jsonTable{PointerTable: t}
+			if follow {
+				for i := range t.Targets {
+					off, n := targetRegion(data, t, i)
+					jt.Regions = append(jt.Regions, jsonTarget{off, n, data[off : off+min(n, dumpSize)]})
+				}
+			}
+			out = append(out, jt)
+		}
+		if code := writeJSON(w, struct {
+			Tables []jsonTable `json:"tables"`
+		}{out}); code != exitOK {
+			return code
+		}
+	} else {
+		fmt.Fprintln(w, "\n=== Pointer Tables ===")
+		for _, t := range tables {
+			kind := "absolute"
+			if t.Relative {
+				kind = fmt.Sprintf("relative to 0x%X", t.Offset)
+			}
+			fmt.Fprintf(w, "0x%-8X %d x u%d%s, %s, pointing to 0x%X-0x%X\n", t.Offset, len(t.Values), t.Width*8, t.Endian, kind, t.Targets[0], t.Targets[len(t.Targets)-1])
+
+			shown := capped(t.Targets, limit)
+			for i := 0; i < len(shown); i += 8 {
+				line := make([]string, 0, 8)
+				for _, off := range shown[i:min(i+8, len(shown))] {
+					line = append(line, fmt.Sprintf("0x%X", off))
+				}
+				fmt.Fprintf(w, "  %s\n", strings.Join(line, " "))
+			}
+			if len(shown) < len(t.Targets) {
+				fmt.Fprintf(w, "  ... and %d more\n", len(t.Targets)-len(shown))
+			}
+
+			if follow {
+				for i := range shown {
+					off, n := targetRegion(data, t, i)
+					if n == 0 {
+						continue // an end marker
+					}
+					fmt.Fprintf(w, "\nEntry %d -> 0x%X (%d bytes to the next):", i, off, n)
+					printFileHeader(w, data, min(n, dumpSize), off)
+				}
+				fmt.Fprintln(w)
+			}
+		}
+		if len(tables) == 0 {
+			fmt.Fprintf(w, "No runs of %d or more increasing offsets found\n", minEntries)
+		}
+	}
+
+	if len(tables) == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+// The region entry i of t points to, up to the next entry or the end of data
+func targetRegion(data []byte, t fdi.PointerTable, i int) (int, int) {
+	off := t.Targets[i]
+	next := len(data)
+	if i+1 < len(t.Targets) {
+		next = t.Targets[i+1]
+	}
+	return off, next - off
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"fdi-analyzer/fdi"
+)
+
+// How long a pass runs before -progress auto shows its bar, so that quick
+// ones leave no trace, how often the bar is redrawn and its width
+const (
+	progressDelay  = time.Second
+	progressRedraw = 100 * time.Millisecond
+	progressWidth  = 30
+)
+
+// The progress of the analysis passes, shown by meter; set from -progress in
+// main
+var (
+	progress = &fdi.Progress{}
+	meter    *progressMeter // nil shows nothing
+)
+
+// Shows how far each pass has got on stderr
+type progressMeter struct {
+	out   io.Writer
+	bar   bool          // redraw a bar on one line rather than log every tenth
+	delay time.Duration // before a bar first shows
+
+	mu      sync.Mutex
+	pass    string
+	started time.Time
+	drawn   time.Time // when the bar was last drawn; zero while the line is clear
+	tenth   int       // of the pass last logged
+}
+
+// Set up the meter for -progress: auto draws a bar when stderr is a terminal
+// and a pass runs for more than a second, bar draws it from the start,
+// percent logs every tenth of each pass and off shows nothing. -q leaves only
+// a bar asked for.
+func setupProgress(mode string, quiet bool) bool {
+	meter = nil
+	switch mode {
+	case "auto":
+		if !quiet && stderrIsTerminal() {
+			meter = &progressMeter{out: os.Stderr, bar: true, delay: progressDelay}
+		}
+	case "bar":
+		meter = &progressMeter{out: os.Stderr, bar: true}
+	case "percent":
+		meter = &progressMeter{out: os.Stderr}
+	case "off":
+	default:
+		return false
+	}
+	progress.Report = nil
+	if meter != nil {
+		progress.Report = meter.report
+	}
+	return true
+}
+
+// Whether stderr is a terminal rather than a pipe or file
+func stderrIsTerminal() bool {
+	info, err := os.Stderr.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (m *progressMeter) report(pass string, done, total int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	if pass != m.pass {
+		m.clearLine()
+		m.pass, m.started, m.tenth = pass, now, 0
+	}
+	if total <= 0 {
+		return
+	}
+	pct := int(int64(done) * 100 / int64(total))
+	if !m.bar {
+		if pct/10 > m.tenth {
+			m.tenth = pct / 10
+			logInfo("%s: %d%%", pass, pct)
+		}
+		return
+	}
+	if done >= total {
+		m.clearLine()
+		return
+	}
+	if now.Sub(m.started) < m.delay || now.Sub(m.drawn) < progressRedraw {
+		return
+	}
+	fill := pct * progressWidth / 100
+	fmt.Fprintf(m.out, "\r%-10s [%s%s] %3d%%", pass, strings.Repeat("#", fill), strings.Repeat(" ", progressWidth-fill), pct)
+	m.drawn = now
+}
+
+// Clear the bar, before a message or at the end of a pass
+func (m *progressMeter) clear() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clearLine()
+}
+
+func (m *progressMeter) clearLine() {
+	if !m.drawn.IsZero() {
+		fmt.Fprint(m.out, "\r\033[K")
+		m.drawn = time.Time{}
+	}
+}
+
+// Until the returned function is called, Ctrl-C stops the passes progress
+// follows, which then give what they found so far, rather than ending the
+// program; a second Ctrl-C ends it at once. The function reports whether
+// Ctrl-C was pressed, and the results are partial.
+func interruptible() func() bool {
+	ctx, cancel := context.WithCancel(context.Background())
+	progress.Context = ctx
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	var pressed atomic.Bool
+	go func() {
+		select {
+		case <-sig:
+			signal.Stop(sig)
+			pressed.Store(true)
+			meter.clear()
+			logInfo("Interrupted: finishing with the results so far; press Ctrl-C again to quit")
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return func() bool {
+		signal.Stop(sig)
+		cancel()
+		progress.Context = nil
+		meter.clear()
+		return pressed.Load()
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Dump record n of the record table starting at base. Without a record size,
+// the length (and start) detected from delimiter spacing is used.
+func dumpRecord(w io.Writer, data []byte, n int, recordSize int, base int) int {
+	detected := recordSize <= 0
+	if detected {
+		best, ok := fdi.LikelyRecordSize(fdi.TallyStrides(fdi.FindRepeatPatterns(data)))
+		if !ok {
+			logError("No record size available: pass -record-size or use a file with a detectable record length")
+			return exitUsage
+		}
+		recordSize, base = best.Stride, best.Offset
+		if !outputJSON {
+			fmt.Fprintf(w, "Using detected record length %d bytes, starting at 0x%X\n", recordSize, base)
+		}
+	}
+
+	recStart := base + n*recordSize
+	if recStart >= len(data) {
+		logError("Record %d starts at 0x%X, beyond the end of the file", n, recStart)
+		return exitUsage
+	}
+	if outputJSON {
+		return writeJSON(w, struct {
+			Index      int          `json:"index"`
+			Offset     int          `json:"offset"`
+			RecordSize int          `json:"record_size"`
+			Detected   bool         `json:"detected"` // record size came from delimiter spacing
+			Bytes      fdi.HexBytes `json:"bytes"`    // shorter than the record size if truncated
+		}{n, recStart, recordSize, detected, data[recStart:min(recStart+recordSize, len(data))]})
+	}
+
+	if recStart+recordSize > len(data) {
+		fmt.Fprintf(w, "Record %d is truncated by the end of the file\n", n)
+	}
+
+	fmt.Fprintf(w, "\n=== Record %d (Offset: 0x%X, Size: %d) ===\n", n, recStart, recordSize)
+	printFileHeader(w, data, recordSize, recStart)
+	return exitOK
+}
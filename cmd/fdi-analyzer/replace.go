@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Bytes of context shown on either side of an occurrence
+const replaceContext = 16
+
+// What -replace changes and where the result goes
+type replaceRequest struct {
+	specs      []string
+	ignoreCase bool
+	yes        bool      // replace without asking
+	outPath    string    // write a copy there rather than edit in place
+	answer     io.Reader // where the confirmation is read from
+}
+
+// One -replace spec and the occurrences it rewrites
+type replacement struct {
+	spec    string
+	patches []fdi.Patch
+}
+
+// Replace every occurrence of each -replace old value, showing them all and
+// asking before the file, or a copy at -out, is rewritten
+func replaceAll(w io.Writer, data []byte, path string, req replaceRequest) int {
+	if (path == "-" || isArchived(path)) && req.outPath == "" {
+		logError("Cannot replace in stdin or a file inside an archive in place; give -out for the result")
+		return exitUsage
+	}
+	if req.answer == nil && !req.yes {
+		logError("Cannot ask for confirmation with the file on stdin; give -yes")
+		return exitUsage
+	}
+
+	// Each spec sees the replacements before it
+	current, total := data, 0
+	var repls []replacement
+	for _, spec := range req.specs {
+		from, to, err := fdi.ParseReplacement(spec, dumpCodepage)
+		if err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+		patches, err := fdi.ReplacePatches(current, from, to, req.ignoreCase)
+		if err == nil {
+			current, err = fdi.ApplyPatches(current, patches)
+		}
+		if err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+		repls = append(repls, replacement{spec, patches})
+		total += len(patches)
+	}
+
+	if !outputJSON {
+		for _, r := range repls {
+			fmt.Fprintf(w, "\n=== Replace %s: %d occurrences ===\n", r.spec, len(r.patches))
+			for _, p := range r.patches {
+				from, to := max(p.Offset-replaceContext, 0), min(p.Offset+len(p.Bytes)+replaceContext, len(data))
+				fmt.Fprintf(w, "0x%08X  %s\n", p.Offset, contextText(data[from:to]))
+				fmt.Fprintf(w, "         -> %s\n", contextText(current[from:to]))
+			}
+		}
+	}
+	if total == 0 {
+		if outputJSON {
+			writeJSON(w, replaceResult(path, "", "", data, current, repls))
+		} else {
+			fmt.Fprintln(w, "\nNothing to replace")
+		}
+		return exitNoMatch
+	}
+
+	target := path
+	if req.outPath != "" {
+		target = req.outPath
+	}
+	if !req.yes && !confirm(req.answer, fmt.Sprintf("Replace %d occurrences in %s?", total, target)) {
+		logInfo("Nothing replaced")
+		return exitOK
+	}
+
+	backup := ""
+	if req.outPath != "" {
+		if err := os.WriteFile(req.outPath, current, 0o644); err != nil {
+			logError("Error writing file: %v", err)
+			return exitIOError
+		}
+	} else {
+		backup = path + ".bak"
+		if err := os.WriteFile(backup, data, 0o644); err != nil {
+			logError("Error writing backup: %v", err)
+			return exitIOError
+		}
+		if err := replaceFile(path, current); err != nil {
+			logError("Error writing file: %v", err)
+			return exitIOError
+		}
+		if code := journalEdit(path, data, current); code != exitOK {
+			return code
+		}
+	}
+
+	if outputJSON {
+		return writeJSON(w, replaceResult(path, req.outPath, backup, data, current, repls))
+	}
+	if backup != "" {
+		fmt.Fprintf(w, "\nReplaced %d occurrences in %s (original saved as %s)\n", total, path, backup)
+	} else {
+		fmt.Fprintf(w, "\nReplaced %d occurrences, wrote %s\n", total, req.outPath)
+	}
+	return exitOK
+}
+
+type jsonReplacement struct {
+	Spec        string           `json:"spec"`
+	Occurrences []jsonOccurrence `json:"occurrences"`
+}
+
+type jsonOccurrence struct {
+	Offset int          `json:"offset"`
+	Before fdi.HexBytes `json:"before"`
+	After  fdi.HexBytes `json:"after"`
+}
+
+func replaceResult(path, out, backup string, data, patched []byte, repls []replacement) any {
+	list := make([]jsonReplacement, 0, len(repls))
+	for _, r := range repls {
+		jr := jsonReplacement{Spec: r.spec, Occurrences: []jsonOccurrence{}}
+		for _, p := range r.patches {
+			end := p.Offset + len(p.Bytes)
+			jr.Occurrences = append(jr.Occurrences, jsonOccurrence{p.Offset, data[p.Offset:end], patched[p.Offset:end]})
+		}
+		list = append(list, jr)
+	}
+	return struct {
+		File         string            `json:"file"`
+		Out          string            `json:"out,omitempty"`
+		Backup       string            `json:"backup,omitempty"`
+		Replacements []jsonReplacement `json:"replacements"`
+	}{path, out, backup, list}
+}
+
+// Bytes as text, with -codepage if given, and the others as dots
+func contextText(b []byte) string {
+	var sb strings.Builder
+	for _, c := range b {
+		switch {
+		case dumpCodepage != nil && dumpCodepage.IsPrintable(c):
+			sb.WriteRune(dumpCodepage.Decode(c))
+		case dumpCodepage == nil && c >= 0x20 && c < 0x7F:
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte('.')
+		}
+	}
+	return sb.String()
+}
+
+// Ask a yes/no question on stderr and read the answer, no by default
+func confirm(answer io.Reader, question string) bool {
+	fmt.Fprintf(os.Stderr, "%s [y/N] ", question)
+	line, _ := bufio.NewReader(answer).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"fdi-analyzer/fdi"
+)
+
+// Decode the records described by a schema file as a table, one row per record.
+// A non-zero start or count overrides the schema's.
+func decodeSchema(w io.Writer, data []byte, path string, start int, count int) int {
+	schema, code := loadSchema(w, path, start, count)
+	if code != exitOK {
+		return code
+	}
+
+	records, err := fdi.DecodeRecords(data, schema)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Schema  fdi.Schema   `json:"schema"`
+			Records []fdi.Record `json:"records"`
+		}{schema, records})
+	}
+
+	title := schema.Name
+	if title == "" {
+		title = path
+	}
+	fmt.Fprintf(w, "\n=== Schema %s (Offset: 0x%X, Record size: %d, Records: %d) ===\n",
+		title, schema.Start, schema.RecordSize, len(records))
+	printRecordTable(w, schema, records)
+	return exitOK
+}
+
+// Break record n of a schema file down field by field: the name, bytes and
+// value of each, and the bytes no field maps as unknown
+func printBreakdown(w io.Writer, data []byte, path string, start int, n int) int {
+	schema, code := loadSchema(w, path, start, 0)
+	if code != exitOK {
+		return code
+	}
+	spans, err := schema.Breakdown(data, n)
+	if err != nil {
+		logError("Error: record %d: %v", n, err)
+		return exitUsage
+	}
+	unknown := 0
+	for _, sp := range spans {
+		if sp.Unknown {
+			unknown += sp.Size
+		}
+	}
+	base := schema.Start + n*schema.RecordSize
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Index   int             `json:"index"`
+			Offset  int             `json:"offset"`
+			Size    int             `json:"record_size"`
+			Unknown int             `json:"unknown_bytes"`
+			Spans   []fdi.FieldSpan `json:"spans"`
+		}{n, base, schema.RecordSize, unknown, spans})
+	}
+
+	title := schema.Name
+	if title == "" {
+		title = path
+	}
+	fmt.Fprintf(w, "\n=== Record %d of %s (Offset: 0x%X, Size: %d) ===\n", n, title, base, schema.RecordSize)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Offset\tField\tBytes\tValue")
+	for _, sp := range spans {
+		name, value := sp.Name, ""
+		if sp.Unknown {
+			name = "unknown"
+		} else {
+			value = formatFieldValue(sp.Value)
+		}
+		// Long runs go on over further rows, 16 bytes to a row
+		for i := 0; i < len(sp.Bytes); i += 16 {
+			fmt.Fprintf(tw, "+0x%02X\t%s\t% X\t%s\n", sp.Offset-base+i, name, []byte(sp.Bytes[i:min(i+16, len(sp.Bytes))]), value)
+			name, value = "", ""
+		}
+	}
+	tw.Flush()
+	if unknown > 0 {
+		fmt.Fprintf(w, "%d of %d bytes are not mapped by the schema\n", unknown, schema.RecordSize)
+	} else {
+		fmt.Fprintln(w, "Every byte is mapped by the schema")
+	}
+	return exitOK
+}
+
+// Print decoded records as a table, one row per record
+func printRecordTable(w io.Writer, schema fdi.Schema, records []fdi.Record) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	header := []string{"#", "Offset"}
+	for _, f := range schema.Fields {
+		header = append(header, f.Name)
+	}
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, rec := range records {
+		row := []string{fmt.Sprint(rec.Index), fmt.Sprintf("0x%X", rec.Offset)}
+		for _, f := range rec.Fields {
+			row = append(row, formatFieldValue(f.Value))
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	tw.Flush()
+}
+
+// Read a schema file. A non-zero start or count overrides the schema's.
+func loadSchema(w io.Writer, path string, start int, count int) (fdi.Schema, int) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		logError("Error reading schema: %v", err)
+		return fdi.Schema{}, exitIOError
+	}
+	schema, err := fdi.ParseSchema(src)
+	if err != nil {
+		logError("Error in schema %s: %v", path, err)
+		return fdi.Schema{}, exitUsage
+	}
+	if start > 0 {
+		schema.Start = start
+	}
+	if count > 0 {
+		schema.Count = count
+	}
+	return schema, exitOK
+}
+
+// A decoded value as shown in the table: strings quoted, bytes as hex
+func formatFieldValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case fdi.HexBytes:
+		return fmt.Sprintf("%X", []byte(v))
+	case float64:
+		return fmt.Sprintf("%g", v)
+	}
+	return fmt.Sprint(v)
+}
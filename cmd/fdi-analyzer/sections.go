@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Print a table of contents built from the section tags found in data.
+// The extra tags are searched for alongside the defaults.
+func printSections(w io.Writer, data []byte, extra []string) int {
+	var tags [][]byte
+	names := append(append([]string{}, fdi.DefaultSectionTags...), extra...)
+	for _, name := range names {
+		tag, err := fdi.ParseSectionTag(name)
+		if err != nil {
+			logError("Error: %v", err)
+			return exitUsage
+		}
+		tags = append(tags, tag)
+	}
+
+	sections := fdi.FindSections(data, tags)
+	if outputJSON {
+		return writeJSON(w, struct {
+			Sections []fdi.Section `json:"sections"`
+		}{nonNil(sections)})
+	}
+
+	fmt.Fprintln(w, "\n=== Sections ===")
+	if len(sections) == 0 {
+		fmt.Fprintln(w, "No section tags found")
+		return exitOK
+	}
+
+	fmt.Fprintf(w, "%-10s %-10s %s\n", "Tag", "Offset", "Gap")
+	for _, s := range sections {
+		fmt.Fprintf(w, "%-10s 0x%-8X %d\n", tagName(s.Tag), s.Offset, s.Gap)
+	}
+	fmt.Fprintf(w, "\n%d sections\n", len(sections))
+	return exitOK
+}
+
+// A tag as text when it is printable ASCII, otherwise as hex
+func tagName(tag []byte) string {
+	for _, b := range tag {
+		if b < 0x20 || b > 0x7E {
+			return fmt.Sprintf("0x%X", tag)
+		}
+	}
+	return string(tag)
+}
@@ -0,0 +1,371 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"fdi-analyzer/fdi"
+)
+
+// Bytes /dump returns when no size is asked for, and the most it returns at
+// once; items of a list page likewise
+const (
+	serveDumpSize = 4096
+	maxServeDump  = 1 << 20
+	serveLimit    = 100
+	maxServeLimit = 10000
+)
+
+// What the serve command serves
+type serveRequest struct {
+	files       []string
+	listen      string
+	allowOrigin string // sent as Access-Control-Allow-Origin when set
+	analysis    fdi.AnalysisOptions
+	layout      *fdi.Layout // the table /decode decodes, from -schema
+	stop        <-chan struct{}
+}
+
+// The analysis endpoints over the files given on the command line. Each file
+// is read again for every request, so changes to it are seen.
+type server struct {
+	files       []string
+	allowOrigin string
+	analysis    fdi.AnalysisOptions
+	layout      *fdi.Layout
+}
+
+// An error the client caused, answered with 400 rather than 500
+type requestError struct{ msg string }
+
+func (e requestError) Error() string { return e.msg }
+
+// Serve the analysis endpoints as JSON over HTTP until stop is closed
+func serveFiles(w io.Writer, req serveRequest) int {
+	for _, path := range req.files {
+		if path == "-" {
+			logError("Cannot serve stdin; give the files to serve")
+			return exitUsage
+		}
+	}
+	ln, err := net.Listen("tcp", req.listen)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitIOError
+	}
+
+	s := &server{files: req.files, allowOrigin: req.allowOrigin, analysis: req.analysis, layout: req.layout}
+	srv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 10 * time.Second}
+	logInfo("Serving %d file(s) on http://%s (Ctrl-C to stop)", len(req.files), ln.Addr())
+
+	done := make(chan error, 1)
+	go func() { done <- srv.Serve(ln) }()
+	select {
+	case err = <-done:
+	case <-req.stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = srv.Shutdown(ctx)
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logError("Error: %v", err)
+		return exitIOError
+	}
+	return exitOK
+}
+
+func (s *server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/files", s.endpoint(s.listFiles))
+	mux.HandleFunc("/dump", s.endpoint(s.dump))
+	mux.HandleFunc("/search", s.endpoint(s.search))
+	mux.HandleFunc("/strings", s.endpoint(s.strings))
+	mux.HandleFunc("/records", s.endpoint(s.records))
+	mux.HandleFunc("/decode", s.endpoint(s.decode))
+	return mux
+}
+
+// Wrap an endpoint: GET only, the result or the error as JSON
+func (s *server) endpoint(fn func(r *http.Request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.allowOrigin != "" {
+			w.Header().Set("Access-Control-Allow-Origin", s.allowOrigin)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			writeJSON(w, map[string]string{"error": "only GET is supported"})
+			return
+		}
+
+		v, err := fn(r)
+		var bad requestError
+		switch {
+		case errors.As(err, &bad):
+			w.WriteHeader(http.StatusBadRequest)
+		case err != nil:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		if err != nil {
+			v = map[string]string{"error": err.Error()}
+		}
+		writeJSON(w, v)
+	}
+}
+
+// The file a request names with file=, by its index or base name; the first
+// when it names none
+func (s *server) open(r *http.Request) ([]byte, func(), error) {
+	path := s.files[0]
+	if name := r.URL.Query().Get("file"); name != "" {
+		path = ""
+		for i, f := range s.files {
+			if name == strconv.Itoa(i) || name == filepath.Base(f) || name == f {
+				path = f
+				break
+			}
+		}
+		if path == "" {
+			return nil, nil, requestError{fmt.Sprintf("no file %q is served", name)}
+		}
+	}
+	return readInput(path)
+}
+
+// A decimal or 0x-prefixed number parameter, def when absent
+func queryNumber(r *http.Request, name string, def int) (int, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(v, 0, 64)
+	if err != nil || n < 0 {
+		return 0, requestError{fmt.Sprintf("%s: expected a non-negative decimal or 0x-prefixed hex number, got %q", name, v)}
+	}
+	return int(n), nil
+}
+
+// A page of a list, from= the index of its first item and limit= its length
+type page[T any] struct {
+	Total int `json:"total"`
+	From  int `json:"from"`
+	Next  int `json:"next,omitempty"` // from= for the next page; absent on the last
+	Items []T `json:"items"`
+}
+
+func paginate[T any](r *http.Request, items []T) (page[T], error) {
+	from, err := queryNumber(r, "from", 0)
+	if err != nil {
+		return page[T]{}, err
+	}
+	limit, err := queryNumber(r, "limit", serveLimit)
+	if err != nil {
+		return page[T]{}, err
+	}
+	limit = min(max(limit, 1), maxServeLimit)
+
+	p := page[T]{Total: len(items), From: from, Items: []T{}}
+	if from < len(items) {
+		end := min(from+limit, len(items))
+		p.Items = items[from:end]
+		if end < len(items) {
+			p.Next = end
+		}
+	}
+	return p, nil
+}
+
+type serveFile struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+}
+
+// GET /files: the files served
+func (s *server) listFiles(r *http.Request) (any, error) {
+	files := make([]serveFile, 0, len(s.files))
+	for i, path := range s.files {
+		f := serveFile{Index: i, Name: filepath.Base(path), Size: -1}
+		if data, release, err := readInput(path); err == nil {
+			f.Size = int64(len(data))
+			release()
+		}
+		files = append(files, f)
+	}
+	return files, nil
+}
+
+// GET /dump?offset=&size=: the hex dump rows of a range, with the offset of
+// the next range so a large dump can be read a page at a time
+func (s *server) dump(r *http.Request) (any, error) {
+	data, release, err := s.open(r)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	offset, err := queryNumber(r, "offset", 0)
+	if err != nil {
+		return nil, err
+	}
+	size, err := queryNumber(r, "size", serveDumpSize)
+	if err != nil {
+		return nil, err
+	}
+	size = min(size, maxServeDump)
+
+	rows, err := fdi.NewAnalyzer(data, s.analysis).Dump(fdi.DumpOptions{Offset: offset, Size: size, Codepage: dumpCodepage})
+	if err != nil {
+		return nil, requestError{err.Error()}
+	}
+	res := struct {
+		FileSize int           `json:"file_size"`
+		Offset   int           `json:"offset"`
+		Next     int           `json:"next,omitempty"` // offset= for the next range; absent at the end
+		Rows     []fdi.DumpRow `json:"rows"`
+	}{FileSize: len(data), Offset: offset, Rows: nonNil(rows)}
+	if end := offset + size; end < len(data) {
+		res.Next = end
+	}
+	return res, nil
+}
+
+// GET /search?q= or ?hex=, with ignorecase=1: a page of the matches
+func (s *server) search(r *http.Request) (any, error) {
+	data, release, err := s.open(r)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	q := r.URL.Query()
+	an := fdi.NewAnalyzer(data, s.analysis)
+
+	var results []fdi.SearchResult
+	switch {
+	case q.Get("q") != "":
+		opts := fdi.SearchOptions{IgnoreCase: q.Get("ignorecase") == "1" || q.Get("ignorecase") == "true"}
+		results, err = an.SearchText(q.Get("q"), opts)
+	case q.Get("hex") != "":
+		pattern, wild, perr := fdi.ParseHexWildcards(q.Get("hex"))
+		if perr != nil {
+			return nil, requestError{perr.Error()}
+		}
+		results, err = an.Search(pattern, fdi.SearchOptions{Wild: wild})
+	default:
+		return nil, requestError{"search needs q= or hex="}
+	}
+	if err != nil {
+		return nil, requestError{err.Error()}
+	}
+	return paginate(r, results)
+}
+
+// GET /strings?offset=&end=: a page of the strings in a range
+func (s *server) strings(r *http.Request) (any, error) {
+	data, release, err := s.open(r)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	start, end, err := queryRange(r, len(data))
+	if err != nil {
+		return nil, err
+	}
+	return paginate(r, rangeStrings(data, start, end, s.analysis))
+}
+
+// GET /records?offset=&end=: the record analysis of a range
+func (s *server) records(r *http.Request) (any, error) {
+	data, release, err := s.open(r)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	start, end, err := queryRange(r, len(data))
+	if err != nil {
+		return nil, err
+	}
+	return fdi.NewAnalyzer(data, s.analysis).UseCache(analysisCache).Records(start, end), nil
+}
+
+// GET /decode?offset=&count=: a page of the records of the table serve's
+// -schema describes, found in the file unless offset= or the schema gives
+// its start
+func (s *server) decode(r *http.Request) (any, error) {
+	if s.layout == nil {
+		return nil, requestError{"no table to decode; start serve with a -schema"}
+	}
+	l := *s.layout
+	data, release, err := s.open(r)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	count, err := queryNumber(r, "count", 0)
+	if err != nil {
+		return nil, err
+	}
+	start, err := queryNumber(r, "offset", -1)
+	if err != nil {
+		return nil, err
+	}
+	if start < 0 && l.Schema.Start > 0 {
+		start = l.Schema.Start
+	}
+	if count == 0 {
+		count = l.Schema.Count
+	}
+	if start < 0 {
+		found, n, ok := fdi.FindLayout(data, l)
+		if !ok {
+			return nil, requestError{fmt.Sprintf("no %s table found; give its offset", l.Name)}
+		}
+		start = found
+		if count == 0 {
+			count = n
+		}
+	}
+
+	records, err := fdi.DecodeLayout(data, l, start, count)
+	if err != nil {
+		return nil, requestError{err.Error()}
+	}
+	p, err := paginate(r, records)
+	if err != nil {
+		return nil, err
+	}
+	schema := l.Schema
+	schema.Start, schema.Count = start, len(records)
+	return struct {
+		Layout string     `json:"layout"`
+		Schema fdi.Schema `json:"schema"`
+		page[fdi.Record]
+	}{l.Name, schema, p}, nil
+}
+
+// The offset= and end= of a range, the whole file by default; end is cut
+// to the file's size, and an offset past it or past end is refused
+func queryRange(r *http.Request, size int) (int, int, error) {
+	start, err := queryNumber(r, "offset", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := queryNumber(r, "end", size)
+	if err != nil {
+		return 0, 0, err
+	}
+	end = min(end, size)
+	switch {
+	case start > size:
+		return 0, 0, requestError{fmt.Sprintf("offset 0x%X is past the end of the file (%d bytes)", start, size)}
+	case start > end:
+		return 0, 0, requestError{fmt.Sprintf("offset 0x%X is past end 0x%X", start, end)}
+	}
+	return start, end, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Candidate locations saved between runs by -session
+type session struct {
+	File       string          `json:"file"` // snapshot the values were last read from
+	Candidates []fdi.Candidate `json:"candidates"`
+}
+
+// What a -session run should do with the candidates
+type sessionRequest struct {
+	name      string // session name or path
+	file      string // path of the snapshot being read
+	value     string // -findvalue: seed a new session, or keep candidates that hold it
+	types     string
+	endian    string // the file's byte order for the types when none are given, or ""
+	filter    string // one of the fdi.Filter constants, or ""
+	start     int
+	end       int
+	showLimit int
+}
+
+// Session file path: the name as given, with .json added when it has no extension
+func sessionPath(name string) string {
+	if filepath.Ext(name) == "" {
+		return name + ".json"
+	}
+	return name
+}
+
+// Start, narrow or list a -session against the snapshot in data
+func runSession(w io.Writer, data []byte, req sessionRequest) int {
+	path := sessionPath(req.name)
+	var s session
+	src, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		logError("Error reading session: %v", err)
+		return exitIOError
+	default:
+		if err := json.Unmarshal(src, &s); err != nil {
+			logError("Error reading session %s: %v", path, err)
+			return exitIOError
+		}
+	}
+	started := src == nil
+
+	before := len(s.Candidates)
+	switch {
+	case started && req.value == "":
+		logError("Session %s does not exist yet: start it with -findvalue", path)
+		return exitUsage
+
+	case started:
+		if req.filter != "" {
+			logError("-%s needs an existing session: start it with -findvalue first", req.filter)
+			return exitUsage
+		}
+		if req.start >= req.end {
+			logError("Offset is beyond file size")
+			return exitUsage
+		}
+		types, err := valueTypeList(req.types)
+		if err == nil {
+			var matches []fdi.ValueMatches
+			if matches, err = findValueTypes(data[req.start:req.end], req.value, types, req.endian); err == nil {
+				s.Candidates = fdi.CandidatesFrom(matches)
+			}
+		}
+		if err != nil {
+			logError("Error: %v (types: %s)", err, strings.Join(fdi.ValueTypes, ", "))
+			return exitUsage
+		}
+		for i := range s.Candidates {
+			s.Candidates[i].Offset += req.start
+		}
+
+	default:
+		if req.filter != "" {
+			if s.Candidates, err = fdi.NarrowCandidates(data, s.Candidates, req.filter); err != nil {
+				logError("Error: %v", err)
+				return exitUsage
+			}
+		}
+		if req.value != "" {
+			s.Candidates = fdi.NarrowToValue(data, s.Candidates, req.value)
+		}
+	}
+
+	// Listing an existing session leaves it as it is
+	changed := started || req.filter != "" || req.value != ""
+	if changed {
+		s.File = req.file
+		s.Candidates = nonNil(s.Candidates)
+		if code := saveSession(w, path, s); code != exitOK {
+			return code
+		}
+	}
+
+	if outputJSON {
+		if code := writeJSON(w, struct {
+			Session string `json:"session"`
+			session
+			Before int `json:"before"`
+		}{path, s, before}); code != exitOK {
+			return code
+		}
+	} else {
+		printSession(w, data, path, s, before, started, changed, req.showLimit)
+	}
+
+	if len(s.Candidates) == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
+
+func printSession(w io.Writer, data []byte, path string, s session, before int, started bool, changed bool, limit int) {
+	switch {
+	case started:
+		fmt.Fprintf(w, "\n=== Session %s: started with %d candidates ===\n", path, len(s.Candidates))
+	case changed:
+		fmt.Fprintf(w, "\n=== Session %s: %d -> %d candidates ===\n", path, before, len(s.Candidates))
+	default:
+		fmt.Fprintf(w, "\n=== Session %s: %d candidates (last read from %s) ===\n", path, len(s.Candidates), s.File)
+	}
+
+	for i, c := range s.Candidates {
+		if limit > 0 && i >= limit {
+			fmt.Fprintf(w, "... and %d more\n", len(s.Candidates)-limit)
+			break
+		}
+		fmt.Fprintf(w, "0x%-8X %-6s %s", c.Offset, c.Type, fdi.FormatValue(c.Type, c.Value))
+		// When only listing, show what this snapshot holds there now
+		if size := fdi.ValueSize(c.Type); !changed && c.Offset+size <= len(data) {
+			fmt.Fprintf(w, " (now %s)", fdi.FormatValue(c.Type, data[c.Offset:c.Offset+size]))
+		}
+		fmt.Fprintln(w)
+	}
+	if len(s.Candidates) == 0 {
+		fmt.Fprintln(w, "No candidates left")
+	}
+}
+
+func saveSession(w io.Writer, path string, s session) int {
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err == nil {
+		err = os.WriteFile(path, append(out, '\n'), 0o644)
+	}
+	if err != nil {
+		logError("Error writing session: %v", err)
+		return exitIOError
+	}
+	return exitOK
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// A minimal writer of SQLite 3 database files, enough to hand tables of
+// exported data to the sqlite3 shell or any SQLite library. It writes each
+// table as a b-tree of rows once and supports no updates.
+
+const sqlitePageSize = 4096
+
+// A table for writeSQLite: its columns and rows of nil, int64, float64,
+// string or []byte values
+type sqlTable struct {
+	name    string
+	columns []sqlColumn
+	rows    [][]any
+}
+
+type sqlColumn struct {
+	name string
+	typ  string // INTEGER, REAL, TEXT or BLOB
+}
+
+// The pages of the database being built, page 1 first
+type sqlitePager struct {
+	pages [][]byte
+}
+
+func (p *sqlitePager) alloc() int {
+	p.pages = append(p.pages, make([]byte, sqlitePageSize))
+	return len(p.pages)
+}
+
+func (p *sqlitePager) page(n int) []byte {
+	return p.pages[n-1]
+}
+
+// Write the tables to a new database at path, replacing any file there
+func writeSQLite(path string, tables []sqlTable) error {
+	p := &sqlitePager{}
+	p.alloc() // page 1 holds the header and the schema table
+
+	var schema [][]any
+	for _, t := range tables {
+		root := p.writeTable(t.rows)
+		cols := make([]string, len(t.columns))
+		for i, c := range t.columns {
+			cols[i] = sqlQuote(c.name) + " " + c.typ
+		}
+		sql := fmt.Sprintf("CREATE TABLE %s (%s)", sqlQuote(t.name), strings.Join(cols, ", "))
+		schema = append(schema, []any{"table", t.name, t.name, int64(root), sql})
+	}
+
+	cells := make([][]byte, len(schema))
+	used := 100 + 8
+	for i, row := range schema {
+		cells[i] = p.leafCell(int64(i+1), sqliteRecord(row))
+		used += 2 + len(cells[i])
+	}
+	if used > sqlitePageSize {
+		return fmt.Errorf("too many tables for the schema page")
+	}
+	writeBTreePage(p.page(1), 100, 0x0D, cells, 0)
+	writeSQLiteHeader(p.page(1), len(p.pages))
+
+	out := make([]byte, 0, len(p.pages)*sqlitePageSize)
+	for _, pg := range p.pages {
+		out = append(out, pg...)
+	}
+	return os.WriteFile(path, out, 0o644)
+}
+
+// The 100-byte database header at the start of page 1
+func writeSQLiteHeader(pg []byte, pages int) {
+	copy(pg, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(pg[16:], sqlitePageSize)
+	pg[18], pg[19] = 1, 1                  // legacy journal format
+	pg[21], pg[22], pg[23] = 64, 32, 32    // fixed payload fractions
+	binary.BigEndian.PutUint32(pg[24:], 1) // change counter
+	binary.BigEndian.PutUint32(pg[28:], uint32(pages))
+	binary.BigEndian.PutUint32(pg[40:], 1) // schema cookie
+	binary.BigEndian.PutUint32(pg[44:], 4) // schema format
+	binary.BigEndian.PutUint32(pg[56:], 1) // UTF-8
+	binary.BigEndian.PutUint32(pg[92:], 1) // change counter the page count is valid for
+	binary.BigEndian.PutUint32(pg[96:], 3040001)
+}
+
+// Write rows with rowids from 1 as a table b-tree and return its root page
+func (p *sqlitePager) writeTable(rows [][]any) int {
+	type child struct {
+		page   int
+		maxKey int64
+	}
+	var leaves []child
+	var cells [][]byte
+	used := 8
+	flush := func(maxKey int64) {
+		pg := p.alloc()
+		writeBTreePage(p.page(pg), 0, 0x0D, cells, 0)
+		leaves = append(leaves, child{pg, maxKey})
+		cells, used = nil, 8
+	}
+	for i, row := range rows {
+		cell := p.leafCell(int64(i+1), sqliteRecord(row))
+		if used+2+len(cell) > sqlitePageSize {
+			flush(int64(i))
+		}
+		cells = append(cells, cell)
+		used += 2 + len(cell)
+	}
+	if len(cells) > 0 || len(leaves) == 0 {
+		flush(int64(len(rows)))
+	}
+
+	// Interior pages over the level below until one page is left
+	level := leaves
+	for len(level) > 1 {
+		var next []child
+		for start := 0; start < len(level); {
+			cells, used := [][]byte(nil), 12
+			end := start
+			for end < len(level)-1 {
+				cell := binary.BigEndian.AppendUint32(nil, uint32(level[end].page))
+				cell = append(cell, sqliteVarint(uint64(level[end].maxKey))...)
+				if used+2+len(cell) > sqlitePageSize {
+					break
+				}
+				cells = append(cells, cell)
+				used += 2 + len(cell)
+				end++
+			}
+			// The child after the last cell is the right-most pointer
+			pg := p.alloc()
+			writeBTreePage(p.page(pg), 0, 0x05, cells, level[end].page)
+			next = append(next, child{pg, level[end].maxKey})
+			start = end + 1
+		}
+		level = next
+	}
+	return level[0].page
+}
+
+// A table leaf cell, spilling the end of a long payload to overflow pages
+// as the file format lays it out
+func (p *sqlitePager) leafCell(rowid int64, payload []byte) []byte {
+	const usable = sqlitePageSize
+	maxLocal := usable - 35
+	minLocal := (usable-12)*32/255 - 23
+
+	local := len(payload)
+	if local > maxLocal {
+		local = minLocal + (len(payload)-minLocal)%(usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+
+	cell := sqliteVarint(uint64(len(payload)))
+	cell = append(cell, sqliteVarint(uint64(rowid))...)
+	cell = append(cell, payload[:local]...)
+	if local == len(payload) {
+		return cell
+	}
+
+	rest := payload[local:]
+	first := p.alloc()
+	cell = binary.BigEndian.AppendUint32(cell, uint32(first))
+	for pg := first; ; {
+		n := copy(p.page(pg)[4:], rest)
+		rest = rest[n:]
+		if len(rest) == 0 {
+			return cell
+		}
+		next := p.alloc()
+		binary.BigEndian.PutUint32(p.page(pg), uint32(next))
+		pg = next
+	}
+}
+
+// Lay out a b-tree page: the header at hdr, the cell pointers after it and
+// the cells packed against the end of the page
+func writeBTreePage(pg []byte, hdr int, kind byte, cells [][]byte, rightPtr int) {
+	hdrSize := 8
+	if kind == 0x05 {
+		hdrSize = 12
+		binary.BigEndian.PutUint32(pg[hdr+8:], uint32(rightPtr))
+	}
+	content := len(pg)
+	for i, c := range cells {
+		content -= len(c)
+		copy(pg[content:], c)
+		binary.BigEndian.PutUint16(pg[hdr+hdrSize+2*i:], uint16(content))
+	}
+	pg[hdr] = kind
+	binary.BigEndian.PutUint16(pg[hdr+3:], uint16(len(cells)))
+	binary.BigEndian.PutUint16(pg[hdr+5:], uint16(content))
+}
+
+// Encode a row in the record format: a header of serial types, then the values
+func sqliteRecord(values []any) []byte {
+	var types, body []byte
+	for _, v := range values {
+		switch v := v.(type) {
+		case nil:
+			types = append(types, 0)
+		case int64:
+			typ, size := sqliteIntType(v)
+			types = append(types, sqliteVarint(typ)...)
+			for i := size - 1; i >= 0; i-- {
+				body = append(body, byte(v>>(8*i)))
+			}
+		case float64:
+			types = append(types, 7)
+			body = binary.BigEndian.AppendUint64(body, math.Float64bits(v))
+		case string:
+			v = strings.ToValidUTF8(v, "\uFFFD") // the header declares UTF-8
+			types = append(types, sqliteVarint(uint64(13+2*len(v)))...)
+			body = append(body, v...)
+		case []byte:
+			types = append(types, sqliteVarint(uint64(12+2*len(v)))...)
+			body = append(body, v...)
+		default:
+			panic(fmt.Sprintf("sqliteRecord: unsupported value %T", v))
+		}
+	}
+
+	// The header length counts its own varint
+	hdrLen := len(types) + 1
+	for len(sqliteVarint(uint64(hdrLen)))+len(types) != hdrLen {
+		hdrLen = len(sqliteVarint(uint64(hdrLen))) + len(types)
+	}
+	rec := append(sqliteVarint(uint64(hdrLen)), types...)
+	return append(rec, body...)
+}
+
+// The serial type and byte size of the smallest integer encoding of v
+func sqliteIntType(v int64) (uint64, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= -1<<7 && v < 1<<7:
+		return 1, 1
+	case v >= -1<<15 && v < 1<<15:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= -1<<31 && v < 1<<31:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	}
+	return 6, 8
+}
+
+// SQLite's big-endian varint: 7 bits per byte with the high bit marking
+// more to come, and a full ninth byte for values over 56 bits
+func sqliteVarint(v uint64) []byte {
+	if v > 1<<56-1 {
+		out := make([]byte, 9)
+		out[8] = byte(v)
+		v >>= 8
+		for i := 7; i >= 0; i-- {
+			out[i] = byte(v&0x7F) | 0x80
+			v >>= 7
+		}
+		return out
+	}
+	var rev []byte
+	for {
+		rev = append(rev, byte(v&0x7F))
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	out := make([]byte, len(rev))
+	for i, b := range rev {
+		out[len(rev)-1-i] = b
+		if i > 0 {
+			out[len(rev)-1-i] |= 0x80
+		}
+	}
+	return out
+}
+
+// Quote an SQL identifier
+func sqlQuote(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
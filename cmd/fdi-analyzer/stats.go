@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"fdi-analyzer/fdi"
+)
+
+// Print checksums, entropy and a byte histogram for data[start:end]
+func printStats(w io.Writer, data []byte, start int, end int) int {
+	if start >= len(data) {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+	if end <= 0 || end > len(data) {
+		end = len(data)
+	}
+	if end <= start {
+		logError("End offset must be greater than the start offset")
+		return exitUsage
+	}
+
+	st := fdi.ComputeStats(data[start:end])
+	if outputJSON {
+		return writeJSON(w, struct {
+			Start int `json:"start"`
+			End   int `json:"end"` // exclusive
+			fdi.Stats
+		}{start, end, st})
+	}
+
+	fmt.Fprintf(w, "\n=== Statistics (0x%X-0x%X, %d bytes) ===\n", start, end-1, st.Length)
+	fmt.Fprintf(w, "MD5:     %s\n", st.MD5)
+	fmt.Fprintf(w, "SHA-256: %s\n", st.SHA256)
+	fmt.Fprintf(w, "CRC32:   %08x\n", st.CRC32)
+	fmt.Fprintf(w, "Entropy: %.4f bits/byte\n", st.Entropy)
+
+	// 16x16 table of counts, rows by high nibble and columns by low nibble
+	fmt.Fprintln(w, "\nByte histogram:")
+	fmt.Fprint(w, "   ")
+	for lo := 0; lo < 16; lo++ {
+		fmt.Fprintf(w, " %6X", lo)
+	}
+	fmt.Fprintln(w)
+	for hi := 0; hi < 16; hi++ {
+		fmt.Fprintf(w, "%X0:", hi)
+		for lo := 0; lo < 16; lo++ {
+			fmt.Fprintf(w, " %6d", st.Histogram[hi*16+lo])
+		}
+		fmt.Fprintln(w)
+	}
+	return exitOK
+}
+
+// Summarize each field of the records of a -schema, or each byte of records
+// of -record-size, across the table
+func printColumnStats(w io.Writer, data []byte, schemaPath string, start, recordSize, count int) int {
+	schema := fdi.Schema{Start: start, RecordSize: recordSize, Count: count}
+	if schemaPath != "" {
+		var code int
+		if schema, code = loadSchema(w, schemaPath, start, count); code != exitOK {
+			return code
+		}
+	} else {
+		schema.Fields = fdi.ByteFields(recordSize)
+	}
+	records, err := fdi.DecodeRecords(data, schema)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+	columns := fdi.ComputeColumnStats(schema, records)
+	if outputJSON {
+		return writeJSON(w, struct {
+			Start      int               `json:"start"`
+			RecordSize int               `json:"record_size"`
+			Records    int               `json:"records"`
+			Fields     []fdi.ColumnStats `json:"fields"`
+		}{schema.Start, schema.RecordSize, len(records), columns})
+	}
+
+	fmt.Fprintf(w, "\n=== Field Statistics (Offset: 0x%X, Record size: %d, Records: %d) ===\n", schema.Start, schema.RecordSize, len(records))
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "Field\tOffset\tType\tDistinct\tMin\tMax\tMean\tKind")
+	for _, c := range columns {
+		lo, hi, mean := "-", "-", "-"
+		if c.Numeric {
+			lo, hi, mean = fmt.Sprintf("%g", c.Min), fmt.Sprintf("%g", c.Max), fmt.Sprintf("%.2f", c.Mean)
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%s\t%s\t%s\t%s\n", c.Field, c.Offset, c.Type, c.Distinct, lo, hi, mean, c.Kind)
+	}
+	tw.Flush()
+
+	// Histograms only where they tell something: not for constants or IDs
+	for _, c := range columns {
+		if c.Kind == "constant" || c.Kind == "increasing" {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s:\n", c.Field)
+		for _, b := range c.Histogram {
+			fmt.Fprintf(w, "  %-16s %6d  %s\n", b.Value, b.Count, strings.Repeat("#", (b.Count*40+len(records)-1)/len(records)))
+		}
+	}
+	return exitOK
+}
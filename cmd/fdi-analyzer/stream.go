@@ -0,0 +1,242 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// A file larger than -maxmem that could not be memory-mapped, which the
+// report streams through in -maxmem chunks instead
+type tooLargeError struct {
+	path    string // the file to stream, a temporary copy for stdin
+	size    int64
+	err     error  // why mapping failed
+	release func() // removes the temporary copy
+}
+
+func (e *tooLargeError) Error() string {
+	return fmt.Sprintf("%s is larger than -maxmem (%d bytes) and cannot be memory-mapped: %v", e.path, maxMem, e.err)
+}
+
+func (e *tooLargeError) Unwrap() error {
+	return e.err
+}
+
+// Read stdin, spooling it to a temporary file to map when it is larger than
+// -maxmem
+func readStdin() ([]byte, func(), error) {
+	head, err := io.ReadAll(io.LimitReader(os.Stdin, maxMem+1))
+	if err != nil || int64(len(head)) <= maxMem {
+		return head, func() {}, err
+	}
+
+	f, err := os.CreateTemp("", "fdi-analyzer-stdin-*")
+	if err != nil {
+		return nil, nil, err
+	}
+	remove := func() { os.Remove(f.Name()) }
+	defer f.Close()
+	size, err := f.Write(head)
+	if err == nil {
+		var rest int64
+		rest, err = io.Copy(f, os.Stdin)
+		size += int(rest)
+	}
+	if err != nil {
+		remove()
+		return nil, nil, err
+	}
+
+	data, release, err := mapFile(f, int64(size))
+	if err != nil {
+		return nil, nil, &tooLargeError{path: f.Name(), size: int64(size), err: err, release: remove}
+	}
+	return data, func() { release(); remove() }, nil
+}
+
+// The flags of the report that works on a streamed file; anything else
+// needs the whole file in memory
+var streamFlags = map[string]bool{
+	"file": true, "format": true, "json": true, "color": true, "maxmem": true,
+	"offset": true, "bytes": true, "end": true,
+	"search": true, "isearch": true, "hexsearch": true, "regex": true, "utf16": true, "ignorecase": true, "nooverlap": true, "fuzzy": true,
+	"minstr": true, "maxstr": true, "encoding": true, "codepage": true, "stringsout": true,
+}
+
+// Refuse the flags given that a streamed file cannot honor
+func checkStreamable(w io.Writer, in *tooLargeError) bool {
+	var names []string
+	flag.Visit(func(f *flag.Flag) {
+		if !streamFlags[f.Name] {
+			names = append(names, "-"+f.Name)
+		}
+	})
+	if len(names) == 0 {
+		return true
+	}
+	sort.Strings(names)
+	logError("%s needs the whole file in memory, but %v", strings.Join(names, ", "), in)
+	logError("Raise -maxmem above %d bytes to use it", in.size)
+	return false
+}
+
+// Search a file by reading it in -maxmem chunks
+func streamed(path string) searchFunc {
+	return func(pattern []byte, opts fdi.SearchOptions) ([]fdi.SearchResult, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return fdi.SearchReader(f, pattern, opts, int(maxMem))
+	}
+}
+
+// The strings of a file read in -maxmem chunks
+func streamStrings(path string, opts fdi.AnalysisOptions) ([]fdi.FoundString, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return fdi.StringsReader(f, opts, int(maxMem))
+}
+
+// The streamed report as JSON: the parts of the default report that do not
+// need the whole file in memory
+type jsonStreamReport struct {
+	FileSize int               `json:"file_size"`
+	Streamed bool              `json:"streamed"`
+	Dump     *jsonDump         `json:"dump,omitempty"`
+	Searches []jsonSearch      `json:"searches,omitempty"`
+	Regex    *jsonRegex        `json:"regex,omitempty"`
+	Strings  []fdi.FoundString `json:"strings,omitempty"`
+}
+
+// The default report for a file too large to hold or map: the dump, the
+// searches and the strings, each read through the file in -maxmem chunks.
+// The record analysis needs the whole file and is left out.
+func streamReport(w io.Writer, in *tooLargeError, req reportRequest, maxStrings int, stringsOut string) int {
+	if int64(req.dump.Offset) >= in.size {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+
+	f, err := os.Open(in.path)
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	window := make([]byte, min(int64(req.dump.Size), in.size-int64(req.dump.Offset)))
+	_, err = f.ReadAt(window, int64(req.dump.Offset))
+	f.Close()
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+
+	strs, err := streamStrings(in.path, req.analysis)
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	search := streamed(in.path)
+	searched := len(req.terms) > 0 || len(req.iterms) > 0 || req.hexSearch != "" || req.regex != nil
+
+	var matches int
+	if outputJSON {
+		report := jsonStreamReport{FileSize: int(in.size), Streamed: true}
+		if req.has("dump") {
+			opts := req.dump
+			opts.Base = req.dump.Offset
+			report.Dump = &jsonDump{Offset: req.dump.Offset}
+			report.Dump.Rows, _ = fdi.Dump(window, opts)
+		}
+		if req.has("strings") {
+			report.Strings = nonNil(strs)
+		}
+
+		iopts := req.searchOpts
+		iopts.IgnoreCase = true
+		for i, term := range append(req.terms[:len(req.terms):len(req.terms)], req.iterms...) {
+			opts := req.searchOpts
+			if i >= len(req.terms) {
+				opts = iopts
+			}
+			pattern := []byte(term)
+			if opts.UTF16 {
+				pattern = fdi.EncodeUTF16LE(term)
+			}
+			results, _ := search(pattern, opts)
+			report.Searches = append(report.Searches, jsonSearch{Term: term, IgnoreCase: opts.IgnoreCase, Matches: nonNil(results)})
+			matches += len(results)
+		}
+		if req.hexSearch != "" {
+			pattern, wild, err := fdi.ParseHexWildcards(req.hexSearch)
+			if err != nil {
+				logError("Invalid hex pattern: %v", err)
+				return exitUsage
+			}
+			opts := req.hexOpts()
+			opts.Wild = wild
+			results, _ := search(pattern, opts)
+			report.Searches = append(report.Searches, jsonSearch{Term: req.hexSearch, Hex: true, Matches: nonNil(results)})
+			matches += len(results)
+		}
+		if req.regex != nil {
+			report.Regex = &jsonRegex{Pattern: req.regex.String(), Matches: nonNil(fdi.MatchStrings(strs, req.regex))}
+			matches += len(report.Regex.Matches)
+		}
+		if code := writeJSON(w, report); code != exitOK {
+			return code
+		}
+	} else {
+		fmt.Fprintf(w, "File size: %d bytes, streamed in %d-byte chunks (%v)\n", in.size, maxMem, in.err)
+		if req.has("dump") {
+			printWindow(w, window, req.dump.Offset, req.dump.Size, req.dump.Offset, false)
+		}
+
+		if len(req.terms) > 0 || len(req.iterms) > 0 {
+			matches += searchForTerms(w, search, req.terms, req.iterms, req.searchOpts)
+		}
+		if req.hexSearch != "" {
+			found, err := searchForHex(w, search, req.hexSearch, req.hexOpts())
+			if err != nil {
+				return exitUsage
+			}
+			matches += found
+		}
+		if req.regex != nil {
+			matches += searchForRegexp(w, req.regex, fdi.MatchStrings(strs, req.regex))
+		}
+
+		if req.has("records") {
+			fmt.Fprintln(w, "\nRecord analysis skipped: the file is streamed")
+		}
+		if req.has("strings") {
+			fmt.Fprintln(w, "\nPotential text strings found:")
+			printStrings(w, strs, maxStrings)
+		}
+	}
+
+	if stringsOut != "" {
+		if err := writeStrings(stringsOut, strs); err != nil {
+			logError("Error writing strings: %v", err)
+			return exitIOError
+		}
+		if !outputJSON {
+			fmt.Fprintf(w, "\nWrote %d strings to %s\n", len(strs), stringsOut)
+		}
+	}
+
+	if searched && matches == 0 {
+		return exitNoMatch
+	}
+	return exitOK
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Infer the record size and layout of data[start:end] from autocorrelation
+func inferStride(w io.Writer, data []byte, start int, end int) int {
+	if start >= len(data) {
+		logError("Offset is beyond file size")
+		return exitUsage
+	}
+	if end <= 0 || end > len(data) {
+		end = len(data)
+	}
+
+	res, ok := cachedStride(data, start, end)
+	if outputJSON {
+		var found *fdi.StrideResult
+		if ok {
+			found = &res
+		}
+		if code := writeJSON(w, struct {
+			Records *fdi.StrideResult `json:"records"`
+		}{found}); code != exitOK {
+			return code
+		}
+	}
+	if !ok {
+		if !outputJSON {
+			fmt.Fprintln(w, "\nNo fixed-size records found")
+		}
+		return exitNoMatch
+	}
+	if outputJSON {
+		return exitOK
+	}
+
+	fmt.Fprintln(w, "\n=== Stride Inference ===")
+	fmt.Fprintf(w, "Records of %d bytes starting at 0x%X (%d records, score %.2f)\n", res.Size, res.Start, res.Records, res.Score)
+	if len(res.Alternatives) > 0 {
+		fmt.Fprint(w, "Next best sizes:")
+		for _, a := range res.Alternatives {
+			fmt.Fprintf(w, " %d (%.2f)", a.Size, a.Score)
+		}
+		fmt.Fprintln(w)
+	}
+
+	fmt.Fprintln(w, "\nColumns:")
+	for _, c := range res.Columns {
+		cols := fmt.Sprint(c.Start)
+		if c.End-c.Start > 1 {
+			cols = fmt.Sprintf("%d-%d", c.Start, c.End-1)
+		}
+		fmt.Fprintf(w, "  %-9s %-9s %s\n", cols, c.Type, c.Detail)
+	}
+	fmt.Fprintf(w, "\nDump one with -record 0 -offset 0x%X -record-size %d\n", res.Start, res.Size)
+	return exitOK
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fdi-analyzer/fdi"
+)
+
+// Dump consecutive fixed-width string fields as an indexed list
+func dumpStringTable(w io.Writer, data []byte, offset int, width int, count int) int {
+	entries, err := fdi.StringTable(data, offset, width, count)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Offset  int      `json:"offset"`
+			Width   int      `json:"width"`
+			Entries []string `json:"entries"`
+		}{offset, width, nonNil(entries)})
+	}
+
+	fmt.Fprintf(w, "\n=== String Table (Offset: 0x%X, Width: %d) ===\n", offset, width)
+	for i, str := range entries {
+		fmt.Fprintf(w, "%d: %q\n", i, str)
+	}
+	fmt.Fprintf(w, "%d entries\n", len(entries))
+	return exitOK
+}
+
+// Print the most likely fixed-width string table
+func inferStringTable(w io.Writer, data []byte) int {
+	best, ok := fdi.InferStringTable(data)
+	if outputJSON {
+		if !ok {
+			return writeJSON(w, struct {
+				Found bool `json:"found"`
+			}{false})
+		}
+		entries, _ := fdi.StringTable(data, best.Offset, best.Width, best.Entries)
+		return writeJSON(w, struct {
+			Found bool `json:"found"`
+			fdi.TableCandidate
+			Entries []string `json:"entries"`
+		}{true, best, entries})
+	}
+
+	fmt.Fprintln(w, "\n=== String Table Inference ===")
+	if !ok {
+		fmt.Fprintln(w, "No fixed-width string table found")
+		return exitOK
+	}
+
+	fmt.Fprintf(w, "Best candidate: offset 0x%X, field width %d, %d entries\n", best.Offset, best.Width, best.Entries)
+	entries, _ := fdi.StringTable(data, best.Offset, best.Width, 5)
+	for i, str := range entries {
+		fmt.Fprintf(w, "%d: %q\n", i, str)
+	}
+	if best.Entries > 5 {
+		fmt.Fprintln(w, "...")
+	}
+	fmt.Fprintf(w, "Dump it with: -rename-strings-table -offset %d -width %d\n", best.Offset, best.Width)
+	return exitOK
+}
+
+// Write strings as "offset<TAB>text" lines, with offsets in the 0x form -offset
+// accepts. Strings with a detected encoding get it as a third column.
+func writeStrings(path string, strs []fdi.FoundString) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	for _, str := range strs {
+		if str.Encoding != "" {
+			fmt.Fprintf(w, "0x%X\t%s\t%s\n", str.Offset, str.Text, str.Encoding)
+		} else {
+			fmt.Fprintf(w, "0x%X\t%s\n", str.Offset, str.Text)
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Names of a table shown before the list is cut short
+const nameTablePreview = 5
+
+// List every name table with its first names, then the columns of the
+// records that index them with a few of the links they make
+func findNameTables(w io.Writer, data []byte) int {
+	report := fdi.FindNameTables(data)
+	if outputJSON {
+		report.Tables = nonNil(report.Tables)
+		report.References = nonNil(report.References)
+		return writeJSON(w, report)
+	}
+
+	fmt.Fprintln(w, "\n=== Name Tables ===")
+	if len(report.Tables) == 0 {
+		fmt.Fprintln(w, "No name tables found")
+		return exitNoMatch
+	}
+	for i, t := range report.Tables {
+		kind := fmt.Sprintf("a name every %d bytes", t.Stride)
+		if t.Prefix > 0 {
+			kind = fmt.Sprintf("%d-byte length before each name", t.Prefix)
+		}
+		names := make([]string, 0, nameTablePreview)
+		for _, e := range t.Names[:min(len(t.Names), nameTablePreview)] {
+			names = append(names, fmt.Sprintf("%q", e.Name))
+		}
+		if len(t.Names) > nameTablePreview {
+			names = append(names, "...")
+		}
+		fmt.Fprintf(w, "Table %d: 0x%X, %d names, %s: %s\n", i, t.Offset, len(t.Names), kind, strings.Join(names, ", "))
+	}
+
+	fmt.Fprintln(w, "\n=== Name References ===")
+	if len(report.References) == 0 {
+		fmt.Fprintln(w, "No columns index the tables")
+		return exitOK
+	}
+	for _, r := range report.References {
+		from := "records"
+		if r.Source >= 0 {
+			from = fmt.Sprintf("the records of table %d", r.Source)
+		}
+		fmt.Fprintf(w, "Table %d <- u%d at 0x%X every %d bytes in %s: %d records, %d of %d names, from %d\n",
+			r.Table, 8*r.Width, r.Offset, r.Stride, from, r.Records, r.Distinct, len(report.Tables[r.Table].Names), r.Base)
+		for _, l := range r.Links {
+			source := ""
+			if l.Source != "" {
+				source = fmt.Sprintf("%q ", l.Source)
+			}
+			fmt.Fprintf(w, "  0x%08X  %s-> %d %q\n", l.Offset, source, l.Value, l.Name)
+		}
+	}
+	return exitOK
+}
@@ -0,0 +1,14 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package main
+
+import "errors"
+
+// Raw terminal input is only implemented for Unix terminals
+func rawTerminal() (func(), error) {
+	return nil, errors.New("-browse is not supported on this platform")
+}
+
+func terminalHeight() int {
+	return 24
+}
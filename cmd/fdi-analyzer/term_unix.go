@@ -0,0 +1,46 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Put the terminal on stdin into raw mode with stty. The returned function
+// restores the previous settings.
+func rawTerminal() (func(), error) {
+	if stdinIsPiped() {
+		return nil, errors.New("-browse needs an interactive terminal on stdin")
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read terminal settings: %v", err)
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, fmt.Errorf("cannot switch the terminal to raw mode: %v", err)
+	}
+	return func() { stty(strings.TrimSpace(saved)) }, nil
+}
+
+// Terminal height in lines, or 24 when it cannot be read
+func terminalHeight() int {
+	out, err := stty("size")
+	if err == nil {
+		var rows, cols int
+		if _, err := fmt.Sscan(out, &rows, &cols); err == nil && rows > 0 {
+			return rows
+		}
+	}
+	return 24
+}
+
+func stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	out, err := cmd.Output()
+	return string(out), err
+}
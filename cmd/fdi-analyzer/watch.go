@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"fdi-analyzer/fdi"
+)
+
+// What -watch does on every change to the file
+type watchRequest struct {
+	path     string
+	interval time.Duration // between looks at the file
+	layout   *fdi.Schema   // to attribute the changes to records and fields
+	plugin   string        // run on every new version as well
+	context  pluginContext
+	stop     <-chan struct{} // ends the watch
+}
+
+// Look at the file every interval and, when it has changed and then stayed
+// the same for an interval, so that a save in progress is not read half
+// written, print a diff against the version before
+func watchFile(w io.Writer, data []byte, req watchRequest) int {
+	if req.path == "-" || isArchived(req.path) {
+		logError("Cannot watch stdin or a file inside an archive; give the file to watch")
+		return exitUsage
+	}
+	if req.interval <= 0 {
+		logError("-interval must be positive")
+		return exitUsage
+	}
+	seen, err := os.Stat(req.path)
+	if err != nil {
+		logError("Error reading file: %v", err)
+		return exitIOError
+	}
+	prev := bytes.Clone(data)
+	logInfo("Watching %s for changes every %v; press Ctrl-C to stop", req.path, req.interval)
+
+	ticker := time.NewTicker(req.interval)
+	defer ticker.Stop()
+	var pending os.FileInfo // the changed file, until it settles
+	for {
+		select {
+		case <-req.stop:
+			return exitOK
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(req.path)
+		if err != nil || sameFileInfo(info, seen) {
+			continue // the game may be replacing the file
+		}
+		if pending == nil || !sameFileInfo(info, pending) {
+			pending = info
+			continue
+		}
+		seen, pending = info, nil
+
+		cur, err := os.ReadFile(req.path)
+		if err != nil {
+			logError("Error reading file: %v", err)
+			continue
+		}
+		if bytes.Equal(cur, prev) {
+			continue
+		}
+		diffData(w, prev, cur, fmt.Sprintf("%s saved at %s", req.path, info.ModTime().Format("15:04:05")), req.layout)
+		if req.plugin != "" {
+			runPlugin(w, cur, req.plugin, req.context)
+		}
+		prev = cur
+	}
+}
+
+func sameFileInfo(a, b os.FileInfo) bool {
+	return a.Size() == b.Size() && a.ModTime().Equal(b.ModTime())
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// Report everything the analyses know about a single offset
+func whereIsOffset(w io.Writer, data []byte, offset int, recordSize int) int {
+	info, err := fdi.Locate(data, offset, recordSize)
+	if err != nil {
+		logError("Error: %v", err)
+		return exitUsage
+	}
+
+	if outputJSON {
+		return writeJSON(w, info)
+	}
+
+	fmt.Fprintf(w, "\n=== Offset 0x%X (%d) ===\n", offset, offset)
+	fmt.Fprintf(w, "Byte value: 0x%02X\n", info.Value)
+	fmt.Fprintf(w, "Region: %s (window 0x%X-0x%X, entropy %.2f bits/byte)\n",
+		info.Region, info.RegionStart, info.RegionEnd-1, info.Entropy)
+
+	if info.Record >= 0 {
+		fmt.Fprintf(w, "Record: #%d, byte %d of %d\n", info.Record, info.RecordByte, recordSize)
+	}
+
+	if info.String != nil {
+		fmt.Fprintf(w, "String: inside %q at 0x%X (character %d)\n", info.String.Text, info.String.Offset, offset-info.String.Offset)
+	} else {
+		fmt.Fprintln(w, "String: none")
+	}
+
+	if info.Pattern != nil {
+		fmt.Fprintf(w, "Pattern: inside 0x%X at 0x%X (repeats %d times)\n", info.Pattern.Pattern, info.Pattern.Offset, info.Pattern.Repeats)
+	} else {
+		fmt.Fprintln(w, "Pattern: none")
+	}
+
+	fmt.Fprintf(w, "Alignment: %d bytes\n", info.Alignment)
+	return exitOK
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"fdi-analyzer/fdi"
+)
+
+// List the 16- and 32-bit values that refer to target, absolutely, from
+// one of bases or relative to where they are stored
+func printXRefs(w io.Writer, data []byte, target int, bases []int, limit int) int {
+	if target >= len(data) {
+		logError("Offset 0x%X is beyond the end of the file", target)
+		return exitUsage
+	}
+	refs := fdi.FindXRefs(data, target, bases)
+
+	if outputJSON {
+		return writeJSON(w, struct {
+			Target int        `json:"target"`
+			Bases  []int      `json:"bases"`
+			Refs   []fdi.XRef `json:"refs"`
+		}{target, nonNil(bases), nonNil(refs)})
+	}
+
+	fmt.Fprintf(w, "\n=== References to 0x%X ===\n", target)
+	if len(refs) == 0 {
+		fmt.Fprintln(w, "No 16- or 32-bit value refers to this offset")
+		return exitNoMatch
+	}
+	shown := refs
+	if limit > 0 && len(shown) > limit {
+		shown = shown[:limit]
+	}
+	for _, r := range shown {
+		how := "absolute"
+		switch r.Kind {
+		case fdi.XRefBase:
+			how = fmt.Sprintf("0x%X from base 0x%X", r.Value, r.Base)
+		case fdi.XRefSelf:
+			how = fmt.Sprintf("%+d from itself", r.Value)
+		case fdi.XRefNext:
+			how = fmt.Sprintf("%+d from the next byte (0x%X)", r.Value, r.Base)
+		}
+		fmt.Fprintf(w, "0x%08X  %-5s  %s\n", r.Offset, r.Type, how)
+	}
+	if len(shown) < len(refs) {
+		fmt.Fprintf(w, "... and %d more (use -limit or -verbose)\n", len(refs)-len(shown))
+	}
+	fmt.Fprintf(w, "%d references\n", len(refs))
+	return exitOK
+}
@@ -0,0 +1,100 @@
+package fdi
+
+// AnalysisResult is the combined output of the record structure analysis.
+type AnalysisResult struct {
+	FileSize int             `json:"file_size"`
+	Start    int             `json:"start"`
+	End      int             `json:"end"` // exclusive
+	Patterns []RepeatPattern `json:"patterns"`
+	Strings  []FoundString   `json:"strings"`
+
+	// The pattern search was stopped by Progress, so the patterns are only
+	// those of the part of the range it got through
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// Partial reports whether the analysis was interrupted, so that Cached does
+// not keep it.
+func (r AnalysisResult) Partial() bool {
+	return r.Interrupted
+}
+
+// AnalysisOptions tunes the string and pattern detection of the analysis.
+type AnalysisOptions struct {
+	MinString int           // minimum string length
+	Codepage  *Codepage     // decode high bytes with this codepage; nil keeps the built-in Latin range
+	Encoding  string        // EncodingUTF16LE or EncodingAuto overrides Codepage; "" uses it
+	Patterns  PatternSearch // the zero value is DefaultPatternSearch
+
+	// Runs of this many padding bytes are left out of the patterns and
+	// strings, unless KeepPadding; 0 is MinPaddingRun
+	MinPadding  int
+	KeepPadding bool
+
+	// Shows and stops the pattern search; nil runs it to the end unseen
+	Progress *Progress `json:"-"`
+}
+
+// Extract the strings of data as the options ask, outside the padding
+// unless KeepPadding
+func (o AnalysisOptions) strings(data []byte) []FoundString {
+	if o.KeepPadding {
+		return o.extract(data)
+	}
+	return o.stringsOutside(data, FindPadding(data, o.MinPadding))
+}
+
+func (o AnalysisOptions) extract(data []byte) []FoundString {
+	switch o.Encoding {
+	case EncodingUTF16LE:
+		return ExtractStringsUTF16LE(data, o.MinString)
+	case EncodingAuto:
+		return ExtractStringsAuto(data, o.MinString)
+	}
+	return ExtractStringsCodepage(data, o.MinString, o.Codepage)
+}
+
+// Analyze runs the repeating-pattern and string detection over data.
+func Analyze(data []byte, opts AnalysisOptions) AnalysisResult {
+	return AnalyzeRange(data, 0, len(data), opts)
+}
+
+// AnalyzeRange runs the analysis over data[start:end] only. Reported offsets
+// are still absolute. The range is clamped to the data. When opts.Progress
+// stops the pattern search, the strings are still extracted from the whole
+// range.
+func AnalyzeRange(data []byte, start int, end int, opts AnalysisOptions) AnalysisResult {
+	end = min(max(end, 0), len(data))
+	start = min(max(start, 0), end)
+	window := data[start:end]
+
+	result := AnalysisResult{FileSize: len(data), Start: start, End: end}
+	result.Patterns, result.Interrupted = findRepeatPatterns(window, opts.Patterns, opts.Progress)
+	if opts.KeepPadding {
+		result.Strings = opts.extract(window)
+	} else {
+		runs := FindPadding(window, opts.MinPadding)
+		result.Patterns = dropPaddingPatterns(result.Patterns, runs)
+		result.Strings = opts.stringsOutside(window, runs)
+	}
+
+	if start > 0 {
+		for i := range result.Patterns {
+			for j := range result.Patterns[i].Offsets {
+				result.Patterns[i].Offsets[j] += start
+			}
+		}
+		for i := range result.Strings {
+			result.Strings[i].Offset += start
+		}
+	}
+
+	// Empty lists rather than null keep the JSON shape stable
+	if result.Patterns == nil {
+		result.Patterns = []RepeatPattern{}
+	}
+	if result.Strings == nil {
+		result.Strings = []FoundString{}
+	}
+	return result
+}
@@ -0,0 +1,110 @@
+package fdi
+
+import (
+	"io"
+	"regexp"
+)
+
+// Analyzer runs the analyses over the contents of one file.
+type Analyzer struct {
+	data  []byte
+	opts  AnalysisOptions
+	cache *Cache // nil runs every pass
+}
+
+// RecordReport is the outcome of the record structure analysis over a range.
+type RecordReport struct {
+	AnalysisResult
+	Strides      []StrideCandidate `json:"strides"`       // most common first
+	RecordLength *StrideCandidate  `json:"record_length"` // nil when no stride dominates
+}
+
+// NewAnalyzer returns an Analyzer over data. The data is not copied and must
+// not change while the Analyzer is in use.
+func NewAnalyzer(data []byte, opts AnalysisOptions) *Analyzer {
+	return &Analyzer{data: data, opts: opts}
+}
+
+// UseCache makes the Analyzer reuse the results of its deep passes stored in
+// c, and store those it runs; nil turns the cache off.
+func (a *Analyzer) UseCache(c *Cache) *Analyzer {
+	a.cache = c
+	return a
+}
+
+// Data returns the bytes being analyzed.
+func (a *Analyzer) Data() []byte {
+	return a.data
+}
+
+// Dump splits a range of the data into hex dump rows. A nil opts.Codepage
+// falls back to the Analyzer's codepage.
+func (a *Analyzer) Dump(opts DumpOptions) ([]DumpRow, error) {
+	return Dump(a.data, a.dumpOptions(opts))
+}
+
+// WriteDump writes a hex dump of a range of the data to w.
+func (a *Analyzer) WriteDump(w io.Writer, opts DumpOptions) error {
+	return WriteDump(w, a.data, a.dumpOptions(opts))
+}
+
+func (a *Analyzer) dumpOptions(opts DumpOptions) DumpOptions {
+	if opts.Codepage == nil {
+		opts.Codepage = a.opts.Codepage
+	}
+	return opts
+}
+
+// Search reports every occurrence of pattern in the data.
+func (a *Analyzer) Search(pattern []byte, opts SearchOptions) ([]SearchResult, error) {
+	return Search(a.data, pattern, opts)
+}
+
+// SearchText encodes text according to opts and searches for it.
+func (a *Analyzer) SearchText(text string, opts SearchOptions) ([]SearchResult, error) {
+	return SearchText(a.data, text, opts)
+}
+
+// SearchRegexp matches re against the Analyzer's text strings.
+func (a *Analyzer) SearchRegexp(re *regexp.Regexp) []RegexpMatch {
+	return MatchStrings(a.Strings(), re)
+}
+
+// Strings returns every text string in the data.
+func (a *Analyzer) Strings() []FoundString {
+	return a.opts.strings(a.data)
+}
+
+// Records runs the record structure analysis over data[start:end]: repeating
+// patterns, the strides between them, the likely record length and the text
+// strings. The range is clamped to the data.
+func (a *Analyzer) Records(start int, end int) RecordReport {
+	params := struct {
+		Start, End int
+		Options    AnalysisOptions
+	}{start, end, a.opts}
+	report := Cached(a.cache, a.data, "records", params, func() RecordReport {
+		return a.records(start, end)
+	})
+	// Empty lists rather than null keep the JSON shape stable; the cache
+	// loads them as nil
+	if report.Patterns == nil {
+		report.Patterns = []RepeatPattern{}
+	}
+	if report.Strings == nil {
+		report.Strings = []FoundString{}
+	}
+	if report.Strides == nil {
+		report.Strides = []StrideCandidate{}
+	}
+	return report
+}
+
+func (a *Analyzer) records(start int, end int) RecordReport {
+	report := RecordReport{AnalysisResult: AnalyzeRange(a.data, start, end, a.opts)}
+	report.Strides = TallyStrides(report.Patterns)
+	if best, ok := LikelyRecordSize(report.Strides); ok {
+		report.RecordLength = &best
+	}
+	return report
+}
@@ -0,0 +1,82 @@
+package fdi
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Annotation is a note on a byte or range of bytes, such as what a block
+// turned out to hold.
+type Annotation struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"` // the last annotated byte
+	Note  string `json:"note"`
+}
+
+// Annotations are kept sorted by start offset.
+type Annotations []Annotation
+
+// ParseAnnotation parses an "<offset>[:<last>]=<note>" spec such as
+// "0x4F0:0x52F=player name block". The range includes its last byte; an
+// empty note asks for the annotation on that range to be removed.
+func ParseAnnotation(spec string) (Annotation, error) {
+	rangeStr, note, ok := strings.Cut(spec, "=")
+	if !ok {
+		return Annotation{}, fmt.Errorf("annotation %q: expected <offset>[:<last>]=<note>", spec)
+	}
+	startStr, endStr, hasEnd := strings.Cut(strings.TrimSpace(rangeStr), ":")
+	start, err := strconv.ParseInt(startStr, 0, 64)
+	if err != nil || start < 0 {
+		return Annotation{}, fmt.Errorf("annotation %q: invalid offset %q", spec, startStr)
+	}
+	end := start
+	if hasEnd {
+		end, err = strconv.ParseInt(endStr, 0, 64)
+		if err != nil || end < start {
+			return Annotation{}, fmt.Errorf("annotation %q: invalid last offset %q", spec, endStr)
+		}
+	}
+	return Annotation{Start: int(start), End: int(end), Note: strings.TrimSpace(note)}, nil
+}
+
+// Set returns the annotations with a replacing any on the same range, or
+// with that range's annotation removed when a has no note.
+func (as Annotations) Set(a Annotation) Annotations {
+	var out Annotations
+	for _, old := range as {
+		if old.Start != a.Start || old.End != a.End {
+			out = append(out, old)
+		}
+	}
+	if a.Note != "" {
+		out = append(out, a)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Start != out[j].Start {
+			return out[i].Start < out[j].Start
+		}
+		return out[i].End < out[j].End
+	})
+	return out
+}
+
+// Overlapping returns the annotations that cover any byte of [start, end).
+func (as Annotations) Overlapping(start int, end int) []Annotation {
+	var out []Annotation
+	for _, a := range as {
+		if a.Start < end && a.End >= start {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// Label is the note with the range it covers.
+func (a Annotation) Label() string {
+	if a.Start == a.End {
+		return fmt.Sprintf("0x%X: %s", a.Start, a.Note)
+	}
+	return fmt.Sprintf("0x%X-0x%X: %s", a.Start, a.End, a.Note)
+}
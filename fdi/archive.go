@@ -0,0 +1,361 @@
+package fdi
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"path"
+	"strings"
+)
+
+// ArchiveFormats lists the containers OpenArchive reads, named as their file
+// extensions. zip members may be stored or deflated; ISO 9660 images are read
+// through their primary volume descriptor, with 8.3 names; ARJ members must be
+// stored (arj -m0), as the ARJ compression methods are not decoded.
+var ArchiveFormats = []string{"zip", "iso", "arj"}
+
+// ArchiveEntry is a file inside an archive.
+type ArchiveEntry struct {
+	Name string `json:"name"` // with / between directories, without an ISO version
+	Size int64  `json:"size"`
+
+	read func() ([]byte, error)
+}
+
+// Archive is the list of files in a zip, ISO 9660 or ARJ container.
+type Archive struct {
+	Format  string         `json:"format"`
+	Entries []ArchiveEntry `json:"entries"`
+}
+
+// ArchiveFormat returns the format of ArchiveFormats a file name's extension
+// names, or "" when it names none.
+func ArchiveFormat(name string) string {
+	ext := strings.ToLower(path.Ext(strings.ReplaceAll(name, `\`, "/")))
+	for _, f := range ArchiveFormats {
+		if ext == "."+f {
+			return f
+		}
+	}
+	return ""
+}
+
+// SplitArchivePath splits an "<archive>:<member>" path, such as
+// game.zip:DATA/LIGA.FDI, at the first colon after a name ArchiveFormat
+// knows, so that a drive letter before it is kept.
+func SplitArchivePath(p string) (archive string, member string, ok bool) {
+	for i := 0; i < len(p); i++ {
+		if p[i] == ':' && i+1 < len(p) && ArchiveFormat(p[:i]) != "" {
+			return p[:i], p[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// OpenArchive reads the directory of the archive of the given format in r,
+// of size bytes.
+func OpenArchive(r io.ReaderAt, size int64, format string) (*Archive, error) {
+	a := &Archive{Format: format}
+	var err error
+	switch format {
+	case "zip":
+		a.Entries, err = zipEntries(r, size)
+	case "iso":
+		a.Entries, err = isoEntries(r, size)
+	case "arj":
+		a.Entries, err = arjEntries(r, size)
+	default:
+		return nil, fmt.Errorf("unknown archive format %q (expected %s)", format, strings.Join(ArchiveFormats, ", "))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a readable %s archive: %v", format, err)
+	}
+	return a, nil
+}
+
+// Find returns the entry called name, compared ignoring ASCII case and with
+// either slash between directories, as the names on DOS media are.
+func (a *Archive) Find(name string) (ArchiveEntry, bool) {
+	name = cleanMember(name)
+	for _, e := range a.Entries {
+		if strings.EqualFold(e.Name, name) {
+			return e, true
+		}
+	}
+	return ArchiveEntry{}, false
+}
+
+// Match returns the names of the entries matching a path.Match pattern,
+// ignoring ASCII case.
+func (a *Archive) Match(pattern string) ([]string, error) {
+	pattern = strings.ToUpper(cleanMember(pattern))
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range a.Entries {
+		if ok, _ := path.Match(pattern, strings.ToUpper(e.Name)); ok {
+			names = append(names, e.Name)
+		}
+	}
+	return names, nil
+}
+
+// Read returns the contents of the entry.
+func (e ArchiveEntry) Read() ([]byte, error) {
+	data, err := e.read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", e.Name, err)
+	}
+	return data, nil
+}
+
+// A member name as the entries hold it
+func cleanMember(name string) string {
+	name = strings.ReplaceAll(name, `\`, "/")
+	for strings.HasPrefix(name, "./") {
+		name = name[2:]
+	}
+	return strings.TrimLeft(name, "/")
+}
+
+func zipEntries(r io.ReaderAt, size int64) ([]ArchiveEntry, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	var entries []ArchiveEntry
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		f := f
+		entries = append(entries, ArchiveEntry{Name: cleanMember(f.Name), Size: int64(f.UncompressedSize64), read: func() ([]byte, error) {
+			rc, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer rc.Close()
+			out, err := io.ReadAll(io.LimitReader(rc, maxDecompressed+1))
+			if err == nil && len(out) > maxDecompressed {
+				err = fmt.Errorf("larger than %d bytes", maxDecompressed)
+			}
+			return out, err
+		}})
+	}
+	return entries, nil
+}
+
+// ISO 9660 sectors, and the sector of the first volume descriptor
+const (
+	isoSector       = 2048
+	isoFirstVolume  = 16
+	isoMaxDirectory = 1 << 20 // bytes of one directory's records
+	isoMaxDepth     = 8       // the standard's limit of nested directories
+)
+
+func isoEntries(r io.ReaderAt, size int64) ([]ArchiveEntry, error) {
+	desc := make([]byte, isoSector)
+	for sector := int64(isoFirstVolume); ; sector++ {
+		if _, err := r.ReadAt(desc, sector*isoSector); err != nil {
+			return nil, errors.New("no primary volume descriptor")
+		}
+		if string(desc[1:6]) != "CD001" || desc[0] == 0xFF {
+			return nil, errors.New("no primary volume descriptor")
+		}
+		if desc[0] == 1 {
+			break
+		}
+	}
+	root := desc[156 : 156+34]
+	var entries []ArchiveEntry
+	err := isoDirectory(r, size, binary.LittleEndian.Uint32(root[2:]), binary.LittleEndian.Uint32(root[10:]), "", 0, &entries)
+	return entries, err
+}
+
+// Add the files of the directory at extent, of length bytes, and those of the
+// directories in it, to entries
+func isoDirectory(r io.ReaderAt, size int64, extent, length uint32, prefix string, depth int, entries *[]ArchiveEntry) error {
+	if length > isoMaxDirectory || int64(extent)*isoSector+int64(length) > size {
+		return fmt.Errorf("directory %q at sector %d runs past the end of the image", prefix, extent)
+	}
+	buf := make([]byte, length)
+	if _, err := r.ReadAt(buf, int64(extent)*isoSector); err != nil {
+		return err
+	}
+	for off := 0; off < len(buf); {
+		n := int(buf[off])
+		if n == 0 { // records do not cross sectors; the rest is padding
+			off = (off/isoSector + 1) * isoSector
+			continue
+		}
+		if n < 34 || off+n > len(buf) || 33+int(buf[off+32]) > n {
+			return fmt.Errorf("bad directory record in %q", prefix)
+		}
+		rec := buf[off : off+n]
+		off += n
+		name := rec[33 : 33+int(rec[32])]
+		if len(name) == 1 && name[0] <= 1 {
+			continue // the directory itself and its parent
+		}
+		start, fileSize := binary.LittleEndian.Uint32(rec[2:]), binary.LittleEndian.Uint32(rec[10:])
+		full := prefix + isoName(name)
+		if rec[25]&2 != 0 {
+			if depth+1 < isoMaxDepth {
+				if err := isoDirectory(r, size, start, fileSize, full+"/", depth+1, entries); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		*entries = append(*entries, ArchiveEntry{Name: full, Size: int64(fileSize), read: func() ([]byte, error) {
+			return readSection(r, size, int64(start)*isoSector, int64(fileSize))
+		}})
+	}
+	return nil
+}
+
+// An ISO 9660 file name without its ;1 version and the dot of no extension
+func isoName(name []byte) string {
+	s := string(name)
+	if i := strings.IndexByte(s, ';'); i >= 0 {
+		s = s[:i]
+	}
+	return strings.TrimSuffix(s, ".")
+}
+
+func readSection(r io.ReaderAt, size int64, off int64, n int64) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > size {
+		return nil, errors.New("runs past the end of the archive")
+	}
+	out := make([]byte, n)
+	if _, err := r.ReadAt(out, off); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ARJ headers start with these bytes and hold at most arjMaxHeader bytes
+const (
+	arjMagic0    = 0x60
+	arjMagic1    = 0xEA
+	arjMaxHeader = 2600
+	arjScan      = 1 << 16 // how far into a self-extracting program the archive may start
+)
+
+// The file types of ARJ local headers that hold data
+const (
+	arjBinary = 0
+	arjText   = 1
+)
+
+// An ARJ header at off: its basic header, and the offset after it and its
+// extended headers, or a nil header at the end of the archive
+func arjHeader(r io.ReaderAt, size int64, off int64) ([]byte, int64, error) {
+	var head [4]byte
+	if _, err := r.ReadAt(head[:], off); err != nil {
+		return nil, 0, errors.New("truncated header")
+	}
+	if head[0] != arjMagic0 || head[1] != arjMagic1 {
+		return nil, 0, fmt.Errorf("no header at 0x%X", off)
+	}
+	n := int64(binary.LittleEndian.Uint16(head[2:]))
+	if n == 0 {
+		return nil, off + 4, nil
+	}
+	if n > arjMaxHeader || n < 30 {
+		return nil, 0, fmt.Errorf("bad header size %d at 0x%X", n, off)
+	}
+	hdr, err := readSection(r, size, off+4, n+4)
+	if err != nil {
+		return nil, 0, errors.New("truncated header")
+	}
+	if crc32.ChecksumIEEE(hdr[:n]) != binary.LittleEndian.Uint32(hdr[n:]) {
+		return nil, 0, fmt.Errorf("header at 0x%X fails its CRC", off)
+	}
+	next := off + 4 + n + 4
+	for { // extended headers, unused
+		var ext [2]byte
+		if _, err := r.ReadAt(ext[:], next); err != nil {
+			return nil, 0, errors.New("truncated header")
+		}
+		next += 2
+		extSize := int64(binary.LittleEndian.Uint16(ext[:]))
+		if extSize == 0 {
+			break
+		}
+		next += extSize + 4
+	}
+	return hdr[:n], next, nil
+}
+
+func arjEntries(r io.ReaderAt, size int64) ([]ArchiveEntry, error) {
+	// The main header starts the archive, or follows a self-extracting stub
+	start := int64(-1)
+	lead, _ := readSection(r, size, 0, min(size, arjScan))
+	for i := 0; i+1 < len(lead); i++ {
+		if lead[i] == arjMagic0 && lead[i+1] == arjMagic1 {
+			if _, _, err := arjHeader(r, size, int64(i)); err == nil {
+				start = int64(i)
+				break
+			}
+		}
+	}
+	if start < 0 {
+		return nil, errors.New("no main header")
+	}
+	_, off, err := arjHeader(r, size, start)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ArchiveEntry
+	for {
+		hdr, next, err := arjHeader(r, size, off)
+		if err != nil {
+			return nil, err
+		}
+		if hdr == nil {
+			return entries, nil
+		}
+		first := int(hdr[0])
+		flags, method, fileType := hdr[4], hdr[5], hdr[6]
+		packed := int64(binary.LittleEndian.Uint32(hdr[12:]))
+		original := int64(binary.LittleEndian.Uint32(hdr[16:]))
+		crc := binary.LittleEndian.Uint32(hdr[20:])
+		if first > len(hdr) {
+			return nil, fmt.Errorf("bad header at 0x%X", off)
+		}
+		name := hdr[first:]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		data := next
+		off = next + packed
+		if off > size {
+			return nil, fmt.Errorf("%s runs past the end of the archive", name)
+		}
+		if fileType != arjBinary && fileType != arjText {
+			continue
+		}
+		entries = append(entries, ArchiveEntry{Name: cleanMember(string(name)), Size: original, read: func() ([]byte, error) {
+			switch {
+			case flags&0x01 != 0:
+				return nil, errors.New("is encrypted")
+			case flags&0x0C != 0:
+				return nil, errors.New("is split across volumes")
+			case method != 0:
+				return nil, fmt.Errorf("is compressed with ARJ method %d; only stored members (arj -m0) are read, extract it with arj first", method)
+			}
+			out, err := readSection(r, size, data, packed)
+			if err == nil && crc32.ChecksumIEEE(out) != crc {
+				err = errors.New("fails its CRC")
+			}
+			return out, err
+		}})
+	}
+}
@@ -0,0 +1,171 @@
+package fdi
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// A zip of the files by name, the first stored and the rest deflated
+func zipFiles(t *testing.T, files map[string]string, names ...string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, name := range names {
+		method := zip.Deflate
+		if i == 0 {
+			method = zip.Store
+		}
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(files[name]))
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// An ISO 9660 image with DATA/LIGA.FDI;1 and README.TXT;1 in its root
+func isoImage(liga, readme string) []byte {
+	img := make([]byte, 24*isoSector)
+	record := func(at int, extent, size int, flags byte, name string) int {
+		n := 33 + len(name)
+		n += n % 2
+		img[at] = byte(n)
+		binary.LittleEndian.PutUint32(img[at+2:], uint32(extent))
+		binary.BigEndian.PutUint32(img[at+6:], uint32(extent))
+		binary.LittleEndian.PutUint32(img[at+10:], uint32(size))
+		binary.BigEndian.PutUint32(img[at+14:], uint32(size))
+		img[at+25] = flags
+		img[at+32] = byte(len(name))
+		copy(img[at+33:], name)
+		return at + n
+	}
+
+	pvd := 16 * isoSector
+	img[pvd] = 1
+	copy(img[pvd+1:], "CD001")
+	record(pvd+156, 18, isoSector, 2, "\x00")
+	term := 17 * isoSector
+	img[term] = 0xFF
+	copy(img[term+1:], "CD001")
+
+	at := record(18*isoSector, 18, isoSector, 2, "\x00")
+	at = record(at, 18, isoSector, 2, "\x01")
+	at = record(at, 19, isoSector, 2, "DATA")
+	record(at, 21, len(readme), 0, "README.TXT;1")
+	at = record(19*isoSector, 19, isoSector, 2, "\x00")
+	at = record(at, 18, isoSector, 2, "\x01")
+	record(at, 20, len(liga), 0, "LIGA.FDI;1")
+	copy(img[20*isoSector:], liga)
+	copy(img[21*isoSector:], readme)
+	return img
+}
+
+// An ARJ header block: the magic, the size, the basic header and its CRC,
+// with no extended headers
+func arjBlock(fileType, method byte, packed, original int, crc uint32, name string) []byte {
+	hdr := make([]byte, 30)
+	hdr[0] = 30
+	hdr[1], hdr[2] = 11, 1
+	hdr[5], hdr[6] = method, fileType
+	binary.LittleEndian.PutUint32(hdr[12:], uint32(packed))
+	binary.LittleEndian.PutUint32(hdr[16:], uint32(original))
+	binary.LittleEndian.PutUint32(hdr[20:], crc)
+	hdr = append(append(hdr, name...), 0, 0)
+
+	out := []byte{arjMagic0, arjMagic1, 0, 0}
+	binary.LittleEndian.PutUint16(out[2:], uint16(len(hdr)))
+	out = append(out, hdr...)
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(hdr))
+	return append(out, 0, 0)
+}
+
+// An ARJ archive holding the files stored, and PACKED.FDI compressed
+func arjArchive(files map[string]string, names ...string) []byte {
+	out := arjBlock(2, 0, 0, 0, 0, "GAME.ARJ")
+	for _, name := range names {
+		data := files[name]
+		out = append(out, arjBlock(arjBinary, 0, len(data), len(data), crc32.ChecksumIEEE([]byte(data)), name)...)
+		out = append(out, data...)
+	}
+	out = append(out, arjBlock(arjBinary, 1, 3, 40, 0, "DATA\\PACKED.FDI")...)
+	out = append(out, "xyz"...)
+	return append(out, arjMagic0, arjMagic1, 0, 0)
+}
+
+func TestSplitArchivePath(t *testing.T) {
+	tests := []struct {
+		in, archive, member string
+		ok                  bool
+	}{
+		{"game.zip:DATA/LIGA.FDI", "game.zip", "DATA/LIGA.FDI", true},
+		{`C:\GAMES\CD.ISO:DATA\LIGA.FDI`, `C:\GAMES\CD.ISO`, `DATA\LIGA.FDI`, true},
+		{"disk1.Arj:*.FDI", "disk1.Arj", "*.FDI", true},
+		{"C:liga.fdi", "", "", false},
+		{"game.zip", "", "", false},
+		{"game.zip:", "", "", false},
+	}
+	for _, tt := range tests {
+		archive, member, ok := SplitArchivePath(tt.in)
+		if archive != tt.archive || member != tt.member || ok != tt.ok {
+			t.Errorf("SplitArchivePath(%q) = %q, %q, %v, want %q, %q, %v", tt.in, archive, member, ok, tt.archive, tt.member, tt.ok)
+		}
+	}
+}
+
+func TestOpenArchive(t *testing.T) {
+	files := map[string]string{
+		"DATA/LIGA.FDI": strings.Repeat("LIGA RECORD ", 20),
+		"README.TXT":    "Read me",
+	}
+	archives := map[string][]byte{
+		"zip": zipFiles(t, files, "README.TXT", "DATA/LIGA.FDI"),
+		"iso": isoImage(files["DATA/LIGA.FDI"], files["README.TXT"]),
+		"arj": arjArchive(files, "README.TXT", "DATA/LIGA.FDI"),
+	}
+	for format, data := range archives {
+		a, err := OpenArchive(bytes.NewReader(data), int64(len(data)), format)
+		if err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		for name, want := range files {
+			e, ok := a.Find(strings.ToLower(strings.ReplaceAll(name, "/", `\`)))
+			if !ok {
+				t.Errorf("%s: no %s in %+v", format, name, a.Entries)
+				continue
+			}
+			got, err := e.Read()
+			if err != nil || string(got) != want || e.Size != int64(len(want)) {
+				t.Errorf("%s: %s = %q (size %d), %v, want %q", format, name, got, e.Size, err, want)
+			}
+		}
+		if names, err := a.Match("data/*.fdi"); err != nil || len(names) == 0 || names[0] != "DATA/LIGA.FDI" {
+			t.Errorf("%s: Match(data/*.fdi) = %v, %v", format, names, err)
+		}
+		if _, ok := a.Find("LIGA.FDI"); ok {
+			t.Errorf("%s: found LIGA.FDI outside DATA", format)
+		}
+	}
+
+	// ARJ compression is not decoded
+	data := archives["arj"]
+	a, _ := OpenArchive(bytes.NewReader(data), int64(len(data)), "arj")
+	e, ok := a.Find("DATA/PACKED.FDI")
+	if !ok {
+		t.Fatalf("no DATA/PACKED.FDI in %+v", a.Entries)
+	}
+	if _, err := e.Read(); err == nil || !strings.Contains(err.Error(), "method 1") {
+		t.Errorf("reading a compressed ARJ member: %v, want an error naming its method", err)
+	}
+
+	if _, err := OpenArchive(bytes.NewReader([]byte("not an archive")), 14, "iso"); err == nil {
+		t.Error("opened text as an ISO image")
+	}
+}
@@ -0,0 +1,67 @@
+package fdi
+
+import "strings"
+
+// BCDNumber is a run of bytes that decodes as packed BCD.
+type BCDNumber struct {
+	Offset int    `json:"offset"`
+	Length int    `json:"length"` // in bytes
+	Digits string `json:"digits"` // decoded digits, leading zeros included
+	Value  uint64 `json:"value"`
+}
+
+// ScanBCD finds runs of 2 to 8 bytes (4 to 16 digits) that decode as packed
+// BCD, two decimal digits per byte. All-zero runs are skipped as padding.
+func ScanBCD(data []byte) []BCDNumber {
+	var found []BCDNumber
+	runStart := -1
+
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && IsBCDByte(data[i]) {
+			if runStart < 0 {
+				runStart = i
+			}
+			continue
+		}
+
+		if runStart >= 0 {
+			run := data[runStart:i]
+			if len(run) >= 2 && len(run) <= 8 && !allZero(run) {
+				digits, value := DecodeBCD(run)
+				found = append(found, BCDNumber{Offset: runStart, Length: len(run), Digits: digits, Value: value})
+			}
+			runStart = -1
+		}
+	}
+
+	return found
+}
+
+// IsBCDByte reports whether both nibbles of b are decimal digits.
+func IsBCDByte(b byte) bool {
+	return b>>4 <= 9 && b&0x0F <= 9
+}
+
+// DecodeBCD decodes packed BCD bytes into their digit string and numeric value.
+func DecodeBCD(run []byte) (string, uint64) {
+	var sb strings.Builder
+	var value uint64
+
+	for _, b := range run {
+		hi, lo := b>>4, b&0x0F
+		sb.WriteByte('0' + hi)
+		sb.WriteByte('0' + lo)
+		value = value*100 + uint64(hi)*10 + uint64(lo)
+	}
+
+	return sb.String(), value
+}
+
+func allZero(b []byte) bool {
+	for _, v := range b {
+		if v != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,111 @@
+package fdi
+
+import (
+	"errors"
+	"sort"
+)
+
+// Values listed for each candidate group of bits
+const maxBitGroupValues = 8
+
+// BitStats is one bit of the byte sampled across all records.
+type BitStats struct {
+	Bit   int     `json:"bit"` // 0 is the least significant
+	Set   int     `json:"set"` // records with the bit set
+	Ratio float64 `json:"ratio"`
+}
+
+// BitValue is a value of a group of bits and the records holding it.
+type BitValue struct {
+	Value   int `json:"value"`
+	Records int `json:"records"`
+}
+
+// BitGroup is a run of adjacent bits that vary across the records, a
+// candidate sub-byte field.
+type BitGroup struct {
+	BitOffset int        `json:"bit_offset"`
+	Bits      int        `json:"bits"`
+	Distinct  int        `json:"distinct"`
+	Min       int        `json:"min"`
+	Max       int        `json:"max"`
+	Values    []BitValue `json:"values"` // the most common first
+}
+
+// BitReport describes the bits of one intra-record byte.
+type BitReport struct {
+	Records int        `json:"records"`
+	Bits    []BitStats `json:"bits"`
+	Groups  []BitGroup `json:"groups"`
+}
+
+// AnalyzeBits samples the byte at fieldOff in every record starting at start
+// and reports how often each bit is set. Bits that are set in every record
+// or in none are taken as unused; each run of the other bits is reported as
+// a candidate field with its value distribution.
+func AnalyzeBits(data []byte, start int, recordSize int, fieldOff int) (BitReport, error) {
+	if recordSize <= 0 {
+		return BitReport{}, ErrNoRecordSize
+	}
+	if fieldOff < 0 || fieldOff >= recordSize {
+		return BitReport{}, errors.New("field offset must be inside the record")
+	}
+	if start < 0 || start >= len(data) {
+		return BitReport{}, ErrOffsetOutOfRange
+	}
+	records := RecordCount(data, start, recordSize)
+	if records == 0 {
+		return BitReport{}, errors.New("no complete records after the start offset")
+	}
+
+	samples := make([]byte, records)
+	for r := range samples {
+		samples[r] = data[start+r*recordSize+fieldOff]
+	}
+
+	report := BitReport{Records: records, Bits: make([]BitStats, 8)}
+	for bit := range report.Bits {
+		set := 0
+		for _, b := range samples {
+			set += int(b >> bit & 1)
+		}
+		report.Bits[bit] = BitStats{Bit: bit, Set: set, Ratio: float64(set) / float64(records)}
+	}
+
+	for bit := 0; bit < 8; {
+		if set := report.Bits[bit].Set; set == 0 || set == records {
+			bit++
+			continue
+		}
+		end := bit
+		for end < 8 && report.Bits[end].Set != 0 && report.Bits[end].Set != records {
+			end++
+		}
+		report.Groups = append(report.Groups, bitGroup(samples, bit, end-bit))
+		bit = end
+	}
+	return report, nil
+}
+
+// The value distribution of bits bit..bit+width-1 of the samples
+func bitGroup(samples []byte, bit int, width int) BitGroup {
+	counts := make(map[int]int)
+	for _, b := range samples {
+		counts[int(b>>bit)&(1<<width-1)]++
+	}
+	g := BitGroup{BitOffset: bit, Bits: width, Distinct: len(counts), Min: 1 << width}
+	for v, n := range counts {
+		g.Min, g.Max = min(g.Min, v), max(g.Max, v)
+		g.Values = append(g.Values, BitValue{Value: v, Records: n})
+	}
+	sort.Slice(g.Values, func(i, j int) bool {
+		if g.Values[i].Records != g.Values[j].Records {
+			return g.Values[i].Records > g.Values[j].Records
+		}
+		return g.Values[i].Value < g.Values[j].Value
+	})
+	if len(g.Values) > maxBitGroupValues {
+		g.Values = g.Values[:maxBitGroupValues]
+	}
+	return g
+}
@@ -0,0 +1,28 @@
+package fdi
+
+import "testing"
+
+func TestAnalyzeBits(t *testing.T) {
+	// Byte 1 of each record: bit 7 always set, bits 4-5 a value 0-3, bit 0 a flag
+	var data []byte
+	for i := 0; i < 12; i++ {
+		data = append(data, 0xEE, 0x80|byte(i%4)<<4|byte(i%3/2), 0)
+	}
+	report, err := AnalyzeBits(data, 0, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Records != 12 || report.Bits[7].Set != 12 || report.Bits[6].Set != 0 || report.Bits[0].Set != 4 {
+		t.Errorf("bits = %+v", report.Bits)
+	}
+	if len(report.Groups) != 2 {
+		t.Fatalf("groups = %+v, want bit 0 and bits 4-5", report.Groups)
+	}
+	if g := report.Groups[1]; g.BitOffset != 4 || g.Bits != 2 || g.Distinct != 4 || g.Min != 0 || g.Max != 3 || g.Values[0].Records != 3 {
+		t.Errorf("bits 4-5 = %+v", g)
+	}
+
+	if _, err := AnalyzeBits(data, 0, 3, 3); err == nil {
+		t.Error("an offset past the record was accepted")
+	}
+}
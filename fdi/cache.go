@@ -0,0 +1,138 @@
+package fdi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Bumped when the results cached change shape, so old entries are not read
+const cacheVersion = "v1"
+
+// Cache keeps the results of analysis passes on disk, keyed by the SHA-256
+// of the file's contents, so that a pass over an unchanged file is not run
+// again. Results are stored with encoding/gob, which keeps the bytes of
+// strings that are not UTF-8, and which drops empty slices: they load as nil.
+// Entries are written to a temporary file and renamed into place, so
+// several analyzers may share one directory: a reader sees a whole entry or
+// none, and an entry it cannot read is taken as missing.
+type Cache struct {
+	Dir string
+}
+
+// DefaultCacheDir returns fdi-analyzer under the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "fdi-analyzer"), nil
+}
+
+// OpenCache returns a Cache in dir, creating it; "" is DefaultCacheDir.
+func OpenCache(dir string) (*Cache, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{Dir: dir}, nil
+}
+
+// The file holding the result of pass over data run with params
+func (c *Cache) entry(data []byte, pass string, params any) (string, error) {
+	key, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	file := hex.EncodeToString(sum[:])
+	ps := sha256.Sum256(key)
+	name := pass + "-" + hex.EncodeToString(ps[:8]) + ".gob"
+	return filepath.Join(c.Dir, cacheVersion, file[:2], file, name), nil
+}
+
+// Load reads the stored result of pass over data run with params into v,
+// reporting whether there was one.
+func (c *Cache) Load(data []byte, pass string, params any, v any) bool {
+	path, err := c.entry(data, pass, params)
+	if err != nil {
+		return false
+	}
+	return c.load(path, v)
+}
+
+func (c *Cache) load(path string, v any) bool {
+	src, err := os.ReadFile(path)
+	return err == nil && gob.NewDecoder(bytes.NewReader(src)).Decode(v) == nil
+}
+
+// Store saves v as the result of pass over data run with params.
+func (c *Cache) Store(data []byte, pass string, params any, v any) error {
+	path, err := c.entry(data, pass, params)
+	if err != nil {
+		return err
+	}
+	return c.store(path, v)
+}
+
+func (c *Cache) store(path string, v any) error {
+	var src bytes.Buffer
+	if err := gob.NewEncoder(&src).Encode(v); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(src.Bytes())
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		// Another analyzer storing the same entry at once may win the rename
+		if _, serr := os.Stat(path); serr == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// Cached returns the result of pass over data run with params from c, or runs
+// it and stores what it returns, unless it has a Partial method that reports
+// it was interrupted. A nil c, as with caching turned off, always runs the
+// pass; failing to store the result is not an error.
+func Cached[T any](c *Cache, data []byte, pass string, params any, run func() T) T {
+	if c == nil {
+		return run()
+	}
+	path, err := c.entry(data, pass, params)
+	if err != nil {
+		return run()
+	}
+	var v T
+	if c.load(path, &v) {
+		return v
+	}
+	v = run()
+	if p, ok := any(v).(interface{ Partial() bool }); ok && p.Partial() {
+		return v
+	}
+	c.store(path, v)
+	return v
+}
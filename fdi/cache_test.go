@@ -0,0 +1,87 @@
+package fdi
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestCached(t *testing.T) {
+	c, err := OpenCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	runs := 0
+	pass := func(data []byte, params int) []int {
+		return Cached(c, data, "test", params, func() []int {
+			runs++
+			return []int{len(data), params}
+		})
+	}
+	data := []byte("SQUADRA JUVENTUS")
+	first := pass(data, 1)
+	if again := pass(data, 1); !reflect.DeepEqual(again, first) || runs != 1 {
+		t.Errorf("second run = %v after %d runs, want %v from the cache", again, runs, first)
+	}
+	pass(data, 2)
+	pass([]byte("SQUADRA TORINO"), 1)
+	if runs != 3 {
+		t.Errorf("%d runs, want other params and data to miss the cache", runs)
+	}
+
+	// An entry that cannot be read is run again
+	path, _ := c.entry(data, "test", 1)
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pass(data, 1)
+	if runs != 4 {
+		t.Errorf("%d runs, want a broken entry to be run again", runs)
+	}
+
+	// Latin text that is not UTF-8 loads with its bytes
+	latin := FoundString{Offset: 3, Text: "CAF\xC9"}
+	c.Store(data, "latin", nil, latin)
+	var loaded FoundString
+	if !c.Load(data, "latin", nil, &loaded) || loaded != latin {
+		t.Errorf("Load = %q, want %q", loaded.Text, latin.Text)
+	}
+
+	if got := Cached(nil, data, "test", 1, func() int { return 7 }); got != 7 {
+		t.Errorf("Cached without a cache = %d, want the pass run", got)
+	}
+}
+
+func TestCachedConcurrent(t *testing.T) {
+	c := &Cache{Dir: t.TempDir()}
+	data := recordsForCacheTest()
+	want := NewAnalyzer(data, AnalysisOptions{MinString: 4}).Records(0, len(data))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := NewAnalyzer(data, AnalysisOptions{MinString: 4}).UseCache(c).Records(0, len(data))
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("cached Records = %+v, want %+v", got, want)
+			}
+		}()
+	}
+	wg.Wait()
+
+	leftover, _ := filepath.Glob(filepath.Join(c.Dir, "*", "*", "*", ".tmp-*"))
+	if len(leftover) > 0 {
+		t.Errorf("temporary files left behind: %v", leftover)
+	}
+}
+
+func recordsForCacheTest() []byte {
+	var data []byte
+	for i := 0; i < 20; i++ {
+		data = append(data, 0xFF, 0xFE, byte(i), 'P', 'L', 'A', 'Y', 'E', 'R', byte('A'+i), 0, 0)
+	}
+	return data
+}
@@ -0,0 +1,78 @@
+package fdi
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Candidate is a possible location of a value tracked across snapshots of a
+// file, as in a memory scanner's narrowing workflow.
+type Candidate struct {
+	Offset int      `json:"offset"`
+	Type   string   `json:"type"`
+	Value  HexBytes `json:"value"` // the bytes at the offset in the last snapshot
+}
+
+// Filters NarrowCandidates applies between two snapshots
+const (
+	FilterChanged   = "changed"
+	FilterUnchanged = "unchanged"
+	FilterIncreased = "increased"
+	FilterDecreased = "decreased"
+)
+
+// CandidatesFrom turns FindValue results into candidates.
+func CandidatesFrom(matches []ValueMatches) []Candidate {
+	var cands []Candidate
+	for _, m := range matches {
+		for _, off := range m.Offsets {
+			cands = append(cands, Candidate{Offset: off, Type: m.Type, Value: m.Pattern})
+		}
+	}
+	return cands
+}
+
+// NarrowCandidates keeps the candidates whose value in data passes filter
+// compared with their stored value, and updates the kept values to data's.
+// Candidates that no longer fit in data are dropped.
+func NarrowCandidates(data []byte, cands []Candidate, filter string) ([]Candidate, error) {
+	var keep func(typ string, old, cur []byte) bool
+	switch filter {
+	case FilterChanged:
+		keep = func(_ string, old, cur []byte) bool { return !bytes.Equal(old, cur) }
+	case FilterUnchanged:
+		keep = func(_ string, old, cur []byte) bool { return bytes.Equal(old, cur) }
+	case FilterIncreased:
+		keep = func(typ string, old, cur []byte) bool { return CompareValues(typ, cur, old) > 0 }
+	case FilterDecreased:
+		keep = func(typ string, old, cur []byte) bool { return CompareValues(typ, cur, old) < 0 }
+	default:
+		return nil, fmt.Errorf("unknown filter %q", filter)
+	}
+	return narrow(data, cands, keep), nil
+}
+
+// NarrowToValue keeps the candidates that hold value in data, encoded as
+// their own type, and updates their stored values.
+func NarrowToValue(data []byte, cands []Candidate, value string) []Candidate {
+	return narrow(data, cands, func(typ string, _, cur []byte) bool {
+		want, err := EncodeValue(value, typ)
+		return err == nil && bytes.Equal(cur, want)
+	})
+}
+
+func narrow(data []byte, cands []Candidate, keep func(typ string, old, cur []byte) bool) []Candidate {
+	kept := []Candidate{}
+	for _, c := range cands {
+		size := ValueSize(c.Type)
+		if size == 0 || c.Offset < 0 || c.Offset+size > len(data) {
+			continue
+		}
+		cur := data[c.Offset : c.Offset+size]
+		if keep(c.Type, c.Value, cur) {
+			c.Value = bytes.Clone(cur)
+			kept = append(kept, c)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,126 @@
+package fdi
+
+import (
+	"fmt"
+	"sort"
+)
+
+// MinCarveSection is the shortest section Carve makes: of two cuts closer
+// than this, only the one from the stronger evidence is kept.
+const MinCarveSection = 64
+
+// Sources of a cut between sections, weakest first
+const (
+	CutEntropy      = "entropy" // the entropy class changes
+	CutEnd          = "end"     // a table or the records end
+	CutPointedTo    = "pointed-to"
+	CutRecords      = "records"
+	CutSectionTag   = "section-tag"
+	CutPointerTable = "pointer-table"
+	CutStart        = "start" // of the data
+)
+
+var cutStrength = map[string]int{
+	CutEntropy: 1, CutEnd: 2, CutPointedTo: 3, CutRecords: 4, CutSectionTag: 5, CutPointerTable: 6, CutStart: 7,
+}
+
+// CarvedSection is one piece of the data between two cuts.
+type CarvedSection struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`    // exclusive
+	Source string `json:"source"` // what placed the cut at Start, one of the Cut constants
+	Detail string `json:"detail,omitempty"`
+	Class  string `json:"class"` // as from ClassifyRegion over the whole section
+}
+
+// Carve splits data into sections at the structure the other scans find:
+// the start and end of pointer tables and of the data they index, of the
+// run of records with the dominant delimiter stride, at section tags, and
+// where the entropy class changes between RegionWindow-sized windows.
+func Carve(data []byte) []CarvedSection {
+	type cut struct {
+		at     int
+		source string
+		detail string
+	}
+	var cuts []cut
+	add := func(at int, source string, detail string) {
+		if at >= 0 && at < len(data) {
+			cuts = append(cuts, cut{at, source, detail})
+		}
+	}
+	add(0, CutStart, "")
+
+	// Spans the structure scans account for, which entropy changes within
+	// should not split
+	type span struct{ start, end int }
+	var spans []span
+
+	for _, t := range FindPointerTables(data, MinPointerEntries) {
+		kind := fmt.Sprintf("u%d%s, %d entries", t.Width*8, t.Endian, len(t.Values))
+		add(t.Offset, CutPointerTable, kind)
+		add(t.End(), CutEnd, fmt.Sprintf("the table at 0x%X", t.Offset))
+		add(t.Targets[0], CutPointedTo, fmt.Sprintf("indexed by the table at 0x%X", t.Offset))
+		spans = append(spans, span{t.Offset, t.End()}, span{t.Targets[0], t.Targets[len(t.Targets)-1]})
+	}
+
+	patterns := FindRepeatPatterns(data)
+	if best, ok := LikelyRecordSize(TallyStrides(patterns)); ok {
+		// The records run to the end of the last one a delimiter at the
+		// stride occurs in
+		last := best.Offset
+		for _, p := range patterns {
+			for i, d := range p.Distances {
+				if d == best.Stride {
+					last = max(last, p.Offsets[i+1])
+				}
+			}
+		}
+		end := best.Offset + ((last-best.Offset)/best.Stride+1)*best.Stride
+		add(best.Offset, CutRecords, fmt.Sprintf("%d-byte records", best.Stride))
+		add(end, CutEnd, "the records")
+		spans = append(spans, span{best.Offset, end})
+	}
+
+	var tags [][]byte
+	for _, tag := range DefaultSectionTags {
+		tags = append(tags, []byte(tag))
+	}
+	for _, s := range FindSections(data, tags) {
+		add(s.Offset, CutSectionTag, fmt.Sprintf("%q", string(s.Tag)))
+	}
+
+	for _, r := range EntropyRegions(EntropyMap(data, RegionWindow)) {
+		inside := false
+		for _, s := range spans {
+			inside = inside || (r.Start > s.start && r.Start < s.end)
+		}
+		if !inside {
+			add(r.Start, CutEntropy, r.Class)
+		}
+	}
+
+	// Of cuts too close together, keep the strongest, and the earlier on a tie
+	sort.SliceStable(cuts, func(i, j int) bool { return cuts[i].at < cuts[j].at })
+	var kept []cut
+	for _, c := range cuts {
+		last := len(kept) - 1
+		if last >= 0 && c.at-kept[last].at < MinCarveSection {
+			if cutStrength[c.source] > cutStrength[kept[last].source] {
+				kept[last] = c
+			}
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	sections := make([]CarvedSection, len(kept))
+	for i, c := range kept {
+		end := len(data)
+		if i+1 < len(kept) {
+			end = kept[i+1].at
+		}
+		sections[i] = CarvedSection{Start: c.at, End: end, Source: c.source, Detail: c.detail, Class: ClassifyRegion(data[c.at:end])}
+	}
+	return sections
+}
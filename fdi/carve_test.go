@@ -0,0 +1,54 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestCarve(t *testing.T) {
+	// A header, a u32le table at 0x100 indexing names at 0x200, 48-byte
+	// records from 0x400, random bytes and zero padding
+	data := make([]byte, 0x400)
+	copy(data, "HEADER v1")
+	for i := 0; i < 10; i++ {
+		binary.LittleEndian.PutUint32(data[0x100+4*i:], uint32(0x200+24*i))
+		copy(data[0x200+24*i:], fmt.Sprintf("NAME%02d", i))
+	}
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 30; i++ {
+		rec := make([]byte, 48)
+		copy(rec, fmt.Sprintf("\xAA\x55PLAYER%02d", i))
+		for j := 32; j < 48; j++ {
+			rec[j] = byte(rng.Intn(100))
+		}
+		data = append(data, rec...)
+	}
+	recordsEnd := len(data)
+	noise := make([]byte, 2048)
+	rng.Read(noise)
+	data = append(append(data, noise...), make([]byte, 512)...)
+
+	sections := Carve(data)
+	cuts := make(map[int]string)
+	for i, s := range sections {
+		cuts[s.Start] = s.Source
+		if i > 0 && s.Start != sections[i-1].End {
+			t.Errorf("section %d starts at 0x%X, not where section %d ends (0x%X)", i, s.Start, i-1, sections[i-1].End)
+		}
+	}
+	if sections[len(sections)-1].End != len(data) {
+		t.Errorf("sections end at 0x%X, want 0x%X", sections[len(sections)-1].End, len(data))
+	}
+	for at, source := range map[int]string{0: CutStart, 0x100: CutPointerTable, 0x200: CutPointedTo, 0x400: CutRecords, recordsEnd: CutEnd} {
+		if cuts[at] != source {
+			t.Errorf("cut at 0x%X = %q, want %q (sections %+v)", at, cuts[at], source, sections)
+		}
+	}
+	for _, s := range sections {
+		if s.Start > 0x400 && s.Start < recordsEnd {
+			t.Errorf("records split at 0x%X", s.Start)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/adler32"
+	"hash/crc32"
+)
+
+// ErrNoRecordSize is returned by record analyses when no record size was given.
+var ErrNoRecordSize = errors.New("record size must be greater than 0")
+
+// ChecksumField is an intra-record field that holds a checksum of the rest of the record.
+type ChecksumField struct {
+	Offset    int    `json:"offset"` // offset within the record
+	Width     int    `json:"width"`
+	Algorithm string `json:"algorithm"`
+}
+
+// A checksum candidate: field width and how to compute the value from the covered bytes
+type checksumAlgo struct {
+	Name  string
+	Width int
+	Sum   func(covered [][]byte) uint64
+	Read  func(field []byte) uint64
+}
+
+var checksumAlgos = []checksumAlgo{
+	{"sum8", 1, sumBytes(0xFF), readUint8},
+	{"xor8", 1, xorBytes, readUint8},
+	{"sum16-le", 2, sumBytes(0xFFFF), readUint16LE},
+	{"sum16-be", 2, sumBytes(0xFFFF), readUint16BE},
+	{"crc16-le", 2, crc16ARC, readUint16LE},
+	{"crc16-be", 2, crc16ARC, readUint16BE},
+	{"crc16ccitt-le", 2, crc16CCITT, readUint16LE},
+	{"crc16ccitt-be", 2, crc16CCITT, readUint16BE},
+	{"crc32-le", 4, crc32Bytes, readUint32LE},
+	{"crc32-be", 4, crc32Bytes, readUint32BE},
+	{"adler32-le", 4, adler32Bytes, readUint32LE},
+	{"adler32-be", 4, adler32Bytes, readUint32BE},
+}
+
+// ChecksumAlgorithms lists the algorithm names the checksum scans report.
+func ChecksumAlgorithms() []string {
+	names := make([]string, len(checksumAlgos))
+	for i, a := range checksumAlgos {
+		names[i] = a.Name
+	}
+	return names
+}
+
+func lookupChecksumAlgo(name string) (checksumAlgo, bool) {
+	for _, a := range checksumAlgos {
+		if a.Name == name {
+			return a, true
+		}
+	}
+	return checksumAlgo{}, false
+}
+
+// RecordCount returns the number of complete records of recordSize bytes after start.
+func RecordCount(data []byte, start int, recordSize int) int {
+	if recordSize <= 0 || start < 0 || start >= len(data) {
+		return 0
+	}
+	return (len(data) - start) / recordSize
+}
+
+// FindRecordChecksums tests every intra-record offset and checksum algorithm
+// against all records starting at start, and returns the fields that match
+// in every record.
+func FindRecordChecksums(data []byte, start int, recordSize int) ([]ChecksumField, error) {
+	if recordSize <= 0 {
+		return nil, ErrNoRecordSize
+	}
+	if start < 0 || start >= len(data) {
+		return nil, ErrOffsetOutOfRange
+	}
+
+	records := RecordCount(data, start, recordSize)
+	if records < 2 {
+		return nil, errors.New("need at least 2 records to test for checksums")
+	}
+
+	var fields []ChecksumField
+	for _, algo := range checksumAlgos {
+		for fieldOff := 0; fieldOff+algo.Width <= recordSize; fieldOff++ {
+			if checksumMatches(data, start, recordSize, records, fieldOff, algo) {
+				fields = append(fields, ChecksumField{Offset: fieldOff, Width: algo.Width, Algorithm: algo.Name})
+			}
+		}
+	}
+	return fields, nil
+}
+
+// Check the candidate field against every record. Fields that are zero in all
+// records are rejected since an all-zero record trivially matches most sums.
+func checksumMatches(data []byte, start, recordSize, records, fieldOff int, algo checksumAlgo) bool {
+	nonZero := false
+	for r := 0; r < records; r++ {
+		rec := data[start+r*recordSize : start+(r+1)*recordSize]
+		field := rec[fieldOff : fieldOff+algo.Width]
+		covered := [][]byte{rec[:fieldOff], rec[fieldOff+algo.Width:]}
+
+		value := algo.Read(field)
+		if value != algo.Sum(covered) {
+			return false
+		}
+		if value != 0 {
+			nonZero = true
+		}
+	}
+	return nonZero
+}
+
+func sumBytes(mask uint64) func([][]byte) uint64 {
+	return func(covered [][]byte) uint64 {
+		var sum uint64
+		for _, part := range covered {
+			for _, b := range part {
+				sum += uint64(b)
+			}
+		}
+		return sum & mask
+	}
+}
+
+func xorBytes(covered [][]byte) uint64 {
+	var x byte
+	for _, part := range covered {
+		for _, b := range part {
+			x ^= b
+		}
+	}
+	return uint64(x)
+}
+
+func crc32Bytes(covered [][]byte) uint64 {
+	h := crc32.NewIEEE()
+	for _, part := range covered {
+		h.Write(part)
+	}
+	return uint64(h.Sum32())
+}
+
+// CRC-16/ARC: reflected polynomial 0x8005, initial value 0
+func crc16ARC(covered [][]byte) uint64 {
+	var crc uint16
+	for _, part := range covered {
+		for _, b := range part {
+			crc ^= uint16(b)
+			for i := 0; i < 8; i++ {
+				if crc&1 != 0 {
+					crc = crc>>1 ^ 0xA001
+				} else {
+					crc >>= 1
+				}
+			}
+		}
+	}
+	return uint64(crc)
+}
+
+// CRC-16/CCITT-FALSE: polynomial 0x1021, initial value 0xFFFF
+func crc16CCITT(covered [][]byte) uint64 {
+	crc := uint16(0xFFFF)
+	for _, part := range covered {
+		for _, b := range part {
+			crc ^= uint16(b) << 8
+			for i := 0; i < 8; i++ {
+				if crc&0x8000 != 0 {
+					crc = crc<<1 ^ 0x1021
+				} else {
+					crc <<= 1
+				}
+			}
+		}
+	}
+	return uint64(crc)
+}
+
+func adler32Bytes(covered [][]byte) uint64 {
+	h := adler32.New()
+	for _, part := range covered {
+		h.Write(part)
+	}
+	return uint64(h.Sum32())
+}
+
+func readUint8(b []byte) uint64    { return uint64(b[0]) }
+func readUint16LE(b []byte) uint64 { return uint64(binary.LittleEndian.Uint16(b)) }
+func readUint16BE(b []byte) uint64 { return uint64(binary.BigEndian.Uint16(b)) }
+func readUint32LE(b []byte) uint64 { return uint64(binary.LittleEndian.Uint32(b)) }
+func readUint32BE(b []byte) uint64 { return uint64(binary.BigEndian.Uint32(b)) }
@@ -0,0 +1,96 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// A file with a CRC32 of the rest stored little endian in its first 4 bytes
+func checksummedFile() []byte {
+	body := []byte("PLAYERS\x00ROSSI\x00BAGGIO\x00ZOFF\x00\x01\x02\x03")
+	data := make([]byte, 4, 4+len(body))
+	binary.LittleEndian.PutUint32(data, crc32.ChecksumIEEE(body))
+	return append(data, body...)
+}
+
+func TestFindChecksums(t *testing.T) {
+	data := checksummedFile()
+	want := Checksum{Algorithm: "crc32-le", Offset: 0, Width: 4, Start: 4, End: len(data)}
+
+	found := FindChecksums(data)
+	ok := false
+	for _, c := range found {
+		ok = ok || c == want
+	}
+	if !ok {
+		t.Errorf("FindChecksums = %v, want it to include %v", found, want)
+	}
+}
+
+func TestFindChecksumsTrailer(t *testing.T) {
+	// sum16 big endian of everything before it, at the end of the data
+	data := []byte("HEADER\x00\x10\x20\x30")
+	var sum uint16
+	for _, b := range data {
+		sum += uint16(b)
+	}
+	data = binary.BigEndian.AppendUint16(data, sum)
+
+	want := Checksum{Algorithm: "sum16-be", Offset: len(data) - 2, Width: 2, Start: 0, End: len(data) - 2}
+	ok := false
+	for _, c := range FindChecksums(data) {
+		ok = ok || c == want
+	}
+	if !ok {
+		t.Errorf("FindChecksums did not find %v", want)
+	}
+}
+
+func TestFixChecksums(t *testing.T) {
+	orig := checksummedFile()
+	c, err := ParseChecksum("crc32-le@0:4-end", len(orig))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edited := bytes.Replace(orig, []byte("ZOFF"), []byte("BURI"), 1)
+	if c.Matches(edited) {
+		t.Fatal("checksum still matches after the edit")
+	}
+	fixed, patches := FixChecksums(edited, []Checksum{c})
+	if !c.Matches(fixed) || len(patches) != 1 || patches[0].Offset != 0 {
+		t.Errorf("FixChecksums patches = %+v, fixed checksum matches = %v", patches, c.Matches(fixed))
+	}
+	if _, patches := FixChecksums(fixed, []Checksum{c}); len(patches) != 0 {
+		t.Errorf("fixing a matching checksum gave patches %+v", patches)
+	}
+}
+
+func TestParseChecksum(t *testing.T) {
+	c, err := ParseChecksum("crc16ccitt-be@0x10:0x0-0x10", 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.String(); got != "crc16ccitt-be@0x10:0x0-0x10" {
+		t.Errorf("String() = %q", got)
+	}
+
+	for _, bad := range []string{"md5@0:1-2", "crc32-le@0", "crc32-le@0:8-4", "crc32-le@0:0-64", "sum8@x:0-1"} {
+		if _, err := ParseChecksum(bad, 32); err == nil {
+			t.Errorf("ParseChecksum(%q) succeeded, want an error", bad)
+		}
+	}
+}
+
+func TestCRC16(t *testing.T) {
+	// Check values for "123456789" from the CRC catalogue
+	check := [][]byte{[]byte("123456789")}
+	if got := crc16ARC(check); got != 0xBB3D {
+		t.Errorf("CRC-16/ARC = 0x%X, want 0xBB3D", got)
+	}
+	if got := crc16CCITT(check); got != 0x29B1 {
+		t.Errorf("CRC-16/CCITT-FALSE = 0x%X, want 0x29B1", got)
+	}
+}
@@ -0,0 +1,106 @@
+package fdi
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// Codepage maps single bytes to runes for a legacy 8-bit encoding.
+type Codepage struct {
+	Name  string
+	runes [256]rune
+}
+
+// Decode returns the rune for b, or unicode.ReplacementChar if b is unmapped.
+func (cp *Codepage) Decode(b byte) rune {
+	return cp.runes[b]
+}
+
+// DecodeString decodes a byte string to UTF-8.
+func (cp *Codepage) DecodeString(b []byte) string {
+	var sb strings.Builder
+	for _, v := range b {
+		sb.WriteRune(cp.runes[v])
+	}
+	return sb.String()
+}
+
+// EncodeString encodes UTF-8 text in the codepage. It fails on characters the
+// codepage cannot represent.
+func (cp *Codepage) EncodeString(s string) ([]byte, error) {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		b, ok := cp.encodeRune(r)
+		if !ok {
+			return nil, fmt.Errorf("%q cannot be encoded in %s", r, cp.Name)
+		}
+		out = append(out, b)
+	}
+	return out, nil
+}
+
+func (cp *Codepage) encodeRune(r rune) (byte, bool) {
+	if r < 0x80 {
+		return byte(r), true
+	}
+	for i := 0x80; i < 256; i++ {
+		if cp.runes[i] == r {
+			return byte(i), true
+		}
+	}
+	return 0, false
+}
+
+// IsPrintable reports whether b decodes to a visible character.
+func (cp *Codepage) IsPrintable(b byte) bool {
+	r := cp.runes[b]
+	return r != unicode.ReplacementChar && unicode.IsGraphic(r)
+}
+
+// IsTextByte reports whether b is printable ASCII or a high byte that decodes
+// to a letter, punctuation or currency sign, the codepage-aware counterpart of
+// the package IsTextByte. Box drawing and other symbols are not text.
+func (cp *Codepage) IsTextByte(b byte) bool {
+	if b >= 32 && b <= 126 {
+		return true
+	}
+	if b < 0x80 {
+		return false
+	}
+	r := cp.runes[b]
+	return unicode.IsLetter(r) || unicode.IsPunct(r) || unicode.Is(unicode.Sc, r)
+}
+
+// Build a codepage from the charmap's decoder, so the tables are those of
+// golang.org/x/text rather than written out here
+func newCodepage(name string, cm *charmap.Charmap) *Codepage {
+	cp := &Codepage{Name: name}
+	for i := range cp.runes {
+		cp.runes[i] = cm.DecodeByte(byte(i))
+	}
+	return cp
+}
+
+var codepages = map[string]*Codepage{
+	"latin1": newCodepage("latin1", charmap.ISO8859_1),
+	"cp1252": newCodepage("cp1252", charmap.Windows1252),
+	"cp437":  newCodepage("cp437", charmap.CodePage437),
+	"cp850":  newCodepage("cp850", charmap.CodePage850),
+}
+
+// LookupCodepage returns the named codepage (latin1, cp1252, cp437 or cp850).
+// Names are case-insensitive and "iso-8859-1" and "windows-1252" are accepted as aliases.
+func LookupCodepage(name string) (*Codepage, bool) {
+	name = strings.ToLower(name)
+	switch name {
+	case "iso-8859-1", "iso8859-1":
+		name = "latin1"
+	case "windows-1252":
+		name = "cp1252"
+	}
+	cp, ok := codepages[name]
+	return cp, ok
+}
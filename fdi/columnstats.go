@@ -0,0 +1,171 @@
+package fdi
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// Fields with at most this many distinct values get a count of each, and
+// look like enums when the values repeat
+const maxEnumValues = 16
+
+// Numeric fields with more distinct values are counted in this many ranges
+const histogramBins = 8
+
+// ColumnStats summarizes one field's values across the records of a table.
+type ColumnStats struct {
+	Field    string  `json:"field"`
+	Offset   int     `json:"offset"` // within the record
+	Type     string  `json:"type"`
+	Records  int     `json:"records"`
+	Distinct int     `json:"distinct"`
+	Numeric  bool    `json:"numeric"` // Min, Max and Mean are set
+	Min      float64 `json:"min"`
+	Max      float64 `json:"max"`
+	Mean     float64 `json:"mean"`
+
+	// constant, increasing (every record's value above the one before) or
+	// enum (a few values shared by many records); "" otherwise
+	Kind      string       `json:"kind,omitempty"`
+	Histogram []ValueCount `json:"histogram"`
+}
+
+// ValueCount is one bar of a histogram: a value, or a range of values, and
+// the records that hold it.
+type ValueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// ByteFields describes each byte of a record of the given size as a uint8
+// field, for a table whose layout is only known by its stride.
+func ByteFields(recordSize int) []Field {
+	fields := make([]Field, recordSize)
+	for i := range fields {
+		fields[i] = Field{Name: fmt.Sprintf("byte %d", i), Offset: i, Type: "uint8"}
+	}
+	return fields
+}
+
+// ComputeColumnStats summarizes each field of the schema over the decoded
+// records, in the schema's order.
+func ComputeColumnStats(s Schema, records []Record) []ColumnStats {
+	stats := make([]ColumnStats, 0, len(s.Fields))
+	for i, f := range s.Fields {
+		c := ColumnStats{Field: f.Name, Offset: f.Offset, Type: f.Type, Records: len(records), Numeric: len(records) > 0}
+		if f.IsBitField() {
+			c.Type = fmt.Sprintf("%s bits %d-%d", f.Type, f.BitOffset, f.BitOffset+f.Bits-1)
+		}
+		counts := make(map[string]int)
+		var order []any // distinct values as first seen
+		var numbers []float64
+		for _, r := range records {
+			v := r.Fields[i].Value
+			key := columnKey(v)
+			if counts[key] == 0 {
+				order = append(order, v)
+			}
+			counts[key]++
+			if n, ok := numericValue(v); ok && c.Numeric {
+				numbers = append(numbers, n)
+			} else {
+				c.Numeric = false
+			}
+		}
+		c.Distinct = len(counts)
+
+		if c.Numeric {
+			c.Min, c.Max = slices.Min(numbers), slices.Max(numbers)
+			sum := 0.0
+			increasing := len(numbers) > 1
+			for j, n := range numbers {
+				sum += n
+				if j > 0 && n <= numbers[j-1] {
+					increasing = false
+				}
+			}
+			c.Mean = sum / float64(len(numbers))
+			if increasing {
+				c.Kind = "increasing"
+			}
+		}
+		switch {
+		case c.Distinct == 1:
+			c.Kind = "constant"
+		case c.Kind == "" && c.Distinct <= maxEnumValues && c.Distinct*2 <= c.Records:
+			c.Kind = "enum"
+		}
+
+		if c.Distinct <= maxEnumValues || !c.Numeric {
+			c.Histogram = valueHistogram(counts, order, c.Numeric)
+		} else {
+			c.Histogram = rangeHistogram(numbers, c.Min, c.Max)
+		}
+		stats = append(stats, c)
+	}
+	return stats
+}
+
+// The distinct values that make up a histogram
+func columnKey(v any) string {
+	if b, ok := v.(HexBytes); ok {
+		return fmt.Sprintf("%X", []byte(b))
+	}
+	return fmt.Sprint(v)
+}
+
+func numericValue(v any) (float64, bool) {
+	switch v := v.(type) {
+	case uint64:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+// A count of each value, the most common first, keeping at most
+// maxEnumValues of them; numbers in ascending order when all fit
+func valueHistogram(counts map[string]int, order []any, numeric bool) []ValueCount {
+	if numeric && len(order) <= maxEnumValues {
+		sort.SliceStable(order, func(i, j int) bool {
+			a, _ := numericValue(order[i])
+			b, _ := numericValue(order[j])
+			return a < b
+		})
+	}
+	bars := make([]ValueCount, 0, len(order))
+	for _, v := range order {
+		bars = append(bars, ValueCount{columnKey(v), counts[columnKey(v)]})
+	}
+	if numeric && len(bars) <= maxEnumValues {
+		return bars
+	}
+	sort.SliceStable(bars, func(i, j int) bool { return bars[i].Count > bars[j].Count })
+	if len(bars) > maxEnumValues {
+		rest := 0
+		for _, b := range bars[maxEnumValues-1:] {
+			rest += b.Count
+		}
+		bars = append(bars[:maxEnumValues-1], ValueCount{"other", rest})
+	}
+	return bars
+}
+
+// Counts of the numbers in histogramBins equal ranges from lo to hi
+func rangeHistogram(numbers []float64, lo, hi float64) []ValueCount {
+	width := (hi - lo) / histogramBins
+	bars := make([]ValueCount, histogramBins)
+	for i := range bars {
+		from, to := lo+float64(i)*width, lo+float64(i+1)*width
+		bars[i].Value = fmt.Sprintf("%g-%g", from, to)
+	}
+	for _, n := range numbers {
+		i := min(int((n-lo)/width), histogramBins-1)
+		bars[i].Count++
+	}
+	return bars
+}
@@ -0,0 +1,46 @@
+package fdi
+
+import "testing"
+
+func TestComputeColumnStats(t *testing.T) {
+	// id, then a constant version byte, a role out of three and a rating
+	var data []byte
+	for i := 0; i < 20; i++ {
+		data = append(data, byte(i+1), 7, byte(i%3), byte(i*i%97))
+	}
+	s := Schema{RecordSize: 4, Fields: []Field{
+		{Name: "id", Offset: 0, Type: "uint8"},
+		{Name: "version", Offset: 1, Type: "uint8"},
+		{Name: "role", Offset: 2, Type: "uint8"},
+		{Name: "rating", Offset: 3, Type: "uint8"},
+	}}
+	records, err := DecodeRecords(data, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := ComputeColumnStats(s, records)
+
+	kinds := map[string]string{"id": "increasing", "version": "constant", "role": "enum", "rating": ""}
+	for _, c := range stats {
+		if c.Kind != kinds[c.Field] {
+			t.Errorf("%s: kind %q, want %q", c.Field, c.Kind, kinds[c.Field])
+		}
+	}
+	if id := stats[0]; id.Min != 1 || id.Max != 20 || id.Mean != 10.5 || id.Distinct != 20 {
+		t.Errorf("id: min %g, max %g, mean %g, %d distinct", id.Min, id.Max, id.Mean, id.Distinct)
+	}
+	if role := stats[2].Histogram; len(role) != 3 || role[0] != (ValueCount{"0", 7}) || role[2] != (ValueCount{"2", 6}) {
+		t.Errorf("role histogram = %v", role)
+	}
+	total := 0
+	for _, b := range stats[3].Histogram {
+		total += b.Count
+	}
+	if len(stats[3].Histogram) != histogramBins || total != 20 {
+		t.Errorf("rating histogram = %v", stats[3].Histogram)
+	}
+
+	if f := ByteFields(4); len(f) != 4 || f[3] != (Field{Name: "byte 3", Offset: 3, Type: "uint8"}) {
+		t.Errorf("ByteFields(4) = %v", f)
+	}
+}
@@ -0,0 +1,306 @@
+package fdi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CompressionFormats lists the formats Decompress understands. zlib and gzip
+// streams carry a header and are found by FindCompressed. lzss (Okumura's
+// 4 KiB window variant) and rle (PackBits) have none: FindCompressed only
+// recognises LZSS-compressed text, and rle blocks must be named.
+var CompressionFormats = []string{"zlib", "gzip", "lzss", "rle"}
+
+// Largest output Decompress produces, so a corrupt stream cannot exhaust memory
+const maxDecompressed = 64 << 20
+
+// Windows of compressedWindow bytes with an entropy of at least
+// compressedEntropy bits per byte look compressed or encrypted. Random bytes
+// reach about 7.8 over such a window and plain data rarely exceeds 6.
+const (
+	compressedWindow  = 1024
+	compressedEntropy = 7.0
+)
+
+// CompressedBlock is a region of data that holds compressed bytes.
+type CompressedBlock struct {
+	Offset  int     `json:"offset"`
+	Length  int     `json:"length"` // compressed bytes
+	Format  string  `json:"format"` // one of CompressionFormats, or "unknown"
+	Size    int     `json:"size"`   // decompressed bytes, 0 when unknown
+	Entropy float64 `json:"entropy"`
+}
+
+// Decompress decodes the stream at the start of data in the given format,
+// returning the output and the number of bytes the stream took up. lzss and
+// rle streams have no end marker and run to the end of data.
+func Decompress(data []byte, format string) ([]byte, int, error) {
+	switch format {
+	case "zlib", "gzip":
+		br := bytes.NewReader(data)
+		var r io.ReadCloser
+		var err error
+		if format == "zlib" {
+			r, err = zlib.NewReader(br)
+		} else {
+			var gz *gzip.Reader
+			if gz, err = gzip.NewReader(br); err == nil {
+				gz.Multistream(false)
+				r = gz
+			}
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", format, err)
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, maxDecompressed+1))
+		if err == nil && len(out) > maxDecompressed {
+			err = errors.New("output too large")
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%s: %w", format, err)
+		}
+		return out, len(data) - br.Len(), nil
+	case "lzss":
+		out, err := decompressLZSS(data)
+		return out, len(data), err
+	case "rle":
+		out, err := decompressPackBits(data)
+		return out, len(data), err
+	}
+	return nil, 0, fmt.Errorf("unknown compression format %q (supported: %s)", format, strings.Join(CompressionFormats, ", "))
+}
+
+// Okumura LZSS: a flag byte announces eight items, least significant bit
+// first; a set bit is a literal and a clear one a 12-bit position in a 4 KiB
+// ring buffer, initially spaces, and a 4-bit length of 3 to 18 bytes
+func decompressLZSS(data []byte) ([]byte, error) {
+	const window, maxMatch, threshold = 4096, 18, 2
+	var ring [window]byte
+	for i := range ring {
+		ring[i] = ' '
+	}
+	r := window - maxMatch
+
+	var out []byte
+	for i := 0; i < len(data); {
+		flags := data[i]
+		i++
+		for bit := 0; bit < 8 && i < len(data); bit++ {
+			if flags&(1<<bit) != 0 {
+				out = append(out, data[i])
+				ring[r] = data[i]
+				r = (r + 1) & (window - 1)
+				i++
+				continue
+			}
+			if i+1 >= len(data) {
+				return nil, errors.New("lzss: stream ends inside a match")
+			}
+			pos := int(data[i]) | int(data[i+1]&0xF0)<<4
+			n := int(data[i+1]&0x0F) + threshold + 1
+			i += 2
+			for k := 0; k < n; k++ {
+				b := ring[(pos+k)&(window-1)]
+				out = append(out, b)
+				ring[r] = b
+				r = (r + 1) & (window - 1)
+			}
+		}
+		if len(out) > maxDecompressed {
+			return nil, errors.New("lzss: output too large")
+		}
+	}
+	return out, nil
+}
+
+// PackBits RLE: a count byte n of 0 to 127 copies the next n+1 bytes, one
+// of -1 to -127 repeats the next byte 1-n times and -128 is skipped
+func decompressPackBits(data []byte) ([]byte, error) {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := int(int8(data[i]))
+		i++
+		switch {
+		case n >= 0:
+			if i+n+1 > len(data) {
+				return nil, errors.New("rle: stream ends inside a literal run")
+			}
+			out = append(out, data[i:i+n+1]...)
+			i += n + 1
+		case n > -128:
+			if i >= len(data) {
+				return nil, errors.New("rle: stream ends inside a repeat")
+			}
+			out = append(out, bytes.Repeat(data[i:i+1], 1-n)...)
+			i++
+		}
+		if len(out) > maxDecompressed {
+			return nil, errors.New("rle: output too large")
+		}
+	}
+	return out, nil
+}
+
+// ParseCompressedBlock parses a "[<format>@]<offset>[:<end>]" spec naming a
+// compressed block. With no format the block must start with a zlib or gzip
+// header; with no end lzss and rle blocks run to the end of the data.
+func ParseCompressedBlock(spec string, dataLen int) (format string, start int, end int, err error) {
+	format, rest, ok := strings.Cut(spec, "@")
+	if !ok {
+		format, rest = "", spec
+	}
+	startStr, endStr, hasEnd := strings.Cut(rest, ":")
+	n, err := strconv.ParseInt(startStr, 0, 64)
+	if err != nil || n < 0 || int(n) >= dataLen {
+		return "", 0, 0, fmt.Errorf("block %q: invalid offset %q", spec, startStr)
+	}
+	start, end = int(n), dataLen
+	if hasEnd {
+		n, err := strconv.ParseInt(endStr, 0, 64)
+		if err != nil || int(n) <= start || int(n) > dataLen {
+			return "", 0, 0, fmt.Errorf("block %q: invalid end %q", spec, endStr)
+		}
+		end = int(n)
+	}
+	return format, start, end, nil
+}
+
+// DetectCompression reports the format of the zlib or gzip stream starting
+// data from its header, or "" when there is none.
+func DetectCompression(data []byte) string {
+	switch {
+	case len(data) >= 3 && data[0] == 0x1F && data[1] == 0x8B && data[2] == 0x08:
+		return "gzip"
+	case len(data) >= 2 && data[0]&0x0F == 8 && data[0]>>4 <= 7 && (int(data[0])<<8|int(data[1]))%31 == 0 && data[1]&0x20 == 0:
+		return "zlib"
+	}
+	return ""
+}
+
+// FindCompressed lists the compressed blocks in data. zlib and gzip streams
+// are reported when their header is followed by a stream that decodes
+// cleanly. Other runs of high-entropy windows are reported as lzss when they
+// decode as LZSS to text that reads much better than the raw bytes, and as
+// unknown otherwise, which may also mean encrypted.
+func FindCompressed(data []byte) []CompressedBlock {
+	var blocks []CompressedBlock
+	for i := 0; i+2 <= len(data); i++ {
+		// Only the header bytes real encoders write, to keep the scan quick
+		if !(data[i] == 0x1F && i+3 <= len(data) && data[i+1] == 0x8B) &&
+			!(data[i] == 0x78 && bytes.IndexByte([]byte{0x01, 0x5E, 0x9C, 0xDA}, data[i+1]) >= 0) {
+			continue
+		}
+		format := DetectCompression(data[i:])
+		if format == "" {
+			continue
+		}
+		out, n, err := Decompress(data[i:], format)
+		if err != nil || len(out) == 0 {
+			continue
+		}
+		blocks = append(blocks, CompressedBlock{Offset: i, Length: n, Format: format, Size: len(out), Entropy: ShannonEntropy(data[i : i+n])})
+		i += n - 1
+	}
+
+	// High-entropy runs between the streams already found
+	var unknown []CompressedBlock
+	next := 0
+	for _, b := range blocks {
+		unknown = append(unknown, denseRuns(data, next, b.Offset)...)
+		next = b.Offset + b.Length
+	}
+	unknown = append(unknown, denseRuns(data, next, len(data))...)
+	blocks = append(blocks, unknown...)
+	sort.SliceStable(blocks, func(i, j int) bool { return blocks[i].Offset < blocks[j].Offset })
+	return blocks
+}
+
+// Runs of compressedWindow windows in data[start:end] whose entropy marks
+// them as compressed, each named lzss when it decodes as LZSS to text
+func denseRuns(data []byte, start int, end int) []CompressedBlock {
+	var runs []CompressedBlock
+	runStart := -1
+	for off := start; ; off += compressedWindow {
+		dense := off+compressedWindow <= end && ShannonEntropy(data[off:off+compressedWindow]) >= compressedEntropy
+		if dense {
+			if runStart < 0 {
+				runStart = off
+			}
+			continue
+		}
+		if runStart >= 0 {
+			from, to := extendRun(data, start, end, runStart, off)
+			block := data[from:to]
+			b := CompressedBlock{Offset: from, Length: len(block), Format: "unknown", Entropy: ShannonEntropy(block)}
+			if out, err := decompressLZSS(block); err == nil && readsAsText(out) && textRatio(block) < 0.75 {
+				b.Format, b.Size = "lzss", len(out)
+			}
+			runs = append(runs, b)
+			runStart = -1
+		}
+		if off+compressedWindow > end {
+			return runs
+		}
+	}
+}
+
+// Grow a run of dense windows over the neighbouring bytes up to any zero
+// padding, as a stream rarely starts or ends on a window boundary
+func extendRun(data []byte, start int, end int, from int, to int) (int, int) {
+	const step, minEntropy = 64, 3.5
+	for from > start {
+		n := min(step, from-start)
+		if ShannonEntropy(data[from-n:from]) < minEntropy {
+			break
+		}
+		from -= n
+	}
+	for n := 0; n < step && from > start && data[from-1] != 0; n++ {
+		from--
+	}
+	for from < to && data[from] == 0 {
+		from++
+	}
+	for to < end {
+		n := min(step, end-to)
+		if ShannonEntropy(data[to:to+n]) < minEntropy {
+			break
+		}
+		to += n
+	}
+	for n := 0; n < step && to < end && data[to] != 0; n++ {
+		to++
+	}
+	for to > from && data[to-1] == 0 {
+		to--
+	}
+	return from, to
+}
+
+// LZSS output that is text with a plausible share of spaces; garbage decodes
+// to mostly spaces copied from the initial ring buffer
+func readsAsText(out []byte) bool {
+	return textRatio(out) >= 0.9 && bytes.Count(out, []byte{' '})*10 < len(out)*3
+}
+
+// Fraction of the bytes that are printable text
+func textRatio(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	text := 0
+	for _, b := range data {
+		if IsTextByte(b) || b == '\n' || b == '\r' || b == '\t' {
+			text++
+		}
+	}
+	return float64(text) / float64(len(data))
+}
@@ -0,0 +1,158 @@
+package fdi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// Greedy Okumura LZSS encoder, the counterpart of decompressLZSS
+func compressLZSS(data []byte) []byte {
+	const window, maxMatch, threshold = 4096, 18, 2
+	var ring [window]byte
+	for i := range ring {
+		ring[i] = ' '
+	}
+	r := window - maxMatch
+
+	var out, group []byte
+	var flags byte
+	items := 0
+	flush := func() {
+		out = append(append(out, flags), group...)
+		flags, group, items = 0, group[:0], 0
+	}
+	for i := 0; i < len(data); {
+		bestPos, bestLen := 0, 0
+		for pos := 0; pos < window; pos++ {
+			n := 0
+			// Only match bytes already in the ring, not the ones about to be written
+			for n < maxMatch && i+n < len(data) && (pos+n)&(window-1) != r && ring[(pos+n)&(window-1)] == data[i+n] {
+				n++
+			}
+			if n > bestLen {
+				bestPos, bestLen = pos, n
+			}
+		}
+		if bestLen <= threshold {
+			flags |= 1 << items
+			group = append(group, data[i])
+			bestLen = 1
+		} else {
+			group = append(group, byte(bestPos), byte(bestPos>>4&0xF0|(bestLen-threshold-1)))
+		}
+		for k := 0; k < bestLen; k++ {
+			ring[r] = data[i+k]
+			r = (r + 1) & (window - 1)
+		}
+		i += bestLen
+		if items++; items == 8 {
+			flush()
+		}
+	}
+	if items > 0 {
+		flush()
+	}
+	return out
+}
+
+func TestDecompressRoundTrip(t *testing.T) {
+	plain := []byte(strings.Repeat("PLAYER ROSSI PLAYER BIANCHI ", 40))
+
+	var zbuf, gbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write(plain)
+	zw.Close()
+	gw := gzip.NewWriter(&gbuf)
+	gw.Write(plain)
+	gw.Close()
+
+	for _, tc := range []struct {
+		format string
+		packed []byte
+	}{
+		{"zlib", zbuf.Bytes()},
+		{"gzip", gbuf.Bytes()},
+		{"lzss", compressLZSS(plain)},
+		{"rle", []byte{0x02, 'A', 'B', 'C', 0xFD, 'x', 0x80, 0x00, 'Z'}},
+	} {
+		want := plain
+		if tc.format == "rle" {
+			want = []byte("ABCxxxxZ")
+		}
+		// Trailing bytes after a zlib or gzip stream are not part of it
+		data := append(append([]byte{}, tc.packed...), "TAIL"...)
+		if tc.format == "lzss" || tc.format == "rle" {
+			data = tc.packed
+		}
+
+		got, n, err := Decompress(data, tc.format)
+		if err != nil {
+			t.Errorf("%s: %v", tc.format, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("%s: decompressed %q", tc.format, got)
+		}
+		if n != len(tc.packed) {
+			t.Errorf("%s: consumed %d bytes, want %d", tc.format, n, len(tc.packed))
+		}
+	}
+}
+
+func TestFindCompressed(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := make([]byte, 4096)
+	rng.Read(noise)
+
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	zw.Write(bytes.Repeat([]byte("HEADER DATA "), 100))
+	zw.Close()
+
+	data := make([]byte, 0x100)
+	data = append(data, zbuf.Bytes()...)
+	data = append(data, make([]byte, 0x300)...)
+	noiseAt := len(data)
+	data = append(data, noise...)
+	data = append(data, make([]byte, 0x100)...)
+
+	blocks := FindCompressed(data)
+	if len(blocks) != 2 {
+		t.Fatalf("FindCompressed = %+v, want a zlib and an unknown block", blocks)
+	}
+	if b := blocks[0]; b.Format != "zlib" || b.Offset != 0x100 || b.Length != zbuf.Len() || b.Size != 1200 {
+		t.Errorf("zlib block = %+v", b)
+	}
+	if b := blocks[1]; b.Format != "unknown" || b.Offset < noiseAt || b.Offset+b.Length > noiseAt+len(noise) {
+		t.Errorf("noise block = %+v", b)
+	}
+}
+
+func TestFindCompressedLZSSText(t *testing.T) {
+	// Varied text so the compressed stream has little redundancy left
+	rng := rand.New(rand.NewSource(2))
+	words := strings.Fields("rossi bianchi verdi neri gialli portiere difensore centrocampista attaccante allenatore squadra stadio partita campionato coppa lega")
+	var text strings.Builder
+	for text.Len() < 16384 {
+		text.WriteString(words[rng.Intn(len(words))])
+		text.WriteString(" ")
+		if rng.Intn(5) == 0 {
+			text.WriteString(strings.ToUpper(words[rng.Intn(len(words))]))
+			text.WriteString(" ")
+		}
+	}
+
+	packed := compressLZSS([]byte(text.String()))
+	data := append(make([]byte, 0x400), packed...)
+	blocks := FindCompressed(data)
+	if len(blocks) != 1 || blocks[0].Format != "lzss" {
+		t.Fatalf("FindCompressed = %+v, want one lzss block (entropy %.2f)", blocks, ShannonEntropy(packed))
+	}
+	if b := blocks[0]; b.Offset != 0x400 || b.Length != len(packed) || b.Size != text.Len() {
+		t.Errorf("lzss block = %+v, want offset 0x400, %d bytes decoding to %d", b, len(packed), text.Len())
+	}
+}
@@ -0,0 +1,223 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateEncoding is a way a date may be stored.
+type DateEncoding struct {
+	Name        string
+	Width       int // bytes
+	Description string
+	decode      func(b []byte) (time.Time, bool)
+}
+
+var unixEpoch = time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// DateEncodings lists the encodings ScanDates looks for.
+var DateEncodings = []DateEncoding{
+	{"dos", 2, "MS-DOS packed date, u16le: years since 1980, month and day", func(b []byte) (time.Time, bool) {
+		return dosDate(binary.LittleEndian.Uint16(b))
+	}},
+	{"days", 2, "u16le days since 1970-01-01", func(b []byte) (time.Time, bool) {
+		return unixEpoch.AddDate(0, 0, int(binary.LittleEndian.Uint16(b))), true
+	}},
+	{"ymd", 3, "bytes of years since 1900, month and day", func(b []byte) (time.Time, bool) {
+		return validDate(1900+int(b[0]), int(b[1]), int(b[2]))
+	}},
+	{"dmy", 3, "bytes of day, month and years since 1900", func(b []byte) (time.Time, bool) {
+		return validDate(1900+int(b[2]), int(b[1]), int(b[0]))
+	}},
+	{"ymd16", 4, "u16le year, then bytes of month and day", func(b []byte) (time.Time, bool) {
+		return validDate(int(binary.LittleEndian.Uint16(b)), int(b[2]), int(b[3]))
+	}},
+}
+
+// The date, if day and month exist in the year
+func validDate(year, month, day int) (time.Time, bool) {
+	d := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	return d, month >= 1 && month <= 12 && d.Day() == day
+}
+
+// A date field is reported when this many records in a row hold one
+const MinDateRecords = 6
+
+// Dates further apart than this are not taken as records of one table
+const maxDateStride = 512
+
+// DateOptions limits the dates ScanDates accepts.
+type DateOptions struct {
+	FromYear, ToYear int // inclusive; 1980 and 2030 when zero
+}
+
+// ParseYears reads a range of years written as <from>-<to>.
+func ParseYears(spec string) (DateOptions, error) {
+	from, to, ok := strings.Cut(spec, "-")
+	lo, err1 := strconv.Atoi(strings.TrimSpace(from))
+	hi, err2 := strconv.Atoi(strings.TrimSpace(to))
+	if !ok || err1 != nil || err2 != nil || lo > hi {
+		return DateOptions{}, fmt.Errorf("invalid year range %q (want e.g. 1980-2030)", spec)
+	}
+	return DateOptions{FromYear: lo, ToYear: hi}, nil
+}
+
+// DateField is a date repeated at a fixed stride, as in the records of a
+// calendar or of players' birthdays.
+type DateField struct {
+	Encoding  string   `json:"encoding"` // see DateEncodings
+	Offset    int      `json:"offset"`   // of the first record's date
+	Stride    int      `json:"stride"`
+	Count     int      `json:"count"`
+	Distinct  int      `json:"distinct"`
+	Earliest  string   `json:"earliest"` // yyyy-mm-dd
+	Latest    string   `json:"latest"`
+	Ascending bool     `json:"ascending"` // each date on or after the one before, as in a calendar
+	Sample    []string `json:"sample"`    // the first dates
+
+	span time.Duration // from the earliest date to the latest
+}
+
+// Dates shown in a DateField's sample
+const dateSample = 5
+
+// ScanDates finds fields that hold a plausible date, in one of
+// DateEncodings and within the years of opts, in at least MinDateRecords
+// records in a row. Bytes within text, or all the same, are not taken as
+// dates, and the dates of a field must span at most half the years, as a
+// season's calendar or players' birthdays do while bytes that merely decode
+// as dates spread over the whole range. The longest runs come first, and of
+// those the closest dates.
+func ScanDates(data []byte, opts DateOptions) ([]DateField, error) {
+	if opts.FromYear == 0 && opts.ToYear == 0 {
+		opts = DateOptions{FromYear: 1980, ToYear: 2030}
+	}
+	if opts.FromYear > opts.ToYear {
+		return nil, errors.New("the first year of the range is after the last")
+	}
+
+	maxSpan := time.Duration(opts.ToYear-opts.FromYear+1) * 365 * 24 * time.Hour / 2
+
+	var fields []DateField
+	for _, enc := range DateEncodings {
+		hit := make([]bool, len(data))
+		var hits []int
+		for off := 0; off+enc.Width <= len(data); off++ {
+			b := data[off : off+enc.Width]
+			if inText(data, off, enc.Width) || allSame(b) {
+				continue
+			}
+			if d, ok := enc.decode(b); ok && d.Year() >= opts.FromYear && d.Year() <= opts.ToYear {
+				hit[off] = true
+				hits = append(hits, off)
+			}
+		}
+
+		// Each run of hits at a stride, from where it starts
+		var runs []DateField
+		for i, start := range hits {
+			for _, next := range hits[i+1:] {
+				stride := next - start
+				if stride > maxDateStride {
+					break
+				}
+				if stride < enc.Width || (start >= stride && hit[start-stride]) {
+					continue
+				}
+				n := 0
+				for off := start; off < len(data) && hit[off]; off += stride {
+					n++
+				}
+				if n >= MinDateRecords {
+					if f, ok := dateField(data, enc, start, stride, n, maxSpan); ok {
+						runs = append(runs, f)
+					}
+				}
+			}
+		}
+
+		// The longest runs claim their dates; shorter ones through them, as
+		// at a multiple of the stride, are dropped
+		sort.SliceStable(runs, func(i, j int) bool { return runs[i].Count > runs[j].Count })
+		claimed := make(map[int]bool)
+		for _, f := range runs {
+			taken := false
+			for k := 0; k < f.Count && !taken; k++ {
+				taken = claimed[f.Offset+k*f.Stride]
+			}
+			if taken {
+				continue
+			}
+			for k := 0; k < f.Count; k++ {
+				claimed[f.Offset+k*f.Stride] = true
+			}
+			fields = append(fields, f)
+		}
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		if fields[i].Count != fields[j].Count {
+			return fields[i].Count > fields[j].Count
+		}
+		if fields[i].span != fields[j].span {
+			return fields[i].span < fields[j].span // a calendar's dates are close together
+		}
+		return fields[i].Offset < fields[j].Offset
+	})
+	return fields, nil
+}
+
+// Summarize a run of dates, unless they are all the same date or spread
+// over more than maxSpan
+func dateField(data []byte, enc DateEncoding, start, stride, n int, maxSpan time.Duration) (DateField, bool) {
+	f := DateField{Encoding: enc.Name, Offset: start, Stride: stride, Count: n, Ascending: true}
+	distinct := make(map[time.Time]bool)
+	var lo, hi, prev time.Time
+	for k := 0; k < n; k++ {
+		off := start + k*stride
+		d, _ := enc.decode(data[off : off+enc.Width])
+		distinct[d] = true
+		if k == 0 {
+			lo, hi = d, d
+		}
+		if d.Before(lo) {
+			lo = d
+		}
+		if d.After(hi) {
+			hi = d
+		}
+		if k > 0 && d.Before(prev) {
+			f.Ascending = false
+		}
+		prev = d
+		if k < dateSample {
+			f.Sample = append(f.Sample, d.Format(time.DateOnly))
+		}
+	}
+	f.Distinct = len(distinct)
+	f.Earliest, f.Latest, f.span = lo.Format(time.DateOnly), hi.Format(time.DateOnly), hi.Sub(lo)
+	return f, f.Distinct > 1 && f.span <= maxSpan
+}
+
+// Whether the bytes and those either side of them are text, as inside a name
+func inText(data []byte, off, width int) bool {
+	for i := max(off-1, 0); i < min(off+width+1, len(data)); i++ {
+		if !IsTextByte(data[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func allSame(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
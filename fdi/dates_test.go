@@ -0,0 +1,44 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestScanDates(t *testing.T) {
+	// A calendar of 8-byte records, a match a week from 6 September 1997,
+	// with the MS-DOS date in the last two bytes, between zero filler
+	data := make([]byte, 0x100)
+	for i := 0; i < 10; i++ {
+		day := 6 + 7*i
+		month := 9 + (day-1)/30
+		day = (day-1)%30 + 1
+		rec := []byte{byte(i + 1), 0, byte(i), 0, byte(i + 10), 0, 0, 0}
+		binary.LittleEndian.PutUint16(rec[6:], uint16(17<<9|month<<5|day))
+		data = append(data, rec...)
+	}
+	data = append(data, make([]byte, 0x100)...)
+
+	fields, err := ScanDates(data, DateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) == 0 {
+		t.Fatal("no date field found")
+	}
+	f := fields[0]
+	if f.Encoding != "dos" || f.Offset != 0x106 || f.Stride != 8 || f.Count != 10 || !f.Ascending {
+		t.Errorf("best field = %+v, want dos dates at 0x106 every 8 bytes", f)
+	}
+	if f.Earliest != "1997-09-06" || f.Sample[1] != "1997-09-13" {
+		t.Errorf("dates %s to %s, sample %v", f.Earliest, f.Latest, f.Sample)
+	}
+
+	// Outside the years asked for, nothing is found
+	if fields, _ := ScanDates(data, DateOptions{FromYear: 2000, ToYear: 2030}); len(fields) != 0 {
+		t.Errorf("found %+v in 2000-2030", fields[0])
+	}
+	if _, err := ParseYears("2030-1980"); err == nil {
+		t.Error("ParseYears took a reversed range")
+	}
+}
@@ -0,0 +1,93 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// NumericValues holds the bytes at an offset interpreted as common numeric
+// types. Only widths up to Available bytes are filled in.
+type NumericValues struct {
+	Offset    int    `json:"offset"`
+	Available int    `json:"available"`        // bytes available at the offset, capped at 8
+	Endian    string `json:"endian,omitempty"` // the file's likely byte order, when the caller knows it
+
+	U8 uint8 `json:"u8"`
+	I8 int8  `json:"i8"`
+
+	U16LE uint16 `json:"u16_le"`
+	U16BE uint16 `json:"u16_be"`
+	I16LE int16  `json:"i16_le"`
+	I16BE int16  `json:"i16_be"`
+
+	U32LE uint32  `json:"u32_le"`
+	U32BE uint32  `json:"u32_be"`
+	I32LE int32   `json:"i32_le"`
+	I32BE int32   `json:"i32_be"`
+	F32LE float32 `json:"f32_le"`
+	F32BE float32 `json:"f32_be"`
+
+	U64LE uint64  `json:"u64_le"`
+	U64BE uint64  `json:"u64_be"`
+	I64LE int64   `json:"i64_le"`
+	I64BE int64   `json:"i64_be"`
+	F64LE float64 `json:"f64_le"`
+	F64BE float64 `json:"f64_be"`
+}
+
+// Decode interprets the bytes at offset as integers and floats of every width that fits.
+func Decode(data []byte, offset int) (NumericValues, error) {
+	if offset < 0 || offset >= len(data) {
+		return NumericValues{}, ErrOffsetOutOfRange
+	}
+
+	b := data[offset:]
+	v := NumericValues{Offset: offset, Available: min(len(b), 8)}
+
+	v.U8 = b[0]
+	v.I8 = int8(b[0])
+
+	if v.Available >= 2 {
+		v.U16LE, v.U16BE = binary.LittleEndian.Uint16(b), binary.BigEndian.Uint16(b)
+		v.I16LE, v.I16BE = int16(v.U16LE), int16(v.U16BE)
+	}
+	if v.Available >= 4 {
+		v.U32LE, v.U32BE = binary.LittleEndian.Uint32(b), binary.BigEndian.Uint32(b)
+		v.I32LE, v.I32BE = int32(v.U32LE), int32(v.U32BE)
+		v.F32LE, v.F32BE = math.Float32frombits(v.U32LE), math.Float32frombits(v.U32BE)
+	}
+	if v.Available >= 8 {
+		v.U64LE, v.U64BE = binary.LittleEndian.Uint64(b), binary.BigEndian.Uint64(b)
+		v.I64LE, v.I64BE = int64(v.U64LE), int64(v.U64BE)
+		v.F64LE, v.F64BE = math.Float64frombits(v.U64LE), math.Float64frombits(v.U64BE)
+	}
+	return v, nil
+}
+
+// MarshalJSON encodes the values, with NaN and infinite floats as the strings
+// "NaN", "+Inf" and "-Inf" since JSON numbers cannot represent them.
+func (v NumericValues) MarshalJSON() ([]byte, error) {
+	type plain NumericValues
+	return json.Marshal(struct {
+		plain
+		F32LE jsonFloat `json:"f32_le"`
+		F32BE jsonFloat `json:"f32_be"`
+		F64LE jsonFloat `json:"f64_le"`
+		F64BE jsonFloat `json:"f64_be"`
+	}{plain(v), jsonFloat{float64(v.F32LE), 32}, jsonFloat{float64(v.F32BE), 32}, jsonFloat{v.F64LE, 64}, jsonFloat{v.F64BE, 64}})
+}
+
+// A float that marshals non-finite values as strings
+type jsonFloat struct {
+	value float64
+	bits  int
+}
+
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if math.IsNaN(f.value) || math.IsInf(f.value, 0) {
+		return json.Marshal(strconv.FormatFloat(f.value, 'g', -1, f.bits))
+	}
+	return strconv.AppendFloat(nil, f.value, 'g', -1, f.bits), nil
+}
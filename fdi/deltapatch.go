@@ -0,0 +1,377 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+// PatchFormats lists the delta patch formats CreateDeltaPatch writes and
+// ApplyDeltaPatch reads: IPS, BPS and a JSON list of changed runs.
+var PatchFormats = []string{"ips", "bps", "json"}
+
+// Limits of the IPS format: 24-bit offsets and 16-bit record sizes
+const (
+	maxIPSOffset = 1<<24 - 1
+	maxIPSRecord = 1<<16 - 1
+	ipsEOF       = 0x454F46 // an offset that reads as the "EOF" marker
+)
+
+var (
+	ipsMagic = []byte("PATCH")
+	bpsMagic = []byte("BPS1")
+)
+
+// JSONPatch is the JSON delta patch: the changed runs with their bytes before
+// and after, so that applying it checks it fits the file.
+type JSONPatch struct {
+	Format      string    `json:"format"` // always fdi-patch
+	SourceSize  int       `json:"source_size"`
+	TargetSize  int       `json:"target_size"`
+	SourceCRC32 uint32    `json:"source_crc32"`
+	TargetCRC32 uint32    `json:"target_crc32"`
+	Changes     []DiffRun `json:"changes"` // bytes past the source are added with an empty old
+}
+
+const jsonPatchFormat = "fdi-patch"
+
+// DetectPatchFormat names the format of a delta patch by its first bytes,
+// or returns "" for none of PatchFormats.
+func DetectPatchFormat(patch []byte) string {
+	switch {
+	case bytes.HasPrefix(patch, ipsMagic):
+		return "ips"
+	case bytes.HasPrefix(patch, bpsMagic):
+		return "bps"
+	case bytes.HasPrefix(bytes.TrimSpace(patch), []byte("{")):
+		return "json"
+	}
+	return ""
+}
+
+// CreateDeltaPatch encodes the changes that turn source into target as a
+// patch in one of PatchFormats.
+func CreateDeltaPatch(format string, source, target []byte) ([]byte, error) {
+	switch format {
+	case "ips":
+		return createIPS(source, target)
+	case "bps":
+		return createBPS(source, target), nil
+	case "json":
+		return createJSONPatch(source, target)
+	}
+	return nil, fmt.Errorf("unknown patch format %q (supported: ips, bps, json)", format)
+}
+
+// ApplyDeltaPatch applies a patch in any of PatchFormats to data and returns
+// the result with the patch's format. BPS and JSON patches are checked
+// against the file they were made from; IPS patches carry no check.
+func ApplyDeltaPatch(data, patch []byte) ([]byte, string, error) {
+	format := DetectPatchFormat(patch)
+	var out []byte
+	var err error
+	switch format {
+	case "ips":
+		out, err = applyIPS(data, patch)
+	case "bps":
+		out, err = applyBPS(data, patch)
+	case "json":
+		out, err = applyJSONPatch(data, patch)
+	default:
+		return nil, "", errors.New("not an IPS, BPS or JSON patch")
+	}
+	return out, format, err
+}
+
+// The changed runs of target against source, with the bytes target adds
+// past the end of source as a run of their own
+func deltaRuns(source, target []byte) []DiffRun {
+	runs := Diff(source, target)
+	if len(target) > len(source) {
+		runs = append(runs, DiffRun{Offset: len(source), Old: HexBytes{}, New: target[len(source):]})
+	}
+	return runs
+}
+
+// IPS: "PATCH", then records of a 3-byte offset, a 2-byte size and the
+// bytes, or a size of 0 followed by a 2-byte count and a byte to repeat;
+// "EOF", and a 3-byte size to truncate the file to when it shrank
+func createIPS(source, target []byte) ([]byte, error) {
+	if len(target) > maxIPSOffset+1 {
+		return nil, fmt.Errorf("IPS patches reach only the first 16 MiB; the file is %d bytes, use bps", len(target))
+	}
+	out := bytes.Clone(ipsMagic)
+	for _, r := range deltaRuns(source, target) {
+		off, b := r.Offset, []byte(r.New)
+		if off == ipsEOF {
+			off, b = off-1, target[off-1:off+len(b)] // would read as the end marker
+		}
+		for len(b) > 0 {
+			n := min(len(b), maxIPSRecord)
+			out = append(out, byte(off>>16), byte(off>>8), byte(off), byte(n>>8), byte(n))
+			out = append(out, b[:n]...)
+			off, b = off+n, b[n:]
+			if off == ipsEOF && len(b) > 0 {
+				// Start the next record a byte early rather than at "EOF"
+				off--
+				b = target[off : off+len(b)+1]
+			}
+		}
+	}
+	out = append(out, "EOF"...)
+	if n := len(target); n < len(source) {
+		out = append(out, byte(n>>16), byte(n>>8), byte(n))
+	}
+	return out, nil
+}
+
+func applyIPS(data, patch []byte) ([]byte, error) {
+	out := bytes.Clone(data)
+	at := len(ipsMagic)
+	truncated := errors.New("IPS patch: truncated")
+	for {
+		if at+3 > len(patch) {
+			return nil, truncated
+		}
+		if bytes.Equal(patch[at:at+3], []byte("EOF")) {
+			at += 3
+			break
+		}
+		if at+5 > len(patch) {
+			return nil, truncated
+		}
+		off := int(patch[at])<<16 | int(patch[at+1])<<8 | int(patch[at+2])
+		n := int(binary.BigEndian.Uint16(patch[at+3:]))
+		at += 5
+		var b []byte
+		if n == 0 {
+			if at+3 > len(patch) {
+				return nil, truncated
+			}
+			b = bytes.Repeat(patch[at+2:at+3], int(binary.BigEndian.Uint16(patch[at:])))
+			at += 3
+		} else {
+			if at+n > len(patch) {
+				return nil, truncated
+			}
+			b = patch[at : at+n]
+			at += n
+		}
+		if end := off + len(b); end > len(out) {
+			out = append(out, make([]byte, end-len(out))...)
+		}
+		copy(out[off:], b)
+	}
+	switch len(patch) - at {
+	case 0:
+	case 3:
+		if n := int(patch[at])<<16 | int(patch[at+1])<<8 | int(patch[at+2]); n < len(out) {
+			out = out[:n]
+		}
+	default:
+		return nil, errors.New("IPS patch: unexpected bytes after the end marker")
+	}
+	return out, nil
+}
+
+// BPS actions, in the low two bits of each command
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// BPS: "BPS1", the source, target and metadata sizes, the actions that
+// build the target and the CRC-32s of the source, target and patch. The
+// patch keeps the bytes that match at the same offset with source reads
+// and writes the others as they are.
+func createBPS(source, target []byte) []byte {
+	out := bytes.Clone(bpsMagic)
+	out = appendBPSNumber(out, uint64(len(source)))
+	out = appendBPSNumber(out, uint64(len(target)))
+	out = appendBPSNumber(out, 0) // no metadata
+
+	for i := 0; i < len(target); {
+		start, same := i, i < len(source) && source[i] == target[i]
+		for i < len(target) && (i < len(source) && source[i] == target[i]) == same {
+			i++
+		}
+		action := bpsTargetRead
+		if same {
+			action = bpsSourceRead
+		}
+		out = appendBPSNumber(out, uint64(i-start-1)<<2|uint64(action))
+		if !same {
+			out = append(out, target[start:i]...)
+		}
+	}
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(source))
+	out = binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(target))
+	return binary.LittleEndian.AppendUint32(out, crc32.ChecksumIEEE(out))
+}
+
+func appendBPSNumber(out []byte, n uint64) []byte {
+	for {
+		x := byte(n & 0x7F)
+		n >>= 7
+		if n == 0 {
+			return append(out, 0x80|x)
+		}
+		out = append(out, x)
+		n--
+	}
+}
+
+func applyBPS(data, patch []byte) ([]byte, error) {
+	if len(patch) < len(bpsMagic)+12 {
+		return nil, errors.New("BPS patch: truncated")
+	}
+	body, footer := patch[:len(patch)-12], patch[len(patch)-12:]
+	if crc32.ChecksumIEEE(patch[:len(patch)-4]) != binary.LittleEndian.Uint32(footer[8:]) {
+		return nil, errors.New("BPS patch: the patch is damaged (checksum mismatch)")
+	}
+	if crc32.ChecksumIEEE(data) != binary.LittleEndian.Uint32(footer) {
+		return nil, errors.New("BPS patch: made for a different file (source checksum mismatch)")
+	}
+
+	at := len(bpsMagic)
+	number := func() (int, error) {
+		var n, shift uint64 = 0, 1
+		for {
+			if at >= len(body) || shift > 1<<56 {
+				return 0, errors.New("BPS patch: truncated")
+			}
+			x := uint64(body[at])
+			at++
+			n += (x & 0x7F) * shift
+			if x&0x80 != 0 {
+				return int(n), nil
+			}
+			shift <<= 7
+			n += shift
+		}
+	}
+	sourceSize, err := number()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := number()
+	if err != nil {
+		return nil, err
+	}
+	metaSize, err := number()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != len(data) {
+		return nil, fmt.Errorf("BPS patch: made for a %d-byte file, this one is %d bytes", sourceSize, len(data))
+	}
+	if at += metaSize; at > len(body) {
+		return nil, errors.New("BPS patch: truncated")
+	}
+
+	out := make([]byte, 0, targetSize)
+	sourceRel, targetRel := 0, 0
+	relative := func(pos int) (int, error) {
+		v, err := number()
+		if v&1 != 0 {
+			return pos - v>>1, err
+		}
+		return pos + v>>1, err
+	}
+	bad := errors.New("BPS patch: an action reads outside the files")
+	for at < len(body) {
+		cmd, err := number()
+		if err != nil {
+			return nil, err
+		}
+		n := cmd>>2 + 1
+		if len(out)+n > targetSize {
+			return nil, bad
+		}
+		switch cmd & 3 {
+		case bpsSourceRead:
+			if len(out)+n > len(data) {
+				return nil, bad
+			}
+			out = append(out, data[len(out):len(out)+n]...)
+		case bpsTargetRead:
+			if at+n > len(body) {
+				return nil, bad
+			}
+			out = append(out, body[at:at+n]...)
+			at += n
+		case bpsSourceCopy:
+			if sourceRel, err = relative(sourceRel); err != nil {
+				return nil, err
+			}
+			if sourceRel < 0 || sourceRel+n > len(data) {
+				return nil, bad
+			}
+			out = append(out, data[sourceRel:sourceRel+n]...)
+			sourceRel += n
+		case bpsTargetCopy:
+			if targetRel, err = relative(targetRel); err != nil {
+				return nil, err
+			}
+			if targetRel < 0 || targetRel >= len(out) {
+				return nil, bad
+			}
+			for ; n > 0; n-- { // may overlap what it writes
+				out = append(out, out[targetRel])
+				targetRel++
+			}
+		}
+	}
+	if len(out) != targetSize || crc32.ChecksumIEEE(out) != binary.LittleEndian.Uint32(footer[4:]) {
+		return nil, errors.New("BPS patch: the result does not match the target checksum")
+	}
+	return out, nil
+}
+
+func createJSONPatch(source, target []byte) ([]byte, error) {
+	p := JSONPatch{
+		Format:      jsonPatchFormat,
+		SourceSize:  len(source),
+		TargetSize:  len(target),
+		SourceCRC32: crc32.ChecksumIEEE(source),
+		TargetCRC32: crc32.ChecksumIEEE(target),
+		Changes:     deltaRuns(source, target),
+	}
+	if p.Changes == nil {
+		p.Changes = []DiffRun{}
+	}
+	out, err := json.MarshalIndent(p, "", "  ")
+	return append(out, '\n'), err
+}
+
+// A JSON patch applies to any file that holds the old bytes of each run, so
+// that a fix can go onto a copy edited elsewhere
+func applyJSONPatch(data, patch []byte) ([]byte, error) {
+	var p JSONPatch
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, fmt.Errorf("JSON patch: %v", err)
+	}
+	if p.Format != jsonPatchFormat {
+		return nil, fmt.Errorf("JSON patch: format %q, want %s", p.Format, jsonPatchFormat)
+	}
+	out := bytes.Clone(data)
+	for _, r := range p.Changes {
+		end := r.Offset + len(r.Old)
+		if r.Offset < 0 || end > len(data) || !bytes.Equal(data[r.Offset:end], r.Old) {
+			return nil, fmt.Errorf("JSON patch: the file does not hold the bytes the patch changes at 0x%X", r.Offset)
+		}
+		if grow := r.Offset + len(r.New) - len(out); grow > 0 {
+			out = append(out, make([]byte, grow)...)
+		}
+		copy(out[r.Offset:], r.New)
+	}
+	if p.TargetSize < len(out) {
+		out = out[:p.TargetSize]
+	}
+	return out, nil
+}
@@ -0,0 +1,93 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+func TestDeltaPatch(t *testing.T) {
+	orig := []byte("ROSSI...BAGGIO..MALDINI.")
+	cases := []struct {
+		name   string
+		target []byte
+	}{
+		{"renamed", []byte("TOTTI...BAGGIO..VIALLI..")},
+		{"grown", []byte("ROSSI...BAGGIO..MALDINI.BARESI..")},
+		{"shrunk", []byte("ROSSI...ZOFF")},
+		{"unchanged", orig},
+	}
+	for _, format := range PatchFormats {
+		for _, c := range cases {
+			patch, err := CreateDeltaPatch(format, orig, c.target)
+			if err != nil {
+				t.Fatalf("%s %s: %v", format, c.name, err)
+			}
+			if got := DetectPatchFormat(patch); got != format {
+				t.Errorf("%s %s: detected as %q", format, c.name, got)
+			}
+			out, _, err := ApplyDeltaPatch(orig, patch)
+			if err != nil || !bytes.Equal(out, c.target) {
+				t.Errorf("%s %s: applied = %q, %v; want %q", format, c.name, out, err, c.target)
+			}
+		}
+	}
+
+	// BPS and JSON patches refuse a file they were not made for
+	patched := []byte("TOTTI...BAGGIO..VIALLI..")
+	for _, format := range []string{"bps", "json"} {
+		patch, _ := CreateDeltaPatch(format, orig, patched)
+		if _, _, err := ApplyDeltaPatch(patched, patch); err == nil {
+			t.Errorf("%s patch applied to the wrong file", format)
+		}
+	}
+	if _, err := CreateDeltaPatch("xdelta", orig, patched); err == nil {
+		t.Error("CreateDeltaPatch took an unknown format")
+	}
+}
+
+func TestIPSEndMarkerOffset(t *testing.T) {
+	orig := make([]byte, ipsEOF+8)
+	target := bytes.Clone(orig)
+	copy(target[ipsEOF:], "TOTTI")
+	patch, err := CreateDeltaPatch("ips", orig, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(patch[:len(patch)-3], []byte("EOF")) {
+		t.Error("a record starts at the offset that reads as EOF")
+	}
+	if out, _, err := ApplyDeltaPatch(orig, patch); err != nil || !bytes.Equal(out, target) {
+		t.Errorf("applied = %v, want the target", err)
+	}
+}
+
+func TestApplyIPSRun(t *testing.T) {
+	patch := []byte("PATCH\x00\x00\x02\x00\x00\x00\x03*EOF")
+	out, _, err := ApplyDeltaPatch([]byte("ROSSI"), patch)
+	if err != nil || string(out) != "RO***" {
+		t.Errorf("applied = %q, %v", out, err)
+	}
+}
+
+func TestApplyBPSCopies(t *testing.T) {
+	source, target := []byte("ABC"), []byte("XBCXBCXBCX")
+	patch := appendBPSNumber(bytes.Clone(bpsMagic), 3)
+	patch = appendBPSNumber(patch, uint64(len(target)))
+	patch = appendBPSNumber(patch, 0)
+	patch = appendBPSNumber(patch, 0<<2|bpsTargetRead)
+	patch = append(patch, 'X')
+	patch = appendBPSNumber(patch, 1<<2|bpsSourceCopy)
+	patch = appendBPSNumber(patch, 1<<1) // source offset +1
+	patch = appendBPSNumber(patch, 6<<2|bpsTargetCopy)
+	patch = appendBPSNumber(patch, 0) // target offset 0, overlapping the output
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(source))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(target))
+	patch = binary.LittleEndian.AppendUint32(patch, crc32.ChecksumIEEE(patch))
+
+	out, _, err := ApplyDeltaPatch(source, patch)
+	if err != nil || !bytes.Equal(out, target) {
+		t.Errorf("applied = %q, %v; want %q", out, err, target)
+	}
+}
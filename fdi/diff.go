@@ -0,0 +1,120 @@
+package fdi
+
+// DiffRun is a contiguous run of bytes that differ between two files.
+type DiffRun struct {
+	Offset int      `json:"offset"`
+	Old    HexBytes `json:"old"`
+	New    HexBytes `json:"new"`
+}
+
+// Diff compares a and b up to the shorter length and returns the changed runs.
+func Diff(a, b []byte) []DiffRun {
+	n := min(len(a), len(b))
+
+	var runs []DiffRun
+	for i := 0; i < n; i++ {
+		if a[i] == b[i] {
+			continue
+		}
+
+		start := i
+		for i < n && a[i] != b[i] {
+			i++
+		}
+		runs = append(runs, DiffRun{Offset: start, Old: a[start:i], New: b[start:i]})
+	}
+	return runs
+}
+
+// RecordChange is a changed field, or a run of changed bytes outside any
+// field, located within a record table.
+type RecordChange struct {
+	Record     int    `json:"record"`          // -1 for bytes before the first record
+	Field      string `json:"field,omitempty"` // empty for bytes outside any field
+	Offset     int    `json:"offset"`          // absolute offset of the field or bytes
+	RecordByte int    `json:"record_byte"`     // offset within the record
+	Old        any    `json:"old"`             // the decoded field, or the bytes as HexBytes
+	New        any    `json:"new"`
+}
+
+// MarshalJSON writes non-finite floats as strings, which JSON numbers cannot hold.
+func (c RecordChange) MarshalJSON() ([]byte, error) {
+	type plain RecordChange
+	c.Old, c.New = jsonValue(c.Old), jsonValue(c.New)
+	return marshalUnescaped(plain(c))
+}
+
+// DiffRecords maps the differences between a and b onto the record table s
+// describes. A changed field is reported once with its old and new decoded
+// values, however many of its bytes changed, and each bit field whose bits
+// changed is reported on its own; other changed bytes are grouped
+// into contiguous runs per record. s may have no fields, in which case only
+// the record positions are reported.
+func DiffRecords(a, b []byte, s Schema) []RecordChange {
+	if s.RecordSize <= 0 {
+		return nil
+	}
+
+	var changes []RecordChange
+	seen := make(map[[2]int]bool) // (record, field index) pairs already reported
+	last := -1                    // index in changes of an open run of unfielded bytes
+
+	for _, run := range Diff(a, b) {
+		for pos := run.Offset; pos < run.Offset+len(run.Old); pos++ {
+			record, recByte := -1, pos
+			if pos >= s.Start {
+				record, recByte = (pos-s.Start)/s.RecordSize, (pos-s.Start)%s.RecordSize
+			}
+
+			// Every field holding the byte; bit fields only count when their bits changed
+			fielded := false
+			if record >= 0 {
+				for i, f := range s.Fields {
+					if recByte < f.Offset || recByte >= f.Offset+f.Size() {
+						continue
+					}
+					start := pos - recByte + f.Offset
+					end := start + f.Size()
+					if f.IsBitField() && (end > len(a) || end > len(b) || decodeField(f, a[start:end]) == decodeField(f, b[start:end])) {
+						continue
+					}
+					fielded = true
+					last = -1
+					if key := [2]int{record, i}; !seen[key] {
+						seen[key] = true
+						c := RecordChange{Record: record, Field: f.Name, Offset: start, RecordByte: f.Offset}
+						if end <= len(a) && end <= len(b) {
+							c.Old, c.New = decodeField(f, a[start:end]), decodeField(f, b[start:end])
+						} else {
+							end = min(end, len(a), len(b))
+							c.Old, c.New = HexBytes(a[start:end]), HexBytes(b[start:end])
+						}
+						changes = append(changes, c)
+					}
+					if !f.IsBitField() {
+						break
+					}
+				}
+			}
+			if fielded {
+				continue
+			}
+
+			// Extend the open run if this byte directly follows it in the same record
+			if last >= 0 {
+				c := &changes[last]
+				if c.Record == record && c.Offset+len(c.Old.(HexBytes)) == pos {
+					c.Old = append(c.Old.(HexBytes), a[pos])
+					c.New = append(c.New.(HexBytes), b[pos])
+					continue
+				}
+			}
+			changes = append(changes, RecordChange{
+				Record: record, Offset: pos, RecordByte: recByte,
+				Old: HexBytes{a[pos]}, New: HexBytes{b[pos]},
+			})
+			last = len(changes) - 1
+		}
+	}
+	return changes
+}
@@ -0,0 +1,57 @@
+package fdi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	runs := Diff([]byte("abcdefgh"), []byte("aXcdYZgh!"))
+	want := []DiffRun{
+		{Offset: 1, Old: HexBytes("b"), New: HexBytes("X")},
+		{Offset: 4, Old: HexBytes("ef"), New: HexBytes("YZ")},
+	}
+	if !reflect.DeepEqual(runs, want) {
+		t.Errorf("Diff = %+v, want %+v", runs, want)
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	schema := Schema{Start: 2, RecordSize: 4, Fields: []Field{{Name: "value", Offset: 0, Type: "uint16"}}}
+	a := []byte("\x00\x00" + "\x01\x00\x00\x00" + "\x02\x00\x00\x00")
+	b := []byte("\x00\xEE" + "\x01\x00\x00\x00" + "\x02\x01\xAA\xBB")
+
+	got := DiffRecords(a, b, schema)
+	want := []RecordChange{
+		{Record: -1, Offset: 1, RecordByte: 1, Old: HexBytes{0x00}, New: HexBytes{0xEE}},
+		{Record: 1, Field: "value", Offset: 6, RecordByte: 0, Old: uint64(2), New: uint64(0x102)},
+		{Record: 1, Offset: 8, RecordByte: 2, Old: HexBytes{0x00, 0x00}, New: HexBytes{0xAA, 0xBB}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiffRecords =\n%+v\nwant\n%+v", got, want)
+	}
+}
+
+func TestDiffRecordsFieldOnce(t *testing.T) {
+	schema := Schema{RecordSize: 4, Fields: []Field{{Name: "n", Type: "uint32"}}}
+	got := DiffRecords([]byte{0, 0, 0, 0}, []byte{1, 0, 1, 0}, schema)
+	if len(got) != 1 || got[0].New != uint64(0x10001) {
+		t.Errorf("DiffRecords = %+v, want one change of n to 0x10001", got)
+	}
+}
+
+func TestDiffRecordsBitFields(t *testing.T) {
+	schema := Schema{RecordSize: 1, Fields: []Field{
+		{Name: "low", Type: "uint8", Bits: 4},
+		{Name: "flag", Type: "uint8", BitOffset: 4, Bits: 1},
+		{Name: "high", Type: "uint8", BitOffset: 5, Bits: 3},
+	}}
+	got := DiffRecords([]byte{0x12}, []byte{0xB2}, schema)
+	if len(got) != 1 || got[0].Field != "high" || got[0].Old != uint64(0) || got[0].New != uint64(5) {
+		t.Errorf("DiffRecords = %+v, want only high changed from 0 to 5", got)
+	}
+	got = DiffRecords([]byte{0x00}, []byte{0x1F}, schema)
+	if len(got) != 2 || got[0].Field != "low" || got[1].Field != "flag" {
+		t.Errorf("DiffRecords = %+v, want low and flag", got)
+	}
+}
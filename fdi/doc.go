@@ -0,0 +1,13 @@
+// Package fdi implements the analyses behind fdi-analyzer: searching,
+// string extraction, repeating-pattern detection and record heuristics for
+// .fdi game data files. Functions operate on an in-memory []byte and return
+// structured results; apart from the plain hex dump of WriteDump, formatting
+// is left to the caller.
+//
+// Analyzer bundles them for a single file:
+//
+//	an := fdi.NewAnalyzer(data, fdi.AnalysisOptions{MinString: 4})
+//	an.WriteDump(os.Stdout, fdi.DumpOptions{Size: 256})
+//	hits, err := an.SearchText("JUVENTUS", fdi.SearchOptions{IgnoreCase: true})
+//	report := an.Records(0, len(data))
+package fdi
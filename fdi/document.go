@@ -0,0 +1,271 @@
+package fdi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+)
+
+// DocumentFormat names the JSON documents of ExportDocument.
+const DocumentFormat = "fdi-document"
+
+// Document is the whole of a file as JSON to edit and build back: the
+// records of a schema as values by field name, and every byte no field holds
+// as hex, so that building an unedited document gives back the same file.
+type Document struct {
+	Format  string      `json:"format"` // DocumentFormat
+	Size    int         `json:"size"`
+	SHA256  string      `json:"sha256"` // of the file exported
+	Schema  string      `json:"schema,omitempty"`
+	Records []DocRecord `json:"records"`
+	Blobs   []Blob      `json:"blobs"` // in order, with the records' bytes between them
+}
+
+// DocRecord is one record of a Document.
+type DocRecord struct {
+	Index  int       `json:"index"`
+	Offset int       `json:"offset"`
+	Values DocValues `json:"values"`
+
+	// The bytes of the fields whose value does not encode back to them, as
+	// a string with bytes after its NUL, trailing spaces or bytes that are
+	// not UTF-8: they are written as they were while the value is unchanged
+	Raw map[string]HexBytes `json:"raw,omitempty"`
+}
+
+// DocValue is the value of one field of a record.
+type DocValue struct {
+	Name  string
+	Value any // as DecodeRecords gives it, or json.Number or string once read back
+}
+
+// DocValues are the fields of a record by name, in the schema's order.
+type DocValues []DocValue
+
+// MarshalJSON writes the values as one object, keeping their order.
+func (vs DocValues) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, v := range vs {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := marshalUnescaped(v.Name)
+		if err != nil {
+			return nil, err
+		}
+		val, err := marshalUnescaped(jsonValue(v.Value))
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// UnmarshalJSON reads an object of values in order, with numbers as
+// json.Number so that 64-bit integers keep every digit.
+func (vs *DocValues) UnmarshalJSON(src []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(src))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return errors.New("record values must be an object")
+	}
+	*vs = nil
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return err
+		}
+		*vs = append(*vs, DocValue{Name: tok.(string), Value: v})
+	}
+	return nil
+}
+
+func (vs DocValues) lookup(name string) (any, bool) {
+	for _, v := range vs {
+		if v.Name == name {
+			return v.Value, true
+		}
+	}
+	return nil, false
+}
+
+// Blob is a run of bytes no field holds, kept as they are.
+type Blob struct {
+	Offset int      `json:"offset"`
+	Bytes  HexBytes `json:"bytes"`
+}
+
+// ExportDocument decodes the records of the schema and keeps the rest of
+// the file, the header, the gaps between fields, the bits of a byte no bit
+// field takes and anything after the table, as blobs.
+func ExportDocument(data []byte, s Schema) (Document, error) {
+	records, err := DecodeRecords(data, s)
+	if err != nil {
+		return Document{}, err
+	}
+	sum := sha256.Sum256(data)
+	doc := Document{Format: DocumentFormat, Size: len(data), SHA256: hex.EncodeToString(sum[:]), Schema: s.Name,
+		Records: make([]DocRecord, 0, len(records)), Blobs: []Blob{}}
+
+	held := make([]bool, len(data)) // bytes a whole field holds
+	for _, rec := range records {
+		dr := DocRecord{Index: rec.Index, Offset: rec.Offset, Values: make(DocValues, 0, len(s.Fields))}
+		for i, f := range s.Fields {
+			v := rec.Fields[i]
+			dr.Values = append(dr.Values, DocValue{Name: f.Name, Value: v.Value})
+			if f.IsBitField() {
+				continue // the other bits of its bytes stay in the blobs
+			}
+			raw := data[v.Offset : v.Offset+f.Size()]
+			for j := range raw {
+				held[v.Offset+j] = true
+			}
+			text := fieldText(v.Value)
+			if enc, err := EncodeField(f, text); err != nil || !bytes.Equal(enc, raw) || !utf8.ValidString(text) {
+				if dr.Raw == nil {
+					dr.Raw = make(map[string]HexBytes)
+				}
+				dr.Raw[f.Name] = bytes.Clone(raw)
+			}
+		}
+		doc.Records = append(doc.Records, dr)
+	}
+
+	for off := 0; off < len(data); {
+		if held[off] {
+			off++
+			continue
+		}
+		end := off
+		for end < len(data) && !held[end] {
+			end++
+		}
+		doc.Blobs = append(doc.Blobs, Blob{Offset: off, Bytes: bytes.Clone(data[off:end])})
+		off = end
+	}
+	return doc, nil
+}
+
+// BuildDocument writes the blobs of a document, then encodes the values of
+// each record with the schema's fields at the record's offset. A field kept
+// raw is written as it was unless its value was changed.
+func BuildDocument(doc Document, s Schema) ([]byte, error) {
+	if doc.Format != DocumentFormat {
+		return nil, fmt.Errorf("not an %s document", DocumentFormat)
+	}
+	if doc.Size < 0 {
+		return nil, errors.New("the document's size is negative")
+	}
+	out := make([]byte, doc.Size)
+	for _, b := range doc.Blobs {
+		if b.Offset < 0 || b.Offset+len(b.Bytes) > doc.Size {
+			return nil, fmt.Errorf("blob at 0x%X runs past the end of the file", b.Offset)
+		}
+		copy(out[b.Offset:], b.Bytes)
+	}
+
+	for _, rec := range doc.Records {
+		for _, f := range s.Fields {
+			off := rec.Offset + f.Offset
+			if rec.Offset < 0 || off+f.Size() > doc.Size {
+				return nil, fmt.Errorf("record %d: field %q runs past the end of the file", rec.Index, f.Name)
+			}
+			v, ok := rec.Values.lookup(f.Name)
+			if !ok {
+				return nil, fmt.Errorf("record %d has no value for %q", rec.Index, f.Name)
+			}
+			text, err := valueText(v)
+			if err != nil {
+				return nil, fmt.Errorf("record %d field %q: %v", rec.Index, f.Name, err)
+			}
+			if raw, ok := rec.Raw[f.Name]; ok && len(raw) == f.Size() && unchanged(f, raw, text) {
+				copy(out[off:], raw)
+				continue
+			}
+			var enc []byte
+			if f.IsBitField() {
+				enc, err = EncodeBits(f, out[off:off+f.Size()], text)
+			} else {
+				enc, err = EncodeField(f, text)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("record %d field %q: %v", rec.Index, f.Name, err)
+			}
+			if raw, ok := rec.Raw[f.Name]; ok && f.Type == "string" {
+				padLike(enc, raw)
+			}
+			copy(out[off:], enc)
+		}
+	}
+	return out, nil
+}
+
+// Whether text is still the value the raw bytes decode to, as JSON holds it:
+// bytes that are not UTF-8 are read back as U+FFFD
+func unchanged(f Field, raw []byte, text string) bool {
+	was := fieldText(decodeField(f, raw))
+	if text == was {
+		return true
+	}
+	if !utf8.ValidString(was) {
+		var held string
+		if src, err := json.Marshal(was); err == nil && json.Unmarshal(src, &held) == nil && text == held {
+			return true
+		}
+	}
+	a, errA := EncodeField(f, text)
+	b, errB := EncodeField(f, was)
+	return errA == nil && errB == nil && bytes.Equal(a, b)
+}
+
+// Pad a new string with spaces rather than NULs where the old one was
+func padLike(enc, raw []byte) {
+	if len(raw) == 0 || raw[len(raw)-1] != ' ' {
+		return
+	}
+	for i := len(enc) - 1; i >= 0 && enc[i] == 0; i-- {
+		enc[i] = ' '
+	}
+}
+
+// A decoded value as EncodeField takes it
+func fieldText(v any) string {
+	switch v := v.(type) {
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case HexBytes:
+		return hex.EncodeToString(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// A value read back from JSON, or still as decoded, as EncodeField takes it
+func valueText(v any) (string, error) {
+	switch v := v.(type) {
+	case json.Number:
+		return v.String(), nil
+	case uint64, int64, float64, HexBytes, string:
+		return fieldText(v), nil
+	}
+	return "", fmt.Errorf("expected a number or a string, got %v", v)
+}
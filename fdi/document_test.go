@@ -0,0 +1,65 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDocumentRoundTrip(t *testing.T) {
+	s := Schema{Start: 4, RecordSize: 16, Count: 2, Fields: []Field{
+		{Name: "name", Offset: 0, Type: "string", Length: 8},
+		{Name: "age", Offset: 8, Type: "uint16"},
+		{Name: "speed", Offset: 10, Type: "float32"},
+		{Name: "foot", Offset: 14, Type: "uint8", BitOffset: 6, Bits: 2},
+		{Name: "kit", Offset: 15, Type: "bcd", Length: 1},
+	}}
+	if err := s.validate(); err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("HDR\x01" +
+		"ROSSI   \x19\x00\xcd\xcc\xcc\x3d\x9f\x12" + // space-padded, bits kept beside the foot
+		"BIANC\xc9\x00Z\x1e\x00\x00\x00\x80\x7f\x40\x1a" + // Latin-1, junk after the NUL, invalid BCD
+		"END")
+
+	doc, err := ExportDocument(data, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var back Document
+	if err := json.Unmarshal(src, &back); err != nil {
+		t.Fatal(err)
+	}
+	built, err := BuildDocument(back, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(built, data) {
+		t.Fatalf("built\n% X\nwant\n% X", built, data)
+	}
+
+	// Edits change only their fields; a new name keeps the spaces
+	back.Records[0].Values[0].Value = "VERDI"
+	back.Records[1].Values[1].Value = json.Number("31")
+	back.Records[1].Values[3].Value = json.Number("0")
+	built, err = BuildDocument(back, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := bytes.Clone(data)
+	copy(want[4:], "VERDI   ")
+	want[28] = 31
+	want[34] = 0x40 &^ 0xC0
+	if !bytes.Equal(built, want) {
+		t.Errorf("edited\n% X\nwant\n% X", built, want)
+	}
+
+	back.Records[0].Values[1].Value = json.Number("70000")
+	if _, err := BuildDocument(back, s); err == nil {
+		t.Error("BuildDocument took 70000 for a uint16")
+	}
+}
@@ -0,0 +1,156 @@
+package fdi
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Bytes shown per dump row
+const dumpRowSize = 16
+
+// ANSI color codes for the dump's byte classes
+const (
+	colorReset     = "\x1b[0m"
+	colorZero      = "\x1b[90m" // 0x00, dark gray
+	colorPrintable = "\x1b[32m" // printable ASCII, green
+	colorOther     = "\x1b[33m" // everything else, yellow
+	colorHighlight = "\x1b[7m"  // highlighted bytes, reverse video
+)
+
+// DumpOptions selects the range and rendering of a hex dump.
+type DumpOptions struct {
+	Offset    int       // first byte to dump
+	Size      int       // number of bytes, clamped to the end of the data
+	SkipZeros bool      // leave 0x00 out of the text column so UTF-16LE reads naturally
+	Codepage  *Codepage // render printable high bytes with this codepage; nil shows them as '.'
+	Color     bool      // colorize bytes by class with ANSI escapes
+	Base      int       // offset of data[0], when data is a window read from a larger file
+
+	// Annotations are shown on the row each one starts in, or on the first
+	// row for those that start before the range
+	Annotations Annotations
+
+	// Highlighted bytes are shown in reverse video with Color, and followed
+	// by a * in the hex column without
+	Highlights Highlights
+}
+
+// DumpRow is one row of a hex dump.
+type DumpRow struct {
+	Offset int      `json:"offset"`
+	Bytes  HexBytes `json:"bytes"`
+	Text   string   `json:"text"` // the text column
+	Notes  []string `json:"notes,omitempty"`
+}
+
+// Dump splits the requested range into rows of up to 16 bytes.
+func Dump(data []byte, opts DumpOptions) ([]DumpRow, error) {
+	start := opts.Offset - opts.Base
+	if start < 0 || start >= len(data) {
+		return nil, ErrOffsetOutOfRange
+	}
+	end := min(start+opts.Size, len(data))
+
+	var rows []DumpRow
+	for i := start; i < end; i += dumpRowSize {
+		rowEnd := min(i+dumpRowSize, end)
+		row := DumpRow{Offset: opts.Base + i, Bytes: data[i:rowEnd]}
+
+		var text strings.Builder
+		for _, b := range row.Bytes {
+			if opts.SkipZeros && b == 0 {
+				continue
+			}
+			text.WriteRune(DumpChar(b, opts.Codepage))
+		}
+		row.Text = text.String()
+		for _, a := range opts.Annotations.Overlapping(row.Offset, opts.Base+rowEnd) {
+			if a.Start >= row.Offset || row.Offset == opts.Offset {
+				row.Notes = append(row.Notes, a.Label())
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// DumpChar is how a byte appears in the dump's text column: printable ASCII
+// as itself, high bytes printable in cp as their character, and '.' otherwise.
+func DumpChar(b byte, cp *Codepage) rune {
+	switch {
+	case b >= 32 && b <= 126:
+		return rune(b)
+	case cp != nil && b >= 0x80 && cp.IsPrintable(b):
+		return cp.Decode(b)
+	default:
+		return '.'
+	}
+}
+
+// WriteDump writes a hex and text dump of the requested range to w, with a
+// column header.
+func WriteDump(w io.Writer, data []byte, opts DumpOptions) error {
+	rows, err := Dump(data, opts)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "Offset    | Hex                                             | ASCII")
+	fmt.Fprintln(w, "----------+------------------------------------------------+------------------")
+	for _, row := range rows {
+		fmt.Fprintf(w, "0x%08X | ", row.Offset)
+
+		for i, b := range row.Bytes {
+			marked := opts.Highlights.Contains(row.Offset + i)
+			switch {
+			case opts.Color && marked:
+				fmt.Fprintf(w, "%s%s%02X%s ", colorHighlight, byteColor(b), b, colorReset)
+			case opts.Color:
+				fmt.Fprintf(w, "%s%02X%s ", byteColor(b), b, colorReset)
+			case marked:
+				fmt.Fprintf(w, "%02X*", b)
+			default:
+				fmt.Fprintf(w, "%02X ", b)
+			}
+		}
+
+		// Padding for incomplete rows
+		fmt.Fprint(w, strings.Repeat("   ", dumpRowSize-len(row.Bytes)))
+		fmt.Fprint(w, "| ")
+
+		if !opts.Color {
+			fmt.Fprint(w, row.Text)
+		} else {
+			for i, b := range row.Bytes {
+				if opts.SkipZeros && b == 0 {
+					continue
+				}
+				color := byteColor(b)
+				if opts.Highlights.Contains(row.Offset + i) {
+					color = colorHighlight + color
+				}
+				fmt.Fprintf(w, "%s%c%s", color, DumpChar(b, opts.Codepage), colorReset)
+			}
+		}
+		if len(row.Notes) > 0 {
+			pad := max(dumpRowSize-utf8.RuneCountInString(row.Text), 0)
+			fmt.Fprintf(w, "%s  <- %s", strings.Repeat(" ", pad), strings.Join(row.Notes, "; "))
+		}
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// Color escape for a byte's class: zero, printable ASCII or other
+func byteColor(b byte) string {
+	switch {
+	case b == 0:
+		return colorZero
+	case b >= 32 && b <= 126:
+		return colorPrintable
+	default:
+		return colorOther
+	}
+}
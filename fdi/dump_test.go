@@ -0,0 +1,109 @@
+package fdi
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	data := []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ\x00\x01")
+
+	rows, err := Dump(data, DumpOptions{Offset: 4, Size: 256})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	if rows[0].Offset != 4 || rows[0].Text != "EFGHIJKLMNOPQRST" {
+		t.Errorf("row 0 = 0x%X %q", rows[0].Offset, rows[0].Text)
+	}
+	if rows[1].Offset != 20 || rows[1].Text != "UVWXYZ.." {
+		t.Errorf("row 1 = 0x%X %q", rows[1].Offset, rows[1].Text)
+	}
+}
+
+func TestDumpSkipZeros(t *testing.T) {
+	rows, err := Dump(EncodeUTF16LE("JUVE"), DumpOptions{Size: 16, SkipZeros: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Text != "JUVE" || len(rows[0].Bytes) != 8 {
+		t.Errorf("rows = %+v, want one row of 8 bytes reading JUVE", rows)
+	}
+}
+
+func TestDumpCodepage(t *testing.T) {
+	cp, _ := LookupCodepage("latin1")
+	rows, _ := Dump([]byte("caf\xE9"), DumpOptions{Size: 4, Codepage: cp})
+	if rows[0].Text != "café" {
+		t.Errorf("text = %q, want café", rows[0].Text)
+	}
+	rows, _ = Dump([]byte("caf\xE9"), DumpOptions{Size: 4})
+	if rows[0].Text != "caf." {
+		t.Errorf("text without codepage = %q, want caf.", rows[0].Text)
+	}
+}
+
+func TestDumpBeyondEOF(t *testing.T) {
+	if _, err := Dump([]byte("abc"), DumpOptions{Offset: 3, Size: 16}); !errors.Is(err, ErrOffsetOutOfRange) {
+		t.Errorf("err = %v, want ErrOffsetOutOfRange", err)
+	}
+}
+
+func TestWriteDump(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDump(&buf, []byte("AB\x00"), DumpOptions{Size: 16}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	want := "0x00000000 | 41 42 00 " + strings.Repeat("   ", 13) + "| AB."
+	if len(lines) != 3 || lines[2] != want {
+		t.Errorf("dump =\n%s\nwant last line %q", buf.String(), want)
+	}
+}
+
+func TestDumpAnnotations(t *testing.T) {
+	var notes Annotations
+	for _, spec := range []string{"0x12:0x2F=name block", "0x4=magic", "0x20=old"} {
+		a, err := ParseAnnotation(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		notes = notes.Set(a)
+	}
+	notes = notes.Set(Annotation{Start: 0x20, End: 0x20}) // removes "old"
+	if len(notes) != 2 || notes[0].Note != "magic" {
+		t.Fatalf("annotations = %+v", notes)
+	}
+
+	rows, err := Dump(make([]byte, 64), DumpOptions{Offset: 0x20, Size: 32, Annotations: notes})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A range started before the dump shows on its first row only
+	if len(rows[0].Notes) != 1 || rows[0].Notes[0] != "0x12-0x2F: name block" || len(rows[1].Notes) != 0 {
+		t.Errorf("rows = %+v", rows)
+	}
+	rows, _ = Dump(make([]byte, 64), DumpOptions{Size: 32, Annotations: notes})
+	if strings.Join(rows[0].Notes, ";") != "0x4: magic" || strings.Join(rows[1].Notes, ";") != "0x12-0x2F: name block" {
+		t.Errorf("rows = %+v", rows)
+	}
+
+	if _, err := ParseAnnotation("0x10:0x8=backwards"); err == nil {
+		t.Error("ParseAnnotation accepted a range ending before it starts")
+	}
+}
+
+func TestDumpHighlights(t *testing.T) {
+	var buf bytes.Buffer
+	hs, _ := ParseHighlights("1-2,5")
+	if err := WriteDump(&buf, []byte("ABCDEFGH"), DumpOptions{Size: 8, Highlights: hs}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "| 41 42*43*44 45 46*47 48 ") {
+		t.Errorf("highlighted dump:\n%s", buf.String())
+	}
+}
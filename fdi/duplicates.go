@@ -0,0 +1,279 @@
+package fdi
+
+import (
+	"errors"
+	"hash/fnv"
+	"sort"
+)
+
+// DuplicateOptions sets how FindDuplicates compares the file with itself.
+type DuplicateOptions struct {
+	Window     int       // bytes hashed at a time, the shortest identical region; 32 when zero
+	Similarity float64   // share of equal bytes for blocks to be alike; 0.6 when zero
+	Progress   *Progress // shows and stops the fingerprinting; nil runs it to the end
+}
+
+// IdenticalRegion is a run of bytes found more than once in the file.
+type IdenticalRegion struct {
+	Length  int   `json:"length"`
+	Offsets []int `json:"offsets"` // ascending
+}
+
+// RepeatedBlocks is a run of consecutive blocks of one size that are alike,
+// as a table of per-team blocks that differ only in names and values.
+type RepeatedBlocks struct {
+	Offset     int     `json:"offset"`
+	BlockSize  int     `json:"block_size"`
+	Count      int     `json:"count"`
+	Similarity float64 `json:"similarity"` // average share of bytes equal to the next block's
+}
+
+// DuplicateReport is what FindDuplicates found.
+type DuplicateReport struct {
+	Window    int               `json:"window"`
+	Blocks    []RepeatedBlocks  `json:"blocks"`
+	Identical []IdenticalRegion `json:"identical"`
+
+	// Progress stopped the fingerprinting, so only the copies in the part of
+	// the file it got through are found
+	Interrupted bool `json:"interrupted,omitempty"`
+}
+
+// Block sizes tried for RepeatedBlocks: the distances between copies of an
+// identical region that occur most often
+const blockSizeCandidates = 5
+
+// FindDuplicates fingerprints every window of the file with a rolling hash
+// to find the regions that occur more than once, then takes the distances
+// between their copies as block sizes and reports the runs of consecutive
+// blocks of those sizes that are alike. Windows of a single repeated byte,
+// as padding, are not matched.
+func FindDuplicates(data []byte, opts DuplicateOptions) (DuplicateReport, error) {
+	if opts.Window == 0 {
+		opts.Window = 32
+	}
+	if opts.Similarity == 0 {
+		opts.Similarity = 0.6
+	}
+	if opts.Window < 4 {
+		return DuplicateReport{}, errors.New("the window must be at least 4 bytes")
+	}
+	if opts.Similarity < 0 || opts.Similarity > 1 {
+		return DuplicateReport{}, errors.New("the similarity must be between 0 and 1")
+	}
+
+	matches, interrupted := findCopies(data, opts.Window, opts.Progress)
+	report := DuplicateReport{
+		Window:      opts.Window,
+		Identical:   groupCopies(data, matches),
+		Blocks:      repeatedBlocks(data, matches, opts.Similarity),
+		Interrupted: interrupted,
+	}
+	return report, nil
+}
+
+// A region found again later in the file
+type copyMatch struct {
+	from, to, length int
+}
+
+const hashBase = 1099511628211
+
+// Index the windows at multiples of the window size, then roll the hash over
+// every offset; a hit on the nearest earlier copy is checked, grown as far as
+// the bytes stay equal in both directions and skipped past. Reports whether p
+// stopped the scan before the end.
+func findCopies(data []byte, window int, p *Progress) ([]copyMatch, bool) {
+	if len(data) < 2*window {
+		return nil, false
+	}
+	var pow uint64 = 1
+	for i := 0; i < window; i++ {
+		pow *= hashBase
+	}
+	hashAt := func(off int) uint64 {
+		var h uint64
+		for _, b := range data[off : off+window] {
+			h = h*hashBase + uint64(b)
+		}
+		return h
+	}
+	index := make(map[uint64][]int)
+	for off := 0; off+window <= len(data); off += window {
+		if !singleByte(data[off : off+window]) {
+			h := hashAt(off)
+			index[h] = append(index[h], off)
+		}
+	}
+
+	var matches []copyMatch
+	h, done := hashAt(0), 0 // done: the end of the last copy found
+	step := progressStep
+	for at := 0; at+window <= len(data); {
+		if at >= step {
+			p.Advance("duplicates", at, len(data))
+			if p.Stopped() {
+				return matches, true
+			}
+			step = at + progressStep
+		}
+		next := at + 1
+		offs := index[h]
+		for i := len(offs) - 1; i >= 0; i-- { // the nearest copy first
+			from := offs[i]
+			if from+window > at || string(data[from:from+window]) != string(data[at:at+window]) {
+				continue // only earlier copies that do not overlap
+			}
+			// The copies may not overlap each other or the last copy found
+			dist, start, to := at-from, from, at
+			for start > 0 && to > done && data[start-1] == data[to-1] && at+window-(to-1) <= dist {
+				start, to = start-1, to-1
+			}
+			n := at + window - to
+			for to+n < len(data) && n < dist && data[start+n] == data[to+n] {
+				n++
+			}
+			matches = append(matches, copyMatch{start, to, n})
+			next, done = to+n, to+n
+			break
+		}
+		// Roll on to next, rehashing when it skips ahead
+		if next == at+1 {
+			if next+window <= len(data) {
+				h = h*hashBase + uint64(data[at+window]) - pow*uint64(data[at])
+			}
+		} else if next+window <= len(data) {
+			h = hashAt(next)
+		}
+		at = next
+	}
+	p.Advance("duplicates", len(data), len(data))
+	return matches, false
+}
+
+func singleByte(b []byte) bool {
+	for _, c := range b[1:] {
+		if c != b[0] {
+			return false
+		}
+	}
+	return true
+}
+
+// The copies of each region with the same bytes, the most bytes repeated first
+func groupCopies(data []byte, matches []copyMatch) []IdenticalRegion {
+	groups := make(map[[2]uint64][]int)
+	var keys [][2]uint64
+	for _, m := range matches {
+		f := fnv.New64a()
+		f.Write(data[m.from : m.from+m.length])
+		key := [2]uint64{f.Sum64(), uint64(m.length)}
+		if groups[key] == nil {
+			keys = append(keys, key)
+		}
+		for _, off := range []int{m.from, m.to} {
+			if !containsInt(groups[key], off) {
+				groups[key] = append(groups[key], off)
+			}
+		}
+	}
+	regions := make([]IdenticalRegion, 0, len(keys))
+	for _, key := range keys {
+		offs := groups[key]
+		sort.Ints(offs)
+		regions = append(regions, IdenticalRegion{Length: int(key[1]), Offsets: offs})
+	}
+	sort.SliceStable(regions, func(i, j int) bool {
+		return regions[i].Length*len(regions[i].Offsets) > regions[j].Length*len(regions[j].Offsets)
+	})
+	return regions
+}
+
+func containsInt(s []int, v int) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// For each common distance between copies, the runs of consecutive blocks
+// that distance long whose bytes mostly equal those of the next block
+func repeatedBlocks(data []byte, matches []copyMatch, similarity float64) []RepeatedBlocks {
+	tally := make(map[int]int)
+	for _, m := range matches {
+		tally[m.to-m.from]++
+	}
+	sizes := make([]int, 0, len(tally))
+	for size, n := range tally {
+		if n >= 2 {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if tally[sizes[i]] != tally[sizes[j]] {
+			return tally[sizes[i]] > tally[sizes[j]]
+		}
+		return sizes[i] < sizes[j]
+	})
+	if len(sizes) > blockSizeCandidates {
+		sizes = sizes[:blockSizeCandidates]
+	}
+
+	var found []RepeatedBlocks
+	for _, size := range sizes {
+		for _, m := range matches {
+			if m.to-m.from != size || covered(found, m.from) {
+				continue
+			}
+			// Walk back and forward from the block holding the copy
+			start := m.from
+			for start >= size && blockSimilarity(data, start-size, size) >= similarity {
+				start -= size
+			}
+			total, count := 0.0, 1
+			for off := start; off+2*size <= len(data); off += size {
+				s := blockSimilarity(data, off, size)
+				if s < similarity {
+					break
+				}
+				total += s
+				count++
+			}
+			if count >= 3 {
+				found = append(found, RepeatedBlocks{Offset: start, BlockSize: size, Count: count, Similarity: total / float64(count-1)})
+			}
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].BlockSize*found[i].Count > found[j].BlockSize*found[j].Count
+	})
+	return found
+}
+
+// Whether an offset is inside blocks already found
+func covered(found []RepeatedBlocks, off int) bool {
+	for _, b := range found {
+		if off >= b.Offset && off < b.Offset+b.BlockSize*b.Count {
+			return true
+		}
+	}
+	return false
+}
+
+// The share of the size bytes from off equal to the bytes size later, or 0
+// when they are padding
+func blockSimilarity(data []byte, off, size int) float64 {
+	a, b := data[off:off+size], data[off+size:off+2*size]
+	if singleByte(a) {
+		return 0
+	}
+	same := 0
+	for i := range a {
+		if a[i] == b[i] {
+			same++
+		}
+	}
+	return float64(same) / float64(size)
+}
@@ -0,0 +1,51 @@
+package fdi
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestFindDuplicates(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	noise := func(n int) []byte {
+		b := make([]byte, n)
+		rng.Read(b)
+		return b
+	}
+
+	// 12 team blocks of 160 bytes: a name, then the same layout with a few
+	// values changed, between unrelated bytes and zero padding
+	data := noise(0x400)
+	template := noise(160)
+	for team := 0; team < 12; team++ {
+		block := append([]byte(nil), template...)
+		copy(block, fmt.Sprintf("TEAM %02d", team))
+		for k := 0; k < 12; k++ {
+			block[80+rng.Intn(60)] = byte(rng.Intn(256))
+		}
+		data = append(data, block...)
+	}
+	data = append(data, make([]byte, 0x200)...)
+	data = append(data, noise(0x400)...)
+
+	report, err := FindDuplicates(data, DuplicateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Blocks) == 0 {
+		t.Fatal("no repeated blocks found")
+	}
+	b := report.Blocks[0]
+	if b.BlockSize != 160 || b.Count != 12 || b.Offset < 0x400 || b.Offset >= 0x400+160 {
+		t.Errorf("blocks = %+v, want 12 of 160 bytes from 0x400", b)
+	}
+	if len(report.Identical) == 0 || len(report.Identical[0].Offsets) < 2 || report.Identical[0].Length < 32 {
+		t.Errorf("identical regions = %+v", report.Identical)
+	}
+
+	// Padding and noise alone hold no duplicates
+	if report, _ := FindDuplicates(append(noise(0x800), make([]byte, 0x400)...), DuplicateOptions{}); len(report.Identical) != 0 || len(report.Blocks) != 0 {
+		t.Errorf("found %+v in noise and padding", report)
+	}
+}
@@ -0,0 +1,180 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+	"unicode"
+	"unicode/utf16"
+)
+
+// String encodings accepted by AnalysisOptions.Encoding besides the codepage
+// names: UTF-16LE, and auto-detection of the encoding of each string
+const (
+	EncodingUTF16LE = "utf16le"
+	EncodingAuto    = "auto"
+)
+
+// Codepages ExtractStringsAuto chooses between, preferred in this order when
+// they decode a string equally well
+var autoCodepages = []string{"latin1", "cp1252", "cp437", "cp850"}
+
+// ExtractStringsUTF16LE returns every run of at least minLen UTF-16LE code
+// units that decode to printable ASCII or Latin letters and punctuation.
+// Both even and odd alignments are scanned.
+func ExtractStringsUTF16LE(data []byte, minLen int) []FoundString {
+	var found []FoundString
+	for align := 0; align < 2; align++ {
+		var units []uint16
+		stringStart := -1
+		for i := align; i+1 < len(data)+2; i += 2 {
+			var u uint16
+			text := i+1 < len(data)
+			if text {
+				u = binary.LittleEndian.Uint16(data[i:])
+				text = isUTF16Text(u)
+			}
+			if text {
+				if stringStart < 0 {
+					stringStart = i
+				}
+				units = append(units, u)
+				continue
+			}
+
+			if stringStart >= 0 && len(units) >= minLen {
+				found = append(found, FoundString{Offset: stringStart, Text: string(utf16.Decode(units)), Encoding: EncodingUTF16LE})
+			}
+			stringStart, units = -1, units[:0]
+		}
+	}
+
+	sort.Slice(found, func(i, j int) bool { return found[i].Offset < found[j].Offset })
+	return found
+}
+
+// A code unit that counts as text: printable ASCII, or a Latin-1 or Latin
+// Extended character that is a letter, punctuation or a currency sign
+func isUTF16Text(u uint16) bool {
+	if u >= 32 && u <= 126 {
+		return true
+	}
+	if u < 0xA0 || u > 0x24F {
+		return false
+	}
+	r := rune(u)
+	return unicode.IsLetter(r) || unicode.IsPunct(r) || unicode.Is(unicode.Sc, r)
+}
+
+// ExtractStringsAuto returns the strings of every supported encoding: single
+// byte strings decoded with whichever of latin1, cp1252, cp437 and cp850
+// reads them most cleanly, and UTF-16LE strings. Each string's Encoding names
+// the encoding that produced it; strings of plain ASCII are marked "ascii".
+func ExtractStringsAuto(data []byte, minLen int) []FoundString {
+	cps := make([]*Codepage, len(autoCodepages))
+	for i, name := range autoCodepages {
+		cps[i], _ = LookupCodepage(name)
+	}
+	anyText := func(b byte) bool {
+		for _, cp := range cps {
+			if cp.IsTextByte(b) {
+				return true
+			}
+		}
+		return false
+	}
+
+	var found []FoundString
+	stringStart := -1
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && anyText(data[i]) {
+			if stringStart < 0 {
+				stringStart = i
+			}
+			continue
+		}
+		if stringStart >= 0 && i-stringStart >= minLen {
+			found = append(found, decodeAuto(data[stringStart:i], stringStart, minLen, cps)...)
+		}
+		stringStart = -1
+	}
+
+	found = append(found, ExtractStringsUTF16LE(data, minLen)...)
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Offset < found[j].Offset })
+	return found
+}
+
+// Decode a run of bytes that are text in at least one codepage with the
+// codepage that scores best, keeping the parts of at least minLen bytes that
+// are text in it
+func decodeAuto(run []byte, offset int, minLen int, cps []*Codepage) []FoundString {
+	best, bestScore := cps[0], math.MinInt
+	ascii := true
+	for _, b := range run {
+		ascii = ascii && b < 0x80
+	}
+	if !ascii {
+		for _, cp := range cps {
+			if score := codepageScore(run, cp); score > bestScore {
+				best, bestScore = cp, score
+			}
+		}
+	}
+
+	var found []FoundString
+	start := -1
+	for i := 0; i <= len(run); i++ {
+		if i < len(run) && best.IsTextByte(run[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minLen {
+			s := FoundString{Offset: offset + start, Text: best.DecodeString(run[start:i]), Encoding: best.Name}
+			if ascii {
+				s.Encoding = "ascii"
+			}
+			found = append(found, s)
+		}
+		start = -1
+	}
+	return found
+}
+
+// How cleanly cp decodes run. Bytes that are not text cost heavily; high
+// bytes score for decoding to Latin letters, more so when they start a word
+// as a capital or their case matches a neighbouring ASCII letter, as in
+// Škoda, MÜLLER or Müller.
+func codepageScore(run []byte, cp *Codepage) int {
+	score := 0
+	for i, b := range run {
+		if b < 0x80 {
+			continue
+		}
+		if !cp.IsTextByte(b) {
+			score -= 10
+			continue
+		}
+		r := cp.Decode(b)
+		if !unicode.Is(unicode.Latin, r) {
+			continue
+		}
+		score += 2
+		if unicode.IsUpper(r) && (i == 0 || !unicode.IsLetter(rune(run[i-1]))) {
+			score++
+			continue
+		}
+		for _, j := range []int{i - 1, i + 1} {
+			if j < 0 || j >= len(run) || run[j] >= 0x80 {
+				continue
+			}
+			n := rune(run[j])
+			if unicode.IsLetter(n) && unicode.IsUpper(n) == unicode.IsUpper(r) {
+				score++
+				break
+			}
+		}
+	}
+	return score
+}
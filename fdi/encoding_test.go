@@ -0,0 +1,46 @@
+package fdi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestExtractStringsUTF16LE(t *testing.T) {
+	data := append([]byte{0xFF}, EncodeUTF16LE("Zoff")...) // odd alignment
+	data = append(data, 0, 0)
+	data = append(data, EncodeUTF16LE("Baresi")...)
+
+	got := ExtractStringsUTF16LE(data, 4)
+	want := []FoundString{
+		{Offset: 1, Text: "Zoff", Encoding: EncodingUTF16LE},
+		{Offset: 11, Text: "Baresi", Encoding: EncodingUTF16LE},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractStringsUTF16LE = %+v, want %+v", got, want)
+	}
+}
+
+func TestExtractStringsAuto(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want FoundString
+	}{
+		{"ascii", "\x00ROSSI\x00", FoundString{Offset: 1, Text: "ROSSI", Encoding: "ascii"}},
+		{"latin1", "\x00Mart\xEDnez\x00", FoundString{Offset: 1, Text: "Martínez", Encoding: "latin1"}},
+		{"cp1252", "\x00\x8Akoda\x00", FoundString{Offset: 1, Text: "Škoda", Encoding: "cp1252"}},
+		{"cp437 upper case", "\x00M\x9ALLER\x00", FoundString{Offset: 1, Text: "MÜLLER", Encoding: "cp437"}},
+		{"cp437 lower case", "\x00M\x81ller\x00", FoundString{Offset: 1, Text: "Müller", Encoding: "cp437"}},
+		{"cp850", "\x00Jo\xC6o\x00", FoundString{Offset: 1, Text: "João", Encoding: "cp850"}},
+		{"utf16le", "\x00\x00F\x00i\x00g\x00o\x00\x00\x00", FoundString{Offset: 2, Text: "Figo", Encoding: EncodingUTF16LE}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExtractStringsAuto([]byte(tt.data), 4)
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("ExtractStringsAuto(%q) = %+v, want [%+v]", tt.data, got, tt.want)
+			}
+		})
+	}
+}
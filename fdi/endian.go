@@ -0,0 +1,192 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// The byte orders DetectEndianness tells apart, as in the suffixes of
+// ValueTypes
+const (
+	LittleEndian = "le"
+	BigEndian    = "be"
+)
+
+// EndianReport is the byte order DetectEndianness found the numbers of a
+// table to be stored in.
+type EndianReport struct {
+	Endian  string        `json:"endian"`  // LittleEndian, BigEndian, or "" when neither wins
+	Little  int           `json:"little"`  // fields that read as plausible numbers only little-endian
+	Big     int           `json:"big"`     // and only big-endian
+	Sampled int           `json:"sampled"` // candidate fields that hold numbers
+	Fields  []EndianField `json:"fields"`  // those that voted, in record order
+}
+
+// EndianField is a 16- or 32-bit field of the records whose values are
+// plausible in one byte order only.
+type EndianField struct {
+	Offset int    `json:"offset"` // within the record
+	Width  int    `json:"width"`
+	Endian string `json:"endian"`
+	Max    int64  `json:"max"`    // the largest magnitude in that order
+	Other  int64  `json:"other"`  // and in the other
+	Rising bool   `json:"rising"` // ascending down the records in that order only, as an id
+}
+
+// How many times larger the other order's values must be for a field to vote
+const endianMargin = 16
+
+// DetectEndianness reads every 16- and 32-bit field of count records of size
+// bytes from start both ways and counts the fields that hold small numbers in
+// one order only: a value of 1300 stored little-endian reads as 5125 the
+// other way. A field votes for an order when its low bytes in that order vary
+// most and its top byte hardly at all, as in a number, and its values there
+// are far smaller; a lone varying byte beside zeros reads as small in either
+// order, so at least two bytes must vary. A field counts twice when it also
+// rises down the records in its order only. Fields that do not vary or hold
+// text are skipped. One order wins when it has more than twice the votes of
+// the other.
+func DetectEndianness(data []byte, start, size, count int) EndianReport {
+	report := EndianReport{Fields: []EndianField{}}
+	if size < 2 || start < 0 || start >= len(data) {
+		return report
+	}
+	if count = min(count, (len(data)-start)/size); count < 2 {
+		return report
+	}
+
+	for _, width := range []int{2, 4} {
+		for col := 0; col+width <= size; col++ {
+			f, voted, sampled := endianField(data, start, size, count, col, width)
+			if sampled {
+				report.Sampled++
+			}
+			if !voted {
+				continue
+			}
+			weight := 1
+			if f.Rising {
+				weight = 2
+			}
+			if f.Endian == LittleEndian {
+				report.Little += weight
+			} else {
+				report.Big += weight
+			}
+			report.Fields = append(report.Fields, f)
+		}
+	}
+	switch {
+	case report.Little > 2*report.Big:
+		report.Endian = LittleEndian
+	case report.Big > 2*report.Little:
+		report.Endian = BigEndian
+	}
+	return report
+}
+
+// Read one field across the records both ways; sampled when it holds
+// numbers, voted when one order is clearly the plausible one
+func endianField(data []byte, start, size, count, col, width int) (EndianField, bool, bool) {
+	var maxLE, maxBE int64
+	text, varies := 0, false
+	riseLE, riseBE := true, true
+	var prevLE, prevBE uint64
+	seen := make([][256]bool, width)
+	distinct := make([]int, width)
+	for r := 0; r < count; r++ {
+		b := data[start+r*size+col : start+r*size+col+width]
+		if allText(b) {
+			text++
+		}
+		for i, c := range b {
+			if !seen[i][c] {
+				seen[i][c] = true
+				distinct[i]++
+			}
+		}
+		le, be := readUnsigned(b, binary.LittleEndian), readUnsigned(b, binary.BigEndian)
+		maxLE, maxBE = max(maxLE, magnitude(le, width)), max(maxBE, magnitude(be, width))
+		if r > 0 {
+			varies = varies || le != prevLE
+			riseLE = riseLE && le > prevLE
+			riseBE = riseBE && be > prevBE
+		}
+		prevLE, prevBE = le, be
+	}
+	if !varies || text*2 > count {
+		return EndianField{}, false, false
+	}
+
+	f := EndianField{Offset: col, Width: width}
+	switch {
+	case numberBytes(distinct) && maxLE*endianMargin <= maxBE:
+		f.Endian, f.Max, f.Other, f.Rising = LittleEndian, maxLE, maxBE, riseLE && !riseBE
+	case numberBytes(reversed(distinct)) && maxBE*endianMargin <= maxLE:
+		f.Endian, f.Max, f.Other, f.Rising = BigEndian, maxBE, maxLE, riseBE && !riseLE
+	default:
+		return EndianField{}, false, true
+	}
+	return f, true, true
+}
+
+// Whether the distinct values of each byte, lowest first, are those of a
+// number: at least the two lowest bytes vary, each less than the one below,
+// and the top byte holds at most a sign or a few values
+func numberBytes(distinct []int) bool {
+	if top := distinct[len(distinct)-1]; distinct[0] < 2 || distinct[1] < 2 || top > max(2, distinct[0]/4) {
+		return false
+	}
+	for i := 1; i < len(distinct); i++ {
+		if distinct[i] > distinct[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+func reversed(s []int) []int {
+	r := make([]int, len(s))
+	for i, v := range s {
+		r[len(s)-1-i] = v
+	}
+	return r
+}
+
+func readUnsigned(b []byte, order binary.ByteOrder) uint64 {
+	if len(b) == 2 {
+		return uint64(order.Uint16(b))
+	}
+	return uint64(order.Uint32(b))
+}
+
+// The size of a value, read as signed when its top byte is 0xFF so that small
+// negative numbers count as small
+func magnitude(v uint64, width int) int64 {
+	bits := uint(width * 8)
+	if v>>(bits-8) == 0xFF {
+		return int64(1<<bits - v)
+	}
+	return int64(v)
+}
+
+func allText(b []byte) bool {
+	for _, c := range b {
+		if !IsTextByte(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// ValueTypesIn returns the ValueTypes in one byte order, with the single
+// bytes that have none.
+func ValueTypesIn(endian string) []string {
+	var types []string
+	for _, typ := range ValueTypes {
+		if typ[len(typ)-1] == '8' || strings.HasSuffix(typ, endian) {
+			types = append(types, typ)
+		}
+	}
+	return types
+}
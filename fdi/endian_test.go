@@ -0,0 +1,54 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// Player records: a name, a u16 id, a u32 value, two u8 ratings, u16 goals
+// and a u16 shirt number
+func endianRecords(order binary.ByteOrder) []byte {
+	var data []byte
+	for i := 0; i < 40; i++ {
+		rec := make([]byte, 24)
+		copy(rec, []byte("PLAYER"+string(rune('A'+i%26))))
+		order.PutUint16(rec[8:], uint16(200+i*13))
+		order.PutUint32(rec[10:], uint32(15000+i*731%9000))
+		rec[14], rec[15] = byte(40+i*17%50), byte(i*7%99)
+		order.PutUint16(rec[16:], uint16(i*i%1500))
+		order.PutUint16(rec[20:], uint16(1+i*3%40))
+		data = append(data, rec...)
+	}
+	return data
+}
+
+func TestDetectEndianness(t *testing.T) {
+	for _, tc := range []struct {
+		order binary.ByteOrder
+		want  string
+	}{{binary.LittleEndian, LittleEndian}, {binary.BigEndian, BigEndian}} {
+		r := DetectEndianness(endianRecords(tc.order), 0, 24, 40)
+		if r.Endian != tc.want {
+			t.Errorf("%v records: Endian = %q (%d little, %d big), want %q", tc.order, r.Endian, r.Little, r.Big, tc.want)
+		}
+	}
+
+	// Single bytes say nothing about the byte order
+	data := make([]byte, 400)
+	for i := range data {
+		data[i] = byte(i * 37 % 100)
+	}
+	if r := DetectEndianness(data, 0, 20, 20); r.Endian != "" || r.Little+r.Big > 0 {
+		t.Errorf("byte records: %+v, want no votes", r)
+	}
+	if r := DetectEndianness(data, 390, 20, 20); r.Endian != "" {
+		t.Errorf("too few records: Endian = %q", r.Endian)
+	}
+}
+
+func TestValueTypesIn(t *testing.T) {
+	types := ValueTypesIn(BigEndian)
+	if len(types) != 10 || types[0] != "u8" || types[2] != "u16be" {
+		t.Errorf("ValueTypesIn(be) = %v", types)
+	}
+}
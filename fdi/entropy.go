@@ -0,0 +1,64 @@
+package fdi
+
+// WindowStats describes one fixed-size window of the data.
+type WindowStats struct {
+	Offset    int      `json:"offset"`
+	Length    int      `json:"length"` // shorter for the last window
+	Entropy   float64  `json:"entropy"`
+	Distinct  int      `json:"distinct"` // byte values that occur
+	Class     string   `json:"class"`    // as from ClassifyRegion
+	Histogram [256]int `json:"histogram"`
+}
+
+// EntropyRegion is a run of neighbouring windows of the same class.
+type EntropyRegion struct {
+	Start   int     `json:"start"`
+	End     int     `json:"end"` // exclusive
+	Class   string  `json:"class"`
+	Entropy float64 `json:"entropy"` // mean over the windows
+}
+
+// EntropyMap splits data into windows of the given size and measures the
+// entropy and byte frequencies of each.
+func EntropyMap(data []byte, window int) []WindowStats {
+	if window <= 0 {
+		window = RegionWindow
+	}
+	stats := make([]WindowStats, 0, (len(data)+window-1)/window)
+	for off := 0; off < len(data); off += window {
+		end := min(off+window, len(data))
+		ws := WindowStats{Offset: off, Length: end - off, Entropy: ShannonEntropy(data[off:end]), Class: ClassifyRegion(data[off:end])}
+		for _, b := range data[off:end] {
+			if ws.Histogram[b] == 0 {
+				ws.Distinct++
+			}
+			ws.Histogram[b]++
+		}
+		stats = append(stats, ws)
+	}
+	return stats
+}
+
+// EntropyRegions merges neighbouring windows of the same class.
+func EntropyRegions(windows []WindowStats) []EntropyRegion {
+	var regions []EntropyRegion
+	count := 0
+	for _, ws := range windows {
+		last := len(regions) - 1
+		if last >= 0 && regions[last].Class == ws.Class {
+			regions[last].End = ws.Offset + ws.Length
+			regions[last].Entropy += ws.Entropy
+			count++
+			continue
+		}
+		if last >= 0 {
+			regions[last].Entropy /= float64(count)
+		}
+		regions = append(regions, EntropyRegion{Start: ws.Offset, End: ws.Offset + ws.Length, Class: ws.Class, Entropy: ws.Entropy})
+		count = 1
+	}
+	if len(regions) > 0 {
+		regions[len(regions)-1].Entropy /= float64(count)
+	}
+	return regions
+}
@@ -0,0 +1,101 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// FieldStats summarizes an intra-record field sampled across all records.
+type FieldStats struct {
+	Records        int     `json:"records"`
+	Distinct       int     `json:"distinct"` // distinct values of the byte at the field offset
+	Min            int     `json:"min"`      // range of the byte at the field offset
+	Max            int     `json:"max"`
+	HasUint16      bool    `json:"has_uint16"`
+	MinU16         int     `json:"min_u16"` // range of the little-endian 16-bit value, if HasUint16
+	MaxU16         int     `json:"max_u16"`
+	PrintableRatio float64 `json:"printable_ratio"`
+	Type           string  `json:"type"` // constant, flag, uint8, uint16, enum, string or ascii-number
+}
+
+// GuessFieldType samples the field at fieldOff in every record starting at
+// start and guesses its type from the value distribution and printability.
+func GuessFieldType(data []byte, start int, recordSize int, fieldOff int) (FieldStats, error) {
+	if recordSize <= 0 {
+		return FieldStats{}, ErrNoRecordSize
+	}
+	if fieldOff < 0 || fieldOff >= recordSize {
+		return FieldStats{}, errors.New("field offset must be inside the record")
+	}
+	if start < 0 || start >= len(data) {
+		return FieldStats{}, ErrOffsetOutOfRange
+	}
+
+	records := RecordCount(data, start, recordSize)
+	if records == 0 {
+		return FieldStats{}, errors.New("no complete records after the start offset")
+	}
+
+	// Per-record samples of the byte at the field and the byte after it
+	lowValues := make(map[byte]int)
+	highValues := make(map[byte]int)
+	printable, digits := 0, 0
+	stats := FieldStats{Records: records, Min: 255, MinU16: 0xFFFF, HasUint16: fieldOff+1 < recordSize}
+
+	for r := 0; r < records; r++ {
+		pos := start + r*recordSize + fieldOff
+		b := data[pos]
+		lowValues[b]++
+		if IsTextByte(b) {
+			printable++
+		}
+		if b >= '0' && b <= '9' {
+			digits++
+		}
+		stats.Min = min(stats.Min, int(b))
+		stats.Max = max(stats.Max, int(b))
+
+		if stats.HasUint16 {
+			highValues[data[pos+1]]++
+			v := int(binary.LittleEndian.Uint16(data[pos : pos+2]))
+			stats.MinU16 = min(stats.MinU16, v)
+			stats.MaxU16 = max(stats.MaxU16, v)
+		}
+	}
+
+	stats.Distinct = len(lowValues)
+	stats.PrintableRatio = float64(printable) / float64(records)
+	digitRatio := float64(digits) / float64(records)
+
+	stats.Type = "uint8"
+	switch {
+	case len(lowValues) == 1:
+		stats.Type = "constant"
+	case len(lowValues) == 2 && (lowValues[0] > 0 && (lowValues[1] > 0 || lowValues[0xFF] > 0)):
+		stats.Type = "flag"
+	case digitRatio >= 0.9:
+		stats.Type = "ascii-number"
+	case stats.PrintableRatio >= 0.9 && stats.HasUint16 && fieldLooksLikeText(data, start, recordSize, records, fieldOff+1):
+		stats.Type = "string"
+	case stats.HasUint16 && len(highValues) > 1 && len(highValues) <= len(lowValues) && stats.MaxU16 > 255:
+		// A varying high byte with no more distinct values than the low byte
+		// suggests a little-endian 16-bit value
+		stats.Type = "uint16"
+	case len(lowValues) <= 16 && records >= len(lowValues)*4:
+		stats.Type = "enum"
+	}
+
+	return stats, nil
+}
+
+// Whether the byte at the given intra-record offset is mostly text across records
+func fieldLooksLikeText(data []byte, start, recordSize, records, fieldOff int) bool {
+	text := 0
+	for r := 0; r < records; r++ {
+		b := data[start+r*recordSize+fieldOff]
+		if IsTextByte(b) || b == 0 {
+			text++
+		}
+	}
+	return text*10 >= records*9
+}
@@ -0,0 +1,195 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Bytes at each end of the data searched for a stored checksum
+const checksumScanBytes = 16
+
+// Checksum is a checksum stored in the data: the algorithm, where its value
+// is kept, and the range of bytes it covers. When the field lies inside the
+// range its own bytes are left out.
+type Checksum struct {
+	Algorithm string `json:"algorithm"` // one of ChecksumAlgorithms
+	Offset    int    `json:"offset"`    // of the stored value
+	Width     int    `json:"width"`
+	Start     int    `json:"start"` // covered range
+	End       int    `json:"end"`   // exclusive
+}
+
+// String returns the checksum in the form ParseChecksum reads, such as
+// "crc32-le@0x0:0x4-0x2000".
+func (c Checksum) String() string {
+	return fmt.Sprintf("%s@0x%X:0x%X-0x%X", c.Algorithm, c.Offset, c.Start, c.End)
+}
+
+// ParseChecksum parses an "<algorithm>@<offset>:<start>-<end>" spec. Numbers
+// may be decimal or 0x-prefixed hex, and end may be "end" for the end of the
+// data, which dataLen supplies.
+func ParseChecksum(spec string, dataLen int) (Checksum, error) {
+	name, rest, ok := strings.Cut(spec, "@")
+	algo, known := lookupChecksumAlgo(name)
+	if !ok || !known {
+		return Checksum{}, fmt.Errorf("checksum %q: expected <algorithm>@<offset>:<start>-<end> with one of %s", spec, strings.Join(ChecksumAlgorithms(), ", "))
+	}
+	offStr, rangeStr, ok1 := strings.Cut(rest, ":")
+	startStr, endStr, ok2 := strings.Cut(rangeStr, "-")
+	if !ok1 || !ok2 {
+		return Checksum{}, fmt.Errorf("checksum %q: expected <algorithm>@<offset>:<start>-<end>", spec)
+	}
+
+	var nums [3]int
+	for i, str := range []string{offStr, startStr, endStr} {
+		if i == 2 && strings.EqualFold(str, "end") {
+			nums[i] = dataLen
+			continue
+		}
+		n, err := strconv.ParseInt(str, 0, 64)
+		if err != nil || n < 0 {
+			return Checksum{}, fmt.Errorf("checksum %q: invalid number %q", spec, str)
+		}
+		nums[i] = int(n)
+	}
+
+	c := Checksum{Algorithm: algo.Name, Offset: nums[0], Width: algo.Width, Start: nums[1], End: nums[2]}
+	if c.Start >= c.End || c.End > dataLen || c.Offset+c.Width > dataLen {
+		return Checksum{}, fmt.Errorf("checksum %q: range is outside the data", spec)
+	}
+	return c, nil
+}
+
+// The covered bytes, without the field when it lies inside the range
+func (c Checksum) covered(data []byte) [][]byte {
+	if c.Offset >= c.Start && c.Offset+c.Width <= c.End {
+		return [][]byte{data[c.Start:c.Offset], data[c.Offset+c.Width : c.End]}
+	}
+	return [][]byte{data[c.Start:c.End]}
+}
+
+// Compute returns the checksum of the covered bytes, encoded as it is stored.
+func (c Checksum) Compute(data []byte) []byte {
+	algo, _ := lookupChecksumAlgo(c.Algorithm)
+	sum := algo.Sum(c.covered(data))
+
+	out := make([]byte, 8)
+	if strings.HasSuffix(algo.Name, "-be") {
+		binary.BigEndian.PutUint64(out, sum)
+		return out[8-algo.Width:]
+	}
+	binary.LittleEndian.PutUint64(out, sum)
+	return out[:algo.Width]
+}
+
+// Matches reports whether the stored value equals the computed checksum.
+func (c Checksum) Matches(data []byte) bool {
+	return string(data[c.Offset:c.Offset+c.Width]) == string(c.Compute(data))
+}
+
+// FindChecksums looks for a checksum stored in the first or last 16 bytes of
+// data that covers everything after it, everything before it, or the whole
+// data apart from the field. 8-bit sums are only looked for in the first and
+// last byte, and values that are zero are ignored since they match the sums
+// of zero-filled data.
+func FindChecksums(data []byte) []Checksum {
+	type region struct{ offset, width, start, end int }
+	var candidates []region
+	for _, width := range []int{1, 2, 4} {
+		if len(data) < 2*width {
+			continue
+		}
+		// One in 256 positions holds any given 8-bit sum, so those are only
+		// looked for in the first and last byte
+		scan := checksumScanBytes
+		if width == 1 {
+			scan = 1
+		}
+		// At either end of the data, all but the field is the same as the
+		// bytes after or before it
+		for off := 0; off < scan && off+width < len(data); off += width {
+			candidates = append(candidates, region{off, width, off + width, len(data)})
+			if off > 0 {
+				candidates = append(candidates, region{off, width, 0, len(data)})
+			}
+		}
+		for end := len(data); end > len(data)-scan && end-width > 0; end -= width {
+			off := end - width
+			candidates = append(candidates, region{off, width, 0, off})
+			if end < len(data) {
+				candidates = append(candidates, region{off, width, 0, len(data)})
+			}
+		}
+	}
+
+	// LE and BE variants read the same sum, so compute each one once
+	type sumKey struct {
+		kind string
+		region
+	}
+	sums := make(map[sumKey]uint64)
+
+	var found []Checksum
+	seen := make(map[Checksum]bool)
+	for _, algo := range checksumAlgos {
+		kind := strings.TrimSuffix(strings.TrimSuffix(algo.Name, "-le"), "-be")
+		for _, r := range candidates {
+			if r.width != algo.Width {
+				continue
+			}
+			c := Checksum{Algorithm: algo.Name, Offset: r.offset, Width: r.width, Start: r.start, End: r.end}
+			if seen[c] {
+				continue
+			}
+			seen[c] = true
+
+			stored := algo.Read(data[r.offset : r.offset+r.width])
+			if stored == 0 {
+				continue
+			}
+			key := sumKey{kind, r}
+			sum, ok := sums[key]
+			if !ok {
+				sum = algo.Sum(c.covered(data))
+				sums[key] = sum
+			}
+			if sum == stored {
+				found = append(found, c)
+			}
+		}
+	}
+	return found
+}
+
+// RecordChecksums expands a per-record checksum field into the checksum of
+// each of count records of recordSize bytes starting at start.
+func (f ChecksumField) RecordChecksums(start int, recordSize int, count int) []Checksum {
+	sums := make([]Checksum, 0, count)
+	for r := 0; r < count; r++ {
+		base := start + r*recordSize
+		sums = append(sums, Checksum{Algorithm: f.Algorithm, Offset: base + f.Offset, Width: f.Width, Start: base, End: base + recordSize})
+	}
+	return sums
+}
+
+// FixChecksums returns a copy of data in which every stored checksum matches
+// its covered bytes, and the patches that changed the ones that did not.
+// Checksums are recomputed in order, so one covering another's field sees
+// the updated value.
+func FixChecksums(data []byte, sums []Checksum) ([]byte, []Patch) {
+	fixed := make([]byte, len(data))
+	copy(fixed, data)
+
+	var patches []Patch
+	for _, c := range sums {
+		if c.Offset+c.Width > len(fixed) || c.End > len(fixed) || c.Matches(fixed) {
+			continue
+		}
+		value := c.Compute(fixed)
+		copy(fixed[c.Offset:], value)
+		patches = append(patches, Patch{Offset: c.Offset, Bytes: value})
+	}
+	return fixed, patches
+}
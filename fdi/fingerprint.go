@@ -0,0 +1,228 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Profile holds the analysis parameters that suit a file format, for the
+// caller to use where none were chosen explicitly. Zero values leave the
+// defaults alone.
+type Profile struct {
+	RecordSize  int    `json:"record_size,omitempty"`
+	RecordStart int    `json:"record_start,omitempty"`
+	Encoding    string `json:"encoding,omitempty"` // a codepage name, utf16le or auto
+	MinString   int    `json:"min_string,omitempty"`
+}
+
+// Signature identifies a file format, or a version of one, by the bytes at
+// a fixed offset.
+type Signature struct {
+	Name    string   `json:"name"`
+	Magic   HexBytes `json:"magic"`
+	Offset  int      `json:"offset"`
+	Version string   `json:"version,omitempty"` // the version this magic means, if fixed
+
+	// Alternatively the version is a number stored in the file
+	VersionOffset int    `json:"version_offset,omitempty"`
+	VersionType   string `json:"version_type,omitempty"` // one of ValueTypes
+
+	Profile
+
+	// valid checks the header after the magic, for magics too short to
+	// tell a format from data that happens to start with them
+	valid func(data []byte) bool
+}
+
+// Fingerprint is a signature that matched, with the version it read.
+type Fingerprint struct {
+	Name    string   `json:"name"`
+	Version string   `json:"version,omitempty"`
+	Offset  int      `json:"offset"`
+	Magic   HexBytes `json:"magic"`
+	Profile Profile  `json:"profile"`
+}
+
+// KnownSignatures are the formats recognized without a signatures file:
+// the .fdi disk image variants and the archives, images and executables
+// most often found inside or mistaken for game data.
+var KnownSignatures = []Signature{
+	{Name: "FDI disk image (TR-DOS, UKV)", Magic: []byte("FDI")},
+	{Name: "FDI disk image (PC-98)", Magic: []byte{0, 0, 0, 0, 0x90, 0, 0, 0, 0, 0x10, 0, 0}, Version: "2HD"},
+	{Name: "FDI disk image (PC-98)", Magic: []byte{0, 0, 0, 0, 0x30, 0, 0, 0, 0, 0x10, 0, 0}, Version: "1.44M"},
+	{Name: "FDI disk image (PC-98)", Magic: []byte{0, 0, 0, 0, 0x10, 0, 0, 0, 0, 0x10, 0, 0}, Version: "2DD"},
+	{Name: "ZIP archive", Magic: []byte("PK\x03\x04")},
+	{Name: "gzip stream", Magic: []byte{0x1F, 0x8B, 0x08}},
+	{Name: "bzip2 stream", Magic: []byte("BZh")},
+	{Name: "xz stream", Magic: []byte("\xFD7zXZ\x00")},
+	{Name: "7-Zip archive", Magic: []byte("7z\xBC\xAF\x27\x1C")},
+	{Name: "RAR archive", Magic: []byte("Rar!\x1A\x07")},
+	{Name: "Microsoft cabinet", Magic: []byte("MSCF")},
+	{Name: "LHA archive", Magic: []byte("-lh"), Offset: 2},
+	{Name: "SQLite database", Magic: []byte("SQLite format 3\x00"), VersionOffset: 96, VersionType: "u32be"},
+	{Name: "PNG image", Magic: []byte("\x89PNG\r\n\x1A\n")},
+	{Name: "JPEG image", Magic: []byte{0xFF, 0xD8, 0xFF}},
+	{Name: "GIF image", Magic: []byte("GIF87a"), Version: "87a"},
+	{Name: "GIF image", Magic: []byte("GIF89a"), Version: "89a"},
+	{Name: "BMP image", Magic: []byte("BM"), valid: validBMP},
+	{Name: "RIFF container", Magic: []byte("RIFF")},
+	{Name: "IFF container", Magic: []byte("FORM")},
+	{Name: "Ogg stream", Magic: []byte("OggS")},
+	{Name: "PDF document", Magic: []byte("%PDF-")},
+	{Name: "ELF executable", Magic: []byte("\x7FELF")},
+	{Name: "DOS/Windows executable", Magic: []byte("MZ"), valid: validMZ},
+}
+
+// A BMP file header: the file size it gives is the file's, the reserved
+// words are zero and the pixels start after a known size of DIB header
+func validBMP(data []byte) bool {
+	if len(data) < 18 {
+		return false
+	}
+	le := binary.LittleEndian
+	dib := le.Uint32(data[14:])
+	switch dib {
+	case 12, 40, 52, 56, 64, 108, 124:
+	default:
+		return false
+	}
+	pixels := le.Uint32(data[10:])
+	return le.Uint32(data[2:]) == uint32(len(data)) && le.Uint32(data[6:]) == 0 &&
+		pixels >= 14+dib && pixels <= uint32(len(data))
+}
+
+// An MZ header whose fields agree with each other and the file: the last
+// page is shorter than a page, the image fits in the file, and the header
+// and its relocation table fit in the image. A Windows executable's header
+// points on to its "PE\0\0" header, which is also taken.
+func validMZ(data []byte) bool {
+	if len(data) < 0x40 {
+		return false
+	}
+	u16 := func(at int) int { return int(binary.LittleEndian.Uint16(data[at:])) }
+	if pe := int(binary.LittleEndian.Uint32(data[0x3C:])); pe >= 0x40 && pe+4 <= len(data) && string(data[pe:pe+4]) == "PE\x00\x00" {
+		return true
+	}
+	lastPage, pages, relocs, header, relocTable := u16(2), u16(4), u16(6), u16(8)*16, u16(0x18)
+	if lastPage >= 512 || pages == 0 {
+		return false
+	}
+	image := pages * 512
+	if lastPage > 0 {
+		image -= 512 - lastPage
+	}
+	return image <= len(data) && header >= 0x1C && header <= image &&
+		(relocs == 0 || relocTable >= 0x1C && relocTable+4*relocs <= header)
+}
+
+// Identify returns the signatures that match data, longest magic first as
+// the most specific; among equally long ones, those earlier in sigs win. The
+// two-byte BMP and MZ magics also need a consistent header, as they start
+// arbitrary data often enough.
+func Identify(data []byte, sigs []Signature) []Fingerprint {
+	var matched []Signature
+	for _, s := range sigs {
+		if len(s.Magic) > 0 && s.Offset+len(s.Magic) <= len(data) && bytes.Equal(data[s.Offset:s.Offset+len(s.Magic)], s.Magic) &&
+			(s.valid == nil || s.valid(data)) {
+			matched = append(matched, s)
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool { return len(matched[i].Magic) > len(matched[j].Magic) })
+
+	var prints []Fingerprint
+	for _, s := range matched {
+		fp := Fingerprint{Name: s.Name, Version: s.Version, Offset: s.Offset, Magic: s.Magic, Profile: s.Profile}
+		if size := ValueSize(s.VersionType); size > 0 && s.VersionOffset+size <= len(data) {
+			fp.Version = FormatValue(s.VersionType, data[s.VersionOffset:s.VersionOffset+size])
+		}
+		prints = append(prints, fp)
+	}
+	return prints
+}
+
+// ParseSignatures parses a YAML list of signatures, such as the .fdi
+// layouts of different game versions:
+//
+//	signatures:
+//	  - name: Season 2001 save
+//	    magic: 0x46444932      # or text, such as FDI2
+//	    offset: 0
+//	    version_offset: 4
+//	    version_type: u16le
+//	    record_size: 180
+//	    record_start: 0x400
+//	    encoding: cp1252
+func ParseSignatures(src []byte) ([]Signature, error) {
+	doc, err := parseYAML(src)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("signatures: expected a mapping at the top level")
+	}
+	list, ok := m["signatures"].([]any)
+	if !ok {
+		return nil, errors.New("signatures: signatures must be a list")
+	}
+
+	var sigs []Signature
+	for i, item := range list {
+		sm, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("signatures: entry %d is not a mapping", i)
+		}
+		s, err := signatureFromYAML(sm)
+		if err != nil {
+			return nil, fmt.Errorf("signature %d: %v", i, err)
+		}
+		sigs = append(sigs, s)
+	}
+	return sigs, nil
+}
+
+// Build one signature from its YAML mapping
+func signatureFromYAML(m map[string]any) (Signature, error) {
+	var s Signature
+	s.Name, _ = m["name"].(string)
+	s.Version, _ = m["version"].(string)
+	s.VersionType, _ = m["version_type"].(string)
+	s.Encoding, _ = m["encoding"].(string)
+
+	magic, _ := m["magic"].(string)
+	s.Magic = []byte(magic)
+	if hexStr, ok := strings.CutPrefix(magic, "0x"); ok {
+		b, err := ParseHexPattern(hexStr)
+		if err != nil {
+			return Signature{}, fmt.Errorf("magic %q: invalid hex: %v", magic, err)
+		}
+		s.Magic = b
+	}
+	if s.Name == "" || len(s.Magic) == 0 {
+		return Signature{}, errors.New("name and magic are required")
+	}
+	if s.VersionType != "" && ValueSize(s.VersionType) == 0 {
+		return Signature{}, fmt.Errorf("version_type %q: %v", s.VersionType, ErrUnknownValueType)
+	}
+	if _, ok := LookupCodepage(s.Encoding); !ok && s.Encoding != "" && s.Encoding != "ascii" && s.Encoding != EncodingUTF16LE && s.Encoding != EncodingAuto {
+		return Signature{}, fmt.Errorf("unknown encoding %q", s.Encoding)
+	}
+
+	var err error
+	for key, dst := range map[string]*int{
+		"offset":         &s.Offset,
+		"version_offset": &s.VersionOffset,
+		"record_size":    &s.RecordSize,
+		"record_start":   &s.RecordStart,
+		"min_string":     &s.MinString,
+	} {
+		if *dst, err = yamlInt(m, key); err != nil {
+			return Signature{}, err
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,85 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestIdentify(t *testing.T) {
+	sigs, err := ParseSignatures([]byte(`signatures:
+  - name: Season save
+    magic: 0x504B0304
+    version_offset: 4
+    version_type: u16le
+    record_size: 180
+    record_start: 0x400
+    encoding: cp1252
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("PK\x03\x04\x07\x00rest of the file")
+
+	prints := Identify(data, append(sigs, KnownSignatures...))
+	if len(prints) != 2 {
+		t.Fatalf("got %d fingerprints, want the save and ZIP: %+v", len(prints), prints)
+	}
+	fp := prints[0]
+	if fp.Name != "Season save" || fp.Version != "7" {
+		t.Errorf("first fingerprint = %+v, want Season save version 7", fp)
+	}
+	if fp.Profile.RecordSize != 180 || fp.Profile.RecordStart != 0x400 || fp.Profile.Encoding != "cp1252" {
+		t.Errorf("profile = %+v", fp.Profile)
+	}
+	if prints[1].Name != "ZIP archive" {
+		t.Errorf("second fingerprint = %q, want ZIP archive", prints[1].Name)
+	}
+
+	if got := Identify([]byte("FDI\x00\x50\x00\x02\x00"), KnownSignatures); len(got) != 1 || got[0].Name != "FDI disk image (TR-DOS, UKV)" {
+		t.Errorf("TR-DOS image identified as %+v", got)
+	}
+}
+
+func TestParseSignaturesErrors(t *testing.T) {
+	for _, src := range []string{
+		"signatures:\n  - name: no magic\n",
+		"signatures:\n  - name: x\n    magic: AB\n    version_type: u24le\n",
+		"signatures:\n  - name: x\n    magic: AB\n    encoding: ebcdic\n",
+		"signatures:\n  - name: x\n    magic: 0xZZ\n",
+	} {
+		if _, err := ParseSignatures([]byte(src)); err == nil {
+			t.Errorf("ParseSignatures accepted %q", src)
+		}
+	}
+}
+
+func TestIdentifyShortMagics(t *testing.T) {
+	// A BMP whose header gives its size, and text that starts with BM
+	bmp := make([]byte, 70)
+	copy(bmp, "BM")
+	binary.LittleEndian.PutUint32(bmp[2:], uint32(len(bmp)))
+	binary.LittleEndian.PutUint32(bmp[10:], 54)
+	binary.LittleEndian.PutUint32(bmp[14:], 40)
+	// A DOS executable of 2 pages, the last 0x100 bytes long, with a 32-byte
+	// header, and a save that happens to start with MZ
+	exe := make([]byte, 0x300)
+	copy(exe, "MZ")
+	binary.LittleEndian.PutUint16(exe[2:], 0x100)
+	binary.LittleEndian.PutUint16(exe[4:], 2)
+	binary.LittleEndian.PutUint16(exe[8:], 2)
+	binary.LittleEndian.PutUint16(exe[0x18:], 0x1C)
+	for _, tc := range []struct {
+		data []byte
+		want string
+	}{
+		{bmp, "BMP image"},
+		{append([]byte("BMW M3 "), make([]byte, 100)...), ""},
+		{exe, "DOS/Windows executable"},
+		{append([]byte("MZ\xFF\xFFMAZZOLA"), make([]byte, 100)...), ""},
+	} {
+		got := Identify(tc.data, KnownSignatures)
+		if tc.want == "" && len(got) > 0 || tc.want != "" && (len(got) != 1 || got[0].Name != tc.want) {
+			t.Errorf("Identify(%q...) = %+v, want %q", tc.data[:8], got, tc.want)
+		}
+	}
+}
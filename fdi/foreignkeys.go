@@ -0,0 +1,239 @@
+package fdi
+
+import (
+	"sort"
+	"strings"
+)
+
+// Table is a decoded table of records, as FindForeignKeys compares them.
+type Table struct {
+	Name    string   `json:"name"`
+	Schema  Schema   `json:"schema"`
+	Records []Record `json:"-"`
+
+	// Ranges bounds the columns that hold measures, such as an age or a
+	// rating, rather than references, as a Layout's do; they are not tested
+	Ranges map[string][2]int `json:"-"`
+}
+
+// The keys of a table that are the positions of its records rather than a
+// column: the index counting from 0, and the number counting from 1
+const (
+	KeyIndex  = "#index"
+	KeyNumber = "#number"
+)
+
+// ForeignKey is an integer column of one table whose values look like
+// references to the records of another, by a key column of unique values or
+// by their position.
+type ForeignKey struct {
+	From       string  `json:"from"`
+	Column     string  `json:"column"`
+	To         string  `json:"to"`
+	Key        string  `json:"key"`        // a column of To, KeyIndex or KeyNumber
+	Values     int     `json:"values"`     // in the column, not counting the nulls
+	Nulls      int     `json:"nulls"`      // values taken as no reference: all bits set, or 0 when no key is 0
+	Matched    float64 `json:"matched"`    // share of the values that are keys of To
+	Referenced int     `json:"referenced"` // distinct keys the values hit
+	Coverage   float64 `json:"coverage"`   // share of To's records they hit
+	Named      bool    `json:"named"`      // the column is named after To, as team for teams
+	Unmatched  []int64 `json:"unmatched"`  // the first values that are no key
+}
+
+// Score ranks the relationship: the share of values matched times the share
+// of the table they reach.
+func (fk ForeignKey) Score() float64 {
+	return fk.Matched * fk.Coverage
+}
+
+// The bars a column must clear to be reported as a foreign key: most of its
+// values are keys, it reaches a fair share of the other table, as a column of
+// small codes does not, and it has enough values to tell
+const (
+	MinForeignKeyMatch    = 0.9
+	MinForeignKeyCoverage = 0.2
+	minForeignKeyValues   = 4
+	foreignKeyUnmatched   = 5
+)
+
+// An integer column of a table
+type intColumn struct {
+	name   string
+	values []int64
+	ok     []bool // false where the value is no integer
+	allSet int64  // every bit of the field set, as a null reference
+}
+
+// FindForeignKeys tests every integer column of each table, but those its
+// Ranges bound, against the keys of the others: their columns whose values
+// are all different and, unless one of those is an id, the index and number
+// of their records. For each column and other table the key that scores
+// best is kept when at least MinForeignKeyMatch of the column's values are
+// keys and they reach MinForeignKeyCoverage of the records; values with
+// every bit set, and 0 where no key is 0, are taken as no reference. The
+// relationships are returned best first, those named after their table
+// first among equals.
+func FindForeignKeys(tables []Table) []ForeignKey {
+	columns := make([][]intColumn, len(tables))
+	for i, t := range tables {
+		columns[i] = intColumns(t)
+	}
+
+	var keys []ForeignKey
+	for to, target := range tables {
+		n := len(target.Records)
+		if n < 2 {
+			continue
+		}
+		type keySpace struct {
+			name string
+			has  func(int64) bool
+		}
+		var spaces []keySpace
+		hasID := false
+		for _, c := range columns[to] {
+			if set, ok := uniqueValues(c); ok {
+				spaces = append(spaces, keySpace{c.name, func(v int64) bool { return set[v] }})
+				hasID = hasID || isIDName(c.name)
+			}
+		}
+		// A table with an id column is referred to by it, not by position
+		if !hasID {
+			spaces = append(spaces,
+				keySpace{KeyIndex, func(v int64) bool { return v >= 0 && v < int64(n) }},
+				keySpace{KeyNumber, func(v int64) bool { return v >= 1 && v <= int64(n) }})
+		}
+
+		for from, source := range tables {
+			if from == to {
+				continue
+			}
+			for _, c := range columns[from] {
+				var best ForeignKey
+				found := false
+				for _, space := range spaces {
+					fk, ok := matchColumn(c, space.has, n)
+					if !ok {
+						continue
+					}
+					fk.From, fk.Column, fk.To, fk.Key = source.Name, c.name, target.Name, space.name
+					if !found || fk.Score() > best.Score() {
+						best, found = fk, true
+					}
+				}
+				if found {
+					best.Named = namedAfter(c.name, target.Name)
+					keys = append(keys, best)
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		if si, sj := keys[i].Score(), keys[j].Score(); si != sj {
+			return si > sj
+		}
+		return keys[i].Named && !keys[j].Named
+	})
+	return keys
+}
+
+// The integer columns of a table
+func intColumns(t Table) []intColumn {
+	var cols []intColumn
+	for i, f := range t.Schema.Fields {
+		if _, ranged := t.Ranges[f.Name]; ranged {
+			continue
+		}
+		if _, ok := fieldTypeSizes[f.Type]; !ok || strings.HasPrefix(f.Type, "float") {
+			continue
+		}
+		bits := 8 * f.Size()
+		if f.IsBitField() {
+			bits = f.Bits
+		}
+		c := intColumn{name: f.Name, allSet: -1}
+		if strings.HasPrefix(f.Type, "uint") && bits < 64 {
+			c.allSet = 1<<bits - 1
+		}
+		for _, rec := range t.Records {
+			v, ok := int64(0), false
+			if i < len(rec.Fields) {
+				switch n := rec.Fields[i].Value.(type) {
+				case uint64:
+					v, ok = int64(n), n < 1<<63
+				case int64:
+					v, ok = n, true
+				}
+			}
+			c.values, c.ok = append(c.values, v), append(c.ok, ok)
+		}
+		cols = append(cols, c)
+	}
+	return cols
+}
+
+// The values of a column that holds a different one in every record
+func uniqueValues(c intColumn) (map[int64]bool, bool) {
+	set := make(map[int64]bool, len(c.values))
+	for i, v := range c.values {
+		if !c.ok[i] || set[v] {
+			return nil, false
+		}
+		set[v] = true
+	}
+	return set, len(set) >= 2
+}
+
+// How well the values of c hit the keys has holds, of a table of n records
+func matchColumn(c intColumn, has func(int64) bool, n int) (ForeignKey, bool) {
+	var fk ForeignKey
+	zeroIsKey := has(0)
+	hit := make(map[int64]bool)
+	distinct := make(map[int64]bool)
+	matched := 0
+	for i, v := range c.values {
+		switch {
+		case !c.ok[i]:
+			continue
+		case v == c.allSet || v == 0 && !zeroIsKey:
+			fk.Nulls++
+			continue
+		}
+		fk.Values++
+		distinct[v] = true
+		if has(v) {
+			matched++
+			hit[v] = true
+		} else if len(fk.Unmatched) < foreignKeyUnmatched {
+			fk.Unmatched = append(fk.Unmatched, v)
+		}
+	}
+	if fk.Values < minForeignKeyValues || len(distinct) < 2 {
+		return fk, false
+	}
+	fk.Matched = float64(matched) / float64(fk.Values)
+	fk.Referenced = len(hit)
+	fk.Coverage = float64(len(hit)) / float64(n)
+	if fk.Unmatched == nil {
+		fk.Unmatched = []int64{}
+	}
+	return fk, fk.Matched >= MinForeignKeyMatch && fk.Coverage >= MinForeignKeyCoverage
+}
+
+// Whether a column's name is that of an id: id, team_id or teamid
+func isIDName(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), "id")
+}
+
+// Whether a column's name holds the table's, singular: team, team_id or
+// home_team for teams
+func namedAfter(column, table string) bool {
+	column, table = strings.ToLower(column), strings.ToLower(table)
+	for _, name := range []string{table, strings.TrimSuffix(table, "s"), strings.TrimSuffix(table, "es")} {
+		if len(name) >= 3 && strings.Contains(column, name) {
+			return true
+		}
+	}
+	return false
+}
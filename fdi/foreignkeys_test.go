@@ -0,0 +1,65 @@
+package fdi
+
+import "testing"
+
+// A table of a test layout whose records hold the given integer values, and
+// zero in their other fields
+func layoutTable(t *testing.T, name string, n int, values func(i int) map[string]uint64) Table {
+	l := testLayout(t, name)
+	tab := Table{Name: name, Schema: l.Schema, Ranges: l.Ranges}
+	for i := 0; i < n; i++ {
+		v := values(i)
+		rec := Record{Index: i}
+		for _, f := range l.Schema.Fields {
+			rec.Fields = append(rec.Fields, FieldValue{Name: f.Name, Value: v[f.Name]})
+		}
+		tab.Records = append(tab.Records, rec)
+	}
+	return tab
+}
+
+func TestFindForeignKeys(t *testing.T) {
+	// 20 teams with ids from 101, 400 players with 20 each and the home
+	// games of the first round and a half
+	teams := layoutTable(t, "teams", 20, func(i int) map[string]uint64 {
+		return map[string]uint64{"id": uint64(101 + i), "capacity": uint64(20000 + 731*i), "founded": uint64(1890 + i)}
+	})
+	players := layoutTable(t, "players", 400, func(i int) map[string]uint64 {
+		team := uint64(101 + i/20)
+		if i == 399 {
+			team = 0xFFFF // a free agent
+		}
+		return map[string]uint64{"id": uint64(i + 1), "team": team, "position": uint64(i % 4), "age": uint64(18 + i%17), "speed": uint64(i % 30)}
+	})
+	calendar := layoutTable(t, "calendar", 30, func(i int) map[string]uint64 {
+		return map[string]uint64{"round": uint64(1 + i/10), "home": uint64(101 + i%20), "away": uint64(101 + (i+7)%20),
+			"day": uint64(1 + i%28), "month": uint64(8 + i/10)}
+	})
+
+	keys := FindForeignKeys([]Table{players, teams, calendar})
+	found := make(map[string]ForeignKey)
+	for _, fk := range keys {
+		found[fk.From+"."+fk.Column+" "+fk.To+"."+fk.Key] = fk
+	}
+	for _, want := range []string{"players.team teams.id", "calendar.home teams.id", "calendar.away teams.id"} {
+		fk, ok := found[want]
+		if !ok {
+			t.Errorf("%s not found in %v", want, keys)
+			continue
+		}
+		if fk.Matched != 1 || fk.Referenced != 20 {
+			t.Errorf("%s: matched %v, %d referenced", want, fk.Matched, fk.Referenced)
+		}
+	}
+	if fk := found["players.team teams.id"]; fk.Nulls != 1 || !fk.Named {
+		t.Errorf("players.team: %d nulls, named %v", fk.Nulls, fk.Named)
+	}
+	for key, fk := range found {
+		if fk.Column == "position" || fk.Column == "speed" || fk.Key == KeyIndex && fk.To == "teams" {
+			t.Errorf("unexpected foreign key %s", key)
+		}
+	}
+	if len(keys) > 0 && keys[0].Score() < keys[len(keys)-1].Score() {
+		t.Error("foreign keys not sorted best first")
+	}
+}
@@ -0,0 +1,128 @@
+package fdi
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// ErrTooManyEdits is returned when a fuzzy search allows as many edits as
+// the pattern has bytes, which would match anywhere.
+var ErrTooManyEdits = errors.New("fuzzy search allows as many edits as the pattern has bytes")
+
+// ParseHexWildcards decodes a hex pattern in which ?? (or a lone ?) stands
+// for any byte, such as "50 4C ?? 59". wild marks those bytes and is nil
+// when there are none.
+func ParseHexWildcards(hexStr string) (pattern []byte, wild []bool, err error) {
+	cleaned := strings.Join(strings.Fields(hexStr), "")
+	if cleaned == "" {
+		return nil, nil, errors.New("empty pattern")
+	}
+	for i := 0; i < len(cleaned); {
+		if cleaned[i] == '?' {
+			i++
+			if i < len(cleaned) && cleaned[i] == '?' {
+				i++
+			}
+			if wild == nil {
+				wild = make([]bool, len(pattern))
+			}
+			pattern, wild = append(pattern, 0), append(wild, true)
+			continue
+		}
+		if i+1 >= len(cleaned) {
+			return nil, nil, hex.ErrLength
+		}
+		b, err := hex.DecodeString(cleaned[i : i+2])
+		if err != nil {
+			return nil, nil, err
+		}
+		pattern = append(pattern, b[0])
+		if wild != nil {
+			wild = append(wild, false)
+		}
+		i += 2
+	}
+	return pattern, wild, nil
+}
+
+// Whether a data byte matches byte i of the pattern
+func (opts SearchOptions) matches(b byte, pattern []byte, i int) bool {
+	if opts.Wild != nil && opts.Wild[i] {
+		return true
+	}
+	if opts.IgnoreCase {
+		return foldASCII(b) == foldASCII(pattern[i])
+	}
+	return b == pattern[i]
+}
+
+// Search allowing up to opts.MaxEdits inserted, deleted or changed bytes.
+// Every end offset within reach of the pattern is scored by its edit
+// distance, carrying along where its best alignment starts; of overlapping
+// matches the one with the fewest edits, then closest in length, is kept.
+func searchFuzzy(data []byte, pattern []byte, opts SearchOptions) []SearchResult {
+	m := len(pattern)
+	cost := make([]int, m+1) // edits to match pattern[:i] to the data read so far
+	from := make([]int, m+1) // where that match starts
+	for i := range cost {
+		cost[i] = i
+	}
+
+	var results []SearchResult
+	var best SearchResult
+	end := -1 // end of the overlapping matches best is chosen from
+	keep := func() {
+		if end >= 0 {
+			results = append(results, withContext(data, best))
+		}
+	}
+	for j, b := range data {
+		diag, diagFrom := cost[0], from[0]
+		cost[0], from[0] = 0, j+1
+		for i := 1; i <= m; i++ {
+			c, f := diag, diagFrom // pattern byte matched or changed
+			if !opts.matches(b, pattern, i-1) {
+				c++
+			}
+			if cost[i]+1 < c { // data byte inserted
+				c, f = cost[i]+1, from[i]
+			}
+			if cost[i-1]+1 < c { // pattern byte left out
+				c, f = cost[i-1]+1, from[i-1]
+			}
+			diag, diagFrom = cost[i], from[i]
+			cost[i], from[i] = c, f
+		}
+		if cost[m] > opts.MaxEdits {
+			continue
+		}
+
+		hit := SearchResult{Offset: from[m], Length: j + 1 - from[m], Edits: cost[m]}
+		if hit.Offset >= end {
+			keep()
+			best, end = hit, j+1
+			continue
+		}
+		end = j + 1
+		if hit.Edits < best.Edits || hit.Edits == best.Edits && abs(hit.Length-m) < abs(best.Length-m) {
+			best = hit
+		}
+	}
+	keep()
+	return results
+}
+
+// A result with the bytes around it
+func withContext(data []byte, r SearchResult) SearchResult {
+	r.ContextOffset = max(r.Offset-contextSize, 0)
+	r.Context = data[r.ContextOffset:min(r.Offset+r.Length+contextSize, len(data))]
+	return r
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
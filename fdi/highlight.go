@@ -0,0 +1,151 @@
+package fdi
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Highlight is a range of bytes to mark in a dump, including its last byte.
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"` // inclusive
+}
+
+// Highlights are ranges to mark in a dump, ordered by start.
+type Highlights []Highlight
+
+// ParseHighlights reads a comma-separated list of offsets and ranges
+// written as <first>-<last>, e.g. 0x12,0x400-0x40F.
+func ParseHighlights(spec string) (Highlights, error) {
+	var hs Highlights
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseInt(strings.TrimSpace(first), 0, 64)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("highlight %q: invalid offset %q", part, first)
+		}
+		end := start
+		if isRange {
+			end, err = strconv.ParseInt(strings.TrimSpace(last), 0, 64)
+			if err != nil || end < start {
+				return nil, fmt.Errorf("highlight %q: invalid last offset %q", part, last)
+			}
+		}
+		hs = append(hs, Highlight{int(start), int(end)})
+	}
+	return hs.sorted(), nil
+}
+
+// HighlightsFromJSON takes the offsets of the results this tool wrote as
+// JSON: every object with an "offset" is a range of its "length", "size" or
+// "width", the bytes of its "old" or "bytes", or for search matches the
+// length of the term searched for, and a single byte otherwise. The rows of
+// a dump in the results are not taken.
+func HighlightsFromJSON(src []byte) (Highlights, error) {
+	var doc any
+	if err := json.Unmarshal(src, &doc); err != nil {
+		return nil, err
+	}
+	var hs Highlights
+	collectHighlights(doc, 1, &hs)
+	return hs.sorted(), nil
+}
+
+// Walk the JSON for offsets; length is what the enclosing object implies
+func collectHighlights(v any, length int, hs *Highlights) {
+	switch v := v.(type) {
+	case []any:
+		for _, e := range v {
+			collectHighlights(e, length, hs)
+		}
+	case map[string]any:
+		if n := termLength(v); n > 0 {
+			length = n
+		}
+		if off, ok := v["offset"].(float64); ok && off >= 0 {
+			n := length
+			for _, key := range []string{"length", "size", "width"} {
+				if l, ok := v[key].(float64); ok && l > 0 {
+					n = int(l)
+					break
+				}
+			}
+			for _, key := range []string{"old", "bytes"} {
+				if s, ok := v[key].(string); ok {
+					if b, err := hex.DecodeString(s); err == nil && len(b) > 0 {
+						n = len(b)
+						break
+					}
+				}
+			}
+			*hs = append(*hs, Highlight{int(off), int(off) + n - 1})
+		}
+		for key, e := range v {
+			if key != "context" && key != "rows" { // the dump already shown, not results
+				collectHighlights(e, length, hs)
+			}
+		}
+	}
+}
+
+// The bytes a search term matches: its text, or the bytes of a hex pattern
+func termLength(v map[string]any) int {
+	term, ok := v["term"].(string)
+	if !ok {
+		return 0
+	}
+	if isHex, _ := v["hex"].(bool); isHex {
+		return len(strings.Join(strings.Fields(term), "")) / 2
+	}
+	return len(term)
+}
+
+// Add returns the ranges with more added, in order.
+func (hs Highlights) Add(more Highlights) Highlights {
+	return append(hs, more...).sorted()
+}
+
+func (hs Highlights) sorted() Highlights {
+	sort.SliceStable(hs, func(i, j int) bool { return hs[i].Start < hs[j].Start })
+	return hs
+}
+
+// Contains reports whether a highlighted range covers off.
+func (hs Highlights) Contains(off int) bool {
+	// The ranges starting at or before off; any of them may reach it
+	n := sort.Search(len(hs), func(i int) bool { return hs[i].Start > off })
+	for _, h := range hs[:n] {
+		if h.End >= off {
+			return true
+		}
+	}
+	return false
+}
+
+// Neighborhoods returns the windows of whole dump rows within around bytes
+// of each range, merged where they touch, limited to size bytes: [start, end)
+// pairs in order.
+func (hs Highlights) Neighborhoods(around, size int) [][2]int {
+	var windows [][2]int
+	for _, h := range hs {
+		if h.Start >= size {
+			continue
+		}
+		start := max(h.Start-around, 0) / dumpRowSize * dumpRowSize
+		end := min((h.End+around)/dumpRowSize*dumpRowSize+dumpRowSize, size)
+		if n := len(windows); n > 0 && start <= windows[n-1][1] {
+			windows[n-1][1] = max(windows[n-1][1], end)
+			continue
+		}
+		windows = append(windows, [2]int{start, end})
+	}
+	return windows
+}
@@ -0,0 +1,51 @@
+package fdi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHighlights(t *testing.T) {
+	hs, err := ParseHighlights("0x400-0x40F, 0x12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Highlights{{0x12, 0x12}, {0x400, 0x40F}}); !reflect.DeepEqual(hs, want) {
+		t.Errorf("ParseHighlights = %v, want %v", hs, want)
+	}
+	if !hs.Contains(0x40F) || hs.Contains(0x410) || hs.Contains(0x13) {
+		t.Error("Contains does not match the ranges")
+	}
+	for _, bad := range []string{"0x20-0x10", "abc", "-5"} {
+		if _, err := ParseHighlights(bad); err == nil {
+			t.Errorf("ParseHighlights(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestHighlightsFromJSON(t *testing.T) {
+	// A search, a diff run and a value candidate as this tool writes them
+	src := []byte(`{
+		"searches": [{"term": "JUVE", "matches": [{"offset": 100, "context_offset": 84, "context": "00"}]},
+		             {"term": "4A 55", "hex": true, "matches": [{"offset": 300}]}],
+		"runs": [{"offset": 200, "old": "0102", "new": "0304"}],
+		"candidates": [{"offset": 10, "type": "u8"}]
+	}`)
+	hs, err := HighlightsFromJSON(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Highlights{{10, 10}, {100, 103}, {200, 201}, {300, 301}}
+	if !reflect.DeepEqual(hs, want) {
+		t.Errorf("HighlightsFromJSON = %v, want %v", hs, want)
+	}
+}
+
+func TestNeighborhoods(t *testing.T) {
+	hs := Highlights{{0x20, 0x21}, {0x28, 0x28}, {0x100, 0x100}, {0x5000, 0x5000}}
+	got := hs.Neighborhoods(8, 0x200)
+	want := [][2]int{{0x10, 0x40}, {0xF0, 0x110}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Neighborhoods = %X, want %X", got, want)
+	}
+}
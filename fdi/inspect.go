@@ -0,0 +1,116 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"time"
+	"unicode"
+	"unicode/utf16"
+)
+
+// Bytes Inspect reads as text at most
+const inspectText = 32
+
+// Inspection is everything the bytes at an offset might be: the numbers of
+// Decode, MS-DOS dates and times, and text in each supported encoding.
+type Inspection struct {
+	Numbers NumericValues `json:"numbers"`
+
+	// FAT-style timestamps, where the bytes hold a valid one
+	DOSDate     string `json:"dos_date,omitempty"`     // u16le date
+	DOSTime     string `json:"dos_time,omitempty"`     // u16le time
+	DOSDateTime string `json:"dos_datetime,omitempty"` // u32le with the time in the low word, as in ZIP
+
+	Strings []InspectedString `json:"strings"`
+}
+
+// InspectedString is the text at an offset in one encoding, up to a NUL.
+type InspectedString struct {
+	Encoding string `json:"encoding"`
+	Text     string `json:"text"` // characters that do not print as '.'
+	Bytes    int    `json:"bytes"`
+}
+
+// Inspect interprets the bytes at offset in every way the data inspector
+// shows: as numbers, DOS timestamps and text.
+func Inspect(data []byte, offset int) (Inspection, error) {
+	nums, err := Decode(data, offset)
+	if err != nil {
+		return Inspection{}, err
+	}
+	in := Inspection{Numbers: nums}
+	b := data[offset:]
+
+	if len(b) >= 2 {
+		if d, ok := dosDate(binary.LittleEndian.Uint16(b)); ok {
+			in.DOSDate = d.Format("2006-01-02")
+		}
+		if d, ok := dosTime(binary.LittleEndian.Uint16(b)); ok {
+			in.DOSTime = d.Format("15:04:05")
+		}
+	}
+	if len(b) >= 4 {
+		d, okDate := dosDate(binary.LittleEndian.Uint16(b[2:]))
+		t, okTime := dosTime(binary.LittleEndian.Uint16(b))
+		if okDate && okTime {
+			in.DOSDateTime = time.Date(d.Year(), d.Month(), d.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC).Format("2006-01-02 15:04:05")
+		}
+	}
+
+	text := b[:min(len(b), inspectText)]
+	in.Strings = append(in.Strings, singleByteText("ascii", text, nil))
+	for _, name := range autoCodepages {
+		cp, _ := LookupCodepage(name)
+		in.Strings = append(in.Strings, singleByteText(name, text, cp))
+	}
+	in.Strings = append(in.Strings, utf16Text("utf16le", text, binary.LittleEndian), utf16Text("utf16be", text, binary.BigEndian))
+	return in, nil
+}
+
+// A FAT date: 7 bits of years since 1980, then month and day
+func dosDate(v uint16) (time.Time, bool) {
+	year, month, day := 1980+int(v>>9), time.Month(v>>5&0x0F), int(v&0x1F)
+	d := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	return d, d.Month() == month && d.Day() == day && day > 0
+}
+
+// A FAT time: hours, minutes and seconds halved
+func dosTime(v uint16) (time.Time, bool) {
+	hour, minute, sec := int(v>>11), int(v>>5&0x3F), int(v&0x1F)*2
+	return time.Date(2000, 1, 1, hour, minute, sec, 0, time.UTC), hour < 24 && minute < 60 && sec < 60
+}
+
+// The text up to the first NUL in a single-byte encoding, as the dump's text
+// column shows it
+func singleByteText(name string, b []byte, cp *Codepage) InspectedString {
+	s := InspectedString{Encoding: name}
+	var text []rune
+	for _, c := range b {
+		if c == 0 {
+			break
+		}
+		text = append(text, DumpChar(c, cp))
+		s.Bytes++
+	}
+	s.Text = string(text)
+	return s
+}
+
+// The UTF-16 text up to the first NUL character
+func utf16Text(name string, b []byte, order binary.ByteOrder) InspectedString {
+	var units []uint16
+	for i := 0; i+2 <= len(b); i += 2 {
+		u := order.Uint16(b[i:])
+		if u == 0 {
+			break
+		}
+		units = append(units, u)
+	}
+	var text []rune
+	for _, r := range utf16.Decode(units) {
+		if !unicode.IsPrint(r) {
+			r = '.'
+		}
+		text = append(text, r)
+	}
+	return InspectedString{Encoding: name, Text: string(text), Bytes: 2 * len(units)}
+}
@@ -0,0 +1,43 @@
+package fdi
+
+import "testing"
+
+func TestInspect(t *testing.T) {
+	// 2003-04-14 05:52:28 as a DOS time then date, then text
+	data := []byte("\x8E\x2E\x72\x66JUVE\x00")
+
+	in, err := Inspect(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if in.Numbers.U16LE != 0x2E8E {
+		t.Errorf("u16le = %#x", in.Numbers.U16LE)
+	}
+	if in.DOSDate != "2003-04-14" || in.DOSTime != "05:52:28" {
+		t.Errorf("DOS date %q, time %q", in.DOSDate, in.DOSTime)
+	}
+	if in.DOSDateTime != "2031-03-18 05:52:28" {
+		t.Errorf("DOS date+time = %q", in.DOSDateTime)
+	}
+
+	in, _ = Inspect(data, 4)
+	texts := make(map[string]InspectedString)
+	for _, s := range in.Strings {
+		texts[s.Encoding] = s
+	}
+	if s := texts["cp1252"]; s.Text != "JUVE" || s.Bytes != 4 {
+		t.Errorf("cp1252 text = %+v", s)
+	}
+	if in, _ := Inspect([]byte{0xFF, 0xFF, 0xFF, 0xFF}, 0); in.DOSDate != "" || in.DOSTime != "" || in.DOSDateTime != "" {
+		t.Errorf("FFFF read as DOS date %q, time %q", in.DOSDate, in.DOSTime)
+	}
+
+	in, _ = Inspect([]byte("\x80x"), 0)
+	texts = make(map[string]InspectedString)
+	for _, s := range in.Strings {
+		texts[s.Encoding] = s
+	}
+	if texts["ascii"].Text != ".x" || texts["cp1252"].Text != "€x" {
+		t.Errorf("strings = %+v", in.Strings)
+	}
+}
@@ -0,0 +1,96 @@
+package fdi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// JournalEntry is one edit of a file: what made it and the bytes it changed.
+type JournalEntry struct {
+	Time    time.Time `json:"time"`
+	Edit    string    `json:"edit"` // the command line of the edit
+	Changes []DiffRun `json:"changes"`
+}
+
+// Journal is the history of a file's edits, oldest first, from which they
+// can be undone and redone.
+type Journal struct {
+	Entries []JournalEntry `json:"entries"`
+	Undone  int            `json:"undone"` // entries at the end that were undone, the next to redo first
+}
+
+// The errors of undoing or redoing past the ends of the history
+var (
+	ErrNothingToUndo = errors.New("nothing to undo")
+	ErrNothingToRedo = errors.New("nothing to redo")
+)
+
+// Record adds the edit that turned before into after, dropping the edits
+// undone before it, which can no longer be redone. It reports false when
+// the edit changed nothing.
+func (j *Journal) Record(edit string, before, after []byte, at time.Time) bool {
+	runs := Diff(before, after)
+	if len(runs) == 0 {
+		return false
+	}
+	for i, r := range runs {
+		runs[i] = DiffRun{Offset: r.Offset, Old: bytes.Clone(r.Old), New: bytes.Clone(r.New)}
+	}
+	j.Entries = append(j.Entries[:len(j.Entries)-j.Undone], JournalEntry{Time: at, Edit: edit, Changes: runs})
+	j.Undone = 0
+	return true
+}
+
+// CanUndo and CanRedo count the edits that can be undone and redone.
+func (j *Journal) CanUndo() int { return len(j.Entries) - j.Undone }
+func (j *Journal) CanRedo() int { return j.Undone }
+
+// Undo returns a copy of data with the last edit not yet undone reverted,
+// and that edit. The bytes it wrote must still be in data.
+func (j *Journal) Undo(data []byte) ([]byte, JournalEntry, error) {
+	if j.CanUndo() == 0 {
+		return nil, JournalEntry{}, ErrNothingToUndo
+	}
+	e := j.Entries[j.CanUndo()-1]
+	out, err := applyRuns(data, e, true)
+	if err != nil {
+		return nil, JournalEntry{}, err
+	}
+	j.Undone++
+	return out, e, nil
+}
+
+// Redo returns a copy of data with the last edit undone made again, and
+// that edit. The bytes it replaced must be back in data.
+func (j *Journal) Redo(data []byte) ([]byte, JournalEntry, error) {
+	if j.CanRedo() == 0 {
+		return nil, JournalEntry{}, ErrNothingToRedo
+	}
+	e := j.Entries[j.CanUndo()]
+	out, err := applyRuns(data, e, false)
+	if err != nil {
+		return nil, JournalEntry{}, err
+	}
+	j.Undone--
+	return out, e, nil
+}
+
+// Write the old bytes of each change back, or the new ones again, after
+// checking that the file holds what the edit left or found there
+func applyRuns(data []byte, e JournalEntry, undo bool) ([]byte, error) {
+	out := bytes.Clone(data)
+	for _, r := range e.Changes {
+		want, write := r.New, r.Old
+		if !undo {
+			want, write = r.Old, r.New
+		}
+		end := r.Offset + len(want)
+		if end > len(data) || !bytes.Equal(data[r.Offset:end], want) {
+			return nil, fmt.Errorf("the bytes at 0x%X changed since the edit %q of %s", r.Offset, e.Edit, e.Time.Format(time.DateTime))
+		}
+		copy(out[r.Offset:], write)
+	}
+	return out, nil
+}
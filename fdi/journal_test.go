@@ -0,0 +1,46 @@
+package fdi
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestJournal(t *testing.T) {
+	orig := []byte("ROSSI...BAGGIO")
+	first := []byte("TOTTI...BAGGIO")
+	second := []byte("TOTTI...VIALLI")
+
+	var j Journal
+	if j.Record("same", orig, orig, time.Now()) {
+		t.Error("Record took an edit that changed nothing")
+	}
+	j.Record("rename rossi", orig, first, time.Now())
+	j.Record("rename baggio", first, second, time.Now())
+
+	data, e, err := j.Undo(second)
+	if err != nil || string(data) != string(first) || e.Edit != "rename baggio" {
+		t.Fatalf("Undo = %q, %q, %v", data, e.Edit, err)
+	}
+	if data, _, err = j.Undo(data); err != nil || string(data) != string(orig) {
+		t.Fatalf("second Undo = %q, %v", data, err)
+	}
+	if _, _, err := j.Undo(data); !errors.Is(err, ErrNothingToUndo) {
+		t.Errorf("Undo past the start: %v", err)
+	}
+	if data, e, err = j.Redo(data); err != nil || string(data) != string(first) || e.Edit != "rename rossi" {
+		t.Fatalf("Redo = %q, %q, %v", data, e.Edit, err)
+	}
+
+	// A new edit drops the one that could be redone
+	third := []byte("TOTTI...ZOFF..")
+	j.Record("rename baggio again", data, third, time.Now())
+	if j.CanRedo() != 0 || j.CanUndo() != 2 {
+		t.Errorf("after a new edit: %d to undo, %d to redo, want 2 and 0", j.CanUndo(), j.CanRedo())
+	}
+
+	// The file changed outside the journal
+	if _, _, err := j.Undo([]byte("TOTTI...MALDIN")); err == nil {
+		t.Error("Undo reverted bytes that changed since the edit")
+	}
+}
@@ -0,0 +1,904 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Most values a parse may produce, so that a wrong repeat count cannot
+// exhaust memory
+const maxKaitaiNodes = 1 << 20
+
+// KaitaiSpec is a parsed Kaitai Struct (.ksy) definition. The supported
+// subset covers what record-oriented game files need: meta endian and
+// encoding; seq attributes of the integer, float, str, strz and user types
+// or raw bytes, with size, size-eos, terminator, contents, enum, if,
+// repeat (expr, eos and until) and switch-on types; instances with pos or
+// value; nested types and enums; and expressions over the fields with
+// arithmetic, comparison, logic, _, _index, _parent, _root and _io.
+// Bit-sized types, process and imports are not supported.
+type KaitaiSpec struct {
+	ID   string
+	root *ksyType
+}
+
+// A type: the root or one under types
+type ksyType struct {
+	name      string
+	parent    *ksyType
+	endian    string // le, be or "" to take the parent's
+	encoding  string
+	seq       []ksyAttr
+	instances []ksyAttr
+	types     map[string]*ksyType
+	enums     map[string]map[int64]string
+}
+
+// An attribute of seq or instances
+type ksyAttr struct {
+	id          string
+	typ         string
+	switchOn    string            // with cases, the type depends on this expression
+	cases       map[string]string // case expression, or _, to type
+	size        string
+	sizeEOS     bool
+	contents    []byte
+	encoding    string
+	terminator  int // -1 for none
+	enum        string
+	cond        string // if
+	repeat      string // expr, eos or until
+	repeatExpr  string
+	repeatUntil string
+	pos         string // instances only
+	value       string
+}
+
+// KaitaiNode is one parsed value: a field of a built-in type, a user type
+// with fields of its own or a repeated attribute with its items.
+type KaitaiNode struct {
+	Name     string        `json:"name,omitempty"` // empty for the items of a repeat
+	Type     string        `json:"type"`
+	Offset   int           `json:"offset"` // absolute
+	Size     int           `json:"size"`
+	Value    any           `json:"value,omitempty"` // uint64, int64, float64, bool, string or HexBytes
+	Enum     string        `json:"enum,omitempty"`  // the name of Value in the attribute's enum
+	Fields   []*KaitaiNode `json:"fields,omitempty"`
+	Items    []*KaitaiNode `json:"items,omitempty"`
+	repeated bool
+}
+
+// MarshalJSON writes non-finite floats as strings, which JSON numbers cannot hold.
+func (n *KaitaiNode) MarshalJSON() ([]byte, error) {
+	type plain KaitaiNode
+	v := *n
+	v.Value = jsonValue(v.Value)
+	return marshalUnescaped((*plain)(&v))
+}
+
+// Field returns the field of a user type node with the given name.
+func (n *KaitaiNode) Field(name string) (*KaitaiNode, bool) {
+	for _, f := range n.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// ParseKaitai reads a Kaitai Struct definition.
+func ParseKaitai(src []byte) (*KaitaiSpec, error) {
+	doc, err := parseYAML(src)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("ksy: expected a mapping at the top level")
+	}
+	meta, _ := m["meta"].(map[string]any)
+	id, _ := meta["id"].(string)
+	root, err := ksyTypeFromYAML(id, m, nil)
+	if err != nil {
+		return nil, err
+	}
+	if root.endian, err = ksyEndian(meta["endian"]); err != nil {
+		return nil, err
+	}
+	root.encoding, _ = meta["encoding"].(string)
+	if len(root.seq) == 0 && len(root.instances) == 0 {
+		return nil, errors.New("ksy: no seq or instances defined")
+	}
+	return &KaitaiSpec{ID: id, root: root}, nil
+}
+
+func ksyEndian(v any) (string, error) {
+	switch e, _ := v.(string); e {
+	case "", "le", "be":
+		return e, nil
+	default:
+		return "", fmt.Errorf("ksy: endian must be le or be, got %v", v)
+	}
+}
+
+// Build a type from its mapping of seq, instances, types and enums
+func ksyTypeFromYAML(name string, m map[string]any, parent *ksyType) (*ksyType, error) {
+	t := &ksyType{name: name, parent: parent, types: map[string]*ksyType{}, enums: map[string]map[int64]string{}}
+	if meta, ok := m["meta"].(map[string]any); ok && parent != nil {
+		var err error
+		if t.endian, err = ksyEndian(meta["endian"]); err != nil {
+			return nil, err
+		}
+		t.encoding, _ = meta["encoding"].(string)
+	}
+
+	if raw, ok := m["enums"].(map[string]any); ok {
+		for enumName, v := range raw {
+			values, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("ksy: enum %s is not a mapping", enumName)
+			}
+			enum := make(map[int64]string, len(values))
+			for key, label := range values {
+				n, err := strconv.ParseInt(strings.ReplaceAll(key, "_", ""), 0, 64)
+				if err != nil {
+					return nil, fmt.Errorf("ksy: enum %s: %q is not a number", enumName, key)
+				}
+				switch label := label.(type) {
+				case string:
+					enum[n] = label
+				case map[string]any: // the long form, with an id and a doc
+					enum[n], _ = label["id"].(string)
+				}
+			}
+			t.enums[enumName] = enum
+		}
+	}
+
+	if raw, ok := m["types"].(map[string]any); ok {
+		for typeName, v := range raw {
+			tm, ok := v.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("ksy: type %s is not a mapping", typeName)
+			}
+			sub, err := ksyTypeFromYAML(typeName, tm, t)
+			if err != nil {
+				return nil, err
+			}
+			t.types[typeName] = sub
+		}
+	}
+
+	if v, ok := m["seq"]; ok {
+		list, ok := v.([]any)
+		if !ok {
+			return nil, fmt.Errorf("ksy: %s: seq must be a list", ksyName(name))
+		}
+		for i, item := range list {
+			am, ok := item.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("ksy: %s: seq item %d is not a mapping", ksyName(name), i)
+			}
+			a, err := ksyAttrFromYAML(am)
+			if err != nil {
+				return nil, fmt.Errorf("ksy: %s.%s: %v", ksyName(name), a.id, err)
+			}
+			if a.id == "" {
+				a.id = fmt.Sprintf("_unnamed%d", i)
+			}
+			t.seq = append(t.seq, a)
+		}
+	}
+
+	if v, ok := m["instances"].(map[string]any); ok {
+		names := make([]string, 0, len(v))
+		for id := range v {
+			names = append(names, id)
+		}
+		sort.Strings(names) // a mapping has no order; keep the output stable
+		for _, id := range names {
+			am, ok := v[id].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("ksy: %s: instance %s is not a mapping", ksyName(name), id)
+			}
+			a, err := ksyAttrFromYAML(am)
+			if err != nil {
+				return nil, fmt.Errorf("ksy: %s.%s: %v", ksyName(name), id, err)
+			}
+			a.id = id
+			t.instances = append(t.instances, a)
+		}
+	}
+	return t, nil
+}
+
+func ksyName(name string) string {
+	if name == "" {
+		return "root"
+	}
+	return name
+}
+
+func ksyAttrFromYAML(m map[string]any) (ksyAttr, error) {
+	a := ksyAttr{terminator: -1}
+	str := func(key string) string {
+		s, _ := m[key].(string)
+		return s
+	}
+	a.id = str("id")
+	a.size, a.encoding, a.enum = str("size"), str("encoding"), str("enum")
+	a.cond, a.pos, a.value = str("if"), str("pos"), str("value")
+	a.repeat, a.repeatExpr, a.repeatUntil = str("repeat"), str("repeat-expr"), str("repeat-until")
+	a.sizeEOS = str("size-eos") == "true"
+
+	switch typ := m["type"].(type) {
+	case string:
+		a.typ = typ
+	case map[string]any:
+		a.switchOn, _ = typ["switch-on"].(string)
+		cases, ok := typ["cases"].(map[string]any)
+		if a.switchOn == "" || !ok {
+			return a, errors.New("a type mapping needs switch-on and cases")
+		}
+		a.cases = make(map[string]string, len(cases))
+		for k, v := range cases {
+			a.cases[k], _ = v.(string)
+		}
+	case nil:
+	default:
+		return a, fmt.Errorf("unexpected type %v", typ)
+	}
+
+	if t := str("terminator"); t != "" {
+		n, err := strconv.ParseInt(t, 0, 64)
+		if err != nil || n < 0 || n > 255 {
+			return a, fmt.Errorf("terminator must be a byte, got %q", t)
+		}
+		a.terminator = int(n)
+	}
+	if a.typ == "strz" && a.terminator < 0 {
+		a.terminator = 0
+	}
+
+	switch c := m["contents"].(type) {
+	case string:
+		a.contents = []byte(c)
+	case []any:
+		for _, part := range c {
+			s, _ := part.(string)
+			if n, err := strconv.ParseUint(s, 0, 8); err == nil {
+				a.contents = append(a.contents, byte(n))
+			} else {
+				a.contents = append(a.contents, s...)
+			}
+		}
+	}
+
+	switch a.repeat {
+	case "", "eos":
+	case "expr":
+		if a.repeatExpr == "" {
+			return a, errors.New("repeat: expr needs a repeat-expr")
+		}
+	case "until":
+		if a.repeatUntil == "" {
+			return a, errors.New("repeat: until needs a repeat-until")
+		}
+	default:
+		return a, fmt.Errorf("unknown repeat %q (supported: expr, eos, until)", a.repeat)
+	}
+	return a, nil
+}
+
+// Where the parser reads: the file, or the bytes of a sized attribute
+type ksyStream struct {
+	data []byte
+	base int // offset of data[0] in the file
+	pos  int
+}
+
+func (s *ksyStream) read(n int, what string) ([]byte, error) {
+	if n < 0 || s.pos+n > len(s.data) {
+		return nil, fmt.Errorf("%s: %d bytes at 0x%X run past the end of the data", what, n, s.base+s.pos)
+	}
+	b := s.data[s.pos : s.pos+n]
+	s.pos += n
+	return b, nil
+}
+
+// What an expression can see while a user type is parsed
+type ksyScope struct {
+	typ    *ksyType
+	node   *KaitaiNode
+	parent *ksyScope
+	root   *ksyScope
+	io     *ksyStream
+	item   *KaitaiNode // _ in repeat-until
+	index  int         // _index
+
+	// Instances not read yet, so one may refer to another whatever the order
+	parser  *kaitaiParser
+	pending map[string]ksyAttr
+}
+
+type kaitaiParser struct {
+	nodes int
+	exprs map[string]ksyExpr
+}
+
+// Parse parses data with the definition, from offset to the end of the
+// data, into a tree with the root type at the top.
+func (spec *KaitaiSpec) Parse(data []byte, offset int) (*KaitaiNode, error) {
+	if offset < 0 || offset > len(data) {
+		return nil, ErrOffsetOutOfRange
+	}
+	p := &kaitaiParser{exprs: map[string]ksyExpr{}}
+	io := &ksyStream{data: data[offset:], base: offset}
+	return p.parseType(spec.root, ksyName(spec.ID), io, nil)
+}
+
+func (p *kaitaiParser) parseType(t *ksyType, name string, io *ksyStream, parent *ksyScope) (*KaitaiNode, error) {
+	node := &KaitaiNode{Name: name, Type: ksyName(t.name), Offset: io.base + io.pos}
+	scope := &ksyScope{typ: t, node: node, parent: parent, io: io}
+	scope.root = scope
+	if parent != nil {
+		scope.root = parent.root
+	}
+	start := io.pos
+	for _, a := range t.seq {
+		n, err := p.attr(scope, a)
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			node.Fields = append(node.Fields, n)
+		}
+	}
+	node.Size = io.pos - start
+
+	scope.parser, scope.pending = p, make(map[string]ksyAttr, len(t.instances))
+	for _, a := range t.instances {
+		scope.pending[a.id] = a
+	}
+	for _, a := range t.instances {
+		if err := scope.instance(a.id); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// Read the named instance unless it has been already
+func (s *ksyScope) instance(id string) error {
+	a, ok := s.pending[id]
+	if !ok {
+		return nil
+	}
+	delete(s.pending, id)
+	n, err := s.parser.instance(s, a)
+	if n != nil {
+		s.node.Fields = append(s.node.Fields, n)
+	}
+	return err
+}
+
+// An instance: a value computed from the fields, or an attribute read at pos
+func (p *kaitaiParser) instance(scope *ksyScope, a ksyAttr) (*KaitaiNode, error) {
+	if a.value != "" {
+		if ok, err := p.condition(scope, a); err != nil || !ok {
+			return nil, err
+		}
+		v, err := p.eval(scope, a.value, a.id)
+		if err != nil {
+			return nil, err
+		}
+		n := &KaitaiNode{Name: a.id, Type: "value", Offset: scope.node.Offset, Value: plainValue(v)}
+		if a.enum != "" {
+			if i, ok := ksyInt(n.Value); ok {
+				n.Enum = scope.enumLabel(a.enum, i)
+			}
+		}
+		return n, nil
+	}
+	if a.pos == "" {
+		return p.attr(scope, a) // read where the stream is, after the seq
+	}
+	pos, err := p.evalInt(scope, a.pos, a.id)
+	if err != nil {
+		return nil, err
+	}
+	saved := scope.io.pos
+	defer func() { scope.io.pos = saved }()
+	if pos < 0 || pos > int64(len(scope.io.data)) {
+		return nil, fmt.Errorf("%s: pos 0x%X is outside the data", a.id, pos)
+	}
+	scope.io.pos = int(pos)
+	return p.attr(scope, a)
+}
+
+func (p *kaitaiParser) condition(scope *ksyScope, a ksyAttr) (bool, error) {
+	if a.cond == "" {
+		return true, nil
+	}
+	v, err := p.eval(scope, a.cond, a.id)
+	if err != nil {
+		return false, err
+	}
+	ok, isBool := v.(bool)
+	if !isBool {
+		return false, fmt.Errorf("%s: if %q is not a condition", a.id, a.cond)
+	}
+	return ok, nil
+}
+
+// Read a seq attribute, repeated as it asks; nil when its if is false
+func (p *kaitaiParser) attr(scope *ksyScope, a ksyAttr) (*KaitaiNode, error) {
+	if ok, err := p.condition(scope, a); err != nil || !ok {
+		return nil, err
+	}
+	if a.repeat == "" {
+		return p.one(scope, a, a.id)
+	}
+
+	list := &KaitaiNode{Name: a.id, Type: a.typ + "[]", Offset: scope.io.base + scope.io.pos, repeated: true}
+	start := scope.io.pos
+	count := int64(-1)
+	if a.repeat == "expr" {
+		var err error
+		if count, err = p.evalInt(scope, a.repeatExpr, a.id); err != nil {
+			return nil, err
+		}
+		if count < 0 || count > maxKaitaiNodes {
+			return nil, fmt.Errorf("%s: repeat-expr %d is out of range", a.id, count)
+		}
+	}
+	// The list is visible by name while it fills, for repeat-until
+	scope.node.Fields = append(scope.node.Fields, list)
+	defer func() { scope.node.Fields = scope.node.Fields[:len(scope.node.Fields)-1] }()
+	for i := 0; ; i++ {
+		if count >= 0 && int64(i) >= count || a.repeat == "eos" && scope.io.pos >= len(scope.io.data) {
+			break
+		}
+		scope.index = i
+		item, err := p.one(scope, a, "")
+		if err != nil {
+			return nil, err
+		}
+		list.Items = append(list.Items, item)
+		if a.repeat == "until" {
+			scope.item = item
+			v, err := p.eval(scope, a.repeatUntil, a.id)
+			scope.item = nil
+			if err != nil {
+				return nil, err
+			}
+			if done, _ := v.(bool); done {
+				break
+			}
+		}
+		if a.repeat == "eos" && scope.io.pos == start && item.Size == 0 {
+			return nil, fmt.Errorf("%s: repeat: eos reads nothing", a.id)
+		}
+	}
+	list.Size = scope.io.pos - start
+	if len(list.Items) > 0 {
+		list.Type = list.Items[0].Type + "[]"
+	}
+	return list, nil
+}
+
+// Read one value of an attribute
+func (p *kaitaiParser) one(scope *ksyScope, a ksyAttr, name string) (*KaitaiNode, error) {
+	if p.nodes++; p.nodes > maxKaitaiNodes {
+		return nil, fmt.Errorf("%s: more than %d values", a.id, maxKaitaiNodes)
+	}
+	io := scope.io
+	offset := io.base + io.pos
+	what := a.id
+	if name == "" {
+		what = fmt.Sprintf("%s[%d]", a.id, scope.index)
+	}
+
+	if a.contents != nil {
+		b, err := io.read(len(a.contents), what)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(b, a.contents) {
+			return nil, fmt.Errorf("%s: expected % X at 0x%X, got % X", what, a.contents, offset, b)
+		}
+		return &KaitaiNode{Name: name, Type: "contents", Offset: offset, Size: len(b), Value: HexBytes(b)}, nil
+	}
+
+	typ := a.typ
+	if a.switchOn != "" {
+		var err error
+		if typ, err = p.switchType(scope, a); err != nil {
+			return nil, err
+		}
+	}
+
+	if size, signed, ok := ksyIntType(typ); ok {
+		b, err := io.read(size, what)
+		if err != nil {
+			return nil, err
+		}
+		n := &KaitaiNode{Name: name, Type: typ, Offset: offset, Size: size, Value: ksyDecodeInt(b, signed, scope.byteOrder(typ))}
+		if a.enum != "" {
+			i, _ := ksyInt(n.Value)
+			n.Enum = scope.enumLabel(a.enum, i)
+		}
+		return n, nil
+	}
+	if size, ok := ksyFloatType(typ); ok {
+		b, err := io.read(size, what)
+		if err != nil {
+			return nil, err
+		}
+		order := scope.byteOrder(typ)
+		v := float64(0)
+		if size == 4 {
+			v = float64(math.Float32frombits(order.Uint32(b)))
+		} else {
+			v = math.Float64frombits(order.Uint64(b))
+		}
+		return &KaitaiNode{Name: name, Type: typ, Offset: offset, Size: size, Value: v}, nil
+	}
+	if len(typ) > 1 && typ[0] == 'b' && strings.Trim(typ[1:], "0123456789") == "" {
+		return nil, fmt.Errorf("%s: bit-sized type %s is not supported", what, typ)
+	}
+
+	// Everything else takes a size, the rest of the stream or a terminator
+	b, err := p.sized(scope, a, what)
+	if err != nil {
+		return nil, err
+	}
+	switch typ {
+	case "", "str", "strz":
+		n := &KaitaiNode{Name: name, Type: typ, Offset: offset, Size: io.base + io.pos - offset}
+		if typ == "" {
+			n.Type, n.Value = "bytes", HexBytes(b)
+			return n, nil
+		}
+		decode := kaitaiDecoder(scope.encoding(a))
+		if decode == nil {
+			return nil, fmt.Errorf("%s: unknown encoding %q", what, scope.encoding(a))
+		}
+		n.Value = decode(b)
+		return n, nil
+	}
+
+	t, ok := scope.lookupType(typ)
+	if !ok {
+		return nil, fmt.Errorf("%s: unknown type %q", what, typ)
+	}
+	if b == nil {
+		return p.parseType(t, name, io, scope)
+	}
+	n, err := p.parseType(t, name, &ksyStream{data: b, base: offset}, scope)
+	if n != nil {
+		n.Size = io.base + io.pos - offset // all of the size, read or not
+	}
+	return n, err
+}
+
+// The bytes a sized attribute takes, without its terminator; nil for a user
+// type read straight from the stream
+func (p *kaitaiParser) sized(scope *ksyScope, a ksyAttr, what string) ([]byte, error) {
+	io := scope.io
+	switch {
+	case a.size != "":
+		n, err := p.evalInt(scope, a.size, what)
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.read(int(n), what)
+		if err != nil {
+			return nil, err
+		}
+		if a.terminator >= 0 {
+			if i := bytes.IndexByte(b, byte(a.terminator)); i >= 0 {
+				b = b[:i]
+			}
+		}
+		return b, nil
+	case a.sizeEOS:
+		return io.read(len(io.data)-io.pos, what)
+	case a.terminator >= 0:
+		i := bytes.IndexByte(io.data[io.pos:], byte(a.terminator))
+		if i < 0 {
+			return nil, fmt.Errorf("%s: no terminator 0x%02X after 0x%X", what, a.terminator, io.base+io.pos)
+		}
+		b, _ := io.read(i, what)
+		io.pos++ // the terminator is consumed but not kept
+		return b, nil
+	}
+	if a.typ == "" && a.switchOn == "" || a.typ == "str" {
+		return nil, fmt.Errorf("%s: needs a size, size-eos or terminator", what)
+	}
+	return nil, nil
+}
+
+// The type a switch-on attribute takes
+func (p *kaitaiParser) switchType(scope *ksyScope, a ksyAttr) (string, error) {
+	on, err := p.eval(scope, a.switchOn, a.id)
+	if err != nil {
+		return "", err
+	}
+	on = plainValue(on)
+	keys := make([]string, 0, len(a.cases))
+	for k := range a.cases {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if k == "_" {
+			continue
+		}
+		v, err := p.eval(scope, k, a.id)
+		if err != nil {
+			return "", err
+		}
+		if ksyEqual(on, plainValue(v)) {
+			return a.cases[k], nil
+		}
+	}
+	if t, ok := a.cases["_"]; ok {
+		return t, nil
+	}
+	return "", nil // no case: raw bytes
+}
+
+// The size and signedness of an integer type such as u2 or s4be
+func ksyIntType(typ string) (size int, signed bool, ok bool) {
+	if len(typ) < 2 || (typ[0] != 'u' && typ[0] != 's') {
+		return 0, false, false
+	}
+	switch typ[2:] {
+	case "", "le", "be":
+	default:
+		return 0, false, false
+	}
+	switch typ[1] {
+	case '1', '2', '4', '8':
+		if typ[1] == '1' && typ[2:] != "" {
+			return 0, false, false
+		}
+		return int(typ[1] - '0'), typ[0] == 's', true
+	}
+	return 0, false, false
+}
+
+// The size of a float type such as f4 or f8be
+func ksyFloatType(typ string) (int, bool) {
+	switch typ {
+	case "f4", "f4le", "f4be":
+		return 4, true
+	case "f8", "f8le", "f8be":
+		return 8, true
+	}
+	return 0, false
+}
+
+func ksyDecodeInt(b []byte, signed bool, order binary.ByteOrder) any {
+	var u uint64
+	switch len(b) {
+	case 1:
+		u = uint64(b[0])
+	case 2:
+		u = uint64(order.Uint16(b))
+	case 4:
+		u = uint64(order.Uint32(b))
+	case 8:
+		u = order.Uint64(b)
+	}
+	if !signed {
+		return u
+	}
+	shift := 64 - 8*len(b)
+	return int64(u<<shift) >> shift
+}
+
+// The byte order of a type: its own suffix, else the nearest meta endian,
+// else little endian
+func (s *ksyScope) byteOrder(typ string) binary.ByteOrder {
+	switch {
+	case strings.HasSuffix(typ, "be"):
+		return binary.BigEndian
+	case strings.HasSuffix(typ, "le"):
+		return binary.LittleEndian
+	}
+	for t := s.typ; t != nil; t = t.parent {
+		if t.endian == "be" {
+			return binary.BigEndian
+		} else if t.endian == "le" {
+			return binary.LittleEndian
+		}
+	}
+	return binary.LittleEndian
+}
+
+func (s *ksyScope) encoding(a ksyAttr) string {
+	if a.encoding != "" {
+		return a.encoding
+	}
+	for t := s.typ; t != nil; t = t.parent {
+		if t.encoding != "" {
+			return t.encoding
+		}
+	}
+	return "ascii"
+}
+
+// A type by name, from the type being parsed outwards
+func (s *ksyScope) lookupType(name string) (*ksyType, bool) {
+	for t := s.typ; t != nil; t = t.parent {
+		if sub, ok := t.types[name]; ok {
+			return sub, true
+		}
+	}
+	return nil, false
+}
+
+func (s *ksyScope) lookupEnum(name string) (map[int64]string, bool) {
+	path := strings.Split(name, "::")
+	for t := s.typ; t != nil; t = t.parent {
+		scope := t
+		for _, part := range path[:len(path)-1] {
+			if scope = scope.types[part]; scope == nil {
+				break
+			}
+		}
+		if scope != nil {
+			if enum, ok := scope.enums[path[len(path)-1]]; ok {
+				return enum, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (s *ksyScope) enumLabel(name string, v int64) string {
+	enum, _ := s.lookupEnum(name)
+	return enum[v]
+}
+
+// Decoder for a Kaitai encoding name, or nil if it is unknown
+func kaitaiDecoder(encoding string) func([]byte) string {
+	switch strings.ToLower(encoding) {
+	case "utf-8", "utf8":
+		return func(b []byte) string { return string(b) }
+	case "windows-1252":
+		encoding = "cp1252"
+	case "iso-8859-1", "iso8859-1":
+		encoding = "latin1"
+	case "ibm437":
+		encoding = "cp437"
+	case "ibm850":
+		encoding = "cp850"
+	}
+	return fieldDecoder(encoding)
+}
+
+// Kaitai names of the field types, and of the encodings
+var (
+	kaitaiFieldTypes = map[string]string{
+		"uint8": "u1", "int8": "s1", "uint16": "u2", "int16": "s2", "uint32": "u4", "int32": "s4",
+		"uint64": "u8", "int64": "s8", "float32": "f4", "float64": "f8",
+	}
+	kaitaiEncodings = map[string]string{
+		"": "ASCII", "ascii": "ASCII", "utf16le": "UTF-16LE", "utf-16le": "UTF-16LE",
+		"latin1": "ISO-8859-1", "cp1252": "windows-1252", "cp437": "IBM437", "cp850": "IBM850",
+	}
+)
+
+// KaitaiFromSchema writes a schema as a Kaitai Struct definition: the bytes
+// before the table, then the records as a repeated type whose fields are
+// read in offset order. The gaps between fields become unknown_ byte runs
+// and fields that overlap an earlier one become instances, as do bit fields,
+// which take their bits from the integer read by a _raw instance.
+func KaitaiFromSchema(s Schema) []byte {
+	id := kaitaiID(s.Name, "fdi")
+	record := kaitaiID(s.Name, "record")
+	if record == id {
+		record += "_record"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "meta:\n  id: %s\n  endian: le\nseq:\n", id)
+	if s.Start > 0 {
+		fmt.Fprintf(&b, "  - id: header\n    size: 0x%X\n", s.Start)
+	}
+	fmt.Fprintf(&b, "  - id: records\n    type: %s\n    size: %d\n", record, s.RecordSize)
+	if s.Count > 0 {
+		fmt.Fprintf(&b, "    repeat: expr\n    repeat-expr: %d\n", s.Count)
+	} else {
+		b.WriteString("    repeat: eos\n")
+	}
+
+	fields := append([]Field(nil), s.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	var seq, instances strings.Builder
+	pos := 0
+	for _, f := range fields {
+		if f.IsBitField() {
+			id := kaitaiID(f.Name, "field")
+			fmt.Fprintf(&instances, "      %s_raw:\n        pos: %d\n", id, f.Offset)
+			writeKaitaiField(&instances, f, "        ")
+			fmt.Fprintf(&instances, "      %s:\n        value: (%s_raw >> %d) & %d\n", id, id, f.BitOffset, uint64(1)<<f.Bits-1)
+			continue
+		}
+		if f.Offset < pos {
+			fmt.Fprintf(&instances, "      %s:\n        pos: %d\n", kaitaiID(f.Name, "field"), f.Offset)
+			writeKaitaiField(&instances, f, "        ")
+			continue
+		}
+		if f.Offset > pos {
+			fmt.Fprintf(&seq, "      - id: unknown_%d\n        size: %d\n", pos, f.Offset-pos)
+		}
+		fmt.Fprintf(&seq, "      - id: %s\n", kaitaiID(f.Name, "field"))
+		writeKaitaiField(&seq, f, "        ")
+		pos = f.Offset + f.Size()
+	}
+	fmt.Fprintf(&b, "types:\n  %s:\n", record)
+	if seq.Len() > 0 {
+		fmt.Fprintf(&b, "    seq:\n%s", seq.String())
+	}
+	if instances.Len() > 0 {
+		fmt.Fprintf(&b, "    instances:\n%s", instances.String())
+	}
+	return []byte(b.String())
+}
+
+// The type, size and encoding keys of a field
+func writeKaitaiField(b *strings.Builder, f Field, indent string) {
+	if typ, ok := kaitaiFieldTypes[f.Type]; ok {
+		if f.Size() > 1 && f.byteOrder() == binary.BigEndian {
+			typ += "be"
+		}
+		fmt.Fprintf(b, "%stype: %s\n", indent, typ)
+		return
+	}
+	switch f.Type {
+	case "string":
+		enc, ok := kaitaiEncodings[strings.ToLower(f.Encoding)]
+		if !ok {
+			enc = f.Encoding
+		}
+		fmt.Fprintf(b, "%stype: str\n%ssize: %d\n%sencoding: %s\n", indent, indent, f.Length, indent, enc)
+		if enc != "UTF-16LE" { // a single 0 byte does not end UTF-16 text
+			fmt.Fprintf(b, "%sterminator: 0\n", indent)
+		}
+	case "bcd":
+		fmt.Fprintf(b, "%ssize: %d\n%sdoc: packed BCD digits\n", indent, f.Length, indent)
+	default:
+		fmt.Fprintf(b, "%ssize: %d\n", indent, f.Length)
+	}
+}
+
+// A Kaitai identifier from a name: lower case letters, digits and _,
+// starting with a letter; fallback when nothing is left
+func kaitaiID(name string, fallback string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case b.Len() > 0:
+			b.WriteByte('_')
+		}
+	}
+	id := strings.TrimRight(b.String(), "_")
+	if id == "" {
+		return fallback
+	}
+	if id[0] >= '0' && id[0] <= '9' {
+		id = fallback + "_" + id
+	}
+	return id
+}
@@ -0,0 +1,141 @@
+package fdi
+
+import (
+	"strings"
+	"testing"
+)
+
+const testKSY = `
+meta:
+  id: save
+  endian: le
+  encoding: ASCII
+seq:
+  - id: magic
+    contents: [0x46, 0x44, "I"]
+  - id: version
+    type: u1
+  - id: count
+    type: u2
+  - id: entries
+    type: entry
+    repeat: expr
+    repeat-expr: count
+  - id: trailer
+    type:
+      switch-on: version
+      cases:
+        1: u2be
+        _: u1
+  - id: names
+    type: strz
+    repeat: until
+    repeat-until: _ == "END"
+instances:
+  first_kind:
+    value: entries[0].kind
+    enum: kind
+  tail:
+    pos: _io.size - 1
+    type: u1
+types:
+  entry:
+    seq:
+      - id: kind
+        type: u1
+        enum: kind
+      - id: len
+        type: u1
+      - id: body
+        size: len
+        type: body
+  body:
+    seq:
+      - id: text
+        type: str
+        size-eos: true
+        if: _parent.kind == kind::name
+enums:
+  kind:
+    1: name
+    2: blob
+`
+
+func TestKaitaiParse(t *testing.T) {
+	spec, err := ParseKaitai([]byte(testKSY))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("\x00\x00FDI\x01\x02\x00" +
+		"\x01\x05ROSSI" + "\x02\x02\xAB\xCD" +
+		"\x12\x34" + "A\x00END\x00" + "\x7F")
+	root, err := spec.Parse(data, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if root.Name != "save" || root.Offset != 2 || root.Size != len(data)-3 {
+		t.Errorf("root %s at %d, %d bytes", root.Name, root.Offset, root.Size)
+	}
+	entries, _ := root.Field("entries")
+	if len(entries.Items) != 2 || entries.Type != "entry[]" {
+		t.Fatalf("entries = %+v", entries)
+	}
+	first := entries.Items[0]
+	kind, _ := first.Field("kind")
+	body, _ := first.Field("body")
+	if kind.Enum != "name" || body.Offset != 10 || len(body.Fields) != 1 || body.Fields[0].Value != "ROSSI" {
+		t.Errorf("first entry %s, body at %d with %v", kind.Enum, body.Offset, body.Fields)
+	}
+	if second, _ := entries.Items[1].Field("body"); len(second.Fields) != 0 {
+		t.Errorf("the blob entry read text %v", second.Fields[0].Value)
+	}
+	if trailer, _ := root.Field("trailer"); trailer.Value != uint64(0x1234) {
+		t.Errorf("trailer = %v, want the big-endian 0x1234", trailer.Value)
+	}
+	if names, _ := root.Field("names"); len(names.Items) != 2 || names.Items[1].Value != "END" {
+		t.Errorf("names = %+v", names.Items)
+	}
+	if v, _ := root.Field("first_kind"); v.Value != int64(1) || v.Enum != "name" {
+		t.Errorf("first_kind = %v (%s)", v.Value, v.Enum)
+	}
+	if v, _ := root.Field("tail"); v.Value != uint64(0x7F) || v.Offset != len(data)-1 {
+		t.Errorf("tail = %v at %d", v.Value, v.Offset)
+	}
+
+	if _, err := spec.Parse([]byte("FDX\x01"), 0); err == nil || !strings.Contains(err.Error(), "magic") {
+		t.Errorf("a wrong magic gave %v", err)
+	}
+}
+
+func TestKaitaiFromSchema(t *testing.T) {
+	players := testLayout(t, "players")
+	schema := players.Schema
+	schema.Start, schema.Count = 0x105, 3
+	data := make([]byte, 0x105)
+	for i, name := range []string{"ROSSI", "BAGGIO", "MALDINI"} {
+		data = append(data, playerRecord(i+1, name, byte(i), 20+byte(i), 80)...)
+	}
+
+	spec, err := ParseKaitai(KaitaiFromSchema(schema))
+	if err != nil {
+		t.Fatalf("%v in\n%s", err, KaitaiFromSchema(schema))
+	}
+	root, err := spec.Parse(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	records, _ := DecodeRecords(data, schema)
+	list, _ := root.Field("records")
+	if len(list.Items) != len(records) {
+		t.Fatalf("%d records, want %d", len(list.Items), len(records))
+	}
+	for i, rec := range records {
+		for _, f := range rec.Fields {
+			got, ok := list.Items[i].Field(f.Name)
+			if !ok || got.Offset != f.Offset || got.Value != f.Value {
+				t.Errorf("record %d %s = %+v, want %v at %d", i, f.Name, got, f.Value, f.Offset)
+			}
+		}
+	}
+}
@@ -0,0 +1,676 @@
+package fdi
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A compiled Kaitai expression
+type ksyExpr func(s *ksyScope) (any, error)
+
+// Evaluate an expression of attribute what, compiling it once
+func (p *kaitaiParser) eval(scope *ksyScope, src string, what string) (any, error) {
+	e, ok := p.exprs[src]
+	if !ok {
+		var err error
+		if e, err = compileKaitaiExpr(src); err != nil {
+			return nil, fmt.Errorf("%s: %v", what, err)
+		}
+		p.exprs[src] = e
+	}
+	v, err := e(scope)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %q: %v", what, src, err)
+	}
+	return v, nil
+}
+
+func (p *kaitaiParser) evalInt(scope *ksyScope, src string, what string) (int64, error) {
+	v, err := p.eval(scope, src, what)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := ksyInt(plainValue(v))
+	if !ok {
+		return 0, fmt.Errorf("%s: %q is not an integer", what, src)
+	}
+	return n, nil
+}
+
+// The value of a leaf node, or v itself
+func plainValue(v any) any {
+	if n, ok := v.(*KaitaiNode); ok && n.Value != nil {
+		v = n.Value
+	}
+	if u, ok := v.(uint64); ok {
+		return int64(u)
+	}
+	return v
+}
+
+func ksyInt(v any) (int64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func ksyEqual(a, b any) bool {
+	if fa, ok := a.(float64); ok {
+		if ib, ok := b.(int64); ok {
+			return fa == float64(ib)
+		}
+	}
+	if ia, ok := a.(int64); ok {
+		if fb, ok := b.(float64); ok {
+			return float64(ia) == fb
+		}
+	}
+	if ha, ok := a.(HexBytes); ok {
+		hb, ok := b.(HexBytes)
+		return ok && string(ha) == string(hb)
+	}
+	return a == b
+}
+
+// Tokens of an expression: numbers, names, quoted strings and operators
+type ksyToken struct {
+	kind byte // n(umber), i(dent), s(tring) or o(perator)
+	text string
+}
+
+var ksyOperators = []string{"::", "<<", ">>", "<=", ">=", "==", "!=", "+", "-", "*", "/", "%", "<", ">", "&", "|", "^", "~", "(", ")", "[", "]", ".", "?", ":", ","}
+
+func tokenizeKaitai(src string) ([]ksyToken, error) {
+	var tokens []ksyToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(src) && (isKaitaiIdentByte(src[j]) || src[j] == '.' && j+1 < len(src) && src[j+1] >= '0' && src[j+1] <= '9') {
+				j++
+			}
+			tokens = append(tokens, ksyToken{'n', src[i:j]})
+			i = j
+		case isKaitaiIdentByte(c):
+			j := i + 1
+			for j < len(src) && isKaitaiIdentByte(src[j]) {
+				j++
+			}
+			tokens = append(tokens, ksyToken{'i', src[i:j]})
+			i = j
+		case c == '"' || c == '\'':
+			j := strings.IndexByte(src[i+1:], c)
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, ksyToken{'s', src[i+1 : i+1+j]})
+			i += j + 2
+		default:
+			op := ""
+			for _, o := range ksyOperators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q", c)
+			}
+			tokens = append(tokens, ksyToken{'o', op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+func isKaitaiIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// A recursive-descent parser over the tokens, from the loosest binding
+// operators to the tightest
+type ksyExprParser struct {
+	tokens []ksyToken
+	pos    int
+}
+
+func compileKaitaiExpr(src string) (ksyExpr, error) {
+	tokens, err := tokenizeKaitai(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &ksyExprParser{tokens: tokens}
+	e, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return e, nil
+}
+
+func (p *ksyExprParser) peek(texts ...string) (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	t := p.tokens[p.pos]
+	for _, text := range texts {
+		if t.text == text && (t.kind == 'o' || t.kind == 'i') {
+			return text, true
+		}
+	}
+	return "", false
+}
+
+func (p *ksyExprParser) expect(text string) error {
+	if _, ok := p.peek(text); !ok {
+		return fmt.Errorf("expected %q", text)
+	}
+	p.pos++
+	return nil
+}
+
+func (p *ksyExprParser) ternary() (ksyExpr, error) {
+	cond, err := p.binary(0)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := p.peek("?"); !ok {
+		return cond, nil
+	}
+	p.pos++
+	then, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	otherwise, err := p.ternary()
+	if err != nil {
+		return nil, err
+	}
+	return func(s *ksyScope) (any, error) {
+		c, err := cond(s)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := c.(bool)
+		if !ok {
+			return nil, fmt.Errorf("? needs a condition")
+		}
+		if b {
+			return then(s)
+		}
+		return otherwise(s)
+	}, nil
+}
+
+// Binary operators by level, loosest first
+var ksyLevels = [][]string{
+	{"or"},
+	{"and"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *ksyExprParser) binary(level int) (ksyExpr, error) {
+	if level == len(ksyLevels) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.peek(ksyLevels[level]...)
+		if !ok {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = ksyBinary(op, left, right)
+	}
+}
+
+func ksyBinary(op string, left, right ksyExpr) ksyExpr {
+	return func(s *ksyScope) (any, error) {
+		a, err := left(s)
+		if err != nil {
+			return nil, err
+		}
+		a = plainValue(a)
+		if op == "and" || op == "or" {
+			ab, ok := a.(bool)
+			if !ok {
+				return nil, fmt.Errorf("%s needs conditions", op)
+			}
+			if ab == (op == "or") {
+				return ab, nil
+			}
+		}
+		b, err := right(s)
+		if err != nil {
+			return nil, err
+		}
+		b = plainValue(b)
+		return ksyApply(op, a, b)
+	}
+}
+
+func ksyApply(op string, a, b any) (any, error) {
+	switch op {
+	case "and", "or":
+		bb, ok := b.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s needs conditions", op)
+		}
+		return bb, nil
+	case "==":
+		return ksyEqual(a, b), nil
+	case "!=":
+		return !ksyEqual(a, b), nil
+	}
+
+	if sa, ok := a.(string); ok {
+		sb, ok := b.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot apply %s to text and %T", op, b)
+		}
+		switch op {
+		case "+":
+			return sa + sb, nil
+		case "<":
+			return sa < sb, nil
+		case "<=":
+			return sa <= sb, nil
+		case ">":
+			return sa > sb, nil
+		case ">=":
+			return sa >= sb, nil
+		}
+		return nil, fmt.Errorf("cannot apply %s to text", op)
+	}
+
+	ia, aInt := ksyInt(a)
+	ib, bInt := ksyInt(b)
+	if aInt && bInt {
+		switch op {
+		case "+":
+			return ia + ib, nil
+		case "-":
+			return ia - ib, nil
+		case "*":
+			return ia * ib, nil
+		case "/", "%":
+			if ib == 0 {
+				return nil, fmt.Errorf("division by zero")
+			}
+			q, r := ia/ib, ia%ib
+			if r != 0 && (r < 0) != (ib < 0) { // floored, as Kaitai divides
+				q, r = q-1, r+ib
+			}
+			if op == "/" {
+				return q, nil
+			}
+			return r, nil
+		case "<<":
+			return ia << uint(ib), nil
+		case ">>":
+			return ia >> uint(ib), nil
+		case "&":
+			return ia & ib, nil
+		case "|":
+			return ia | ib, nil
+		case "^":
+			return ia ^ ib, nil
+		}
+	}
+
+	fa, aNum := ksyFloat(a)
+	fb, bNum := ksyFloat(b)
+	if !aNum || !bNum {
+		return nil, fmt.Errorf("cannot apply %s to %v and %v", op, a, b)
+	}
+	switch op {
+	case "+":
+		return fa + fb, nil
+	case "-":
+		return fa - fb, nil
+	case "*":
+		return fa * fb, nil
+	case "/":
+		return fa / fb, nil
+	case "%":
+		return math.Mod(fa, fb), nil
+	case "<":
+		return fa < fb, nil
+	case "<=":
+		return fa <= fb, nil
+	case ">":
+		return fa > fb, nil
+	case ">=":
+		return fa >= fb, nil
+	}
+	return nil, fmt.Errorf("cannot apply %s to numbers with a fraction", op)
+}
+
+func ksyFloat(v any) (float64, bool) {
+	if f, ok := v.(float64); ok {
+		return f, true
+	}
+	if i, ok := ksyInt(v); ok {
+		return float64(i), true
+	}
+	return 0, false
+}
+
+func (p *ksyExprParser) unary() (ksyExpr, error) {
+	op, ok := p.peek("-", "~", "not")
+	if !ok {
+		return p.postfix()
+	}
+	p.pos++
+	operand, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return func(s *ksyScope) (any, error) {
+		v, err := operand(s)
+		if err != nil {
+			return nil, err
+		}
+		v = plainValue(v)
+		switch v := v.(type) {
+		case bool:
+			if op == "not" {
+				return !v, nil
+			}
+		case int64:
+			if op == "-" {
+				return -v, nil
+			} else if op == "~" {
+				return ^v, nil
+			}
+		case float64:
+			if op == "-" {
+				return -v, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot apply %s to %v", op, v)
+	}, nil
+}
+
+func (p *ksyExprParser) postfix() (ksyExpr, error) {
+	e, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch op, _ := p.peek(".", "["); op {
+		case ".":
+			p.pos++
+			if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != 'i' {
+				return nil, fmt.Errorf("expected a name after .")
+			}
+			name := p.tokens[p.pos].text
+			p.pos++
+			e = ksyMember(e, name)
+		case "[":
+			p.pos++
+			index, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			e = ksyIndex(e, index)
+		default:
+			return e, nil
+		}
+	}
+}
+
+func (p *ksyExprParser) primary() (ksyExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	t := p.tokens[p.pos]
+	p.pos++
+	switch t.kind {
+	case 'n':
+		text := strings.ReplaceAll(t.text, "_", "")
+		if n, err := strconv.ParseInt(text, 0, 64); err == nil {
+			return func(*ksyScope) (any, error) { return n, nil }, nil
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", t.text)
+		}
+		return func(*ksyScope) (any, error) { return f, nil }, nil
+	case 's':
+		return func(*ksyScope) (any, error) { return t.text, nil }, nil
+	case 'i':
+		// enum_name::label, possibly under types: a::b::label
+		if _, ok := p.peek("::"); ok {
+			path := []string{t.text}
+			for {
+				if _, ok := p.peek("::"); !ok {
+					break
+				}
+				p.pos++
+				if p.pos >= len(p.tokens) || p.tokens[p.pos].kind != 'i' {
+					return nil, fmt.Errorf("expected a name after ::")
+				}
+				path = append(path, p.tokens[p.pos].text)
+				p.pos++
+			}
+			enum, label := strings.Join(path[:len(path)-1], "::"), path[len(path)-1]
+			return func(s *ksyScope) (any, error) {
+				values, ok := s.lookupEnum(enum)
+				if !ok {
+					return nil, fmt.Errorf("unknown enum %s", enum)
+				}
+				for n, l := range values {
+					if l == label {
+						return n, nil
+					}
+				}
+				return nil, fmt.Errorf("enum %s has no %s", enum, label)
+			}, nil
+		}
+		return ksyNameExpr(t.text), nil
+	case 'o':
+		if t.text == "(" {
+			e, err := p.ternary()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	}
+	return nil, fmt.Errorf("unexpected %q", t.text)
+}
+
+// A name: a field read so far, or one of the special names
+func ksyNameExpr(name string) ksyExpr {
+	return func(s *ksyScope) (any, error) {
+		switch name {
+		case "true", "false":
+			return name == "true", nil
+		case "_":
+			if s.item == nil {
+				return nil, fmt.Errorf("_ is only defined in repeat-until")
+			}
+			return s.item, nil
+		case "_index":
+			return int64(s.index), nil
+		case "_root":
+			return s.root, nil
+		case "_parent":
+			if s.parent == nil {
+				return nil, fmt.Errorf("the root has no _parent")
+			}
+			return s.parent, nil
+		case "_io":
+			return s.io, nil
+		}
+		return s.member(name)
+	}
+}
+
+// A field of a user type being parsed, the last of that name, or an
+// instance, read when first used
+func (s *ksyScope) member(name string) (any, error) {
+	fields := s.node.Fields
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Name == name {
+			return fields[i], nil
+		}
+	}
+	if _, ok := s.pending[name]; ok {
+		if err := s.instance(name); err != nil {
+			return nil, err
+		}
+		return s.member(name)
+	}
+	return nil, fmt.Errorf("unknown name %s", name)
+}
+
+func ksyMember(e ksyExpr, name string) ksyExpr {
+	return func(s *ksyScope) (any, error) {
+		v, err := e(s)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case *ksyScope:
+			switch name {
+			case "_parent":
+				if v.parent == nil {
+					return nil, fmt.Errorf("the root has no _parent")
+				}
+				return v.parent, nil
+			case "_io":
+				return v.io, nil
+			}
+			return v.member(name)
+		case *ksyStream:
+			switch name {
+			case "size":
+				return int64(len(v.data)), nil
+			case "pos":
+				return int64(v.pos), nil
+			case "eof":
+				return v.pos >= len(v.data), nil
+			}
+		case *KaitaiNode:
+			if v.repeated {
+				switch name {
+				case "size":
+					return int64(len(v.Items)), nil
+				case "first", "last":
+					if len(v.Items) == 0 {
+						return nil, fmt.Errorf("%s of an empty list", name)
+					}
+					if name == "first" {
+						return v.Items[0], nil
+					}
+					return v.Items[len(v.Items)-1], nil
+				}
+				break
+			}
+			if v.Value == nil {
+				if f, ok := v.Field(name); ok {
+					return f, nil
+				}
+				break
+			}
+			return ksyProperty(plainValue(v), name)
+		default:
+			return ksyProperty(v, name)
+		}
+		return nil, fmt.Errorf("no %s", name)
+	}
+}
+
+// The properties of plain values: the length of text and bytes, and
+// conversions
+func ksyProperty(v any, name string) (any, error) {
+	switch v := v.(type) {
+	case string:
+		switch name {
+		case "length":
+			return int64(len([]rune(v))), nil
+		case "to_i":
+			n, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%q is not a number", v)
+			}
+			return n, nil
+		}
+	case HexBytes:
+		switch name {
+		case "length", "size":
+			return int64(len(v)), nil
+		}
+	case int64:
+		if name == "to_s" {
+			return strconv.FormatInt(v, 10), nil
+		}
+	case float64:
+		if name == "to_i" {
+			return int64(v), nil
+		}
+	}
+	return nil, fmt.Errorf("%v has no %s", v, name)
+}
+
+func ksyIndex(e, index ksyExpr) ksyExpr {
+	return func(s *ksyScope) (any, error) {
+		v, err := e(s)
+		if err != nil {
+			return nil, err
+		}
+		iv, err := index(s)
+		if err != nil {
+			return nil, err
+		}
+		i, ok := ksyInt(plainValue(iv))
+		if !ok {
+			return nil, fmt.Errorf("index %v is not an integer", iv)
+		}
+		if n, ok := v.(*KaitaiNode); ok && n.repeated {
+			if i < 0 || i >= int64(len(n.Items)) {
+				return nil, fmt.Errorf("index %d is out of range", i)
+			}
+			return n.Items[i], nil
+		}
+		if b, ok := plainValue(v).(HexBytes); ok {
+			if i < 0 || i >= int64(len(b)) {
+				return nil, fmt.Errorf("index %d is out of range", i)
+			}
+			return int64(b[i]), nil
+		}
+		return nil, fmt.Errorf("cannot index %v", v)
+	}
+}
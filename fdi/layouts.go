@@ -0,0 +1,154 @@
+package fdi
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Layout is a table whose structure is known: the schema of its records,
+// the values a real record holds, by which the table is found, and names
+// for coded values such as positions.
+type Layout struct {
+	Name        string
+	Description string
+	Schema      Schema              // Start and Count are found by FindLayout
+	Ranges      map[string][2]int   // inclusive bounds of numeric fields
+	Labels      map[string][]string // names of the values 0, 1, ... of a field
+}
+
+// MinLayoutRecords is the fewest distinct plausible records in a row
+// FindLayout takes for a table.
+const MinLayoutRecords = 4
+
+// Plausible reports whether the record at offset could belong to the table:
+// every string field holds printable text and every numeric field with a
+// range is within it. A layout with no such field to check holds no
+// plausible records, since anything would pass.
+func (l Layout) Plausible(data []byte, offset int) bool {
+	if offset < 0 || offset+l.Schema.RecordSize > len(data) {
+		return false
+	}
+	checked := 0
+	for _, f := range l.Schema.Fields {
+		raw := data[offset+f.Offset : offset+f.Offset+f.Size()]
+		if f.Type == "string" {
+			if !plausibleText(f, raw) {
+				return false
+			}
+			checked++
+			continue
+		}
+		r, ok := l.Ranges[f.Name]
+		if !ok {
+			continue
+		}
+		var v int64
+		switch n := decodeField(f, raw).(type) {
+		case uint64:
+			v = int64(n)
+		case int64:
+			v = n
+		default:
+			continue
+		}
+		if v < int64(r[0]) || v > int64(r[1]) {
+			return false
+		}
+		checked++
+	}
+	return checked > 0
+}
+
+// Text that starts with a letter or digit and goes on in printable
+// characters; in a single-byte encoding only NULs or spaces may follow it
+func plausibleText(f Field, raw []byte) bool {
+	text := decodeField(f, raw).(string)
+	if first, _ := utf8.DecodeRuneInString(text); !unicode.IsLetter(first) && !unicode.IsDigit(first) {
+		return false
+	}
+	for _, r := range text {
+		if r == utf8.RuneError || !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	if i := bytes.IndexByte(raw, 0); i >= 0 && !strings.EqualFold(f.Encoding, "utf16le") {
+		for _, b := range raw[i:] {
+			if b != 0 && b != ' ' {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// FindLayout locates the layout's table as the longest run of plausible
+// records in a row, the first if several are as long. It returns the offset
+// of the first record and their number, or ok false for no run of at least
+// MinLayoutRecords distinct records, such as filler that happens to fit.
+func FindLayout(data []byte, l Layout) (start, count int, ok bool) {
+	size := l.Schema.RecordSize
+	take := func(first, run int) {
+		if (run > count || run == count && first < start) && distinctRecords(data, first, run, size) >= MinLayoutRecords {
+			start, count = first, run
+		}
+	}
+	for phase := 0; phase < size; phase++ {
+		run := 0
+		at := phase
+		for ; at+size <= len(data); at += size {
+			if l.Plausible(data, at) {
+				run++
+				continue
+			}
+			take(at-run*size, run)
+			run = 0
+		}
+		take(at-run*size, run)
+	}
+	if count == 0 {
+		return 0, 0, false
+	}
+	return start, count, true
+}
+
+// The number of different records among the count of size bytes from start
+func distinctRecords(data []byte, start, count, size int) int {
+	if count < MinLayoutRecords {
+		return count
+	}
+	distinct := make(map[string]bool)
+	for r := 0; r < count; r++ {
+		distinct[string(data[start+r*size:start+(r+1)*size])] = true
+	}
+	return len(distinct)
+}
+
+// DecodeLayout decodes count records of the layout from start, or the run
+// of plausible records there when count is 0, naming coded values with the
+// layout's labels.
+func DecodeLayout(data []byte, l Layout, start, count int) ([]Record, error) {
+	s := l.Schema
+	s.Start, s.Count = start, count
+	if count == 0 {
+		for at := start; l.Plausible(data, at); at += s.RecordSize {
+			s.Count++
+		}
+		if s.Count == 0 {
+			s.Count = 1 // show the implausible record rather than nothing
+		}
+	}
+	records, err := DecodeRecords(data, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, rec := range records {
+		for i, f := range rec.Fields {
+			if n, ok := f.Value.(uint64); ok && n < uint64(len(l.Labels[f.Name])) {
+				rec.Fields[i].Value = l.Labels[f.Name][n]
+			}
+		}
+	}
+	return records, nil
+}
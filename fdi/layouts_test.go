@@ -0,0 +1,118 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"os"
+	"strings"
+	"testing"
+)
+
+// A table of the test layouts in testdata/tables.yaml
+func testLayout(t *testing.T, name string) Layout {
+	t.Helper()
+	src, err := os.ReadFile("testdata/tables.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	versions, err := ParseRegistry(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, l := range versions[0].Tables {
+		if l.Name == name {
+			return l
+		}
+	}
+	t.Fatalf("no %s layout in testdata/tables.yaml", name)
+	return Layout{}
+}
+
+func TestParseLayout(t *testing.T) {
+	l, err := ParseLayout([]byte(`
+record_size: 8
+fields:
+  - name: round
+    offset: 0
+    type: uint8
+    range: [1, 38]
+  - name: result
+    offset: 1
+    type: uint8
+    labels: [home, draw, away]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.Name != "" || l.Schema.RecordSize != 8 || l.Ranges["round"] != [2]int{1, 38} || l.Labels["result"][2] != "away" {
+		t.Errorf("ParseLayout = %+v", l)
+	}
+
+	_, err = ParseLayout([]byte("record_size: 1\nfields:\n  - name: a\n    offset: 0\n    type: uint8\n    range: [9, 1]\n"))
+	if err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("an empty range gave %v", err)
+	}
+}
+
+// A player record of the players layout
+func playerRecord(id int, name string, position, age, quality byte) []byte {
+	rec := make([]byte, 64)
+	binary.LittleEndian.PutUint16(rec, uint16(id))
+	copy(rec[4:20], name)
+	copy(rec[20:50], name+" JUNIOR")
+	rec[51], rec[52], rec[57] = position, age, quality
+	return rec
+}
+
+func TestFindLayout(t *testing.T) {
+	players := testLayout(t, "players")
+	data := make([]byte, 0x105) // header, off the record size
+	for i, name := range []string{"ROSSI", "BAGGIO", "MALDINI", "BARESI", "ZOFF"} {
+		data = append(data, playerRecord(i+1, name, byte(i%4), 20+byte(i), 80)...)
+	}
+	data = append(data, playerRecord(9, "\x01\x02", 7, 200, 0)...) // not a player
+	data = append(data, make([]byte, 100)...)
+
+	start, count, ok := FindLayout(data, players)
+	if !ok || start != 0x105 || count != 5 {
+		t.Fatalf("FindLayout = 0x%X, %d, %v, want 0x105, 5, true", start, count, ok)
+	}
+
+	records, err := DecodeLayout(data, players, start, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 5 {
+		t.Fatalf("DecodeLayout gave %d records, want 5", len(records))
+	}
+	rec := records[2]
+	if rec.Fields[2].Value != "MALDINI" || rec.Fields[3].Value != "MALDINI JUNIOR" || rec.Fields[5].Value != "MF" {
+		t.Errorf("record 2 = %v, want MALDINI, MALDINI JUNIOR, MF", rec.Fields)
+	}
+
+	if _, _, ok := FindLayout(data[:0x105+3*64], players); ok {
+		t.Error("FindLayout took a run of 3 records for a table")
+	}
+}
+
+func TestFindLayoutNeedsEvidence(t *testing.T) {
+	players := testLayout(t, "players")
+	var data []byte
+	for i := 0; i < 6; i++ {
+		data = append(data, playerRecord(1, "ROSSI", 0, 20, 80)...) // filler
+	}
+	if _, _, ok := FindLayout(data, players); ok {
+		t.Error("FindLayout took a run of identical records for a table")
+	}
+
+	calendar := testLayout(t, "calendar")
+	text := []byte("hello world\nthis is a text\nfile\n")
+	if _, _, ok := FindLayout(text, calendar); ok {
+		t.Error("FindLayout found a calendar in plain text")
+	}
+
+	// With nothing to check, nothing is plausible
+	bare := Layout{Name: "bare", Schema: Schema{RecordSize: 2, Fields: []Field{{Name: "id", Type: "uint16"}}}}
+	if bare.Plausible([]byte{1, 2}, 0) {
+		t.Error("a layout with no string or ranged field took a record")
+	}
+}
@@ -0,0 +1,345 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// Limits on what FindNameTables takes for a name and a table of them
+const (
+	minNameLen     = 3
+	maxNameLen     = 64
+	minNameEntries = 4
+	maxNameStride  = 1024
+	nameNeighbours = 16 // later names tried as the next entry of a table
+	minReferences  = 4  // records a reference column needs
+	maxNameLinks   = 5
+)
+
+// NameEntry is one name of a table and where it is.
+type NameEntry struct {
+	Offset int    `json:"offset"`
+	Name   string `json:"name"`
+}
+
+// NameTable is a block of names read as an indexed table: fixed-width
+// fields one after another, or names at a fixed distance within larger
+// records, when Stride is set; names each after a length when Prefix is.
+type NameTable struct {
+	Offset int         `json:"offset"`
+	Stride int         `json:"stride,omitempty"` // distance from one name to the next
+	Prefix int         `json:"prefix,omitempty"` // bytes of the length before each name
+	Names  []NameEntry `json:"names"`
+}
+
+// NameLink is one value of a reference column and the name it points to.
+type NameLink struct {
+	Offset int    `json:"offset"` // of the value
+	Value  int    `json:"value"`
+	Name   string `json:"name"`
+	Source string `json:"source,omitempty"` // the name of the record holding the value
+}
+
+// NameReference is a column of small integers, one per record, that index a
+// name table: every value is an index into it, and together they cover
+// much of it.
+type NameReference struct {
+	Table    int        `json:"table"`  // index of the table referred to
+	Source   int        `json:"source"` // table whose records hold the values, -1 for records found by their stride
+	Offset   int        `json:"offset"` // of the value in the first record
+	Stride   int        `json:"stride"`
+	Records  int        `json:"records"`
+	Width    int        `json:"width"` // bytes, little endian
+	Base     int        `json:"base"`  // the value that refers to the first name
+	Distinct int        `json:"distinct"`
+	Links    []NameLink `json:"links"` // the first few
+}
+
+// NameTableReport is what FindNameTables finds.
+type NameTableReport struct {
+	Tables     []NameTable     `json:"tables"`
+	References []NameReference `json:"references"`
+}
+
+// FindNameTables looks for tables of names, fixed-width, at a fixed distance
+// or length-prefixed, and then for columns of small integers that index
+// them, so that records can be linked to the names they refer to. The
+// columns are looked for in the records that hold the names of the other
+// tables, attributed to the record whose name is nearest, and in records
+// found by their stride between the tables.
+func FindNameTables(data []byte) NameTableReport {
+	var report NameTableReport
+	report.Tables = append(fixedNameTables(data), prefixedNameTables(data)...)
+	sort.SliceStable(report.Tables, func(i, j int) bool { return report.Tables[i].Offset < report.Tables[j].Offset })
+	if len(report.Tables) == 0 {
+		return report
+	}
+
+	seen := make(map[[3]int]bool) // table, stride and first offset of each column
+	for _, run := range referenceRuns(data, report.Tables) {
+		for rel := run.from; rel < run.from+run.stride; rel++ {
+			if run.source >= 0 && rel >= 0 && rel <= run.text {
+				continue // the name itself
+			}
+			ref, ok := bestReference(data, report.Tables, run, rel)
+			if !ok {
+				continue
+			}
+			key := [3]int{ref.Table, ref.Stride, ref.Offset}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			report.References = append(report.References, ref)
+			if ref.Width == 2 {
+				rel++ // the high byte is part of the value
+			}
+		}
+	}
+	sort.SliceStable(report.References, func(i, j int) bool {
+		a, b := report.References[i], report.References[j]
+		return a.Distinct*len(report.Tables[b.Table].Names) > b.Distinct*len(report.Tables[a.Table].Names)
+	})
+	return report
+}
+
+// Whether b can begin a name
+func nameLead(b byte) bool {
+	return b >= 'A' && b <= 'Z' || b >= 'a' && b <= 'z' || b >= 0xC0
+}
+
+// The name at pos, if one starts there: text after a non-text byte or two
+// spaces, up to the first non-text byte or two spaces
+func nameAt(data []byte, pos int) (string, bool) {
+	if !nameLead(data[pos]) {
+		return "", false
+	}
+	if pos > 0 && IsTextByte(data[pos-1]) && (data[pos-1] != ' ' || pos < 2 || data[pos-2] != ' ') {
+		return "", false
+	}
+	end := pos
+	for end < len(data) && end-pos <= maxNameLen && IsTextByte(data[end]) && !(data[end] == ' ' && end+1 < len(data) && data[end+1] == ' ') {
+		end++
+	}
+	name := strings.TrimRight(string(data[pos:end]), " ")
+	if len(name) < minNameLen || len(name) > maxNameLen {
+		return "", false
+	}
+	return name, true
+}
+
+// Runs of names the same distance apart
+func fixedNameTables(data []byte) []NameTable {
+	var starts []int
+	names := make(map[int]string)
+	for pos := range data {
+		if name, ok := nameAt(data, pos); ok {
+			starts = append(starts, pos)
+			names[pos] = name
+		}
+	}
+
+	var tables []NameTable
+	used := make(map[int]bool)
+	for i, o := range starts {
+		if used[o] {
+			continue
+		}
+		count := func(stride int) int {
+			n := 1
+			for _, ok := names[o+n*stride]; ok && !used[o+n*stride]; _, ok = names[o+n*stride] {
+				n++
+			}
+			return n
+		}
+		bestStride, best := 0, 0
+		for _, next := range starts[i+1 : min(len(starts), i+1+nameNeighbours)] {
+			stride := next - o
+			if stride > maxNameStride {
+				break
+			}
+			if n := count(stride); n > best {
+				bestStride, best = stride, n
+			}
+		}
+		if best < minNameEntries {
+			continue
+		}
+
+		t := NameTable{Offset: o, Stride: bestStride}
+		distinct := make(map[string]bool)
+		for n := 0; n < best; n++ {
+			pos := o + n*bestStride
+			used[pos] = true
+			t.Names = append(t.Names, NameEntry{Offset: pos, Name: names[pos]})
+			distinct[names[pos]] = true
+		}
+		if len(distinct) >= minNameEntries-1 {
+			tables = append(tables, t)
+		}
+	}
+	return tables
+}
+
+// Runs of names each after its length, in one byte or two little-endian
+func prefixedNameTables(data []byte) []NameTable {
+	var tables []NameTable
+	for _, prefix := range []int{1, 2} {
+		entry := func(pos int) (string, int, bool) {
+			if pos+prefix > len(data) {
+				return "", 0, false
+			}
+			n := int(data[pos])
+			if prefix == 2 {
+				n = int(binary.LittleEndian.Uint16(data[pos:]))
+			}
+			text := pos + prefix
+			if n < minNameLen || n > maxNameLen || text+n > len(data) || !nameLead(data[text]) {
+				return "", 0, false
+			}
+			for _, b := range data[text : text+n] {
+				if !IsTextByte(b) {
+					return "", 0, false
+				}
+			}
+			return string(data[text : text+n]), text + n, true
+		}
+
+		for pos := 0; pos < len(data); pos++ {
+			t := NameTable{Offset: pos, Prefix: prefix}
+			at := pos
+			for {
+				name, next, ok := entry(at)
+				if !ok {
+					break
+				}
+				t.Names = append(t.Names, NameEntry{Offset: at + prefix, Name: name})
+				at = next
+			}
+			if len(t.Names) >= minNameEntries {
+				tables = append(tables, t)
+				pos = at - 1
+			}
+		}
+	}
+	return tables
+}
+
+// Records in which to look for references: from..from+stride-1 are the
+// offsets of the columns relative to start, text the longest name of the
+// source table, whose bytes from start are skipped
+type referenceRun struct {
+	source        int
+	start, stride int
+	count         int
+	from, text    int
+}
+
+// The records holding each fixed table's names, and those found by stride
+// in the gaps between the tables
+func referenceRuns(data []byte, tables []NameTable) []referenceRun {
+	var runs []referenceRun
+	gapStart := 0
+	for i, t := range tables {
+		last := t.Names[len(t.Names)-1]
+		end := last.Offset + len(last.Name)
+		if t.Stride > 0 {
+			longest := 0
+			for _, e := range t.Names {
+				longest = max(longest, len(e.Name))
+			}
+			// Each column goes to the record whose name is nearest
+			runs = append(runs, referenceRun{source: i, start: t.Offset, stride: t.Stride, count: len(t.Names), from: -(t.Stride - 1) / 2, text: longest})
+			end = last.Offset + t.Stride
+		}
+		if t.Offset-gapStart >= minRecords*minStride*4 {
+			if res, ok := InferStride(data, gapStart, t.Offset); ok {
+				runs = append(runs, referenceRun{source: -1, start: res.Start, stride: res.Size, count: res.Records})
+			}
+		}
+		gapStart = max(gapStart, end)
+	}
+	if len(data)-gapStart >= minRecords*minStride*4 {
+		if res, ok := InferStride(data, gapStart, len(data)); ok {
+			runs = append(runs, referenceRun{source: -1, start: res.Start, stride: res.Size, count: res.Records})
+		}
+	}
+	return runs
+}
+
+// The table that the column at rel in the run's records indexes best, read
+// as two bytes when the high byte is always zero and as one otherwise
+func bestReference(data []byte, tables []NameTable, run referenceRun, rel int) (NameReference, bool) {
+	first, records, skipped := run.start+rel, run.count, 0
+	for first < 0 {
+		first += run.stride
+		records--
+		skipped++
+	}
+	for records > 0 && first+(records-1)*run.stride+2 > len(data) {
+		records--
+	}
+	if records < minReferences {
+		return NameReference{}, false
+	}
+
+	width := 2
+	values := make([]int, records)
+	for r := range values {
+		at := first + r*run.stride
+		values[r] = int(data[at])
+		if data[at+1] != 0 {
+			width = 1
+		}
+	}
+
+	lo, hi, counter := values[0], values[0], true
+	distinct := make(map[int]bool)
+	for r, v := range values {
+		lo, hi = min(lo, v), max(hi, v)
+		distinct[v] = true
+		counter = counter && v == values[0]+r
+	}
+	if len(distinct) < minNameEntries-1 || counter {
+		return NameReference{}, false
+	}
+
+	// The smallest table that holds every index, with some past its middle
+	best, bestBase := -1, 0
+	for i, t := range tables {
+		if run.source >= 0 && sameRecords(t, tables[run.source]) {
+			continue // a record does not refer to its own names
+		}
+		base := 0
+		if lo >= 1 && hi == len(t.Names) {
+			base = 1
+		}
+		if hi-base >= len(t.Names) || 2*(hi-base+1) < len(t.Names) {
+			continue
+		}
+		if best < 0 || len(tables[best].Names) > len(t.Names) {
+			best, bestBase = i, base
+		}
+	}
+	if best < 0 {
+		return NameReference{}, false
+	}
+
+	ref := NameReference{Table: best, Source: run.source, Offset: first, Stride: run.stride, Records: records,
+		Width: width, Base: bestBase, Distinct: len(distinct)}
+	for r, v := range values[:min(len(values), maxNameLinks)] {
+		link := NameLink{Offset: first + r*run.stride, Value: v, Name: tables[best].Names[v-bestBase].Name}
+		if run.source >= 0 {
+			link.Source = tables[run.source].Names[r+skipped].Name
+		}
+		ref.Links = append(ref.Links, link)
+	}
+	return ref, true
+}
+
+// Whether the names of two tables are fields of the same records
+func sameRecords(a, b NameTable) bool {
+	diff := a.Offset - b.Offset
+	return a.Stride > 0 && a.Stride == b.Stride && len(a.Names) == len(b.Names) && diff > -a.Stride && diff < a.Stride
+}
@@ -0,0 +1,48 @@
+package fdi
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFindNameTables(t *testing.T) {
+	teams := []string{"JUVENTUS", "MILAN", "INTER", "ROMA", "LAZIO", "NAPOLI"}
+	data := []byte("SAVE\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+	for _, name := range teams {
+		data = append(data, fmt.Sprintf("%-20s", name)...)
+	}
+	// Players: the team as a uint16, the name, then attributes
+	for i := 0; i < 12; i++ {
+		rec := make([]byte, 32)
+		rec[0] = byte(i * 5 % len(teams))
+		copy(rec[2:], fmt.Sprintf("PLAYER%c", 'A'+i))
+		rec[20], rec[21] = byte(60+i), 0xFF
+		data = append(data, rec...)
+	}
+	data = append(data, "\x05ALPHA\x04BETA\x05GAMMA\x05DELTA\xFF"...)
+
+	report := FindNameTables(data)
+	if len(report.Tables) != 3 {
+		t.Fatalf("tables = %+v, want the teams, players and prefixed names", report.Tables)
+	}
+	if tb := report.Tables[0]; tb.Offset != 16 || tb.Stride != 20 || len(tb.Names) != 6 || tb.Names[5].Name != "NAPOLI" {
+		t.Errorf("teams = %+v", tb)
+	}
+	if tb := report.Tables[1]; tb.Offset != 138 || tb.Stride != 32 || len(tb.Names) != 12 {
+		t.Errorf("players = %+v", tb)
+	}
+	if tb := report.Tables[2]; tb.Prefix != 1 || len(tb.Names) != 4 || tb.Names[3] != (NameEntry{Offset: 538, Name: "DELTA"}) {
+		t.Errorf("prefixed = %+v", tb)
+	}
+
+	if len(report.References) != 1 {
+		t.Fatalf("references = %+v, want the players' team", report.References)
+	}
+	ref := report.References[0]
+	if ref.Table != 0 || ref.Source != 1 || ref.Offset != 136 || ref.Width != 2 || ref.Base != 0 || ref.Records != 12 {
+		t.Errorf("reference = %+v", ref)
+	}
+	if l := ref.Links[1]; l.Source != "PLAYERB" || l.Name != "NAPOLI" || l.Offset != 168 {
+		t.Errorf("second link = %+v", l)
+	}
+}
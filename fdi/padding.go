@@ -0,0 +1,160 @@
+package fdi
+
+import "sort"
+
+// MinPaddingRun is how many bytes of one padding byte in a row count as padding.
+const MinPaddingRun = 16
+
+// PaddingBytes are the bytes files are padded with: zeros, erased 0xFF and spaces.
+var PaddingBytes = []byte{0x00, 0xFF, 0x20}
+
+// AlignmentBoundaries are the boundaries AnalyzePadding checks blocks against.
+var AlignmentBoundaries = []int{2, 4, 16, 512}
+
+// PaddingRun is a run of one padding byte.
+type PaddingRun struct {
+	Start     int  `json:"start"`
+	End       int  `json:"end"` // exclusive
+	Byte      byte `json:"byte"`
+	Alignment int  `json:"alignment"` // of End, where the next block starts
+}
+
+// BlockAlignment counts the blocks after padding that start on a boundary.
+type BlockAlignment struct {
+	Boundary int `json:"boundary"`
+	Blocks   int `json:"blocks"`
+	Aligned  int `json:"aligned"`
+}
+
+// PaddingReport is the padding of the data and the alignment of the blocks
+// between it.
+type PaddingReport struct {
+	Runs      []PaddingRun     `json:"runs"`
+	Bytes     int              `json:"bytes"` // in the runs
+	Alignment []BlockAlignment `json:"alignment"`
+	BlockSize int              `json:"block_size"` // largest boundary every block starts on; 0 if fewer than two blocks or none
+}
+
+// FindPadding returns the runs of at least minRun of one of the
+// PaddingBytes, in order. A minRun of 0 is MinPaddingRun.
+func FindPadding(data []byte, minRun int) []PaddingRun {
+	if minRun <= 0 {
+		minRun = MinPaddingRun
+	}
+	var runs []PaddingRun
+	for i := 0; i < len(data); {
+		j := i + 1
+		for j < len(data) && data[j] == data[i] {
+			j++
+		}
+		if j-i >= minRun && isPaddingByte(data[i]) {
+			runs = append(runs, PaddingRun{Start: i, End: j, Byte: data[i], Alignment: Alignment(j)})
+		}
+		i = j
+	}
+	return runs
+}
+
+func isPaddingByte(b byte) bool {
+	for _, p := range PaddingBytes {
+		if b == p {
+			return true
+		}
+	}
+	return false
+}
+
+// AnalyzePadding finds the padding runs of data, as FindPadding, and checks
+// whether the blocks that follow them start on each of the
+// AlignmentBoundaries, which tells how the file's structures are aligned.
+func AnalyzePadding(data []byte, minRun int) PaddingReport {
+	return AnalyzePaddingRange(data, 0, len(data), minRun)
+}
+
+// AnalyzePaddingRange analyzes the padding of data[start:end] only. Offsets,
+// and so the alignment, are still absolute. The range is clamped to the data.
+func AnalyzePaddingRange(data []byte, start int, end int, minRun int) PaddingReport {
+	end = min(max(end, 0), len(data))
+	start = min(max(start, 0), end)
+	report := PaddingReport{Runs: FindPadding(data[start:end], minRun)}
+	var starts []int
+	for i := range report.Runs {
+		r := &report.Runs[i]
+		r.Start += start
+		r.End += start
+		r.Alignment = Alignment(r.End)
+		report.Bytes += r.End - r.Start
+		if r.End < end {
+			starts = append(starts, r.End)
+		}
+	}
+	for _, boundary := range AlignmentBoundaries {
+		a := BlockAlignment{Boundary: boundary, Blocks: len(starts)}
+		for _, s := range starts {
+			if s%boundary == 0 {
+				a.Aligned++
+			}
+		}
+		if len(starts) >= 2 && a.Aligned == a.Blocks {
+			report.BlockSize = boundary
+		}
+		report.Alignment = append(report.Alignment, a)
+	}
+	if report.Runs == nil {
+		report.Runs = []PaddingRun{}
+	}
+	return report
+}
+
+// Whether data[start:end] lies inside one of the runs
+func inPadding(runs []PaddingRun, start int, end int) bool {
+	i := sort.Search(len(runs), func(i int) bool { return runs[i].End > start })
+	return i < len(runs) && runs[i].Start <= start && end <= runs[i].End
+}
+
+// Leave out the pattern occurrences inside padding, and the patterns left
+// with fewer than three
+func dropPaddingPatterns(patterns []RepeatPattern, runs []PaddingRun) []RepeatPattern {
+	kept := patterns[:0]
+	for _, p := range patterns {
+		var offsets []int
+		for _, off := range p.Offsets {
+			if !inPadding(runs, off, off+len(p.Pattern)) {
+				offsets = append(offsets, off)
+			}
+		}
+		if len(offsets) < 3 {
+			continue
+		}
+		if len(offsets) < len(p.Offsets) {
+			p.Offsets, p.Distances = offsets, make([]int, 0, len(offsets)-1)
+			for i := 1; i < len(offsets); i++ {
+				p.Distances = append(p.Distances, offsets[i]-offsets[i-1])
+			}
+		}
+		kept = append(kept, p)
+	}
+	return kept
+}
+
+// The strings of data between the padding runs, so none is made of padding
+// or runs into it
+func (o AnalysisOptions) stringsOutside(data []byte, runs []PaddingRun) []FoundString {
+	var found []FoundString
+	from := 0
+	for i := 0; i <= len(runs); i++ {
+		to := len(data)
+		if i < len(runs) {
+			to = runs[i].Start
+		}
+		strs := o.extract(data[from:to])
+		for j := range strs {
+			strs[j].Offset += from
+		}
+		found = append(found, strs...)
+		if i < len(runs) {
+			from = runs[i].End
+		}
+	}
+	return found
+}
@@ -0,0 +1,65 @@
+package fdi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+// Blocks of a marker and a name, each padded with spaces to 64 bytes and
+// followed by 32 zeros
+func paddedData() []byte {
+	var data []byte
+	for i := 0; i < 4; i++ {
+		block := append([]byte{0xAB, 0xCD, byte(i), 0}, "NAME"...)
+		block = append(block, bytes.Repeat([]byte{' '}, 64-len(block))...)
+		data = append(append(data, block...), make([]byte, 32)...)
+	}
+	return data
+}
+
+func TestAnalyzePaddingRange(t *testing.T) {
+	data := paddedData()
+	report := AnalyzePaddingRange(data, 96, len(data), 0)
+	want := []PaddingRun{
+		{Start: 104, End: 160, Byte: ' ', Alignment: 32},
+		{Start: 160, End: 192, Byte: 0, Alignment: 64},
+		{Start: 200, End: 256, Byte: ' ', Alignment: 256},
+		{Start: 256, End: 288, Byte: 0, Alignment: 32},
+		{Start: 296, End: 352, Byte: ' ', Alignment: 32},
+		{Start: 352, End: 384, Byte: 0, Alignment: 128},
+	}
+	if !reflect.DeepEqual(report.Runs, want) {
+		t.Fatalf("runs %+v, want %+v", report.Runs, want)
+	}
+	if report.Bytes != 3*88 || report.BlockSize != 16 {
+		t.Errorf("%d bytes aligned to %d, want %d aligned to 16", report.Bytes, report.BlockSize, 3*88)
+	}
+	if a := report.Alignment[3]; a.Boundary != 512 || a.Blocks != 5 || a.Aligned != 0 {
+		t.Errorf("512-byte alignment %+v, want 0 of 5 blocks", a)
+	}
+}
+
+func TestAnalyzeLeavesOutPadding(t *testing.T) {
+	data := paddedData()
+	for _, keep := range []bool{false, true} {
+		result := Analyze(data, AnalysisOptions{MinString: 4, KeepPadding: keep})
+		padded := false
+		for _, p := range result.Patterns {
+			padded = padded || bytes.Count(p.Pattern, p.Pattern[:1]) == len(p.Pattern) && (p.Pattern[0] == 0 || p.Pattern[0] == ' ')
+		}
+		if padded != keep {
+			t.Errorf("KeepPadding %v: padding patterns %v", keep, padded)
+		}
+	}
+
+	strs := NewAnalyzer(data, AnalysisOptions{MinString: 4}).Strings()
+	for i, s := range strs {
+		if s.Text != "NAME" || s.Offset != 96*i+4 {
+			t.Errorf("string %d is %q at %d, want NAME at %d", i, s.Text, s.Offset, 96*i+4)
+		}
+	}
+	if len(strs) != 4 {
+		t.Errorf("%d strings, want 4", len(strs))
+	}
+}
@@ -0,0 +1,107 @@
+package fdi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Patch replaces the bytes at Offset with Bytes.
+type Patch struct {
+	Offset int
+	Bytes  []byte
+}
+
+// ParsePatch parses an "<offset>=<hexbytes>" patch spec such as "0x44=0a00".
+// The offset may be decimal or 0x-prefixed hex.
+func ParsePatch(spec string) (Patch, error) {
+	offStr, hexStr, ok := strings.Cut(spec, "=")
+	if !ok {
+		return Patch{}, fmt.Errorf("patch %q: expected <offset>=<hexbytes>", spec)
+	}
+
+	off, err := strconv.ParseInt(strings.TrimSpace(offStr), 0, 64)
+	if err != nil || off < 0 {
+		return Patch{}, fmt.Errorf("patch %q: invalid offset", spec)
+	}
+
+	b, err := ParseHexPattern(hexStr)
+	if err != nil {
+		return Patch{}, fmt.Errorf("patch %q: invalid hex: %v", spec, err)
+	}
+	return Patch{Offset: int(off), Bytes: b}, nil
+}
+
+// ApplyPatches returns a copy of data with the patches applied in order.
+// Patches that would write past the end of the data are rejected.
+func ApplyPatches(data []byte, patches []Patch) ([]byte, error) {
+	for _, p := range patches {
+		if p.Offset+len(p.Bytes) > len(data) {
+			return nil, fmt.Errorf("patch at 0x%X (%d bytes) extends past the end of the file", p.Offset, len(p.Bytes))
+		}
+	}
+
+	patched := make([]byte, len(data))
+	copy(patched, data)
+	for _, p := range patches {
+		copy(patched[p.Offset:], p.Bytes)
+	}
+	return patched, nil
+}
+
+// ParseReplacement parses an "<old>=<new>" replacement spec: text, encoded
+// with cp when it is given, or hex bytes on both sides after a "hex:" prefix,
+// as in "hex:4A55=4A56".
+func ParseReplacement(spec string, cp *Codepage) (from, to []byte, err error) {
+	hexSpec, isHex := strings.CutPrefix(spec, "hex:")
+	if isHex {
+		spec = hexSpec
+	}
+	oldStr, newStr, ok := strings.Cut(spec, "=")
+	if !ok || oldStr == "" {
+		return nil, nil, fmt.Errorf("replace %q: expected <old>=<new>", spec)
+	}
+
+	encode := func(s string) ([]byte, error) {
+		switch {
+		case isHex:
+			return ParseHexPattern(s)
+		case cp != nil:
+			return cp.EncodeString(s)
+		}
+		return []byte(s), nil
+	}
+	if from, err = encode(oldStr); err != nil {
+		return nil, nil, fmt.Errorf("replace %q: %v", spec, err)
+	}
+	if to, err = encode(newStr); err != nil {
+		return nil, nil, fmt.Errorf("replace %q: %v", spec, err)
+	}
+	if len(to) > len(from) {
+		return nil, nil, fmt.Errorf("replace %q: the new value is %d bytes, longer than the %d it replaces", spec, len(to), len(from))
+	}
+	return from, to, nil
+}
+
+// ReplacePatches returns a patch for each occurrence of from in data that
+// writes to over it; occurrences do not overlap. A shorter to is padded out
+// with spaces where a space follows the occurrence, as in a space-padded
+// field, and with NULs otherwise.
+func ReplacePatches(data, from, to []byte, ignoreCase bool) ([]Patch, error) {
+	results, err := Search(data, from, SearchOptions{IgnoreCase: ignoreCase, NoOverlap: true})
+	if err != nil {
+		return nil, err
+	}
+	patches := make([]Patch, 0, len(results))
+	for _, r := range results {
+		b := make([]byte, len(from))
+		copy(b, to)
+		if end := r.Offset + len(from); len(to) < len(from) && end < len(data) && data[end] == ' ' {
+			for i := len(to); i < len(b); i++ {
+				b[i] = ' '
+			}
+		}
+		patches = append(patches, Patch{Offset: r.Offset, Bytes: b})
+	}
+	return patches, nil
+}
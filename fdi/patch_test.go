@@ -0,0 +1,38 @@
+package fdi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReplacePatches(t *testing.T) {
+	data := []byte("\x00ROSSI\x00\x00\x00ROSSI JUNIOR\x00rossi")
+	from, to, err := ParseReplacement("ROSSI=BAGGI", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patches, err := ReplacePatches(data, from, to, true)
+	if err != nil || len(patches) != 3 {
+		t.Fatalf("ReplacePatches = %v, %v, want 3 patches", patches, err)
+	}
+
+	from, to, _ = ParseReplacement("ROSSI=ZOFF", nil)
+	patches, _ = ReplacePatches(data, from, to, false)
+	patched, err := ApplyPatches(data, patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("\x00ZOFF\x00\x00\x00\x00ZOFF  JUNIOR\x00rossi"); !bytes.Equal(patched, want) {
+		t.Errorf("patched = %q, want %q", patched, want)
+	}
+
+	from, to, err = ParseReplacement("hex:00FF=01", nil)
+	if err != nil || !bytes.Equal(from, []byte{0, 0xFF}) || !bytes.Equal(to, []byte{1}) {
+		t.Errorf("hex replacement = % X, % X, %v", from, to, err)
+	}
+	for _, spec := range []string{"ROSSI", "=X", "ZOFF=BUFFON", "hex:0=1"} {
+		if _, _, err := ParseReplacement(spec, nil); err == nil {
+			t.Errorf("ParseReplacement(%q) gave no error", spec)
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package fdi
+
+import (
+	"encoding/hex"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// RepeatPattern is a short byte sequence that recurs nearby, a potential record delimiter.
+type RepeatPattern struct {
+	Pattern   HexBytes `json:"pattern"`
+	Offsets   []int    `json:"offsets"`
+	Distances []int    `json:"distances"` // gaps between consecutive offsets
+}
+
+// HexBytes is a byte slice that marshals as a hex string.
+type HexBytes []byte
+
+// MarshalText encodes the bytes as lowercase hex.
+func (h HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+// UnmarshalText decodes a hex string.
+func (h *HexBytes) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// PatternSearch chooses which repeating patterns FindRepeatPatternsWith looks
+// for, trading thoroughness for speed.
+type PatternSearch struct {
+	Sizes    []int // pattern lengths in bytes, at most 8
+	MaxGap   int   // how far past an occurrence the next may be
+	MaxBytes int   // scan only this many bytes from the start; 0 scans all
+}
+
+// The pattern searches of -fast, the default and -deep
+var (
+	FastPatternSearch    = PatternSearch{Sizes: []int{2, 4}, MaxGap: 1000, MaxBytes: 1 << 20}
+	DefaultPatternSearch = PatternSearch{Sizes: []int{2, 4, 8}, MaxGap: 1000}
+	DeepPatternSearch    = PatternSearch{Sizes: []int{2, 3, 4, 6, 8}, MaxGap: 4096}
+)
+
+// FindRepeatPatterns looks for 2, 4 and 8 byte sequences that repeat within
+// 1000 bytes of a previous occurrence, and returns those seen at least three
+// times ordered by first offset.
+//
+// For each occurrence, the next non-overlapping occurrence within 1000 bytes
+// is recorded. The first such pair starts a pattern's offset list and later
+// ones only extend it with new positions.
+func FindRepeatPatterns(data []byte) []RepeatPattern {
+	return FindRepeatPatternsWith(data, DefaultPatternSearch)
+}
+
+// FindRepeatPatternsWith is FindRepeatPatterns with the pattern sizes, the
+// gap and the range of search; a zero search is the default. The work is
+// split among GOMAXPROCS workers, and the result is the same however many
+// run.
+func FindRepeatPatternsWith(data []byte, search PatternSearch) []RepeatPattern {
+	patterns, _ := findRepeatPatterns(data, search, nil)
+	return patterns
+}
+
+// The patterns, found in rounds that p may stop, reporting whether it did:
+// then the patterns of the size being searched are those of the offsets it
+// got to, and larger sizes are not searched
+func findRepeatPatterns(data []byte, search PatternSearch, p *Progress) ([]RepeatPattern, bool) {
+	if len(search.Sizes) == 0 {
+		search = DefaultPatternSearch
+	}
+	if search.MaxBytes > 0 && len(data) > search.MaxBytes {
+		data = data[:search.MaxBytes]
+	}
+
+	workers := runtime.GOMAXPROCS(0)
+	total, done := 0, 0
+	for _, size := range search.Sizes {
+		if len(data) >= size*2 {
+			total += len(data) - size + 1
+		}
+	}
+	var patterns []RepeatPattern
+	interrupted := false
+	for _, size := range search.Sizes {
+		if len(data) < size*2 || interrupted {
+			continue
+		}
+		repeats := nearestRepeats(data, size, search.MaxGap, workers, func(n int) bool {
+			p.Advance("records", done+n, total)
+			return p.Stopped()
+		})
+		done += len(data) - size + 1
+		interrupted = len(repeats) < len(data)-size+1
+
+		// Each worker gathers the offset lists of the patterns whose hash
+		// falls to it, seeing all of their occurrences in order
+		found := make([]map[int][]int, workers)
+		var wg sync.WaitGroup
+		for part := range found {
+			wg.Add(1)
+			go func(part int) {
+				defer wg.Done()
+				found[part] = gatherRepeats(data, repeats, size, part, workers)
+			}(part)
+		}
+		wg.Wait()
+
+		for _, byFirst := range found {
+			for first, positions := range byFirst {
+				if len(positions) < 3 {
+					continue
+				}
+				distances := make([]int, 0, len(positions)-1)
+				for i := 1; i < len(positions); i++ {
+					distances = append(distances, positions[i]-positions[i-1])
+				}
+				pattern := append(HexBytes(nil), data[first:first+size]...)
+				patterns = append(patterns, RepeatPattern{Pattern: pattern, Offsets: positions, Distances: distances})
+			}
+		}
+	}
+
+	sort.Slice(patterns, func(i, j int) bool {
+		if patterns[i].Offsets[0] != patterns[j].Offsets[0] {
+			return patterns[i].Offsets[0] < patterns[j].Offsets[0]
+		}
+		return len(patterns[i].Pattern) < len(patterns[j].Pattern)
+	})
+	if !interrupted {
+		p.Advance("records", total, total)
+	}
+	return patterns, interrupted
+}
+
+// Bits of the rolling hash that index nearestRepeats' chain heads
+const repeatHashBits = 15
+
+// For every offset, how far after it the first occurrence of the same
+// size-byte sequence starts that starts after it ends and less than maxGap
+// bytes after it starts, or -1. Storing the distance rather than the offset
+// keeps it within an int32 however large the data, at 4 bytes per byte of
+// it. The offsets are taken in rounds of progressStep per worker; before
+// each round step is told how many are done and may stop the search,
+// leaving only the offsets before it. A round is split into one range per
+// worker, each scanned backwards with a rolling hash index of only the last
+// maxGap offsets, so the index stays in cache however large the data.
+func nearestRepeats(data []byte, size int, maxGap int, workers int, step func(int) bool) []int32 {
+	n := len(data) - size + 1
+	repeats := make([]int32, n)
+	for base := 0; base < n; base += workers * progressStep {
+		if step(base) {
+			return repeats[:base]
+		}
+		repeatsIn(data, repeats, size, maxGap, workers, base, min(base+workers*progressStep, n))
+	}
+	return repeats
+}
+
+// Fill repeats[base:end] with nearestRepeats' workers
+func repeatsIn(data []byte, repeats []int32, size int, maxGap int, workers int, base int, end int) {
+	n := len(repeats)
+	chunk := (end - base + workers - 1) / workers
+	var wg sync.WaitGroup
+	for from := base; from < end; from += chunk {
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+			window := 1
+			for window < maxGap {
+				window <<= 1
+			}
+			head := make([]int, 1<<repeatHashBits)
+			for i := range head {
+				head[i] = -1
+			}
+			chain := make([]int, window) // the next offset with the same hash
+			keys := make([]uint64, window)
+
+			// Start far enough past the range to see the repeats of its end
+			start := min(to+maxGap, n) - 1
+			key := patternKey(data[start : start+size])
+			for i := start; i >= from; i-- {
+				if i < start {
+					key = key>>8 | uint64(data[i])<<(8*(size-1))
+				}
+				h := int(hashPattern(key) >> (64 - repeatHashBits))
+				if i < to {
+					repeats[i] = -1
+					for p := head[h]; p >= 0 && p < i+maxGap; p = chain[p&(window-1)] {
+						if p >= i+size && keys[p&(window-1)] == key {
+							repeats[i] = int32(p - i)
+							break
+						}
+					}
+				}
+				chain[i&(window-1)] = head[h]
+				keys[i&(window-1)] = key
+				head[h] = i
+			}
+		}(from, min(from+chunk, end))
+	}
+	wg.Wait()
+}
+
+// The offset lists of the size-byte patterns in the given part of the hash
+// space, keyed by each pattern's first occurrence. The first pair of a
+// pattern starts its list and later ones only extend it with new positions.
+func gatherRepeats(data []byte, repeats []int32, size int, part int, parts int) map[int][]int {
+	found := make(map[int][]int)
+	firstSeen := make(map[uint64]int)
+	mask := ^uint64(0) >> (64 - 8*size)
+	var key uint64
+	for i := 0; i < min(len(data)-size*2, len(repeats)); i++ {
+		if i == 0 {
+			key = patternKey(data[:size])
+		} else {
+			key = (key<<8 | uint64(data[i+size-1])) & mask
+		}
+		if repeats[i] < 0 || int(hashPattern(key)%uint64(parts)) != part {
+			continue
+		}
+		j := i + int(repeats[i])
+
+		first, exists := firstSeen[key]
+		if !exists {
+			firstSeen[key] = i
+			found[i] = []int{i, j}
+		} else if positions := found[first]; j > positions[len(positions)-1] {
+			found[first] = append(positions, j)
+		}
+	}
+	return found
+}
+
+// Spread a pattern key over 64 bits for the rolling hash index and the
+// split among workers
+func hashPattern(key uint64) uint64 {
+	key ^= key >> 29
+	return key * 0x9E3779B97F4A7C15
+}
+
+// Pack a pattern of up to 8 bytes into a map key. Patterns of different
+// sizes are indexed in separate maps, so no length marker is needed.
+func patternKey(b []byte) uint64 {
+	var key uint64
+	for _, v := range b {
+		key = key<<8 | uint64(v)
+	}
+	return key
+}
@@ -0,0 +1,96 @@
+package fdi
+
+import (
+	"math/rand"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// Synthetic save-like data: 180-byte records with a marker, a name and random fields
+func benchmarkData(size int) []byte {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, size)
+	rng.Read(data)
+	for rec := 0; rec+180 <= size; rec += 180 {
+		copy(data[rec:], []byte{0x00, 0xFF, 0x00, 0xFF})
+		copy(data[rec+4:], "PLAYER NAME\x00\x00\x00\x00\x00")
+	}
+	return data
+}
+
+func TestFindRepeatPatterns(t *testing.T) {
+	data := []byte("\x01\x02abcdef\x01\x02ghijkl\x01\x02mnopqr")
+	patterns := FindRepeatPatterns(data)
+
+	for _, p := range patterns {
+		if string(p.Pattern) == "\x01\x02" {
+			if !reflect.DeepEqual(p.Offsets, []int{0, 8, 16}) || !reflect.DeepEqual(p.Distances, []int{8, 8}) {
+				t.Errorf("0102 at %v distances %v, want [0 8 16] and [8 8]", p.Offsets, p.Distances)
+			}
+			return
+		}
+	}
+	t.Errorf("pattern 0102 not found in %v", patterns)
+}
+
+func TestFindRepeatPatternsWorkers(t *testing.T) {
+	data := benchmarkData(1 << 16)
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	want := FindRepeatPatterns(data)
+	for _, procs := range []int{2, 3, 8} {
+		runtime.GOMAXPROCS(procs)
+		if got := FindRepeatPatterns(data); !reflect.DeepEqual(got, want) {
+			t.Errorf("with %d workers: %d patterns, want the %d of one", procs, len(got), len(want))
+		}
+	}
+}
+
+func TestPatternSearchDepth(t *testing.T) {
+	// Records longer than the default gap, with the marker far into the file
+	data := make([]byte, 3<<20)
+	for rec := 2 << 20; rec+1500 <= len(data); rec += 1500 {
+		copy(data[rec:], "\xA5\x5A\xC3\x3C")
+		data[rec+100] = 1
+	}
+	has := func(patterns []RepeatPattern) bool {
+		for _, p := range patterns {
+			if string(p.Pattern) == "\xA5\x5A\xC3\x3C" {
+				return true
+			}
+		}
+		return false
+	}
+	if has(FindRepeatPatternsWith(data, FastPatternSearch)) || has(FindRepeatPatterns(data)) {
+		t.Error("1500-byte records found beyond the fast range or the default gap")
+	}
+	if !has(FindRepeatPatternsWith(data, DeepPatternSearch)) {
+		t.Error("the deep search missed the 1500-byte records")
+	}
+}
+
+func BenchmarkFindRepeatPatterns(b *testing.B) {
+	for _, bm := range []struct {
+		name   string
+		search PatternSearch
+		procs  int
+	}{
+		{"default", DefaultPatternSearch, 0},
+		{"one-worker", DefaultPatternSearch, 1},
+		{"fast", FastPatternSearch, 0},
+		{"deep", DeepPatternSearch, 0},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			if bm.procs > 0 {
+				defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(bm.procs))
+			}
+			data := benchmarkData(4 << 20)
+			b.SetBytes(int64(len(data)))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				FindRepeatPatternsWith(data, bm.search)
+			}
+		})
+	}
+}
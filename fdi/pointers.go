@@ -0,0 +1,129 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"sort"
+)
+
+// MinPointerEntries is the fewest entries FindPointerTables reports a table
+// with; shorter increasing runs turn up by chance in any data.
+const MinPointerEntries = 8
+
+// PointerTable is a run of increasing 16- or 32-bit values that point into
+// the data: absolute offsets, or offsets from the start of the table.
+type PointerTable struct {
+	Offset   int    `json:"offset"`
+	Width    int    `json:"width"`  // 2 or 4
+	Endian   string `json:"endian"` // le or be
+	Relative bool   `json:"relative"`
+	Values   []int  `json:"values"`  // as stored
+	Targets  []int  `json:"targets"` // absolute offsets
+}
+
+// End returns the offset just past the table.
+func (t PointerTable) End() int {
+	return t.Offset + len(t.Values)*t.Width
+}
+
+// FindPointerTables looks for runs of at least minEntries strictly increasing
+// 16- or 32-bit values, in either byte order, that all point past the end
+// of the run and no further than the end of the data. A value equal to the
+// data length is allowed as an end marker. Runs that only work as offsets
+// from the start of the table are marked relative.
+func FindPointerTables(data []byte, minEntries int) []PointerTable {
+	if minEntries < 2 {
+		minEntries = 2
+	}
+	type variant struct {
+		width int
+		order binary.ByteOrder
+		name  string
+	}
+	variants := []variant{
+		{4, binary.LittleEndian, "le"}, {4, binary.BigEndian, "be"},
+		{2, binary.LittleEndian, "le"}, {2, binary.BigEndian, "be"},
+	}
+
+	var tables []PointerTable
+	for _, v := range variants {
+		read := func(at int) int {
+			if v.width == 2 {
+				return int(v.order.Uint16(data[at:]))
+			}
+			return int(v.order.Uint32(data[at:]))
+		}
+		for at := 0; at+v.width*minEntries <= len(data); at++ {
+			for _, relative := range []bool{false, true} {
+				t, ok := pointerRun(data, at, v.width, read, relative, minEntries)
+				if !ok {
+					continue
+				}
+				t.Endian = v.name
+				tables = append(tables, t)
+				at = t.End() - 1
+				break
+			}
+		}
+	}
+
+	// The same bytes often read as a table in more than one way, such as a
+	// u16be table one byte on as u16le; keep the longest, aligned if a tie
+	sort.SliceStable(tables, func(i, j int) bool {
+		a, b := tables[i], tables[j]
+		if len(a.Values) != len(b.Values) {
+			return len(a.Values) > len(b.Values)
+		}
+		return a.Offset%a.Width == 0 && b.Offset%b.Width != 0
+	})
+	var kept []PointerTable
+	for _, t := range tables {
+		overlaps := false
+		for _, k := range kept {
+			overlaps = overlaps || (t.Offset < k.End() && k.Offset < t.End())
+		}
+		if !overlaps {
+			kept = append(kept, t)
+		}
+	}
+	sort.Slice(kept, func(i, j int) bool { return kept[i].Offset < kept[j].Offset })
+	return kept
+}
+
+// The longest run of increasing pointers starting at at, if it has at least
+// minEntries entries
+func pointerRun(data []byte, at int, width int, read func(int) int, relative bool, minEntries int) (PointerTable, bool) {
+	if relative && at == 0 {
+		return PointerTable{}, false // the same as absolute
+	}
+	base := 0
+	if relative {
+		base = at
+	}
+
+	// Count first, since nearly every offset ends a run within an entry or two
+	n, prev := 0, -1
+	for pos := at; pos+width <= len(data); pos += width {
+		v := read(pos)
+		if v <= prev || base+v > len(data) {
+			break
+		}
+		prev = v
+		n++
+	}
+	// Entries pointing inside the table are not pointers to what it indexes;
+	// cut the run back until every target lies past its end
+	for n >= minEntries && base+read(at) < at+n*width {
+		n--
+	}
+	if n < minEntries {
+		return PointerTable{}, false
+	}
+
+	t := PointerTable{Offset: at, Width: width, Relative: relative}
+	for i := 0; i < n; i++ {
+		v := read(at + i*width)
+		t.Values = append(t.Values, v)
+		t.Targets = append(t.Targets, base+v)
+	}
+	return t, true
+}
@@ -0,0 +1,49 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestFindPointerTables(t *testing.T) {
+	names := strings.Fields("ROSSI BIANCHI VERDI ESPOSITO ROMANO COLOMBO RICCI MARINO GRECO BRUNO")
+
+	// An absolute u32le table at the start, followed by the strings it indexes
+	data := make([]byte, 4*len(names))
+	var want []int
+	for i, n := range names {
+		want = append(want, len(data))
+		binary.LittleEndian.PutUint32(data[4*i:], uint32(len(data)))
+		data = append(append(data, n...), 0)
+	}
+
+	// A u16be table of offsets from its own start, then its records
+	data = append(data, 0)
+	rel := len(data)
+	data = append(data, make([]byte, 2*9)...)
+	for i := 0; i < 9; i++ {
+		binary.BigEndian.PutUint16(data[rel+2*i:], uint16(len(data)-rel))
+		data = append(data, make([]byte, 5+i)...)
+	}
+
+	tables := FindPointerTables(data, MinPointerEntries)
+	if len(tables) != 2 {
+		t.Fatalf("FindPointerTables = %+v, want 2 tables", tables)
+	}
+	if tb := tables[0]; tb.Offset != 0 || tb.Width != 4 || tb.Endian != "le" || tb.Relative || len(tb.Targets) != len(names) || tb.Targets[3] != want[3] {
+		t.Errorf("absolute table = %+v", tb)
+	}
+	if tb := tables[1]; tb.Offset != rel || tb.Width != 2 || tb.Endian != "be" || !tb.Relative || len(tb.Targets) != 9 || tb.Targets[0] != rel+18 {
+		t.Errorf("relative table = %+v", tb)
+	}
+}
+
+func TestFindPointerTablesRandom(t *testing.T) {
+	data := make([]byte, 16384)
+	rand.New(rand.NewSource(5)).Read(data)
+	if tables := FindPointerTables(data, MinPointerEntries); len(tables) > 0 {
+		t.Errorf("found %d tables in random data: %+v", len(tables), tables[0])
+	}
+}
@@ -0,0 +1,31 @@
+package fdi
+
+import "context"
+
+// Progress follows the long passes of an analysis, to show how far they have
+// got and to stop them. A pass stopped by its Context returns what it found
+// in the part of the data it got through, marked as interrupted, rather than
+// nothing.
+type Progress struct {
+	Context context.Context // nil never stops
+
+	// Report is told the units a pass has done of its total, every few MiB
+	// and once more when it ends; nil reports nothing. It is called from one
+	// goroutine at a time.
+	Report func(pass string, done, total int)
+}
+
+// Stopped reports whether the passes should stop; never for a nil Progress.
+func (p *Progress) Stopped() bool {
+	return p != nil && p.Context != nil && p.Context.Err() != nil
+}
+
+// Advance tells Report how far a pass has got; nothing for a nil Progress.
+func (p *Progress) Advance(pass string, done, total int) {
+	if p != nil && p.Report != nil {
+		p.Report(pass, done, total)
+	}
+}
+
+// Bytes a pass gets through between looking at its Progress
+const progressStep = 1 << 20
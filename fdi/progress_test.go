@@ -0,0 +1,54 @@
+package fdi
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	// Records 64 bytes apart over a few rounds of the pattern search
+	rec := append([]byte("\xAA\x55PLAYER"), make([]byte, 56)...)
+	data := bytes.Repeat(rec, 3*progressStep/len(rec))
+
+	var last, calls int
+	p := &Progress{Report: func(pass string, done, total int) {
+		if pass != "records" || done < last || done > total {
+			t.Errorf("Report(%q, %d, %d) after %d", pass, done, total, last)
+		}
+		last, calls = done, calls+1
+		if done == total {
+			last = 0
+		}
+	}}
+	opts := AnalysisOptions{MinString: 4, Progress: p}
+	full := AnalyzeRange(data, 0, len(data), opts)
+	if full.Interrupted || calls == 0 {
+		t.Fatalf("uninterrupted analysis: interrupted %v, %d reports", full.Interrupted, calls)
+	}
+
+	// Stopped before the search starts, only the strings are found
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Context = ctx
+	res := AnalyzeRange(data, 0, len(data), opts)
+	if !res.Interrupted || len(res.Patterns) > len(full.Patterns) || len(res.Strings) != len(full.Strings) {
+		t.Errorf("stopped analysis: interrupted %v, %d patterns of %d, %d strings of %d",
+			res.Interrupted, len(res.Patterns), len(full.Patterns), len(res.Strings), len(full.Strings))
+	}
+	c := &Cache{Dir: t.TempDir()}
+	NewAnalyzer(data, opts).UseCache(c).Records(0, len(data))
+	var cached RecordReport
+	if c.Load(data, "records", struct {
+		Start, End int
+		Options    AnalysisOptions
+	}{0, len(data), opts}, &cached) {
+		t.Error("an interrupted analysis was cached")
+	}
+
+	p.Report = nil
+	dups, err := FindDuplicates(data, DuplicateOptions{Progress: p})
+	if err != nil || !dups.Interrupted {
+		t.Errorf("stopped FindDuplicates: interrupted %v, %v", dups.Interrupted, err)
+	}
+}
@@ -0,0 +1,63 @@
+package fdi
+
+import (
+	"bytes"
+	"sort"
+)
+
+// StrideCandidate is a distance that recurs between pattern occurrences.
+type StrideCandidate struct {
+	Stride int `json:"stride"`
+	Gaps   int `json:"gaps"`   // number of inter-occurrence gaps of exactly this size
+	Offset int `json:"offset"` // first offset a gap of this size starts at
+}
+
+// TallyStrides counts the distances between occurrences across all patterns,
+// most common first. Uniform patterns such as 0000 or FFFF are skipped since
+// padding runs would otherwise swamp the tally with tiny strides.
+func TallyStrides(patterns []RepeatPattern) []StrideCandidate {
+	tally := make(map[int]*StrideCandidate)
+
+	for _, p := range patterns {
+		if len(p.Pattern) == 0 || bytes.Count(p.Pattern, p.Pattern[:1]) == len(p.Pattern) {
+			continue
+		}
+		for i, dist := range p.Distances {
+			c, exists := tally[dist]
+			if !exists {
+				c = &StrideCandidate{Stride: dist, Offset: p.Offsets[i]}
+				tally[dist] = c
+			}
+			c.Gaps++
+			c.Offset = min(c.Offset, p.Offsets[i])
+		}
+	}
+
+	strides := make([]StrideCandidate, 0, len(tally))
+	for _, c := range tally {
+		strides = append(strides, *c)
+	}
+	sort.Slice(strides, func(i, j int) bool {
+		if strides[i].Gaps != strides[j].Gaps {
+			return strides[i].Gaps > strides[j].Gaps
+		}
+		return strides[i].Stride < strides[j].Stride
+	})
+	return strides
+}
+
+// LikelyRecordSize picks the dominant stride as the record length. It reports
+// false unless that stride accounts for at least three gaps and 30% of all.
+func LikelyRecordSize(strides []StrideCandidate) (StrideCandidate, bool) {
+	if len(strides) == 0 {
+		return StrideCandidate{}, false
+	}
+
+	total := 0
+	for _, c := range strides {
+		total += c.Gaps
+	}
+
+	best := strides[0]
+	return best, best.Gaps >= 3 && best.Gaps*10 >= total*3
+}
@@ -0,0 +1,145 @@
+package fdi
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// RegionWindow is the size of the window used to classify the region around an offset.
+const RegionWindow = 256
+
+// ClassifyRegion labels a block of bytes as fill, text, high-entropy or binary data.
+func ClassifyRegion(window []byte) string {
+	if len(window) == 0 {
+		return "empty"
+	}
+
+	same := true
+	text := 0
+	for _, b := range window {
+		if b != window[0] {
+			same = false
+		}
+		if IsTextByte(b) {
+			text++
+		}
+	}
+
+	switch {
+	case same:
+		return fmt.Sprintf("fill (0x%02X)", window[0])
+	case text*10 >= len(window)*9:
+		return "text"
+	case ShannonEntropy(window) >= 6.5:
+		return "high-entropy"
+	default:
+		return "binary"
+	}
+}
+
+// ShannonEntropy returns the entropy of data in bits per byte.
+func ShannonEntropy(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	entropy := 0.0
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(len(data))
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// Alignment returns the largest power-of-two boundary (up to 4096) offset is aligned to.
+func Alignment(offset int) int {
+	align := 1
+	for align < 4096 && offset%(align*2) == 0 {
+		align *= 2
+	}
+	return align
+}
+
+// PatternHit is a repeating byte sequence covering an offset.
+type PatternHit struct {
+	Offset  int      `json:"offset"`
+	Pattern HexBytes `json:"pattern"`
+	Repeats int      `json:"repeats"`
+}
+
+// OffsetInfo aggregates what the analyses know about a single offset.
+type OffsetInfo struct {
+	Offset int  `json:"offset"`
+	Value  byte `json:"value"`
+
+	Region      string  `json:"region"`
+	RegionStart int     `json:"region_start"`
+	RegionEnd   int     `json:"region_end"` // exclusive
+	Entropy     float64 `json:"entropy"`
+
+	Record     int `json:"record"` // -1 when the record size is unknown
+	RecordByte int `json:"record_byte"`
+
+	String    *FoundString `json:"string"`  // enclosing string, if any
+	Pattern   *PatternHit  `json:"pattern"` // largest repeating pattern covering the offset, if any
+	Alignment int          `json:"alignment"`
+}
+
+// Locate reports the region, record position, enclosing string, covering
+// pattern and alignment of offset. recordSize may be 0 if unknown.
+func Locate(data []byte, offset int, recordSize int) (OffsetInfo, error) {
+	if offset < 0 || offset >= len(data) {
+		return OffsetInfo{}, ErrOffsetOutOfRange
+	}
+
+	info := OffsetInfo{Offset: offset, Value: data[offset], Record: -1, Alignment: Alignment(offset)}
+
+	// Region classification of the surrounding window
+	info.RegionStart = offset - offset%RegionWindow
+	info.RegionEnd = min(info.RegionStart+RegionWindow, len(data))
+	window := data[info.RegionStart:info.RegionEnd]
+	info.Region = ClassifyRegion(window)
+	info.Entropy = ShannonEntropy(window)
+
+	if recordSize > 0 {
+		info.Record = offset / recordSize
+		info.RecordByte = offset % recordSize
+	}
+
+	// Enclosing text string
+	strStart, strEnd := offset, offset
+	for strStart > 0 && IsTextByte(data[strStart-1]) {
+		strStart--
+	}
+	for strEnd < len(data) && IsTextByte(data[strEnd]) {
+		strEnd++
+	}
+	if IsTextByte(data[offset]) && strEnd-strStart >= 4 {
+		info.String = &FoundString{Offset: strStart, Text: string(data[strStart:strEnd])}
+	}
+
+	// Largest repeating pattern covering the offset
+	for patternSize := 8; patternSize >= 2 && info.Pattern == nil; patternSize /= 2 {
+		for s := offset - patternSize + 1; s <= offset; s++ {
+			if s < 0 || s+patternSize > len(data) {
+				continue
+			}
+			pattern := data[s : s+patternSize]
+			if repeats := bytes.Count(data, pattern); repeats >= 3 {
+				info.Pattern = &PatternHit{Offset: s, Pattern: pattern, Repeats: repeats}
+				break
+			}
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,185 @@
+package fdi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// Version is a version of the .fdi format: the tables it is known to hold.
+type Version struct {
+	Name        string
+	Description string
+	Tables      []Layout
+}
+
+// ParseRegistry reads a registry of format versions from YAML. Each version
+// lists its tables as schemas, whose fields may give a range of plausible
+// values and labels for the values 0, 1, ...:
+//
+//	versions:
+//	  - name: liga
+//	    description: "64-byte players"
+//	    tables:
+//	      - name: players
+//	        description: "Player roster"
+//	        record_size: 64
+//	        fields:
+//	          - name: position
+//	            offset: 51
+//	            type: uint8
+//	            range: [0, 3]
+//	            labels: [GK, DF, MF, FW]
+func ParseRegistry(src []byte) ([]Version, error) {
+	doc, err := parseYAML(src)
+	if err != nil {
+		return nil, err
+	}
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return nil, errors.New("registry: expected a mapping at the top level")
+	}
+	list, ok := m["versions"].([]any)
+	if !ok || len(list) == 0 {
+		return nil, errors.New("registry: versions must be a list")
+	}
+
+	var versions []Version
+	for i, item := range list {
+		vm, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("registry: version %d is not a mapping", i)
+		}
+		var v Version
+		v.Name, _ = vm["name"].(string)
+		v.Description, _ = vm["description"].(string)
+		if v.Name == "" {
+			return nil, fmt.Errorf("registry: version %d has no name", i)
+		}
+		tables, ok := vm["tables"].([]any)
+		if !ok || len(tables) == 0 {
+			return nil, fmt.Errorf("version %s: tables must be a list", v.Name)
+		}
+		for j, t := range tables {
+			l, err := layoutFromYAML(t)
+			if err != nil {
+				return nil, fmt.Errorf("version %s: %v", v.Name, err)
+			}
+			if l.Name == "" {
+				return nil, fmt.Errorf("version %s: table %d has no name", v.Name, j)
+			}
+			v.Tables = append(v.Tables, l)
+		}
+		versions = append(versions, v)
+	}
+	return versions, nil
+}
+
+// ParseLayout reads the schema of a single table from YAML, whose fields
+// may give a range of plausible values and labels as in a registry.
+func ParseLayout(src []byte) (Layout, error) {
+	doc, err := parseYAML(src)
+	if err != nil {
+		return Layout{}, err
+	}
+	return layoutFromYAML(doc)
+}
+
+// Build a layout from a table of a registry or a schema file: its schema,
+// and the ranges and labels of its fields
+func layoutFromYAML(doc any) (Layout, error) {
+	s, err := schemaFromYAML(doc)
+	if err != nil {
+		return Layout{}, err
+	}
+	table := "table"
+	if s.Name != "" {
+		table += " " + s.Name
+	}
+	if err := s.validate(); err != nil {
+		return Layout{}, fmt.Errorf("%s: %v", table, err)
+	}
+
+	m := doc.(map[string]any)
+	l := Layout{Name: s.Name, Schema: s, Ranges: map[string][2]int{}, Labels: map[string][]string{}}
+	l.Description, _ = m["description"].(string)
+	for _, item := range m["fields"].([]any) {
+		fm := item.(map[string]any)
+		name, _ := fm["name"].(string)
+		if r, ok := fm["range"]; ok {
+			bounds, ok := r.([]any)
+			if !ok || len(bounds) != 2 {
+				return Layout{}, fmt.Errorf("%s: field %q: range must be [lo, hi]", table, name)
+			}
+			var lohi [2]int
+			for i, b := range bounds {
+				str, _ := b.(string)
+				n, err := strconv.ParseInt(str, 0, 64)
+				if err != nil {
+					return Layout{}, fmt.Errorf("%s: field %q: range: expected a number, got %q", table, name, str)
+				}
+				lohi[i] = int(n)
+			}
+			if lohi[0] > lohi[1] {
+				return Layout{}, fmt.Errorf("%s: field %q: range %d to %d is empty", table, name, lohi[0], lohi[1])
+			}
+			l.Ranges[name] = lohi
+		}
+		if labels, ok := fm["labels"].([]any); ok {
+			for _, label := range labels {
+				str, _ := label.(string)
+				l.Labels[name] = append(l.Labels[name], str)
+			}
+		}
+	}
+	return l, nil
+}
+
+// TableMatch is how well one table of a version is found in a file.
+type TableMatch struct {
+	Table    string  `json:"table"`
+	Found    bool    `json:"found"`
+	Start    int     `json:"start,omitempty"` // of the run of plausible records
+	Records  int     `json:"records,omitempty"`
+	Distinct int     `json:"distinct,omitempty"` // records that differ from one another
+	Score    float64 `json:"score"`
+}
+
+// VersionMatch is how well a version of the format fits a file.
+type VersionMatch struct {
+	Version     string       `json:"version"`
+	Description string       `json:"description,omitempty"`
+	Confidence  float64      `json:"confidence"` // 0 to 1
+	Tables      []TableMatch `json:"tables"`
+}
+
+// IdentifyVersion tests each version against the file by looking for its
+// tables, whose records must hold printable names and values within their
+// ranges, and returns the versions best match first. A table found with n
+// distinct records scores n/(n+MinLayoutRecords), so that longer runs are
+// surer, and one not found 0; a run of fewer distinct records, such as
+// filler that happens to fit, is not taken. The confidence of a version is
+// the mean of its tables.
+func IdentifyVersion(data []byte, versions []Version) []VersionMatch {
+	matches := make([]VersionMatch, 0, len(versions))
+	for _, v := range versions {
+		m := VersionMatch{Version: v.Name, Description: v.Description}
+		for _, l := range v.Tables {
+			t := TableMatch{Table: l.Name}
+			if start, count, ok := FindLayout(data, l); ok {
+				n := distinctRecords(data, start, count, l.Schema.RecordSize)
+				t.Found, t.Start, t.Records, t.Distinct = true, start, count, n
+				t.Score = float64(n) / float64(n+MinLayoutRecords)
+			}
+			m.Confidence += t.Score
+			m.Tables = append(m.Tables, t)
+		}
+		if len(v.Tables) > 0 {
+			m.Confidence /= float64(len(v.Tables))
+		}
+		matches = append(matches, m)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+	return matches
+}
@@ -0,0 +1,89 @@
+package fdi
+
+import "testing"
+
+// Two versions of a save told apart by the size of their player records
+const testRegistry = `
+versions:
+  - name: roster
+    description: "64-byte players and 96-byte teams"
+    tables:
+      - name: players
+        record_size: 64
+        fields:
+          - name: id
+            offset: 0
+            type: uint16
+          - name: name
+            offset: 4
+            type: string
+            length: 16
+          - name: position
+            offset: 51
+            type: uint8
+            range: [0, 3]
+            labels: [GK, DF, MF, FW]
+          - name: age
+            offset: 52
+            type: uint8
+            range: [14, 50]
+      - name: teams
+        record_size: 96
+        fields:
+          - name: name
+            offset: 2
+            type: string
+            length: 30
+  - name: demo
+    description: "A version whose players hold only a short name"
+    tables:
+      - name: players
+        record_size: 32
+        fields:
+          - name: name
+            offset: 0
+            type: string
+            length: 16
+          - name: rating
+            offset: 16
+            type: uint8
+            range: [0, 99]
+`
+
+func TestIdentifyVersion(t *testing.T) {
+	versions, err := ParseRegistry([]byte(testRegistry))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 2 || versions[1].Tables[0].Ranges["rating"] != [2]int{0, 99} ||
+		versions[0].Tables[0].Labels["position"][3] != "FW" {
+		t.Fatalf("ParseRegistry = %+v", versions)
+	}
+
+	data := make([]byte, 0x105)
+	for i, name := range []string{"ROSSI", "BAGGIO", "MALDINI", "BARESI", "ZOFF", "SCIREA"} {
+		data = append(data, playerRecord(i+1, name, byte(i%4), 20+byte(i), 80)...)
+	}
+	// Listed second, the version that fits still comes first
+	matches := IdentifyVersion(data, []Version{versions[1], versions[0]})
+	if len(matches) != 2 || matches[0].Version != "roster" || matches[0].Confidence <= matches[1].Confidence {
+		t.Fatalf("IdentifyVersion = %+v, want roster first", matches)
+	}
+	best := matches[0]
+	if !best.Tables[0].Found || best.Tables[0].Start != 0x105 || best.Tables[0].Records != 6 || best.Tables[1].Found {
+		t.Errorf("tables = %+v, want players at 0x105 with 6 records and no teams", best.Tables)
+	}
+	if want := 0.6 / 2; best.Confidence < want-1e-9 || best.Confidence > want+1e-9 {
+		t.Errorf("confidence = %v, want %v", best.Confidence, want)
+	}
+
+	for _, src := range []string{
+		"versions: []",
+		"versions:\n  - name: x\n    tables:\n      - name: t\n        fields:\n          - name: a\n            offset: 0\n            type: uint8\n            range: [5]\n",
+		"versions:\n  - name: x\n    tables:\n      - name: t\n        fields:\n          - name: a\n            offset: 0\n            type: nope\n",
+	} {
+		if _, err := ParseRegistry([]byte(src)); err == nil {
+			t.Errorf("ParseRegistry(%q) gave no error", src)
+		}
+	}
+}
@@ -0,0 +1,567 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Schema describes the layout of a table of fixed-size records.
+type Schema struct {
+	Name       string  `json:"name,omitempty"`
+	Start      int     `json:"start"`       // offset of the first record
+	RecordSize int     `json:"record_size"` // 0 means the extent of the fields
+	Count      int     `json:"count"`       // number of records; 0 means as many as fit
+	Fields     []Field `json:"fields"`
+}
+
+// Field is one named value within a record.
+type Field struct {
+	Name     string `json:"name"`
+	Offset   int    `json:"offset"` // within the record
+	Type     string `json:"type"`   // see FieldTypes
+	Length   int    `json:"length,omitempty"`
+	Endian   string `json:"endian,omitempty"`   // little (default) or big
+	Encoding string `json:"encoding,omitempty"` // for strings: ascii (default), utf16le or a codepage name
+
+	// A bit field takes Bits bits of its unsigned integer from BitOffset
+	// up, bit 0 being the least significant, so several can share a byte
+	BitOffset int `json:"bit_offset,omitempty"`
+	Bits      int `json:"bits,omitempty"`
+}
+
+// FieldTypes lists the field types a schema may use. string, bytes and bcd
+// need a length; the others have a fixed size.
+var FieldTypes = []string{
+	"uint8", "int8", "uint16", "int16", "uint32", "int32", "uint64", "int64",
+	"float32", "float64", "string", "bytes", "bcd",
+}
+
+// Sizes of the fixed-width field types
+var fieldTypeSizes = map[string]int{
+	"uint8": 1, "int8": 1, "uint16": 2, "int16": 2, "uint32": 4, "int32": 4,
+	"uint64": 8, "int64": 8, "float32": 4, "float64": 8,
+}
+
+// ParseSchema reads a schema from YAML (or JSON, which it also accepts):
+//
+//	name: players
+//	start: 0x400
+//	record_size: 180
+//	fields:
+//	  - name: id
+//	    offset: 0
+//	    type: uint16
+//	  - name: surname
+//	    offset: 4
+//	    type: string
+//	    length: 16
+//	    encoding: cp1252
+//	  - name: foot
+//	    offset: 20
+//	    type: uint8
+//	    bit_offset: 6
+//	    bits: 2
+func ParseSchema(src []byte) (Schema, error) {
+	var s Schema
+	if trimmed := strings.TrimSpace(string(src)); strings.HasPrefix(trimmed, "{") {
+		if err := json.Unmarshal(src, &s); err != nil {
+			return Schema{}, err
+		}
+	} else {
+		doc, err := parseYAML(src)
+		if err != nil {
+			return Schema{}, err
+		}
+		if s, err = schemaFromYAML(doc); err != nil {
+			return Schema{}, err
+		}
+	}
+	return s, s.validate()
+}
+
+// Build a schema from a parsed YAML document
+func schemaFromYAML(doc any) (Schema, error) {
+	m, ok := doc.(map[string]any)
+	if !ok {
+		return Schema{}, errors.New("schema: expected a mapping at the top level")
+	}
+
+	var s Schema
+	var err error
+	s.Name, _ = m["name"].(string)
+	if s.Start, err = yamlInt(m, "start"); err != nil {
+		return Schema{}, err
+	}
+	if s.RecordSize, err = yamlInt(m, "record_size"); err != nil {
+		return Schema{}, err
+	}
+	if s.Count, err = yamlInt(m, "count"); err != nil {
+		return Schema{}, err
+	}
+
+	list, ok := m["fields"].([]any)
+	if !ok {
+		return Schema{}, errors.New("schema: fields must be a list")
+	}
+	for i, item := range list {
+		fm, ok := item.(map[string]any)
+		if !ok {
+			return Schema{}, fmt.Errorf("schema: field %d is not a mapping", i)
+		}
+		f := Field{}
+		f.Name, _ = fm["name"].(string)
+		f.Type, _ = fm["type"].(string)
+		f.Endian, _ = fm["endian"].(string)
+		f.Encoding, _ = fm["encoding"].(string)
+		if f.Offset, err = yamlInt(fm, "offset"); err != nil {
+			return Schema{}, fmt.Errorf("field %q: %v", f.Name, err)
+		}
+		if f.Length, err = yamlInt(fm, "length"); err != nil {
+			return Schema{}, fmt.Errorf("field %q: %v", f.Name, err)
+		}
+		if f.BitOffset, err = yamlInt(fm, "bit_offset"); err != nil {
+			return Schema{}, fmt.Errorf("field %q: %v", f.Name, err)
+		}
+		if f.Bits, err = yamlInt(fm, "bits"); err != nil {
+			return Schema{}, fmt.Errorf("field %q: %v", f.Name, err)
+		}
+		s.Fields = append(s.Fields, f)
+	}
+	return s, nil
+}
+
+// A decimal or 0x-prefixed integer value, 0 when absent
+func yamlInt(m map[string]any, key string) (int, error) {
+	v, ok := m[key]
+	if !ok || v == nil {
+		return 0, nil
+	}
+	str, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("%s: expected a number", key)
+	}
+	n, err := strconv.ParseInt(str, 0, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: expected a non-negative decimal or 0x-prefixed hex number, got %q", key, str)
+	}
+	return int(n), nil
+}
+
+// Check the fields and fill in the record size when it is not given
+func (s *Schema) validate() error {
+	if len(s.Fields) == 0 {
+		return errors.New("schema: no fields defined")
+	}
+
+	extent := 0
+	names := make(map[string]bool)
+	for i := range s.Fields {
+		f := &s.Fields[i]
+		if f.Name == "" {
+			return fmt.Errorf("schema: field %d has no name", i)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("schema: duplicate field %q", f.Name)
+		}
+		names[f.Name] = true
+
+		size := f.Size()
+		switch {
+		case f.Type == "string" || f.Type == "bytes" || f.Type == "bcd":
+			if f.Length <= 0 {
+				return fmt.Errorf("field %q: type %s needs a length", f.Name, f.Type)
+			}
+		case size == 0:
+			return fmt.Errorf("field %q: unknown type %q (supported: %s)", f.Name, f.Type, strings.Join(FieldTypes, ", "))
+		}
+
+		switch strings.ToLower(f.Endian) {
+		case "", "little", "le", "big", "be":
+		default:
+			return fmt.Errorf("field %q: endian must be little or big, got %q", f.Name, f.Endian)
+		}
+		if f.Type == "string" && fieldDecoder(f.Encoding) == nil {
+			return fmt.Errorf("field %q: unknown encoding %q", f.Name, f.Encoding)
+		}
+		if f.Bits > 0 || f.BitOffset > 0 {
+			if !strings.HasPrefix(f.Type, "uint") {
+				return fmt.Errorf("field %q: bit fields need an unsigned integer type, got %s", f.Name, f.Type)
+			}
+			if f.Bits <= 0 || f.BitOffset+f.Bits > 8*size {
+				return fmt.Errorf("field %q: bits %d from bit %d do not fit in a %s", f.Name, f.Bits, f.BitOffset, f.Type)
+			}
+		}
+		extent = max(extent, f.Offset+size)
+	}
+
+	if s.RecordSize == 0 {
+		s.RecordSize = extent
+	} else if extent > s.RecordSize {
+		return fmt.Errorf("schema: fields extend to byte %d, past the record size %d", extent, s.RecordSize)
+	}
+	return nil
+}
+
+// Size returns the number of bytes the field occupies.
+func (f Field) Size() int {
+	if size, ok := fieldTypeSizes[f.Type]; ok {
+		return size
+	}
+	return f.Length
+}
+
+// IsBitField reports whether the field takes only some bits of its bytes.
+func (f Field) IsBitField() bool { return f.Bits > 0 }
+
+func (f Field) byteOrder() binary.ByteOrder {
+	switch strings.ToLower(f.Endian) {
+	case "big", "be":
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+// Decoder for a string field's encoding, or nil if the encoding is unknown
+func fieldDecoder(encoding string) func([]byte) string {
+	switch strings.ToLower(encoding) {
+	case "", "ascii":
+		return func(b []byte) string { return string(b) }
+	case "utf16le", "utf-16le":
+		return func(b []byte) string {
+			units := make([]uint16, len(b)/2)
+			for i := range units {
+				units[i] = binary.LittleEndian.Uint16(b[2*i:])
+			}
+			return string(utf16.Decode(units))
+		}
+	}
+	if cp, ok := LookupCodepage(encoding); ok {
+		return cp.DecodeString
+	}
+	return nil
+}
+
+// FieldValue is a decoded field.
+type FieldValue struct {
+	Name   string `json:"name"`
+	Offset int    `json:"offset"` // absolute
+	Value  any    `json:"value"`  // uint64, int64, float64, string (text and bcd) or HexBytes
+}
+
+// MarshalJSON writes non-finite floats as strings, which JSON numbers cannot hold.
+func (v FieldValue) MarshalJSON() ([]byte, error) {
+	type plain FieldValue
+	v.Value = jsonValue(v.Value)
+	return marshalUnescaped(plain(v))
+}
+
+// A decoded value that JSON can hold: non-finite floats become strings
+func jsonValue(v any) any {
+	if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return v
+}
+
+// Marshal without HTML escaping so decoded text stays readable
+func marshalUnescaped(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// Record is one decoded record.
+type Record struct {
+	Index  int          `json:"index"`
+	Offset int          `json:"offset"`
+	Fields []FieldValue `json:"fields"`
+}
+
+// DecodeRecords decodes the records the schema describes. Decoding stops at
+// the last record that fits in the data.
+func DecodeRecords(data []byte, s Schema) ([]Record, error) {
+	if s.RecordSize <= 0 {
+		return nil, ErrNoRecordSize
+	}
+	if s.Start >= len(data) {
+		return nil, ErrOffsetOutOfRange
+	}
+
+	n := (len(data) - s.Start) / s.RecordSize
+	if s.Count > 0 {
+		n = min(n, s.Count)
+	}
+
+	records := make([]Record, 0, n)
+	for i := 0; i < n; i++ {
+		base := s.Start + i*s.RecordSize
+		rec := Record{Index: i, Offset: base, Fields: make([]FieldValue, 0, len(s.Fields))}
+		for _, f := range s.Fields {
+			raw := data[base+f.Offset : base+f.Offset+f.Size()]
+			rec.Fields = append(rec.Fields, FieldValue{Name: f.Name, Offset: base + f.Offset, Value: decodeField(f, raw)})
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// FieldSpan is a run of bytes of a record: a field with its decoded value,
+// or bytes no field of the schema maps.
+type FieldSpan struct {
+	Name    string   `json:"name,omitempty"`
+	Offset  int      `json:"offset"` // absolute
+	Size    int      `json:"size"`
+	Bytes   HexBytes `json:"bytes"`
+	Value   any      `json:"value,omitempty"`
+	Unknown bool     `json:"unknown,omitempty"` // no field maps the bytes
+}
+
+// MarshalJSON writes non-finite floats as strings, as FieldValue does.
+func (f FieldSpan) MarshalJSON() ([]byte, error) {
+	type plain FieldSpan
+	f.Value = jsonValue(f.Value)
+	return marshalUnescaped(plain(f))
+}
+
+// Breakdown decodes record n of the table field by field, in order of
+// offset, with each run of bytes no field covers as an unknown span, so that
+// the gaps of an incomplete schema show. Bit fields sharing bytes each get
+// their own span.
+func (s Schema) Breakdown(data []byte, n int) ([]FieldSpan, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	base := s.Start + n*s.RecordSize
+	if n < 0 || base+s.RecordSize > len(data) {
+		return nil, ErrOffsetOutOfRange
+	}
+
+	fields := append([]Field(nil), s.Fields...)
+	sort.SliceStable(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	covered := make([]bool, s.RecordSize)
+	var spans []FieldSpan
+	for _, f := range fields {
+		raw := data[base+f.Offset : base+f.Offset+f.Size()]
+		spans = append(spans, FieldSpan{Name: f.Name, Offset: base + f.Offset, Size: f.Size(), Bytes: bytes.Clone(raw), Value: decodeField(f, raw)})
+		for i := f.Offset; i < f.Offset+f.Size(); i++ {
+			covered[i] = true
+		}
+	}
+	for i := 0; i < s.RecordSize; {
+		if covered[i] {
+			i++
+			continue
+		}
+		j := i
+		for j < s.RecordSize && !covered[j] {
+			j++
+		}
+		spans = append(spans, FieldSpan{Offset: base + i, Size: j - i, Bytes: bytes.Clone(data[base+i : base+j]), Unknown: true})
+		i = j
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].Offset < spans[j].Offset })
+	return spans, nil
+}
+
+// Interpret raw as the field's type
+func decodeField(f Field, raw []byte) any {
+	order := f.byteOrder()
+	if f.IsBitField() {
+		return fieldUint(f, raw) >> f.BitOffset & (1<<f.Bits - 1)
+	}
+	switch f.Type {
+	case "uint8":
+		return uint64(raw[0])
+	case "int8":
+		return int64(int8(raw[0]))
+	case "uint16":
+		return uint64(order.Uint16(raw))
+	case "int16":
+		return int64(int16(order.Uint16(raw)))
+	case "uint32":
+		return uint64(order.Uint32(raw))
+	case "int32":
+		return int64(int32(order.Uint32(raw)))
+	case "uint64":
+		return order.Uint64(raw)
+	case "int64":
+		return int64(order.Uint64(raw))
+	case "float32":
+		return float64(math.Float32frombits(order.Uint32(raw)))
+	case "float64":
+		return math.Float64frombits(order.Uint64(raw))
+	case "string":
+		end := len(raw)
+		if strings.EqualFold(f.Encoding, "utf16le") || strings.EqualFold(f.Encoding, "utf-16le") {
+			for i := 0; i+1 < len(raw); i += 2 {
+				if raw[i] == 0 && raw[i+1] == 0 {
+					end = i
+					break
+				}
+			}
+		} else if i := strings.IndexByte(string(raw), 0); i >= 0 {
+			end = i
+		}
+		return strings.TrimRight(fieldDecoder(f.Encoding)(raw[:end]), " ")
+	case "bcd":
+		for _, b := range raw {
+			if !IsBCDByte(b) {
+				return HexBytes(raw) // not valid BCD, show the bytes
+			}
+		}
+		digits, _ := DecodeBCD(raw)
+		return digits
+	}
+	return HexBytes(raw)
+}
+
+// The bytes of an unsigned integer field as a number
+func fieldUint(f Field, raw []byte) uint64 {
+	var v uint64
+	for i := range raw {
+		b := raw[i]
+		if f.byteOrder() == binary.LittleEndian {
+			b = raw[len(raw)-1-i]
+		}
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+// Field looks up a field by name.
+func (s Schema) Field(name string) (Field, bool) {
+	for _, f := range s.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Field{}, false
+}
+
+// SetField returns the patch that stores value in the named field of the
+// given record. See EncodeField for the accepted values. data is the
+// current contents, whose other bits a bit field keeps; it may be nil when
+// the schema has no bit fields.
+func (s Schema) SetField(data []byte, record int, name string, value string) (Patch, error) {
+	f, ok := s.Field(name)
+	if !ok {
+		return Patch{}, fmt.Errorf("schema has no field %q", name)
+	}
+	if record < 0 || (s.Count > 0 && record >= s.Count) {
+		return Patch{}, fmt.Errorf("record %d is out of range", record)
+	}
+	offset := s.Start + record*s.RecordSize + f.Offset
+	if f.IsBitField() {
+		if offset+f.Size() > len(data) {
+			return Patch{}, fmt.Errorf("record %d is out of range", record)
+		}
+		raw, err := EncodeBits(f, data[offset:offset+f.Size()], value)
+		if err != nil {
+			return Patch{}, fmt.Errorf("field %q: %v", name, err)
+		}
+		return Patch{Offset: offset, Bytes: raw}, nil
+	}
+	raw, err := EncodeField(f, value)
+	if err != nil {
+		return Patch{}, fmt.Errorf("field %q: %v", name, err)
+	}
+	return Patch{Offset: offset, Bytes: raw}, nil
+}
+
+// EncodeBits stores value, a number, in the bits of a bit field and returns
+// the field's bytes with the other bits of old left as they were.
+func EncodeBits(f Field, old []byte, value string) ([]byte, error) {
+	n, err := strconv.ParseUint(value, 0, 64)
+	if err != nil || (f.Bits < 64 && n >= 1<<f.Bits) {
+		return nil, fmt.Errorf("expected a number from 0 to %d for %d bits, got %q", uint64(1)<<f.Bits-1, f.Bits, value)
+	}
+	mask := uint64(1<<f.Bits-1) << f.BitOffset
+	v := fieldUint(f, old)&^mask | n<<f.BitOffset
+
+	raw := make([]byte, len(old))
+	for i := range raw {
+		b := byte(v >> (8 * i))
+		if f.byteOrder() == binary.LittleEndian {
+			raw[i] = b
+		} else {
+			raw[len(raw)-1-i] = b
+		}
+	}
+	return raw, nil
+}
+
+// EncodeField encodes value as the field's type: a number for the numeric
+// types, text for strings (zero-padded to the length), hex for bytes and
+// decimal digits for bcd (zero-padded on the left).
+func EncodeField(f Field, value string) ([]byte, error) {
+	switch f.Type {
+	case "string":
+		var raw []byte
+		switch strings.ToLower(f.Encoding) {
+		case "", "ascii":
+			raw = []byte(value)
+		case "utf16le", "utf-16le":
+			raw = EncodeUTF16LE(value)
+		default:
+			cp, ok := LookupCodepage(f.Encoding)
+			if !ok {
+				return nil, fmt.Errorf("unknown encoding %q", f.Encoding)
+			}
+			var err error
+			if raw, err = cp.EncodeString(value); err != nil {
+				return nil, err
+			}
+		}
+		if len(raw) > f.Length {
+			return nil, fmt.Errorf("%q needs %d bytes, the field holds %d", value, len(raw), f.Length)
+		}
+		return append(raw, make([]byte, f.Length-len(raw))...), nil
+
+	case "bytes":
+		raw, err := ParseHexPattern(value)
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) != f.Length {
+			return nil, fmt.Errorf("expected %d bytes of hex, got %d", f.Length, len(raw))
+		}
+		return raw, nil
+
+	case "bcd":
+		if len(value) > 2*f.Length || strings.Trim(value, "0123456789") != "" {
+			return nil, fmt.Errorf("expected up to %d decimal digits, got %q", 2*f.Length, value)
+		}
+		digits := strings.Repeat("0", 2*f.Length-len(value)) + value
+		raw := make([]byte, f.Length)
+		for i := range raw {
+			raw[i] = (digits[2*i]-'0')<<4 | (digits[2*i+1] - '0')
+		}
+		return raw, nil
+	}
+
+	size, ok := fieldTypeSizes[f.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown type %q", f.Type)
+	}
+	// uint16 big endian is the value type u16be, and so on
+	typ := f.Type[:1] + strconv.Itoa(size*8)
+	if size > 1 {
+		typ += "le"
+		if f.byteOrder() == binary.BigEndian {
+			typ = typ[:len(typ)-2] + "be"
+		}
+	}
+	return EncodeValue(value, typ)
+}
@@ -0,0 +1,230 @@
+package fdi
+
+import "testing"
+
+const testSchema = `
+name: players
+start: 4
+record_size: 12
+fields:
+  - name: id
+    offset: 0
+    type: uint16
+  - name: score
+    offset: 2
+    type: int16
+    endian: big
+  - name: name
+    offset: 4
+    type: string
+    length: 6
+  - name: money
+    offset: 10
+    type: bcd
+    length: 2
+`
+
+func TestDecodeRecords(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("HDR\x00" +
+		"\x01\x00\xFF\xFEROSSI\x00\x12\x34" +
+		"\x02\x00\x00\x0ABONUCC\x99\x00" +
+		"\x03\x00") // truncated third record
+	records, err := DecodeRecords(data, schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	want := [][]any{
+		{uint64(1), int64(-2), "ROSSI", "1234"},
+		{uint64(2), int64(10), "BONUCC", "9900"},
+	}
+	for i, rec := range records {
+		if rec.Offset != 4+i*12 {
+			t.Errorf("record %d offset = %d", i, rec.Offset)
+		}
+		for j, f := range rec.Fields {
+			if f.Value != want[i][j] {
+				t.Errorf("record %d field %s = %#v, want %#v", i, f.Name, f.Value, want[i][j])
+			}
+		}
+	}
+}
+
+func TestBreakdown(t *testing.T) {
+	schema, err := ParseSchema([]byte(`
+start: 2
+record_size: 16
+fields:
+  - name: name
+    offset: 4
+    type: string
+    length: 6
+  - name: id
+    offset: 0
+    type: uint16
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("HD" + "\x01\x00\xAA\xBBROSSI\x00\x00\x00\x00\x00\x00\x07" +
+		"\x02\x00\x00\x00BARESI\x00\x00\x00\x00\x00\x00")
+
+	spans, err := schema.Breakdown(data, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldSpan{
+		{Name: "id", Offset: 2, Size: 2, Value: uint64(1)},
+		{Offset: 4, Size: 2, Unknown: true},
+		{Name: "name", Offset: 6, Size: 6, Value: "ROSSI"},
+		{Offset: 12, Size: 6, Unknown: true},
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("Breakdown = %+v", spans)
+	}
+	for i, w := range want {
+		got := spans[i]
+		if got.Name != w.Name || got.Offset != w.Offset || got.Size != w.Size || got.Value != w.Value || got.Unknown != w.Unknown {
+			t.Errorf("span %d = %+v, want %+v", i, got, w)
+		}
+	}
+	if string(spans[1].Bytes) != "\xAA\xBB" || spans[3].Bytes[5] != 7 {
+		t.Errorf("unknown bytes = %X, %X", spans[1].Bytes, spans[3].Bytes)
+	}
+
+	if _, err := schema.Breakdown(data, 2); err != ErrOffsetOutOfRange {
+		t.Errorf("a record past the end gave %v", err)
+	}
+}
+
+func TestParseSchemaJSON(t *testing.T) {
+	schema, err := ParseSchema([]byte(`{"fields": [{"name": "a", "offset": 2, "type": "uint32"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.RecordSize != 6 {
+		t.Errorf("record size = %d, want the field extent 6", schema.RecordSize)
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown type":    "fields:\n  - name: a\n    type: uint24\n",
+		"missing length":  "fields:\n  - name: a\n    type: string\n",
+		"past the record": "record_size: 2\nfields:\n  - name: a\n    type: uint32\n",
+		"bad endian":      "fields:\n  - name: a\n    type: uint16\n    endian: middle\n",
+		"bad encoding":    "fields:\n  - name: a\n    type: string\n    length: 4\n    encoding: ebcdic\n",
+		"bad number":      "fields:\n  - name: a\n    type: uint8\n    offset: ten\n",
+		"no fields":       "name: empty\nfields: []\n",
+		"duplicate field": "fields:\n  - name: a\n    type: uint8\n  - name: a\n    type: uint8\n",
+	}
+	for name, src := range tests {
+		if _, err := ParseSchema([]byte(src)); err == nil {
+			t.Errorf("%s: ParseSchema succeeded, want an error", name)
+		}
+	}
+}
+
+func TestSetField(t *testing.T) {
+	schema, err := ParseSchema([]byte(testSchema))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		record      int
+		field, val  string
+		wantOffset  int
+		wantEncoded string
+	}{
+		{0, "id", "0x102", 4, "\x02\x01"},
+		{1, "score", "-2", 18, "\xFF\xFE"},
+		{0, "name", "BAGGIO", 8, "BAGGIO"},
+		{0, "name", "ZOFF", 8, "ZOFF\x00\x00"},
+		{1, "money", "987", 26, "\x09\x87"},
+	}
+	for _, tt := range tests {
+		p, err := schema.SetField(nil, tt.record, tt.field, tt.val)
+		if err != nil {
+			t.Errorf("SetField(%d, %s, %q): %v", tt.record, tt.field, tt.val, err)
+			continue
+		}
+		if p.Offset != tt.wantOffset || string(p.Bytes) != tt.wantEncoded {
+			t.Errorf("SetField(%d, %s, %q) = 0x%X %X, want 0x%X %X", tt.record, tt.field, tt.val, p.Offset, p.Bytes, tt.wantOffset, tt.wantEncoded)
+		}
+	}
+
+	for _, bad := range [][2]string{{"name", "MALDINI"}, {"id", "70000"}, {"money", "12345"}, {"money", "1a"}, {"age", "30"}} {
+		if _, err := schema.SetField(nil, 0, bad[0], bad[1]); err == nil {
+			t.Errorf("SetField(0, %s, %q) succeeded, want an error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestBitFields(t *testing.T) {
+	schema, err := ParseSchema([]byte(`
+record_size: 2
+fields:
+  - name: foot
+    offset: 0
+    type: uint8
+    bit_offset: 6
+    bits: 2
+  - name: role
+    offset: 0
+    type: uint8
+    bits: 3
+  - name: form
+    offset: 0
+    type: uint16
+    endian: big
+    bit_offset: 4
+    bits: 8
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte{0b10_101_011, 0x45}
+	records, _ := DecodeRecords(data, schema)
+	for i, want := range []uint64{2, 3, 0xB4} {
+		if got := records[0].Fields[i].Value; got != want {
+			t.Errorf("%s = %v, want %v", schema.Fields[i].Name, got, want)
+		}
+	}
+
+	p, err := schema.SetField(data, 0, "role", "5")
+	if err != nil || p.Offset != 0 || string(p.Bytes) != "\xAD" {
+		t.Errorf("SetField(role, 5) = %X, %v, want AD keeping the other bits", p.Bytes, err)
+	}
+	if p, err := schema.SetField(data, 0, "form", "0x12"); err != nil || string(p.Bytes) != "\xA1\x25" {
+		t.Errorf("SetField(form, 0x12) = %X, %v", p.Bytes, err)
+	}
+	if _, err := schema.SetField(data, 0, "foot", "4"); err == nil {
+		t.Error("SetField(foot, 4) fits 4 in 2 bits")
+	}
+
+	for _, bad := range []string{"type: int8\n    bits: 3", "type: uint8\n    bit_offset: 6\n    bits: 3", "type: uint8\n    bit_offset: 2"} {
+		if _, err := ParseSchema([]byte("fields:\n  - name: x\n    offset: 0\n    " + bad + "\n")); err == nil {
+			t.Errorf("schema with %q parsed", bad)
+		}
+	}
+}
+
+func TestEncodeFieldCodepage(t *testing.T) {
+	f := Field{Name: "name", Type: "string", Length: 6, Encoding: "cp437"}
+	raw, err := EncodeField(f, "MÜLLER")
+	if err != nil || string(raw) != "M\x9ALLER" {
+		t.Errorf("EncodeField(MÜLLER, cp437) = %X, %v", raw, err)
+	}
+	if _, err := EncodeField(f, "€"); err == nil {
+		t.Error("EncodeField(€, cp437) succeeded, want an error")
+	}
+}
@@ -0,0 +1,151 @@
+package fdi
+
+import (
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Bytes of context kept on each side of a search hit
+const contextSize = 16
+
+// SearchOptions controls how search patterns are matched.
+type SearchOptions struct {
+	IgnoreCase bool   // fold ASCII case when comparing
+	UTF16      bool   // the pattern is UTF-16LE text
+	NoOverlap  bool   // resume scanning after the end of each match
+	MaxEdits   int    // allow up to this many inserted, deleted or changed bytes
+	Wild       []bool // pattern bytes that match any byte, as from ParseHexWildcards
+}
+
+// SearchResult is a single match with its surrounding bytes.
+type SearchResult struct {
+	Offset        int      `json:"offset"`           // offset of the match
+	ContextOffset int      `json:"context_offset"`   // offset of the first context byte
+	Context       HexBytes `json:"context"`          // the match plus up to 16 bytes on either side
+	Length        int      `json:"length,omitempty"` // bytes matched, in a search with MaxEdits
+	Edits         int      `json:"edits,omitempty"`  // edits that turn the pattern into them
+}
+
+// ErrPatternTooLong is returned when the pattern is longer than the data.
+var ErrPatternTooLong = errors.New("search pattern is longer than the file")
+
+// Search reports every occurrence of pattern in data. Matches may overlap
+// unless opts.NoOverlap is set. With opts.MaxEdits the matches may differ
+// from the pattern by as many edits, and never overlap.
+func Search(data []byte, pattern []byte, opts SearchOptions) ([]SearchResult, error) {
+	if len(pattern)-opts.MaxEdits > len(data) {
+		return nil, ErrPatternTooLong
+	}
+	if opts.MaxEdits > 0 {
+		if opts.MaxEdits >= len(pattern) {
+			return nil, ErrTooManyEdits
+		}
+		return searchFuzzy(data, pattern, opts), nil
+	}
+
+	var results []SearchResult
+	for i := 0; i < len(data)-len(pattern)+1; i++ {
+		if !matchAt(data, i, pattern, opts) {
+			continue
+		}
+
+		contextStart := max(i-contextSize, 0)
+		contextEnd := min(i+len(pattern)+contextSize, len(data))
+		results = append(results, SearchResult{
+			Offset:        i,
+			ContextOffset: contextStart,
+			Context:       data[contextStart:contextEnd],
+		})
+
+		if opts.NoOverlap {
+			i += len(pattern) - 1
+		}
+	}
+	return results, nil
+}
+
+// SearchText encodes text according to opts and searches for it.
+func SearchText(data []byte, text string, opts SearchOptions) ([]SearchResult, error) {
+	pattern := []byte(text)
+	if opts.UTF16 {
+		pattern = EncodeUTF16LE(text)
+	}
+	return Search(data, pattern, opts)
+}
+
+// RegexpMatch is a regular expression match within a text string.
+type RegexpMatch struct {
+	Offset int    `json:"offset"` // offset of the first matched byte
+	Text   string `json:"text"`   // the matched text
+}
+
+// SearchRegexp matches re against the printable-string view of data: each
+// string of at least minLen text bytes, decoded with cp as in
+// ExtractStringsCodepage, is searched separately so matches never span
+// binary bytes.
+func SearchRegexp(data []byte, re *regexp.Regexp, minLen int, cp *Codepage) []RegexpMatch {
+	return MatchStrings(ExtractStringsCodepage(data, minLen, cp), re)
+}
+
+// MatchStrings matches re against each of strs separately, as SearchRegexp
+// does, with the offsets of the matches within the data.
+func MatchStrings(strs []FoundString, re *regexp.Regexp) []RegexpMatch {
+	var matches []RegexpMatch
+	for _, s := range strs {
+		// Every rune of the text is one data byte, or two for UTF-16LE
+		width := 1
+		if s.Encoding == EncodingUTF16LE {
+			width = 2
+		}
+		for _, loc := range re.FindAllStringIndex(s.Text, -1) {
+			if loc[0] == loc[1] {
+				continue // skip empty matches
+			}
+			matches = append(matches, RegexpMatch{
+				Offset: s.Offset + width*utf8.RuneCountInString(s.Text[:loc[0]]),
+				Text:   s.Text[loc[0]:loc[1]],
+			})
+		}
+	}
+	return matches
+}
+
+func matchAt(data []byte, i int, pattern []byte, opts SearchOptions) bool {
+	for j := 0; j < len(pattern); j++ {
+		if !opts.matches(data[i+j], pattern, j) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseHexPattern decodes a hex pattern such as "00ff00ff" or "00 FF 00 FF".
+func ParseHexPattern(hexStr string) ([]byte, error) {
+	cleaned := strings.Join(strings.Fields(hexStr), "")
+	if cleaned == "" {
+		return nil, errors.New("empty pattern")
+	}
+	return hex.DecodeString(cleaned)
+}
+
+// EncodeUTF16LE encodes a string as little-endian UTF-16.
+func EncodeUTF16LE(s string) []byte {
+	units := utf16.Encode([]rune(s))
+	encoded := make([]byte, 0, len(units)*2)
+	for _, u := range units {
+		encoded = append(encoded, byte(u), byte(u>>8))
+	}
+	return encoded
+}
+
+// Lowercase an ASCII letter, leaving other bytes untouched
+func foldASCII(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + ('a' - 'A')
+	}
+	return b
+}
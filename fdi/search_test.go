@@ -0,0 +1,138 @@
+package fdi
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		pattern string
+		opts    SearchOptions
+		want    []int
+	}{
+		{"single", "xxJUVExx", "JUVE", SearchOptions{}, []int{2}},
+		{"at offset 0", "JUVExx", "JUVE", SearchOptions{}, []int{0}},
+		{"at the end", "xxJUVE", "JUVE", SearchOptions{}, []int{2}},
+		{"not found", "xxJUVExx", "MILAN", SearchOptions{}, nil},
+		{"overlapping", "aaaa", "aa", SearchOptions{}, []int{0, 1, 2}},
+		{"no overlap", "aaaa", "aa", SearchOptions{NoOverlap: true}, []int{0, 2}},
+		{"ignore case", "Juve juve", "JUVE", SearchOptions{IgnoreCase: true}, []int{0, 5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Search([]byte(tt.data), []byte(tt.pattern), tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, r := range results {
+				got = append(got, r.Offset)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Search(%q, %q) offsets = %v, want %v", tt.data, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchContext(t *testing.T) {
+	data := []byte("0123456789abcdefJUVE0123456789abcdefXYZ")
+	results, err := Search(data, []byte("JUVE"), SearchOptions{})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("Search = %v, %v", results, err)
+	}
+	r := results[0]
+	if r.ContextOffset != 0 || string(r.Context) != string(data[:36]) {
+		t.Errorf("context = %d %q, want 0 %q", r.ContextOffset, r.Context, data[:36])
+	}
+}
+
+func TestSearchPatternTooLong(t *testing.T) {
+	if _, err := Search([]byte("ab"), []byte("abc"), SearchOptions{}); !errors.Is(err, ErrPatternTooLong) {
+		t.Errorf("err = %v, want ErrPatternTooLong", err)
+	}
+}
+
+func TestSearchTextUTF16(t *testing.T) {
+	data := []byte("x\x00J\x00U\x00V\x00E\x00")
+	results, err := SearchText(data, "JUVE", SearchOptions{UTF16: true})
+	if err != nil || len(results) != 1 || results[0].Offset != 2 {
+		t.Errorf("SearchText = %v, %v, want one match at 2", results, err)
+	}
+}
+
+func TestParseHexPattern(t *testing.T) {
+	got, err := ParseHexPattern("00 ff 00FF")
+	if err != nil || !reflect.DeepEqual(got, []byte{0x00, 0xFF, 0x00, 0xFF}) {
+		t.Errorf("ParseHexPattern = %x, %v", got, err)
+	}
+	if _, err := ParseHexPattern("zz"); err == nil {
+		t.Error("ParseHexPattern(\"zz\") succeeded, want an error")
+	}
+	if _, err := ParseHexPattern(" "); err == nil {
+		t.Error("ParseHexPattern(\" \") succeeded, want an error")
+	}
+}
+
+func TestSearchRegexp(t *testing.T) {
+	data := []byte("\x00\x01ROSSI Paolo\x00\x02BAGGIO Roberto\x00HI\x00")
+	re := regexp.MustCompile(`[A-Z]{4,} [A-Z][a-z]+`)
+
+	got := SearchRegexp(data, re, 4, nil)
+	want := []RegexpMatch{{Offset: 2, Text: "ROSSI Paolo"}, {Offset: 15, Text: "BAGGIO Roberto"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchRegexp = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchRegexpCodepage(t *testing.T) {
+	// "MÜLLER" in cp437, where Ü is 0x9A: offsets count bytes, not UTF-8 bytes
+	data := []byte("\x00M\x9ALLER\x00")
+	cp, _ := LookupCodepage("cp437")
+
+	got := SearchRegexp(data, regexp.MustCompile(`L+ER`), 4, cp)
+	want := []RegexpMatch{{Offset: 3, Text: "LLER"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchRegexp = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchFuzzy(t *testing.T) {
+	data := []byte("xxBAGGIOxx..BAGIO.....BAGGI0......BAGGGIO...TOTTI")
+	results, err := Search(data, []byte("BAGGIO"), SearchOptions{MaxEdits: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type hit struct{ offset, length, edits int }
+	var got []hit
+	for _, r := range results {
+		got = append(got, hit{r.Offset, r.Length, r.Edits})
+	}
+	want := []hit{{2, 6, 0}, {12, 5, 1}, {22, 6, 1}, {34, 7, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fuzzy matches = %v, want %v", got, want)
+	}
+
+	if _, err := Search(data, []byte("AB"), SearchOptions{MaxEdits: 2}); !errors.Is(err, ErrTooManyEdits) {
+		t.Errorf("err = %v, want ErrTooManyEdits", err)
+	}
+}
+
+func TestSearchWildcards(t *testing.T) {
+	pattern, wild, err := ParseHexWildcards("50 ?? 41? 59")
+	if err != nil || !reflect.DeepEqual(pattern, []byte{0x50, 0, 0x41, 0, 0x59}) || !reflect.DeepEqual(wild, []bool{false, true, false, true, false}) {
+		t.Fatalf("ParseHexWildcards = %x, %v, %v", pattern, wild, err)
+	}
+	results, _ := Search([]byte("PLAYPXAXYPLAY"), pattern, SearchOptions{Wild: wild})
+	if len(results) != 1 || results[0].Offset != 4 {
+		t.Errorf("Search with wildcards = %v, want one match at 4", results)
+	}
+	if _, _, err := ParseHexWildcards("5"); err == nil {
+		t.Error("ParseHexWildcards(\"5\") succeeded, want an error")
+	}
+}
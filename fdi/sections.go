@@ -0,0 +1,62 @@
+package fdi
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultSectionTags are the four-byte markers the section scan looks for
+// when no others are given. They are common container chunk tags.
+var DefaultSectionTags = []string{"RIFF", "LIST", "FORM", "HEAD", "INFO", "DATA", "BODY", "TEXT"}
+
+// Section is one occurrence of a section tag.
+type Section struct {
+	Tag    HexBytes `json:"tag"`
+	Offset int      `json:"offset"`
+	Gap    int      `json:"gap"` // bytes up to the next tag, or to the end of the data
+}
+
+// ParseSectionTag parses a four-byte tag given either as four characters
+// ("HEAD") or as 0x-prefixed hex ("0x48454144").
+func ParseSectionTag(s string) ([]byte, error) {
+	tag := []byte(s)
+	if hexStr, ok := strings.CutPrefix(s, "0x"); ok {
+		b, err := ParseHexPattern(hexStr)
+		if err != nil {
+			return nil, fmt.Errorf("tag %q: invalid hex: %v", s, err)
+		}
+		tag = b
+	}
+	if len(tag) != 4 {
+		return nil, fmt.Errorf("tag %q: expected 4 bytes, got %d", s, len(tag))
+	}
+	return tag, nil
+}
+
+// FindSections reports every occurrence of the given tags in offset order,
+// with the distance from each one to the next reconstructing the layout.
+func FindSections(data []byte, tags [][]byte) []Section {
+	var sections []Section
+	for _, tag := range tags {
+		results, err := Search(data, tag, SearchOptions{NoOverlap: true})
+		if err != nil {
+			continue
+		}
+		for _, r := range results {
+			sections = append(sections, Section{Tag: tag, Offset: r.Offset})
+		}
+	}
+
+	sort.SliceStable(sections, func(i, j int) bool {
+		return sections[i].Offset < sections[j].Offset
+	})
+	for i := range sections {
+		next := len(data)
+		if i+1 < len(sections) {
+			next = sections[i+1].Offset
+		}
+		sections[i].Gap = next - sections[i].Offset
+	}
+	return sections
+}
@@ -0,0 +1,36 @@
+package fdi
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash/crc32"
+)
+
+// Stats holds checksums and byte statistics for a block of data.
+type Stats struct {
+	Length    int      `json:"length"`
+	MD5       string   `json:"md5"`
+	SHA256    string   `json:"sha256"`
+	CRC32     uint32   `json:"crc32"`
+	Histogram [256]int `json:"histogram"`
+	Entropy   float64  `json:"entropy"` // bits per byte
+}
+
+// ComputeStats fingerprints data and measures its byte distribution.
+func ComputeStats(data []byte) Stats {
+	md5Sum := md5.Sum(data)
+	shaSum := sha256.Sum256(data)
+
+	st := Stats{
+		Length:  len(data),
+		MD5:     hex.EncodeToString(md5Sum[:]),
+		SHA256:  hex.EncodeToString(shaSum[:]),
+		CRC32:   crc32.ChecksumIEEE(data),
+		Entropy: ShannonEntropy(data),
+	}
+	for _, b := range data {
+		st.Histogram[b]++
+	}
+	return st
+}
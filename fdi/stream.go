@@ -0,0 +1,156 @@
+package fdi
+
+import (
+	"io"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// MinChunkSize is the smallest chunk the Reader functions read at a time.
+const MinChunkSize = 4096
+
+// Read r in chunks of up to size bytes and pass each to fn with the offset of
+// its first byte. fn returns how many bytes from the start of the chunk it is
+// done with; the rest begin the next chunk, so a match or string that
+// straddles two reads is seen whole. final is set for the last chunk.
+func readChunks(r io.Reader, size int, fn func(chunk []byte, base int, final bool) int) error {
+	buf := make([]byte, max(size, MinChunkSize))
+	held, base := 0, 0
+	for {
+		n, err := io.ReadFull(r, buf[held:])
+		held += n
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+		done := fn(buf[:held], base, final)
+		if final {
+			return nil
+		}
+		// A full chunk must make progress
+		done = min(max(done, 1), held)
+		copy(buf, buf[done:held])
+		held -= done
+		base += done
+	}
+}
+
+// SearchReader is Search over a stream, holding at most chunkSize bytes (and
+// at least MinChunkSize, or enough for the pattern and its context) at a time.
+func SearchReader(r io.Reader, pattern []byte, opts SearchOptions, chunkSize int) ([]SearchResult, error) {
+	if len(pattern) == 0 {
+		return nil, nil
+	}
+	if opts.MaxEdits > 0 && opts.MaxEdits >= len(pattern) {
+		return nil, ErrTooManyEdits
+	}
+	reach := len(pattern) + opts.MaxEdits // the longest a match can be
+	keep := reach - 1 + 2*contextSize
+	chunkSize = max(chunkSize, 2*keep)
+
+	var results []SearchResult
+	next, total := 0, 0 // where the next match may start, and the bytes seen
+	err := readChunks(r, chunkSize, func(chunk []byte, base int, final bool) int {
+		total = base + len(chunk)
+		// Matches too near the end lack their context until the next chunk
+		limit := len(chunk)
+		if !final {
+			limit -= reach - 1 + contextSize
+		}
+		if len(chunk) >= len(pattern)-opts.MaxEdits {
+			chunkOpts := opts
+			chunkOpts.NoOverlap = false
+			found, _ := Search(chunk, pattern, chunkOpts)
+			for _, m := range found {
+				if m.Offset >= limit {
+					break
+				}
+				if base+m.Offset < next {
+					continue
+				}
+				m.Offset += base
+				m.ContextOffset += base
+				m.Context = append(HexBytes(nil), m.Context...)
+				results = append(results, m)
+				switch {
+				case opts.MaxEdits > 0:
+					next = m.Offset + m.Length
+				case opts.NoOverlap:
+					next = m.Offset + len(pattern)
+				default:
+					next = m.Offset + 1
+				}
+			}
+		}
+		next = max(next, base+limit)
+		// Keep the context before the next match
+		return limit - contextSize
+	})
+	if err != nil {
+		return nil, err
+	}
+	if total < len(pattern)-opts.MaxEdits {
+		return nil, ErrPatternTooLong
+	}
+	return results, nil
+}
+
+// SearchTextReader is SearchText over a stream, as SearchReader.
+func SearchTextReader(r io.Reader, text string, opts SearchOptions, chunkSize int) ([]SearchResult, error) {
+	pattern := []byte(text)
+	if opts.UTF16 {
+		pattern = EncodeUTF16LE(text)
+	}
+	return SearchReader(r, pattern, opts, chunkSize)
+}
+
+// StringsReader returns the text strings of a stream as Analyzer.Strings
+// would, holding at most chunkSize bytes at a time. A string longer than a
+// chunk is split where the chunk ends.
+func StringsReader(r io.Reader, opts AnalysisOptions, chunkSize int) ([]FoundString, error) {
+	// Text at the end of a chunk may go on in the next, even if it is still
+	// too short to count as a string
+	tail := 2*opts.MinString + 2
+	chunkSize = max(chunkSize, 4*tail)
+
+	var found []FoundString
+	next := 0 // end of the last string kept
+	err := readChunks(r, chunkSize, func(chunk []byte, base int, final bool) int {
+		strs := opts.strings(chunk)
+		cut := len(chunk)
+		if !final {
+			cut -= tail
+			if n := len(strs); n > 0 && strs[n-1].Offset+stringSize(strs[n-1]) >= len(chunk)-2 {
+				cut = min(cut, strs[n-1].Offset)
+			}
+			if cut <= 0 {
+				cut = len(chunk) // the string fills the chunk
+			}
+		}
+		for _, s := range strs {
+			if s.Offset >= cut {
+				break
+			}
+			if base+s.Offset < next {
+				continue // the rest of a string kept from the last chunk
+			}
+			s.Offset += base
+			found = append(found, s)
+			next = s.Offset + stringSize(s)
+		}
+		return cut
+	})
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// Bytes a found string takes in the data: one per character, as decoded by
+// a codepage or kept raw, or UTF-16 code units of two
+func stringSize(s FoundString) int {
+	if s.Encoding == EncodingUTF16LE {
+		return 2 * len(utf16.Encode([]rune(s.Text)))
+	}
+	return utf8.RuneCountInString(s.Text)
+}
@@ -0,0 +1,83 @@
+package fdi
+
+import (
+	"bytes"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// Binary filler with text and repeated patterns placed across several chunk
+// boundaries
+func streamData() []byte {
+	rng := rand.New(rand.NewSource(1))
+	data := make([]byte, 5*MinChunkSize+123)
+	for i := range data {
+		data[i] = byte(rng.Intn(32))
+	}
+	for _, at := range []int{10, MinChunkSize - 5, 2*MinChunkSize - 40, 3*MinChunkSize - 2, 4 * MinChunkSize} {
+		copy(data[at:], "JUVENTUS 1897 TORINO")
+	}
+	copy(data[2*MinChunkSize-3:], "aaaaaa")
+	// UTF-16LE text across a boundary
+	copy(data[3*MinChunkSize-50:], EncodeUTF16LE("MILAN"))
+	return data
+}
+
+func TestSearchReader(t *testing.T) {
+	data := streamData()
+	for _, tt := range []struct {
+		pattern string
+		opts    SearchOptions
+	}{
+		{"JUVENTUS", SearchOptions{}},
+		{"juventus", SearchOptions{IgnoreCase: true}},
+		{"aa", SearchOptions{}},
+		{"aa", SearchOptions{NoOverlap: true}},
+		{"JUVENTOS", SearchOptions{MaxEdits: 2}},
+		{"MILAN", SearchOptions{MaxEdits: 1}},
+	} {
+		want, _ := Search(data, []byte(tt.pattern), tt.opts)
+		got, err := SearchReader(bytes.NewReader(data), []byte(tt.pattern), tt.opts, MinChunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("SearchReader(%q, %+v) = %d matches, want the %d of Search", tt.pattern, tt.opts, len(got), len(want))
+		}
+	}
+
+	if _, err := SearchReader(bytes.NewReader([]byte("ab")), []byte("abc"), SearchOptions{}, 0); err != ErrPatternTooLong {
+		t.Errorf("SearchReader of a pattern longer than the data: err = %v, want ErrPatternTooLong", err)
+	}
+}
+
+func TestStringsReader(t *testing.T) {
+	data := streamData()
+	for _, opts := range []AnalysisOptions{
+		{MinString: 4},
+		{MinString: 4, Encoding: EncodingUTF16LE},
+		{MinString: 4, Encoding: EncodingAuto},
+	} {
+		want := NewAnalyzer(data, opts).Strings()
+		got, err := StringsReader(bytes.NewReader(data), opts, MinChunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("StringsReader(%+v) = %v, want %v", opts, got, want)
+		}
+	}
+}
+
+func TestDumpBase(t *testing.T) {
+	data := []byte("0123456789ABCDEFGHIJ")
+	whole, _ := Dump(data, DumpOptions{Offset: 4, Size: 12})
+	window, err := Dump(data[4:], DumpOptions{Base: 4, Offset: 4, Size: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(window, whole) {
+		t.Errorf("Dump of a window = %v, want %v", window, whole)
+	}
+}
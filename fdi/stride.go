@@ -0,0 +1,383 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Record sizes InferStride tries, and the bytes it samples to rank them
+const (
+	minStride     = 4
+	maxStride     = 1024
+	strideSample  = 64 << 10
+	minRecords    = 4
+	minStrideFit  = 0.2 // least score a stride needs to be reported
+	textColumnMin = 3   // neighbouring text columns that make a text field
+)
+
+// StrideScore rates a candidate record size by how much more often bytes
+// repeat at that distance than chance would have them.
+type StrideScore struct {
+	Size  int     `json:"size"`
+	Score float64 `json:"score"` // 0 for chance, 1 when every byte repeats
+}
+
+// ColumnSpan is a run of byte columns within a record that hold one field.
+type ColumnSpan struct {
+	Start  int    `json:"start"`
+	End    int    `json:"end"`  // exclusive
+	Type   string `json:"type"` // constant, counter, ascii, float, small-int or binary
+	Detail string `json:"detail,omitempty"`
+}
+
+// StrideResult describes fixed-size records found by InferStride.
+type StrideResult struct {
+	Size         int           `json:"size"`
+	Start        int           `json:"start"`
+	Records      int           `json:"records"`
+	Score        float64       `json:"score"`
+	Alternatives []StrideScore `json:"alternatives"` // best other sizes
+	Columns      []ColumnSpan  `json:"columns"`
+}
+
+// InferStride looks for fixed-size records in data[start:end] by
+// autocorrelation: the record size is the shortest distance at which bytes
+// repeat nearly as often as at the best one, since multiples of the size score
+// as well. The records start where that regularity begins and end where it
+// stops, and each column is then classified from its values across records.
+func InferStride(data []byte, start int, end int) (StrideResult, bool) {
+	end = min(end, len(data))
+	if start < 0 || start >= end {
+		return StrideResult{}, false
+	}
+
+	scores, rates, base := strideScores(data[start:min(end, start+strideSample)])
+	if len(scores) == 0 {
+		return StrideResult{}, false
+	}
+	ranked := append([]StrideScore{}, scores...)
+	sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].Score > ranked[j].Score })
+	best := ranked[0]
+	if best.Score < minStrideFit {
+		return StrideResult{}, false
+	}
+	for _, s := range scores {
+		if s.Score >= best.Score*0.9 {
+			best = s
+			break
+		}
+	}
+
+	// Records span the neighbours that match at least halfway between chance
+	// and the rate at the chosen size
+	threshold := base + (rates[best.Size]-base)/2
+	recStart, records := recordRun(data[start:end], best.Size, threshold)
+	recStart, records = trimRun(data[start:end], best.Size, recStart, records)
+	if records < minRecords {
+		return StrideResult{}, false
+	}
+
+	res := StrideResult{Size: best.Size, Start: start + recStart, Records: records, Score: best.Score}
+	for _, s := range ranked {
+		if s.Size != best.Size && len(res.Alternatives) < 3 {
+			res.Alternatives = append(res.Alternatives, s)
+		}
+	}
+	res.Columns = ClassifyColumns(data, res.Start, res.Size, res.Records)
+	return res, true
+}
+
+// The score of every candidate size in ascending order, the raw match rate
+// at each size and the chance of two bytes matching
+func strideScores(sample []byte) ([]StrideScore, map[int]float64, float64) {
+	var counts [256]int
+	for _, b := range sample {
+		counts[b]++
+	}
+	base := 0.0
+	for _, c := range counts {
+		p := float64(c) / float64(len(sample))
+		base += p * p
+	}
+	if base >= 0.999 {
+		return nil, nil, base // all one byte value
+	}
+
+	var scores []StrideScore
+	rates := make(map[int]float64)
+	for size := minStride; size <= maxStride && size*minRecords <= len(sample); size++ {
+		matches := 0
+		for i := 0; i+size < len(sample); i++ {
+			if sample[i] == sample[i+size] {
+				matches++
+			}
+		}
+		rate := float64(matches) / float64(len(sample)-size)
+		rates[size] = rate
+		scores = append(scores, StrideScore{Size: size, Score: (rate - base) / (1 - base)})
+	}
+	return scores, rates, base
+}
+
+// The first offset where a record matches the next one at the threshold
+// rate, and how many records follow it before the matches stop
+func recordRun(data []byte, size int, threshold float64) (int, int) {
+	// Prefix sums of the bytes that equal the byte one record later
+	same := make([]int, len(data)-size+1)
+	for i := 0; i+size < len(data); i++ {
+		same[i+1] = same[i]
+		if data[i] == data[i+size] {
+			same[i+1]++
+		}
+	}
+	matches := func(at int) bool {
+		return at+2*size <= len(data) && float64(same[at+size]-same[at]) >= threshold*float64(size)
+	}
+
+	for at := 0; at+2*size <= len(data); at++ {
+		if !matches(at) {
+			continue
+		}
+		records := 1
+		for matches(at + (records-1)*size) {
+			records++
+		}
+		return at, records
+	}
+	return 0, 0
+}
+
+// Narrow a run of records found by recordRun, which can start up to a record
+// early, to where the columns that repeat in nearly every record first hold
+// their values, and drop records of nothing but padding at either end
+func trimRun(data []byte, size int, at int, records int) (int, int) {
+	if records < 2 {
+		return at, records
+	}
+	end := min(at+records*size, len(data))
+
+	steady := make([]bool, size)
+	for c := range steady {
+		same := 0
+		for r := 1; r+1 < records; r++ {
+			if data[at+r*size+c] == data[at+(r+1)*size+c] {
+				same++
+			}
+		}
+		steady[c] = records > 2 && same*10 >= (records-2)*9
+	}
+
+	// Within the first record-sized window, steady columns match the next
+	// record once the records have begun and mostly do not before. The start
+	// is the split that leaves the fewest bytes on the wrong side.
+	wrong := 0
+	for i := at; i < at+size; i++ {
+		if steady[(i-at)%size] && data[i] != data[i+size] {
+			wrong++
+		}
+	}
+	best, bestWrong := at, wrong
+	for i := at; i < at+size; i++ {
+		if steady[(i-at)%size] {
+			if data[i] != data[i+size] {
+				wrong--
+			} else {
+				wrong++
+			}
+		}
+		if wrong < bestWrong {
+			best, bestWrong = i+1, wrong
+		}
+	}
+	at = best
+	end = at + (end-at)/size*size
+
+	padding := func(rec []byte) bool { return bytes.Count(rec, rec[:1]) == len(rec) }
+	for at+size <= end && padding(data[at:at+size]) {
+		at += size
+	}
+	for end-size >= at && padding(data[end-size:end]) {
+		end -= size
+	}
+	return at, (end - at) / size
+}
+
+// ClassifyColumns groups the byte columns of count records of size bytes
+// starting at start into fields: runs of text, constants, counters, floats
+// and small integers, and binary bytes otherwise.
+func ClassifyColumns(data []byte, start int, size int, count int) []ColumnSpan {
+	column := func(col int, width int) [][]byte {
+		vals := make([][]byte, count)
+		for r := range vals {
+			at := start + r*size + col
+			vals[r] = data[at : at+width]
+		}
+		return vals
+	}
+
+	var spans []ColumnSpan
+	add := func(s ColumnSpan) {
+		last := len(spans) - 1
+		if last >= 0 && (s.Type == "constant" || s.Type == "binary") && spans[last].Type == s.Type {
+			spans[last].End = s.End
+			if s.Type == "constant" {
+				spans[last].Detail = constantDetail(column(spans[last].Start, s.End-spans[last].Start)[0])
+			}
+			return
+		}
+		spans = append(spans, s)
+	}
+
+	for col := 0; col < size; {
+		if n := textColumns(column, col, size); n >= textColumnMin {
+			add(ColumnSpan{Start: col, End: col + n, Type: "ascii", Detail: fmt.Sprintf("%q", trimText(column(col, n)[0]))})
+			col += n
+			continue
+		}
+		if width, detail, ok := counterColumn(column, col, size); ok {
+			add(ColumnSpan{Start: col, End: col + width, Type: "counter", Detail: detail})
+			col += width
+			continue
+		}
+		if col+4 <= size && floatColumn(column(col, 4)) {
+			add(ColumnSpan{Start: col, End: col + 4, Type: "float", Detail: "f32le"})
+			col += 4
+			continue
+		}
+
+		vals := column(col, 1)
+		lo, hi, constant := 255, 0, true
+		for _, v := range vals {
+			lo, hi = min(lo, int(v[0])), max(hi, int(v[0]))
+			constant = constant && v[0] == vals[0][0]
+		}
+		switch {
+		case constant:
+			add(ColumnSpan{Start: col, End: col + 1, Type: "constant", Detail: fmt.Sprintf("%02X", vals[0][0])})
+		case hi <= 100:
+			add(ColumnSpan{Start: col, End: col + 1, Type: "small-int", Detail: fmt.Sprintf("%d-%d", lo, hi)})
+		default:
+			add(ColumnSpan{Start: col, End: col + 1, Type: "binary"})
+		}
+		col++
+	}
+	return spans
+}
+
+// How many columns from col on hold text (or zero padding after it) in
+// nearly every record, with at least one varying and one that is never zero
+func textColumns(column func(int, int) [][]byte, col int, size int) int {
+	n, varies, letters := 0, false, false
+	for c := col; c < size; c++ {
+		vals := column(c, 1)
+		text, nonZero := 0, 0
+		for _, v := range vals {
+			if IsTextByte(v[0]) || v[0] == 0 {
+				text++
+			}
+			if v[0] != 0 {
+				nonZero++
+			}
+		}
+		if text*10 < len(vals)*9 || (c == col && nonZero < len(vals)) {
+			break
+		}
+		varies = varies || !sameValues(vals)
+		letters = letters || nonZero == len(vals)
+		n++
+	}
+	if !varies || !letters {
+		return 0
+	}
+	return n
+}
+
+// The bytes of a constant run, shortened when long
+func constantDetail(b []byte) string {
+	if len(b) > 1 && bytes.Count(b, b[:1]) == len(b) {
+		return fmt.Sprintf("%02X x%d", b[0], len(b))
+	}
+	if len(b) > 16 {
+		return fmt.Sprintf("%X...", b[:16])
+	}
+	return fmt.Sprintf("%X", b)
+}
+
+// A column's first value as text, without its zero padding
+func trimText(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+	return string(b)
+}
+
+// Whether the u32, u16 or u8 little-endian value at col changes by the same
+// step from each record to the next, for nearly all of them. The narrowest
+// width that holds the values is used.
+func counterColumn(column func(int, int) [][]byte, col int, size int) (int, string, bool) {
+	for _, width := range []int{4, 2, 1} {
+		if col+width > size {
+			continue
+		}
+		vals := column(col, width)
+		nums := make([]int64, len(vals))
+		var hi int64
+		for i, v := range vals {
+			switch width {
+			case 4:
+				nums[i] = int64(binary.LittleEndian.Uint32(v))
+			case 2:
+				nums[i] = int64(binary.LittleEndian.Uint16(v))
+			default:
+				nums[i] = int64(v[0])
+			}
+			hi = max(hi, nums[i])
+		}
+		if width > 1 && hi < 1<<(4*width) {
+			continue // fits the next narrower width
+		}
+
+		step := nums[1] - nums[0]
+		same := 0
+		for i := 1; i < len(nums); i++ {
+			if nums[i]-nums[i-1] == step {
+				same++
+			}
+		}
+		if step != 0 && same*10 >= (len(nums)-1)*9 {
+			return width, fmt.Sprintf("u%d %+d from %d", width*8, step, nums[0]), true
+		}
+	}
+	return 0, "", false
+}
+
+// Whether the 4-byte values read as little-endian floats of plausible
+// magnitude in nearly all records, and are not all the same
+func floatColumn(vals [][]byte) bool {
+	if sameValues(vals) {
+		return false
+	}
+	plausible := 0
+	for _, v := range vals {
+		f := math.Float32frombits(binary.LittleEndian.Uint32(v))
+		abs := math.Abs(float64(f))
+		if f == 0 || (abs >= 1e-4 && abs <= 1e6) {
+			plausible++
+		}
+	}
+	return plausible*10 >= len(vals)*9
+}
+
+func sameValues(vals [][]byte) bool {
+	for _, v := range vals {
+		if string(v) != string(vals[0]) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,56 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestInferStride(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	names := []string{"ROSSI", "BIANCHI", "VERDI", "ESPOSITO", "ROMANO", "COLOMBO", "RICCI", "MARINO"}
+
+	data := make([]byte, 0x400)
+	rng.Read(data)
+	for r := 0; r < 40; r++ {
+		rec := make([]byte, 128)
+		copy(rec, names[r%len(names)])
+		rec[5+r%7] = byte('A' + r%26) // vary the padding a little
+		binary.LittleEndian.PutUint16(rec[24:], uint16(1000+r))
+		binary.LittleEndian.PutUint32(rec[28:], math.Float32bits(float32(r)*1.5+0.25))
+		rec[32] = byte(rng.Intn(99))
+		rng.Read(rec[40:48])
+		data = append(data, rec...)
+	}
+	data = append(data, make([]byte, 0x100)...)
+
+	res, ok := InferStride(data, 0, len(data))
+	if !ok {
+		t.Fatal("no stride found")
+	}
+	if res.Size != 128 || res.Start != 0x400 || res.Records != 40 {
+		t.Fatalf("InferStride = %d-byte records at 0x%X x%d, want 128 at 0x400 x40", res.Size, res.Start, res.Records)
+	}
+
+	want := map[int]string{0: "ascii", 24: "counter", 28: "float", 32: "small-int", 40: "binary", 48: "constant"}
+	for _, c := range res.Columns {
+		if typ, ok := want[c.Start]; ok {
+			if c.Type != typ {
+				t.Errorf("column %d-%d = %s (%s), want %s", c.Start, c.End, c.Type, c.Detail, typ)
+			}
+			delete(want, c.Start)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("no columns starting at %v in %+v", want, res.Columns)
+	}
+}
+
+func TestInferStrideRandom(t *testing.T) {
+	data := make([]byte, 8192)
+	rand.New(rand.NewSource(4)).Read(data)
+	if res, ok := InferStride(data, 0, len(data)); ok {
+		t.Errorf("found %d-byte records in random data", res.Size)
+	}
+}
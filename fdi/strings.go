@@ -0,0 +1,218 @@
+package fdi
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// FoundString is a run of text bytes found in the data.
+type FoundString struct {
+	Offset   int    `json:"offset"`
+	Text     string `json:"text"`
+	Encoding string `json:"encoding,omitempty"` // set by the UTF-16LE and auto-detecting extractors
+}
+
+// IsTextByte reports whether b is printable ASCII or an extended Latin character.
+func IsTextByte(b byte) bool {
+	return (b >= 32 && b <= 126) || (b >= 192 && b <= 255)
+}
+
+// ExtractStrings returns every run of at least minLen text bytes.
+func ExtractStrings(data []byte, minLen int) []FoundString {
+	return ExtractStringsCodepage(data, minLen, nil)
+}
+
+// ExtractStringsCodepage is like ExtractStrings but treats high bytes as text
+// when they decode to letters in cp, and returns the text decoded to UTF-8.
+// A nil cp gives the ExtractStrings behavior.
+func ExtractStringsCodepage(data []byte, minLen int, cp *Codepage) []FoundString {
+	isText := IsTextByte
+	if cp != nil {
+		isText = cp.IsTextByte
+	}
+
+	var found []FoundString
+	stringStart := -1
+
+	for i := 0; i <= len(data); i++ {
+		if i < len(data) && isText(data[i]) {
+			if stringStart < 0 {
+				stringStart = i
+			}
+			continue
+		}
+
+		if stringStart >= 0 {
+			if i-stringStart >= minLen {
+				text := string(data[stringStart:i])
+				if cp != nil {
+					text = cp.DecodeString(data[stringStart:i])
+				}
+				found = append(found, FoundString{Offset: stringStart, Text: text})
+			}
+			stringStart = -1
+		}
+	}
+
+	return found
+}
+
+// FixedWidthString reads a null- or space-padded string from a fixed-width
+// field. It reports false when the field is empty or holds non-text bytes.
+func FixedWidthString(field []byte) (string, bool) {
+	end := len(field)
+	for i, b := range field {
+		if b == 0 {
+			end = i
+			break
+		}
+	}
+
+	for _, b := range field[:end] {
+		if !IsTextByte(b) {
+			return "", false
+		}
+	}
+
+	str := strings.TrimRight(string(field[:end]), " ")
+	if str == "" {
+		return "", false
+	}
+	return str, true
+}
+
+// ErrOffsetOutOfRange is returned when an offset lies outside the data.
+var ErrOffsetOutOfRange = errors.New("offset is beyond file size")
+
+// StringTable reads consecutive fixed-width string fields starting at offset.
+// It stops at the first empty or invalid entry, or after count entries when
+// count is positive.
+func StringTable(data []byte, offset int, width int, count int) ([]string, error) {
+	if offset < 0 || offset >= len(data) {
+		return nil, ErrOffsetOutOfRange
+	}
+	if width <= 0 {
+		return nil, errors.New("field width must be greater than 0")
+	}
+
+	var entries []string
+	for pos := offset; pos+width <= len(data); pos += width {
+		if count > 0 && len(entries) >= count {
+			break
+		}
+
+		str, ok := FixedWidthString(data[pos : pos+width])
+		if !ok {
+			break
+		}
+		entries = append(entries, str)
+	}
+	return entries, nil
+}
+
+// TableCandidate describes a likely fixed-width string table.
+type TableCandidate struct {
+	Offset  int `json:"offset"`
+	Width   int `json:"width"`
+	Entries int `json:"entries"`
+}
+
+// InferStringTable finds the longest run of fixed-width string fields for
+// field widths between 4 and 64. It reports false if no table of at least
+// four entries exists.
+func InferStringTable(data []byte) (TableCandidate, bool) {
+	// textRun[i] is the number of consecutive text bytes starting at i
+	textRun := make([]int, len(data)+1)
+	for i := len(data) - 1; i >= 0; i-- {
+		if IsTextByte(data[i]) {
+			textRun[i] = textRun[i+1] + 1
+		}
+	}
+
+	// An entry starts with text right after the previous field's padding and
+	// is either null-terminated inside the field or space-padded to its end
+	entryValid := func(pos, width int) bool {
+		if pos+width > len(data) || !IsTextByte(data[pos]) || data[pos] == ' ' {
+			return false
+		}
+		if pos > 0 && IsTextByte(data[pos-1]) && data[pos-1] != ' ' {
+			return false
+		}
+		if textRun[pos] < width {
+			return data[pos+textRun[pos]] == 0
+		}
+		return data[pos+width-1] == ' '
+	}
+
+	var best TableCandidate
+	for width := 4; width <= 64; width++ {
+		for phase := 0; phase < width; phase++ {
+			runStart, runCount := phase, 0
+			for pos := phase; pos+width <= len(data); pos += width {
+				if entryValid(pos, width) {
+					if runCount == 0 {
+						runStart = pos
+					}
+					runCount++
+					if runCount > best.Entries {
+						best = TableCandidate{Offset: runStart, Width: width, Entries: runCount}
+					}
+				} else {
+					runCount = 0
+				}
+			}
+		}
+	}
+
+	return best, best.Entries >= 4
+}
+
+// CommonString is a string shared by several files.
+type CommonString struct {
+	Text    string `json:"text"`
+	Offsets []int  `json:"offsets"` // first offset in each file, -1 where absent
+	Files   int    `json:"files"`   // number of files containing the string
+}
+
+// FindCommonStrings compares per-file string lists and returns the strings
+// present in at least minFiles of them (0 means all), most widely shared first.
+func FindCommonStrings(perFile [][]FoundString, minFiles int) []CommonString {
+	if minFiles <= 0 || minFiles > len(perFile) {
+		minFiles = len(perFile)
+	}
+
+	seen := make(map[string]*CommonString)
+	for fileIdx, strs := range perFile {
+		for _, s := range strs {
+			cs, exists := seen[s.Text]
+			if !exists {
+				cs = &CommonString{Text: s.Text, Offsets: make([]int, len(perFile))}
+				for i := range cs.Offsets {
+					cs.Offsets[i] = -1
+				}
+				seen[s.Text] = cs
+			}
+			if cs.Offsets[fileIdx] < 0 {
+				cs.Offsets[fileIdx] = s.Offset
+				cs.Files++
+			}
+		}
+	}
+
+	var common []CommonString
+	for _, cs := range seen {
+		if cs.Files >= minFiles {
+			common = append(common, *cs)
+		}
+	}
+
+	// Most widely shared first, then alphabetically for stable output
+	sort.Slice(common, func(i, j int) bool {
+		if common[i].Files != common[j].Files {
+			return common[i].Files > common[j].Files
+		}
+		return common[i].Text < common[j].Text
+	})
+	return common
+}
@@ -0,0 +1,231 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ValueTypes lists the numeric encodings FindValue looks for: unsigned and
+// signed integers and IEEE floats, little (le) or big (be) endian.
+var ValueTypes = []string{
+	"u8", "i8",
+	"u16le", "u16be", "i16le", "i16be",
+	"u32le", "u32be", "i32le", "i32be",
+	"u64le", "u64be", "i64le", "i64be",
+	"f32le", "f32be", "f64le", "f64be",
+}
+
+// ErrUnknownValueType is returned for a type not in ValueTypes.
+var ErrUnknownValueType = errors.New("unknown value type")
+
+// ValueMatches lists the offsets where a value is stored in one encoding.
+type ValueMatches struct {
+	Type    string   `json:"type"`
+	Pattern HexBytes `json:"pattern"` // the encoded value
+	Offsets []int    `json:"offsets"`
+}
+
+// EncodeValue encodes a decimal, 0x-prefixed hex or floating-point number as
+// the given type. It fails when the number does not fit the type.
+func EncodeValue(value string, typ string) ([]byte, error) {
+	kind, bits, order, err := parseValueType(typ)
+	if err != nil {
+		return nil, err
+	}
+
+	digits, base := splitBase(value)
+	var u uint64
+	switch kind {
+	case 'u':
+		if u, err = strconv.ParseUint(digits, base, bits); err != nil {
+			return nil, fmt.Errorf("%s does not fit %s", value, typ)
+		}
+	case 'i':
+		n, err := strconv.ParseInt(digits, base, bits)
+		if err != nil {
+			return nil, fmt.Errorf("%s does not fit %s", value, typ)
+		}
+		u = uint64(n)
+	case 'f':
+		f, err := strconv.ParseFloat(value, bits)
+		if n, ierr := strconv.ParseInt(digits, base, 64); err != nil && ierr == nil {
+			f, err = float64(n), nil // a hex integer
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s is not a %d-bit float", value, bits)
+		}
+		if bits == 32 {
+			u = uint64(math.Float32bits(float32(f)))
+		} else {
+			u = math.Float64bits(f)
+		}
+	}
+
+	buf := make([]byte, 8)
+	switch bits {
+	case 8:
+		return []byte{byte(u)}, nil
+	case 16:
+		order.PutUint16(buf, uint16(u))
+	case 32:
+		order.PutUint32(buf, uint32(u))
+	case 64:
+		order.PutUint64(buf, u)
+	}
+	return buf[:bits/8], nil
+}
+
+// Split an optionally signed 0x-prefixed number into its digits and base 16;
+// anything else is decimal, so a leading zero is not octal
+func splitBase(s string) (string, int) {
+	sign, rest := "", s
+	if strings.HasPrefix(rest, "-") {
+		sign, rest = "-", rest[1:]
+	}
+	if strings.HasPrefix(rest, "0x") || strings.HasPrefix(rest, "0X") {
+		return sign + rest[2:], 16
+	}
+	return s, 10
+}
+
+// Split a value type into its kind (u, i or f), width in bits and byte order
+func parseValueType(typ string) (byte, int, binary.ByteOrder, error) {
+	known := false
+	for _, t := range ValueTypes {
+		known = known || t == typ
+	}
+	if !known {
+		return 0, 0, nil, fmt.Errorf("%w %q", ErrUnknownValueType, typ)
+	}
+
+	bits, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimSuffix(typ[1:], "le"), "be"))
+	var order binary.ByteOrder = binary.LittleEndian
+	if strings.HasSuffix(typ, "be") {
+		order = binary.BigEndian
+	}
+	return typ[0], bits, order, nil
+}
+
+// ValueSize returns the number of bytes a value type occupies, or 0 for an
+// unknown type.
+func ValueSize(typ string) int {
+	_, bits, _, err := parseValueType(typ)
+	if err != nil {
+		return 0
+	}
+	return bits / 8
+}
+
+// A raw value of a known type: the bits for unsigned types, sign-extended
+// for signed ones, and the value for floats
+func decodeValue(typ string, raw []byte) (u uint64, i int64, f float64) {
+	kind, bits, order, _ := parseValueType(typ)
+	switch bits {
+	case 8:
+		u = uint64(raw[0])
+	case 16:
+		u = uint64(order.Uint16(raw))
+	case 32:
+		u = uint64(order.Uint32(raw))
+	case 64:
+		u = order.Uint64(raw)
+	}
+
+	switch kind {
+	case 'i':
+		shift := 64 - bits
+		i = int64(u<<shift) >> shift
+	case 'f':
+		if bits == 32 {
+			f = float64(math.Float32frombits(uint32(u)))
+		} else {
+			f = math.Float64frombits(u)
+		}
+	}
+	return u, i, f
+}
+
+// FormatValue formats raw bytes as the given value type.
+func FormatValue(typ string, raw []byte) string {
+	if len(raw) != ValueSize(typ) || len(raw) == 0 {
+		return fmt.Sprintf("%X", raw)
+	}
+	u, i, f := decodeValue(typ, raw)
+	switch typ[0] {
+	case 'i':
+		return strconv.FormatInt(i, 10)
+	case 'f':
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return strconv.FormatUint(u, 10)
+}
+
+// CompareValues compares two raw values of the same type numerically,
+// returning -1, 0 or 1. Float NaNs compare equal to everything.
+func CompareValues(typ string, a []byte, b []byte) int {
+	if size := ValueSize(typ); size == 0 || len(a) != size || len(b) != size {
+		return 0
+	}
+	ua, ia, fa := decodeValue(typ, a)
+	ub, ib, fb := decodeValue(typ, b)
+	switch {
+	case typ[0] == 'u' && ua < ub, typ[0] == 'i' && ia < ib, typ[0] == 'f' && fa < fb:
+		return -1
+	case typ[0] == 'u' && ua > ub, typ[0] == 'i' && ia > ib, typ[0] == 'f' && fa > fb:
+		return 1
+	}
+	return 0
+}
+
+// FindValue reports every offset in data where value is stored as each of
+// types; matches may overlap. With no types it tries all of ValueTypes,
+// skipping the types that cannot hold the value and those whose encoding
+// repeats an earlier type's, such as i16le for a positive value already
+// found as u16le.
+func FindValue(data []byte, value string, types []string) ([]ValueMatches, error) {
+	if len(types) == 0 {
+		return findValue(data, value, ValueTypes, false)
+	}
+	return findValue(data, value, types, true)
+}
+
+// FindValueIn is FindValue with no types given, trying only the types of one
+// byte order as ValueTypesIn lists them.
+func FindValueIn(data []byte, value string, endian string) ([]ValueMatches, error) {
+	return findValue(data, value, ValueTypesIn(endian), false)
+}
+
+func findValue(data []byte, value string, types []string, explicit bool) ([]ValueMatches, error) {
+	var results []ValueMatches
+	seen := make(map[string]bool)
+	for _, typ := range types {
+		pattern, err := EncodeValue(value, typ)
+		if err != nil {
+			if explicit || errors.Is(err, ErrUnknownValueType) {
+				return nil, err
+			}
+			continue
+		}
+		if !explicit && seen[string(pattern)] {
+			continue
+		}
+		seen[string(pattern)] = true
+
+		m := ValueMatches{Type: typ, Pattern: pattern, Offsets: []int{}}
+		for at := 0; ; at++ {
+			i := bytes.Index(data[at:], pattern)
+			if i < 0 {
+				break
+			}
+			at += i
+			m.Offsets = append(m.Offsets, at)
+		}
+		results = append(results, m)
+	}
+	return results, nil
+}
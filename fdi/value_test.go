@@ -0,0 +1,137 @@
+package fdi
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestEncodeValue(t *testing.T) {
+	tests := []struct {
+		value, typ string
+		want       []byte
+	}{
+		{"87", "u8", []byte{0x57}},
+		{"087", "u8", []byte{0x57}}, // decimal, not octal
+		{"0x57", "u16le", []byte{0x57, 0x00}},
+		{"87", "u16be", []byte{0x00, 0x57}},
+		{"-2", "i16le", []byte{0xFE, 0xFF}},
+		{"87", "u32le", []byte{0x57, 0, 0, 0}},
+		{"1.5", "f32le", []byte{0x00, 0x00, 0xC0, 0x3F}},
+		{"87", "f32be", []byte{0x42, 0xAE, 0x00, 0x00}},
+		{"0x10", "f64be", []byte{0x40, 0x30, 0, 0, 0, 0, 0, 0}},
+	}
+	for _, tt := range tests {
+		got, err := EncodeValue(tt.value, tt.typ)
+		if err != nil || !bytes.Equal(got, tt.want) {
+			t.Errorf("EncodeValue(%q, %s) = %X, %v; want %X", tt.value, tt.typ, got, err, tt.want)
+		}
+	}
+
+	for _, bad := range [][2]string{{"300", "u8"}, {"-1", "u16le"}, {"1.5", "u32le"}, {"87", "u24le"}} {
+		if _, err := EncodeValue(bad[0], bad[1]); err == nil {
+			t.Errorf("EncodeValue(%q, %s) succeeded, want an error", bad[0], bad[1])
+		}
+	}
+}
+
+func TestFindValue(t *testing.T) {
+	data := []byte{0x57, 0x00, 0x00, 0x57, 0xFF, 0x57}
+
+	got, err := FindValue(data, "87", []string{"u8", "u16le", "u16be"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ValueMatches{
+		{Type: "u8", Pattern: HexBytes{0x57}, Offsets: []int{0, 3, 5}},
+		{Type: "u16le", Pattern: HexBytes{0x57, 0x00}, Offsets: []int{0}},
+		{Type: "u16be", Pattern: HexBytes{0x00, 0x57}, Offsets: []int{2}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindValue = %+v, want %+v", got, want)
+	}
+
+	if _, err := FindValue(data, "300", []string{"u8"}); err == nil {
+		t.Error("FindValue(300, u8) succeeded, want an error")
+	}
+}
+
+func TestFindValueAllTypes(t *testing.T) {
+	got, err := FindValue([]byte{1, 44}, "300", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var types []string
+	for _, m := range got {
+		types = append(types, m.Type)
+	}
+	// u8/i8 cannot hold 300 and the signed types repeat the unsigned patterns
+	want := []string{"u16le", "u16be", "u32le", "u32be", "u64le", "u64be", "f32le", "f32be", "f64le", "f64be"}
+	if !reflect.DeepEqual(types, want) {
+		t.Errorf("types = %v, want %v", types, want)
+	}
+	if len(got[1].Offsets) != 1 || got[1].Offsets[0] != 0 {
+		t.Errorf("u16be offsets = %v, want [0]", got[1].Offsets)
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		typ  string
+		a, b []byte
+		want int
+	}{
+		{"u8", []byte{1}, []byte{2}, -1},
+		{"i8", []byte{0xFF}, []byte{1}, -1}, // -1 < 1
+		{"u8", []byte{0xFF}, []byte{1}, 1},
+		{"u16be", []byte{1, 0}, []byte{0, 0xFF}, 1},
+		{"f32le", []byte{0, 0, 0x80, 0x3F}, []byte{0, 0, 0, 0x40}, -1}, // 1.0 < 2.0
+		{"u32le", []byte{1, 2, 3, 4}, []byte{1, 2, 3, 4}, 0},
+	}
+	for _, tt := range tests {
+		if got := CompareValues(tt.typ, tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareValues(%s, %X, %X) = %d, want %d", tt.typ, tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNarrowCandidates(t *testing.T) {
+	before := []byte{87, 0, 87, 0, 87, 0}
+	after := []byte{88, 0, 87, 0, 80, 0}
+	matches, err := FindValue(before, "87", []string{"u16le"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cands := CandidatesFrom(matches)
+
+	tests := []struct {
+		filter string
+		want   []int
+	}{
+		{FilterChanged, []int{0, 4}},
+		{FilterUnchanged, []int{2}},
+		{FilterIncreased, []int{0}},
+		{FilterDecreased, []int{4}},
+	}
+	for _, tt := range tests {
+		got, err := NarrowCandidates(after, cands, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var offsets []int
+		for _, c := range got {
+			offsets = append(offsets, c.Offset)
+		}
+		if !reflect.DeepEqual(offsets, tt.want) {
+			t.Errorf("%s: offsets = %v, want %v", tt.filter, offsets, tt.want)
+		}
+	}
+
+	got := NarrowToValue(after, cands, "88")
+	if len(got) != 1 || got[0].Offset != 0 || FormatValue(got[0].Type, got[0].Value) != "88" {
+		t.Errorf("NarrowToValue(88) = %+v, want the candidate at 0 holding 88", got)
+	}
+	if _, err := NarrowCandidates(after, cands, "bigger"); err == nil {
+		t.Error("unknown filter accepted")
+	}
+}
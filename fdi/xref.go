@@ -0,0 +1,154 @@
+package fdi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"strings"
+)
+
+// Ways an XRef's value can lead to its target
+const (
+	XRefAbsolute = "absolute" // the value is the target offset
+	XRefBase     = "base"     // the value plus a given base
+	XRefSelf     = "self"     // the value plus the offset it is stored at
+	XRefNext     = "next"     // the value plus the offset just past it
+)
+
+// XRef is a 16- or 32-bit value in the data that leads to a target offset.
+type XRef struct {
+	Offset int    `json:"offset"` // where the value is stored
+	Type   string `json:"type"`   // u16le, u16be, u32le or u32be; i16 and i32 when relative
+	Value  int    `json:"value"`
+	Kind   string `json:"kind"` // one of the XRef constants
+	Base   int    `json:"base"` // added to the value to give the target
+}
+
+// FindXRefs lists every place a 16- or 32-bit value in either byte order
+// refers to target: as the offset itself, as an offset from one of bases,
+// or as a signed offset from where the value is stored or from just past it.
+// The results are ordered by where they are stored.
+func FindXRefs(data []byte, target int, bases []int) []XRef {
+	var refs []XRef
+	type encoding struct {
+		name  string
+		width int
+		order binary.ByteOrder
+	}
+	encodings := []encoding{
+		{"u32le", 4, binary.LittleEndian}, {"u32be", 4, binary.BigEndian},
+		{"u16le", 2, binary.LittleEndian}, {"u16be", 2, binary.BigEndian},
+	}
+
+	// Fixed bases: search for the one value each stands for
+	fixed := append([]int{0}, bases...)
+	seen := make(map[int]bool)
+	for _, base := range fixed {
+		value := target - base
+		if value < 0 || seen[base] {
+			continue
+		}
+		seen[base] = true
+		kind := XRefAbsolute
+		if base != 0 {
+			kind = XRefBase
+		}
+		for _, e := range encodings {
+			if value >= 1<<(8*e.width) {
+				continue
+			}
+			pattern := make([]byte, e.width)
+			if e.width == 2 {
+				e.order.PutUint16(pattern, uint16(value))
+			} else {
+				e.order.PutUint32(pattern, uint32(value))
+			}
+			for at := 0; ; at++ {
+				n := bytes.Index(data[at:], pattern)
+				if n < 0 {
+					break
+				}
+				at += n
+				refs = append(refs, XRef{Offset: at, Type: e.name, Value: value, Kind: kind, Base: base})
+			}
+		}
+	}
+
+	// Relative values differ at every position, so read each one
+	for _, e := range encodings {
+		for at := 0; at+e.width <= len(data); at++ {
+			var v int
+			if e.width == 2 {
+				v = int(int16(e.order.Uint16(data[at:])))
+			} else {
+				v = int(int32(e.order.Uint32(data[at:])))
+			}
+			if v == 0 {
+				continue // every value refers to itself
+			}
+			signed := "i" + e.name[1:]
+			switch target {
+			case at + v:
+				refs = append(refs, XRef{Offset: at, Type: signed, Value: v, Kind: XRefSelf, Base: at})
+			case at + e.width + v:
+				refs = append(refs, XRef{Offset: at, Type: signed, Value: v, Kind: XRefNext, Base: at + e.width})
+			}
+		}
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].Offset < refs[j].Offset })
+	return dropOverlappingXRefs(refs)
+}
+
+func (r XRef) width() int {
+	if strings.HasSuffix(r.Type, "16le") || strings.HasSuffix(r.Type, "16be") {
+		return 2
+	}
+	return 4
+}
+
+// A value next to zero bytes reads the same in several widths and byte
+// orders, such as 0x2400 as a u32le at one offset and a u32be one byte
+// earlier. Of overlapping references of the same kind keep one: aligned to
+// its width if any is, then the widest, then the first. The references must
+// be in offset order.
+func dropOverlappingXRefs(refs []XRef) []XRef {
+	better := func(a, b XRef) bool {
+		alignedA, alignedB := a.Offset%a.width() == 0, b.Offset%b.width() == 0
+		if alignedA != alignedB {
+			return alignedA
+		}
+		return a.width() > b.width()
+	}
+
+	type group struct {
+		kind string
+		base int
+	}
+	groups := make(map[group][]XRef)
+	for _, r := range refs {
+		g := group{r.Kind, r.Base}
+		if r.Kind != XRefBase {
+			g.base = 0 // relative references differ in base by width
+		}
+		groups[g] = append(groups[g], r)
+	}
+
+	var kept []XRef
+	for _, g := range groups {
+		for i := 0; i < len(g); {
+			best, end := g[i], g[i].Offset+g[i].width()
+			j := i + 1
+			for ; j < len(g) && g[j].Offset < end; j++ {
+				if better(g[j], best) {
+					best = g[j]
+				}
+				end = max(end, g[j].Offset+g[j].width())
+			}
+			kept = append(kept, best)
+			i = j
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Offset < kept[j].Offset })
+	return kept
+}
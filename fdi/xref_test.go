@@ -0,0 +1,30 @@
+package fdi
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+func TestFindXRefs(t *testing.T) {
+	data := make([]byte, 0x3000)
+	binary.LittleEndian.PutUint32(data[0x120:], 0x2400)               // absolute
+	binary.BigEndian.PutUint16(data[0x200:], 0x2000)                  // from a 0x400 header
+	binary.LittleEndian.PutUint32(data[0x510:], uint32(0x2400-0x510)) // from itself
+	binary.LittleEndian.PutUint16(data[0x600:], uint16(0x2400-0x602)) // from the next byte
+
+	refs := FindXRefs(data, 0x2400, []int{0x400})
+	want := []XRef{
+		{Offset: 0x120, Type: "u32le", Value: 0x2400, Kind: XRefAbsolute},
+		{Offset: 0x200, Type: "u16be", Value: 0x2000, Kind: XRefBase, Base: 0x400},
+		{Offset: 0x510, Type: "i32le", Value: 0x2400 - 0x510, Kind: XRefSelf, Base: 0x510},
+		{Offset: 0x600, Type: "i16le", Value: 0x2400 - 0x602, Kind: XRefNext, Base: 0x602},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("FindXRefs = %+v, want %+v", refs, want)
+	}
+	for i := range want {
+		if refs[i] != want[i] {
+			t.Errorf("ref %d = %+v, want %+v", i, refs[i], want[i])
+		}
+	}
+}
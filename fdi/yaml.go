@@ -0,0 +1,271 @@
+package fdi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A small YAML subset, enough for definition files: block mappings and
+// sequences nested by indentation, flow sequences such as [a, b], plain and
+// quoted scalars and # comments. Scalars are kept as strings; anchors, tags,
+// flow mappings and multi-line scalars are not supported. Mappings decode to
+// map[string]any and sequences to []any.
+
+// One meaningful source line
+type yamlLine struct {
+	num    int // 1-based line number, for errors
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// Parse a YAML document into nested maps, slices and strings
+func parseYAML(src []byte) (any, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(src), "\n") {
+		raw = strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || text == "---" {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("line %d: tabs are not allowed for indentation", i+1)
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	if len(p.lines) == 0 {
+		return nil, nil
+	}
+
+	v, err := p.node(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, fmt.Errorf("line %d: unexpected indentation", p.lines[p.pos].num)
+	}
+	return v, nil
+}
+
+// Parse the mapping or sequence whose lines start at indent
+func (p *yamlParser) node(indent int) (any, error) {
+	if isYAMLItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	m := make(map[string]any)
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent < indent {
+			break
+		}
+		if line.indent > indent {
+			return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+		}
+		if isYAMLItem(line.text) {
+			return nil, fmt.Errorf("line %d: sequence item where a key was expected", line.num)
+		}
+
+		key, rest, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", line.num)
+		}
+		if _, dup := m[key]; dup {
+			return nil, fmt.Errorf("line %d: duplicate key %q", line.num, key)
+		}
+		p.pos++
+
+		if rest != "" {
+			v, err := parseYAMLScalar(rest, line.num)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = v
+			continue
+		}
+
+		// A nested block, or nothing. Sequences may sit at the key's indent.
+		if p.pos < len(p.lines) {
+			next := p.lines[p.pos]
+			if next.indent > indent || (next.indent == indent && isYAMLItem(next.text)) {
+				v, err := p.node(next.indent)
+				if err != nil {
+					return nil, err
+				}
+				m[key] = v
+				continue
+			}
+		}
+		m[key] = nil
+	}
+	return m, nil
+}
+
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	var items []any
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if line.indent != indent || !isYAMLItem(line.text) {
+			if line.indent > indent {
+				return nil, fmt.Errorf("line %d: unexpected indentation", line.num)
+			}
+			break
+		}
+
+		rest := strings.TrimLeft(line.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				v, err := p.node(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				items = append(items, v)
+			} else {
+				items = append(items, nil)
+			}
+			continue
+		}
+
+		// "- key: value" starts a mapping indented to the key
+		if _, _, ok := splitYAMLKey(rest); ok && !strings.HasPrefix(rest, "[") && !strings.HasPrefix(rest, "{") {
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + len(line.text) - len(rest), text: rest}
+			v, err := p.mapping(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+			continue
+		}
+
+		v, err := parseYAMLScalar(rest, line.num)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, v)
+		p.pos++
+	}
+	return items, nil
+}
+
+func isYAMLItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// Split "key: value" outside of quotes. The value may be empty.
+func splitYAMLKey(text string) (string, string, bool) {
+	if text[0] == '"' || text[0] == '\'' {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		key, after := text[1:end+1], text[end+2:]
+		if !strings.HasPrefix(after, ":") {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(after[1:]), true
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i+1 == len(text) || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), i > 0
+		}
+	}
+	return "", "", false
+}
+
+// A scalar or flow sequence
+func parseYAMLScalar(s string, num int) (any, error) {
+	if strings.HasPrefix(s, "[") {
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("line %d: unterminated flow sequence", num)
+		}
+		inner := strings.TrimSpace(s[1 : len(s)-1])
+		items := []any{}
+		if inner == "" {
+			return items, nil
+		}
+		for _, part := range splitYAMLFlow(inner) {
+			v, err := parseYAMLScalar(strings.TrimSpace(part), num)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	}
+	if strings.HasPrefix(s, "{") {
+		return nil, fmt.Errorf("line %d: flow mappings are not supported", num)
+	}
+
+	switch s[0] {
+	case '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, s)
+		}
+		return v, nil
+	case '\'':
+		if len(s) < 2 || s[len(s)-1] != '\'' {
+			return nil, fmt.Errorf("line %d: invalid quoted string %s", num, s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	if s == "~" || s == "null" {
+		return nil, nil
+	}
+	return s, nil
+}
+
+// Split a flow sequence body on commas outside of quotes and brackets
+func splitYAMLFlow(s string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// Drop a # comment that starts a line or follows whitespace, outside quotes
+func stripYAMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && (i == 0 || strings.IndexByte(" \t[,:", line[i-1]) >= 0):
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
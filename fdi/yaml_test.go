@@ -0,0 +1,57 @@
+package fdi
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	src := `# comment
+name: players  # trailing comment
+tags: [a, "b, c", 'd']
+empty:
+nested:
+  key: "quoted # not a comment"
+list:
+- one
+- two
+fields:
+  - name: id
+    offset: 0x10
+  -
+    name: surname
+`
+	got, err := parseYAML([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"name":   "players",
+		"tags":   []any{"a", "b, c", "d"},
+		"empty":  nil,
+		"nested": map[string]any{"key": "quoted # not a comment"},
+		"list":   []any{"one", "two"},
+		"fields": []any{
+			map[string]any{"name": "id", "offset": "0x10"},
+			map[string]any{"name": "surname"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYAML =\n%#v\nwant\n%#v", got, want)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := map[string]string{
+		"bad indentation": "a: 1\n   b: 2\n",
+		"duplicate key":   "a: 1\na: 2\n",
+		"flow mapping":    "a: {b: 1}\n",
+		"no colon":        "just text\n",
+		"tab indentation": "a:\n\tb: 1\n",
+	}
+	for name, src := range tests {
+		if _, err := parseYAML([]byte(src)); err == nil {
+			t.Errorf("%s: parseYAML(%q) succeeded, want an error", name, src)
+		}
+	}
+}
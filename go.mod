@@ -1,3 +1,5 @@
 module fdi-analyzer
 
 go 1.21.3
+
+require golang.org/x/text v0.21.0